@@ -6,6 +6,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
 )
 
 type addNode struct {
@@ -45,16 +47,24 @@ type flagContainer struct {
 	balanceClusters    []string
 	balanceAllClusters bool
 	repairConsistency  string
+	repairDryRun       bool
+	repairStatus       bool
+	repairReport       bool
 	addNode            addNode
 	removeNode         string
 	createSnapshot     string
 	deleteSnapshot     string
 	restoreSnapshot    string
+	snapshotSchedule   string
+	snapshotRetention  string
+	deleteSchedule     string
 	changeState        []string
 	changeStateAll     bool
 	stateOnline        bool
 	stateReadonly      bool
 	stateOffline       bool
+	writeQuorum        string
+	throttle           string
 	syncCluster        string
 	syncClusters       bool
 	clustersReport     bool
@@ -115,6 +125,16 @@ func (f *flagContainer) Define(v string) int {
 		f.active = "repairConsistency"
 	}
 
+	if f.repairStatus {
+		activeCount++
+		f.active = "repairStatus"
+	}
+
+	if f.repairReport {
+		activeCount++
+		f.active = "repairReport"
+	}
+
 	if len(f.addNode.clusterId) > 0 && len(f.addNode.addresses) > 0 {
 		activeCount++
 		f.active = "addNode"
@@ -186,11 +206,115 @@ func (f *flagContainer) Define(v string) int {
 		f.active = "restoreSnapshot"
 	}
 
+	if len(f.snapshotSchedule) != 0 {
+		eqIdx := strings.Index(f.snapshotSchedule, "=")
+		if eqIdx < 1 {
+			fmt.Println("you should define the target cluster id and the cron expression for the schedule")
+			fmt.Println()
+			return 1
+		}
+
+		if _, err := common.ParseCronExpression(f.snapshotSchedule[eqIdx+1:]); err != nil {
+			fmt.Println(err.Error())
+			fmt.Println()
+			return 1
+		}
+
+		if len(f.snapshotRetention) != 0 {
+			retentionValues := strings.Split(f.snapshotRetention, ",")
+			if len(retentionValues) != 3 {
+				fmt.Println("you should define the retention as last,daily,weekly")
+				fmt.Println()
+				return 1
+			}
+			for _, retentionValue := range retentionValues {
+				if _, err := strconv.ParseUint(retentionValue, 10, 16); err != nil {
+					fmt.Println("retention values should be 0 or positive numeric value")
+					fmt.Println()
+					return 1
+				}
+			}
+		}
+
+		activeCount++
+		f.active = "snapshotSchedule"
+	}
+
+	if len(f.deleteSchedule) != 0 {
+		activeCount++
+		f.active = "deleteSchedule"
+	}
+
 	if len(f.changeState) != 0 || f.changeStateAll {
 		activeCount++
 		f.active = "changeState"
 	}
 
+	if len(f.writeQuorum) != 0 {
+		paramTest := f.writeQuorum
+
+		eqIdx := strings.Index(paramTest, "=")
+		if eqIdx == -1 {
+			fmt.Println("you should define the write quorum for the cluster")
+			fmt.Println()
+			return 1
+		}
+
+		clusterId := paramTest[:eqIdx]
+		if len(clusterId) == 0 {
+			fmt.Println("you should define the target cluster id")
+			fmt.Println()
+			return 1
+		}
+
+		_, err := strconv.ParseUint(paramTest[eqIdx+1:], 10, 8)
+		if err != nil {
+			fmt.Println("write quorum should be 0 or positive numeric value")
+			fmt.Println()
+			return 1
+		}
+
+		activeCount++
+		f.active = "writeQuorum"
+	}
+
+	if len(f.throttle) != 0 {
+		paramTest := f.throttle
+
+		eqIdx := strings.Index(paramTest, "=")
+		if eqIdx > -1 {
+			paramTest = paramTest[eqIdx+1:]
+		}
+
+		throttleOptions := strings.Split(paramTest, ",")
+		if len(throttleOptions) != 3 {
+			fmt.Println("you should define the operation, rate and concurrency for the throttle")
+			fmt.Println()
+			return 1
+		}
+
+		if _, err := common.ParseThrottleOperation(throttleOptions[0]); err != nil {
+			fmt.Println(err.Error())
+			fmt.Println()
+			return 1
+		}
+
+		if _, err := strconv.ParseUint(throttleOptions[1], 10, 64); err != nil {
+			fmt.Println("throttle rate should be 0 or positive numeric value")
+			fmt.Println()
+			return 1
+		}
+
+		if _, err := strconv.ParseUint(throttleOptions[2], 10, 16); err != nil {
+			fmt.Println("throttle concurrency should be 0 or positive numeric value")
+			fmt.Println()
+			return 1
+		}
+
+		activeCount++
+		f.active = "throttle"
+	}
+
 	if len(f.syncCluster) > 0 {
 		activeCount++
 		f.active = "syncClusters"
@@ -274,6 +398,10 @@ Ex: clusterId=192.168.0.1:9430,192.168.0.2:9430`)
 	var repairConsistency string
 	set.StringVar(&repairConsistency, `repair-consistency`, "", `Repair file chunk node distribution consistency in metadata and data nodes and mark as zombie for the broken ones. Provide repair model for consistency repairing operation or leave empty to run full repair. Possible repair models (full, structure, structure+integrity, integrity, integrity+checksum, checksum, checksum+rebuild)`)
 
+	set.Bool(`dry-run`, false, `Run the consistency repair without applying the changes and create a report of the intended changes. (Can only be used with -repair-consistency argument)`)
+	set.Bool(`repair-status`, false, `Gets the progress of the running or the last consistency repair.`)
+	set.Bool(`repair-report`, false, `Prints the report of the last dry-run consistency repair.`)
+
 	var createSnapshot string
 	set.StringVar(&createSnapshot, `create-snapshot`, "", `Creates snapshot on a cluster. Provide cluster id to create snapshot.`)
 
@@ -285,14 +413,33 @@ Ex: clusterId=snapshotIndex`)
 	set.StringVar(&restoreSnapshot, `restore-snapshot`, "", `Restores a snapshot in the cluster. Provide cluster id with snapshot index to be restored.
 Ex: clusterId=snapshotIndex`)
 
+	var snapshotSchedule string
+	set.StringVar(&snapshotSchedule, `snapshot-schedule`, "", `Schedules the automatic snapshot creation of the cluster. Provide cluster id with the cron expression (minute hour day-of-month month day-of-week) in UTC.
+Ex: "clusterId=0 3 * * *"`)
+
+	var snapshotRetention string
+	set.StringVar(&snapshotRetention, `retention`, "", `Retention of the scheduled snapshots. Provide the count of the last snapshots, the days and the weeks that the newest snapshot of each is kept. Snapshots out of the retention are deleted automatically. Leave empty to keep all. (Can only be used with -snapshot-schedule argument)
+Ex: 7,14,8`)
+
+	var deleteSchedule string
+	set.StringVar(&deleteSchedule, `delete-snapshot-schedule`, "", `Deletes the snapshot schedule of the cluster. Provide cluster id. Existing snapshots are kept.`)
+
 	var changeState string
 	set.StringVar(&changeState, `change-state`, "", `Change the state of the cluster. Provide at least one cluster id to change the state or leave empty to apply all clusters in the setup.
 Ex: clusterId,clusterId`)
 
-	set.Bool(`online`, false, `Change the state of the cluster to ONLINE. (Can only be used with -change-state argument)`)
+	set.Bool(`online`, false, `Change the state of the cluster to ONLINE when it is used with -change-state argument. Run the consistency repair without taking the clusters into readonly mode when it is used with -repair-consistency argument.`)
 	set.Bool(`readonly`, false, `Change the state of the cluster to READONLY. (Can only be used with -change-state argument)`)
 	set.Bool(`offline`, false, `Change the state of the cluster to OFFLINE. (Can only be used with -change-state argument)`)
 
+	var writeQuorum string
+	set.StringVar(&writeQuorum, `write-quorum`, "", `Change the write quorum of the cluster. Provide cluster id with the count of the nodes that should have the chunk before the write is acknowledged. 0 or 1 keeps the asynchronous replication.
+Ex: clusterId=2`)
+
+	var throttle string
+	set.StringVar(&throttle, `throttle`, "", `Change the background transfer limits of the data nodes. Provide cluster id or node id with the operation (sync or move), the rate in bytes per second and the concurrent transfer count. Leave the id empty to apply all nodes in the setup. 0 is unlimited.
+Ex: clusterId=sync,10485760,4 or =move,0,2`)
+
 	var syncCluster string
 	set.StringVar(&syncCluster, `sync-cluster`, "", `Synchronise selected cluster and their nodes for data consistency.`)
 
@@ -384,16 +531,24 @@ Ex: clusterId,clusterId`)
 		balanceClusters:    bc,
 		balanceAllClusters: bac,
 		repairConsistency:  repairConsistency,
+		repairDryRun:       strings.Contains(joinedArgs, "dry-run"),
+		repairStatus:       strings.Contains(joinedArgs, "repair-status"),
+		repairReport:       strings.Contains(joinedArgs, "repair-report"),
 		addNode:            addNode,
 		removeNode:         removeNode,
 		createSnapshot:     createSnapshot,
 		deleteSnapshot:     deleteSnapshot,
 		restoreSnapshot:    restoreSnapshot,
+		snapshotSchedule:   snapshotSchedule,
+		snapshotRetention:  snapshotRetention,
+		deleteSchedule:     deleteSchedule,
 		changeState:        cs,
 		changeStateAll:     csa,
 		stateOnline:        strings.Contains(joinedArgs, "online"),
 		stateReadonly:      strings.Contains(joinedArgs, "readonly"),
 		stateOffline:       strings.Contains(joinedArgs, "offline"),
+		writeQuorum:        writeQuorum,
+		throttle:           throttle,
 		syncCluster:        syncCluster,
 		syncClusters:       strings.Contains(joinedArgs, "sync-clusters"),
 		clustersReport:     strings.Contains(joinedArgs, "clusters-report"),
@@ -174,6 +174,20 @@ func main() {
 		default:
 			fmt.Println("snapshot restoration is canceled")
 		}
+	case "snapshotSchedule":
+		eqIdx := strings.Index(fc.snapshotSchedule, "=")
+		clusterId := fc.snapshotSchedule[:eqIdx]
+		expression := fc.snapshotSchedule[eqIdx+1:]
+
+		if err := manager.ScheduleSnapshots([]string{fc.managerAddress}, clusterId, expression, fc.snapshotRetention); err != nil {
+			fmt.Printf("%s\n", err.Error())
+			os.Exit(50)
+		}
+	case "deleteSchedule":
+		if err := manager.DeleteSnapshotSchedule([]string{fc.managerAddress}, fc.deleteSchedule); err != nil {
+			fmt.Printf("%s\n", err.Error())
+			os.Exit(50)
+		}
 	case "changeState":
 		if !fc.stateOnline && !fc.stateReadonly && !fc.stateOffline {
 			fmt.Println("You have to define the target state of the cluster(s)")
@@ -218,6 +232,20 @@ func main() {
 		default:
 			fmt.Println("cluster state change is canceled")
 		}
+	case "writeQuorum":
+		eqIdx := strings.Index(fc.writeQuorum, "=")
+		clusterId := fc.writeQuorum[:eqIdx]
+		writeQuorum, _ := strconv.ParseUint(fc.writeQuorum[eqIdx+1:], 10, 8)
+
+		if err := manager.ChangeWriteQuorum([]string{fc.managerAddress}, clusterId, int(writeQuorum)); err != nil {
+			fmt.Printf("%s\n", err.Error())
+			os.Exit(50)
+		}
+	case "throttle":
+		if err := manager.ChangeThrottle([]string{fc.managerAddress}, fc.throttle); err != nil {
+			fmt.Printf("%s\n", err.Error())
+			os.Exit(50)
+		}
 	case "syncClusters":
 		if fc.syncClusters {
 			fmt.Println("CAUTION: The sync of clusters will be started simultaneously on each cluster and it will " +
@@ -249,8 +277,16 @@ func main() {
 		}
 		fmt.Println("ok.")
 	case "repairConsistency":
-		fmt.Println("CAUTION: Repair consistency is a long running process that may take hours/days to complete " +
-			"depending on your DOS setup and will create partial action prevention on cluster data nodes.")
+		if fc.repairDryRun {
+			fmt.Println("Dry-run repair will not apply any change, the intended changes can be printed with --repair-report option.")
+		}
+		if fc.stateOnline {
+			fmt.Println("CAUTION: Online repair consistency is a long running process that may take hours/days to complete " +
+				"depending on your DOS setup. Clusters will stay writable and will be processed one by one.")
+		} else {
+			fmt.Println("CAUTION: Repair consistency is a long running process that may take hours/days to complete " +
+				"depending on your DOS setup and will create partial action prevention on cluster data nodes.")
+		}
 		fmt.Print("Do you want to continue? (y/N) ")
 		reader := bufio.NewReader(os.Stdin)
 		char, _, err := reader.ReadRune()
@@ -260,14 +296,24 @@ func main() {
 
 		switch char {
 		case 'Y', 'y':
-			if err := manager.RepairConsistency([]string{fc.managerAddress}, fc.repairConsistency); err != nil {
+			if err := manager.RepairConsistency([]string{fc.managerAddress}, fc.repairConsistency, fc.stateOnline, fc.repairDryRun); err != nil {
 				fmt.Printf("%s\n", err.Error())
 				os.Exit(55)
 			}
-			fmt.Println("consistency repair is started, you can check the state with --get-clusters option")
+			fmt.Println("consistency repair is started, you can check the progress with --repair-status option")
 		default:
 			fmt.Println("cluster chunk consistency repair is canceled")
 		}
+	case "repairStatus":
+		if err := manager.RepairStatus([]string{fc.managerAddress}); err != nil {
+			fmt.Printf("%s\n", err.Error())
+			os.Exit(56)
+		}
+	case "repairReport":
+		if err := manager.RepairReport([]string{fc.managerAddress}, os.Stdout); err != nil {
+			fmt.Printf("%s\n", err.Error())
+			os.Exit(57)
+		}
 	case "balanceClusters":
 		fmt.Println("CAUTION: Balancing is a long running process and it may take hours/days to complete depending " +
 			"on the internet speed between balancing clusters and the size of them.")
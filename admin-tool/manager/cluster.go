@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -272,6 +273,145 @@ func ChangeState(managerAddr []string, clusterIds []string, state common.States)
 	return nil
 }
 
+func ChangeWriteQuorum(managerAddr []string, clusterId string, writeQuorum int) error {
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://%s%s", managerAddr[0], managerEndPoint), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Action", "quorum")
+	req.Header.Set("X-Options", fmt.Sprintf("%s=%d", clusterId, writeQuorum))
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: manager node is not reachable", managerAddr[0])
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != 200 {
+		var e common.Error
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				return fmt.Errorf("dos manager returned with an unrecognisable status code: %d", res.StatusCode)
+			}
+			return err
+		}
+		return fmt.Errorf(e.Message)
+	}
+
+	fmt.Printf("Write quorum of cluster %s has been changed to %d\n", clusterId, writeQuorum)
+
+	return nil
+}
+
+func ChangeThrottle(managerAddr []string, throttleOptions string) error {
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://%s%s", managerAddr[0], managerEndPoint), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Action", "throttle")
+	req.Header.Set("X-Options", throttleOptions)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: manager node is not reachable", managerAddr[0])
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != 200 {
+		if res.StatusCode == 404 {
+			return fmt.Errorf("cluster or node is not found")
+		}
+
+		var e common.Error
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				return fmt.Errorf("dos manager returned with an unrecognisable status code: %d", res.StatusCode)
+			}
+			return err
+		}
+		return fmt.Errorf(e.Message)
+	}
+
+	fmt.Println("Throttle limits have been changed")
+
+	return nil
+}
+
+func ScheduleSnapshots(managerAddr []string, clusterId string, expression string, retention string) error {
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("http://%s%s", managerAddr[0], managerEndPoint), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Action", "schedule")
+	req.Header.Set("X-Options", fmt.Sprintf("%s=%s", clusterId, expression))
+	if len(retention) > 0 {
+		req.Header.Set("X-Retention", retention)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: manager node is not reachable", managerAddr[0])
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != 200 {
+		if res.StatusCode == 404 {
+			return fmt.Errorf("cluster is not found")
+		}
+
+		var e common.Error
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				return fmt.Errorf("dos manager returned with an unrecognisable status code: %d", res.StatusCode)
+			}
+			return err
+		}
+		return fmt.Errorf(e.Message)
+	}
+
+	var schedule common.SnapshotSchedule
+	if err := json.NewDecoder(res.Body).Decode(&schedule); err != nil {
+		return err
+	}
+	fmt.Printf("Snapshots are scheduled for cluster %s, next run: %s\n", clusterId, schedule.NextRun.Format(common.FriendlyTimeFormatWithSeconds))
+
+	return nil
+}
+
+func DeleteSnapshotSchedule(managerAddr []string, clusterId string) error {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://%s%s", managerAddr[0], managerEndPoint), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Action", "schedule")
+	req.Header.Set("X-Options", clusterId)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: manager node is not reachable", managerAddr[0])
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != 200 {
+		if res.StatusCode == 404 {
+			return fmt.Errorf("cluster is not found")
+		}
+
+		var e common.Error
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				return fmt.Errorf("dos manager returned with an unrecognisable status code: %d", res.StatusCode)
+			}
+			return err
+		}
+		return fmt.Errorf(e.Message)
+	}
+
+	fmt.Printf("Snapshot schedule of cluster %s is deleted\n", clusterId)
+
+	return nil
+}
+
 func CreateSnapshot(managerAddr []string, clusterId string) error {
 	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s%s", managerAddr[0], managerEndPoint), nil)
 	if err != nil {
@@ -396,13 +536,15 @@ func SyncClusters(managerAddr []string, clusterId string) error {
 	return nil
 }
 
-func RepairConsistency(managerAddr []string, repairModel string) error {
+func RepairConsistency(managerAddr []string, repairModel string, online bool, dryRun bool) error {
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", managerAddr[0], managerEndPoint), nil)
 	if err != nil {
 		return err
 	}
 	req.Header.Set("X-Action", "repair")
 	req.Header.Set("X-Options", repairModel)
+	req.Header.Set("X-Online", strconv.FormatBool(online))
+	req.Header.Set("X-Dry-Run", strconv.FormatBool(dryRun))
 
 	res, err := client.Do(req)
 	if err != nil {
@@ -467,11 +609,16 @@ func GetClusters(managerAddr []string, clusterId string) error {
 				mode = "(MASTER)"
 			}
 			fmt.Printf("      Data Node: %s %s -> %s\n", n.Address, mode, n.Id)
+			printThrottleLimit("sync", n.Throttle.Sync)
+			printThrottleLimit("move", n.Throttle.Move)
 		}
 		fmt.Printf("      Size:      %d (%d Gb)\n", cluster.Size, cluster.Size/(1024*1024*1024))
 		fmt.Printf("      Available: %d (%d Gb)\n", cluster.Available(), cluster.Available()/(1024*1024*1024))
 		fmt.Printf("      Weight:    %.2f\n", cluster.Weight())
 		fmt.Printf("      Status:    %s\n", cluster.StateString())
+		if cluster.WriteQuorum > 1 {
+			fmt.Printf("      Quorum:    %d\n", cluster.WriteQuorum)
+		}
 		if cluster.Maintain {
 			fmt.Printf("      Maintain:  InProgress (%s)\n", cluster.MaintainTopic)
 		}
@@ -484,6 +631,9 @@ func GetClusters(managerAddr []string, clusterId string) error {
 				fmt.Printf("                 %-4d %s\n", i, snapshot.Format(common.FriendlyTimeFormatWithSeconds))
 			}
 		}
+		if cluster.SnapshotSchedule != nil {
+			printSnapshotSchedule(cluster.SnapshotSchedule)
+		}
 		fmt.Println()
 	}
 
@@ -493,7 +643,11 @@ func GetClusters(managerAddr []string, clusterId string) error {
 		available := total - used
 		fmt.Printf("      Total Available: %d (%d Gb)\n", available, available/(1024*1024*1024))
 		if strings.Compare(res.Header.Get("X-Repairing"), "true") == 0 {
-			fmt.Printf("      Repairing:       In progress\n")
+			if strings.Compare(res.Header.Get("X-Repairing-Online"), "true") == 0 {
+				fmt.Printf("      Repairing:       In progress (online)\n")
+			} else {
+				fmt.Printf("      Repairing:       In progress\n")
+			}
 		} else {
 			repairCompletedTimestamp := res.Header.Get("X-Repairing-Timestamp")
 			if len(repairCompletedTimestamp) > 0 {
@@ -509,6 +663,120 @@ func GetClusters(managerAddr []string, clusterId string) error {
 	return nil
 }
 
+type repairCheckpoint struct {
+	Phases            []string `json:"phases"`
+	Phase             int      `json:"phase"`
+	Total             uint64   `json:"total"`
+	Processed         uint64   `json:"processed"`
+	CompletedClusters []string `json:"completedClusters"`
+	OrphansRemoved    uint64   `json:"orphansRemoved"`
+	UsagesFixed       uint64   `json:"usagesFixed"`
+	ChecksumsRebuilt  uint64   `json:"checksumsRebuilt"`
+	ZombiesFound      uint64   `json:"zombiesFound"`
+}
+
+type repairStatus struct {
+	Processing bool              `json:"processing"`
+	Online     bool              `json:"online"`
+	DryRun     bool              `json:"dryRun"`
+	Timestamp  *time.Time        `json:"timestamp"`
+	Checkpoint *repairCheckpoint `json:"checkpoint"`
+}
+
+func RepairStatus(managerAddr []string) error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", managerAddr[0], managerEndPoint), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Action", "repair-status")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: manager node is not reachable", managerAddr[0])
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != 200 {
+		return fmt.Errorf("dos manager returned with an unrecognisable status code: %d", res.StatusCode)
+	}
+
+	var r repairStatus
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return err
+	}
+
+	mode := "offline"
+	if r.Online {
+		mode = "online"
+	}
+	if r.DryRun {
+		mode = fmt.Sprintf("%s, dry-run", mode)
+	}
+
+	switch {
+	case r.Processing:
+		fmt.Printf("Repairing:         In progress (%s)\n", mode)
+	case r.Timestamp != nil:
+		fmt.Printf("Repairing:         Completed at %s (%s)\n", r.Timestamp.Local().Format(common.FriendlyTimeFormatWithSeconds), mode)
+	default:
+		fmt.Println("Repairing:         Not running")
+	}
+
+	if r.Checkpoint == nil {
+		return nil
+	}
+
+	if percentage := res.Header.Get("X-Repairing-Percentage"); len(percentage) > 0 {
+		fmt.Printf("Progress:          %s%%\n", percentage)
+	}
+	if eta := res.Header.Get("X-Repairing-ETA"); len(eta) > 0 {
+		seconds, err := strconv.ParseInt(eta, 10, 64)
+		if err == nil {
+			fmt.Printf("ETA:               %s\n", (time.Duration(seconds) * time.Second).String())
+		}
+	}
+	if r.Processing && r.Checkpoint.Phase < len(r.Checkpoint.Phases) {
+		fmt.Printf("Phase:             %s (%d/%d), %d of %d processed\n",
+			r.Checkpoint.Phases[r.Checkpoint.Phase], r.Checkpoint.Phase+1, len(r.Checkpoint.Phases),
+			r.Checkpoint.Processed, r.Checkpoint.Total)
+	}
+	fmt.Printf("Cleaned Clusters:  %d\n", len(r.Checkpoint.CompletedClusters))
+	fmt.Printf("Orphans Removed:   %d\n", r.Checkpoint.OrphansRemoved)
+	fmt.Printf("Usages Fixed:      %d\n", r.Checkpoint.UsagesFixed)
+	fmt.Printf("Checksums Rebuilt: %d\n", r.Checkpoint.ChecksumsRebuilt)
+	fmt.Printf("Zombies Found:     %d\n", r.Checkpoint.ZombiesFound)
+
+	return nil
+}
+
+func RepairReport(managerAddr []string, w io.Writer) error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", managerAddr[0], managerEndPoint), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Action", "repair-report")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: manager node is not reachable", managerAddr[0])
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != 200 {
+		var e common.Error
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				return fmt.Errorf("dos manager returned with an unrecognisable status code: %d", res.StatusCode)
+			}
+			return err
+		}
+		return fmt.Errorf(e.Message)
+	}
+
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
 func GetReport(managerAddr []string) error {
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", managerAddr[0], managerEndPoint), nil)
 	if err != nil {
@@ -567,3 +835,35 @@ func GetReport(managerAddr []string) error {
 
 	return nil
 }
+
+func printThrottleLimit(operation string, limit common.ThrottleLimit) {
+	if limit.Unlimited() {
+		return
+	}
+
+	rate := "unlimited"
+	if limit.Rate > 0 {
+		rate = fmt.Sprintf("%d Kb/s", limit.Rate/1024)
+	}
+	concurrency := "unlimited"
+	if limit.Concurrency > 0 {
+		concurrency = strconv.Itoa(limit.Concurrency)
+	}
+	fmt.Printf("                 Throttle (%s): rate %s, concurrency %s\n", operation, rate, concurrency)
+}
+
+func printSnapshotSchedule(schedule *common.SnapshotSchedule) {
+	fmt.Printf("      Schedule:  %s (keep last %d, daily %d, weekly %d)\n", schedule.Expression, schedule.KeepLast, schedule.KeepDaily, schedule.KeepWeekly)
+	if !schedule.NextRun.IsZero() {
+		fmt.Printf("                 Next Run:   %s\n", schedule.NextRun.Format(common.FriendlyTimeFormatWithSeconds))
+	}
+	if !schedule.LastRun.IsZero() {
+		fmt.Printf("                 Last Run:   %s (%s)\n", schedule.LastRun.Format(common.FriendlyTimeFormatWithSeconds), schedule.LastStatus)
+	}
+	if !schedule.LastPrune.IsZero() {
+		fmt.Printf("                 Last Prune: %s (%s)\n", schedule.LastPrune.Format(common.FriendlyTimeFormatWithSeconds), schedule.LastPruneStatus)
+	}
+	if len(schedule.Created) > 0 {
+		fmt.Printf("                 Created:    %d snapshots under retention\n", len(schedule.Created))
+	}
+}
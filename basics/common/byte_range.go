@@ -0,0 +1,89 @@
+package common
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+)
+
+const maxByteRanges = 64
+
+// ParseByteRanges parses the Http Range header value base on RFC 7233 for the content size.
+// Supported forms are `a-b`, open-ended `a-` and suffix `-n` with multiple ranges separated by comma.
+// It returns nil when the header should be ignored (absent, unsupported unit, invalid syntax or too many ranges)
+// and ErrRangeNotSatisfiable when none of the ranges overlaps the content
+func ParseByteRanges(value string, size uint64) ([]ReadRange, error) {
+	value = strings.TrimSpace(value)
+
+	unitIdx := strings.Index(value, "=")
+	if unitIdx == -1 || !strings.EqualFold(strings.TrimSpace(value[:unitIdx]), "bytes") {
+		return nil, nil
+	}
+
+	specs := strings.Split(value[unitIdx+1:], ",")
+	if len(specs) > maxByteRanges {
+		return nil, nil
+	}
+
+	contentSize := int64(size)
+	ranges := make([]ReadRange, 0)
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if len(spec) == 0 {
+			continue
+		}
+
+		dashIdx := strings.Index(spec, "-")
+		if dashIdx == -1 {
+			return nil, nil
+		}
+		first, last := strings.TrimSpace(spec[:dashIdx]), strings.TrimSpace(spec[dashIdx+1:])
+
+		if len(first) == 0 {
+			suffix, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || suffix < 0 {
+				return nil, nil
+			}
+			if suffix == 0 || contentSize == 0 {
+				continue
+			}
+			if suffix > contentSize {
+				suffix = contentSize
+			}
+			ranges = append(ranges, ReadRange{Begins: contentSize - suffix, Ends: contentSize - 1})
+			continue
+		}
+
+		begins, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || begins < 0 {
+			return nil, nil
+		}
+
+		ends := contentSize - 1
+		if len(last) > 0 {
+			ends, err = strconv.ParseInt(last, 10, 64)
+			if err != nil || ends < begins {
+				return nil, nil
+			}
+			if ends > contentSize-1 {
+				ends = contentSize - 1
+			}
+		}
+
+		if begins >= contentSize {
+			continue
+		}
+		ranges = append(ranges, ReadRange{Begins: begins, Ends: ends})
+	}
+
+	if len(ranges) == 0 {
+		return nil, errors.ErrRangeNotSatisfiable
+	}
+	return ranges, nil
+}
+
+// Length returns the byte count that the range covers
+func (r ReadRange) Length() int64 {
+	return r.Ends - r.Begins + 1
+}
@@ -0,0 +1,50 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseByteRanges(t *testing.T) {
+	ranges, err := ParseByteRanges("bytes=0-499", 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, []ReadRange{{Begins: 0, Ends: 499}}, ranges)
+
+	ranges, err = ParseByteRanges("bytes=500-", 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, []ReadRange{{Begins: 500, Ends: 999}}, ranges)
+
+	ranges, err = ParseByteRanges("bytes=-200", 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, []ReadRange{{Begins: 800, Ends: 999}}, ranges)
+
+	ranges, err = ParseByteRanges("bytes=-2000", 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, []ReadRange{{Begins: 0, Ends: 999}}, ranges)
+
+	ranges, err = ParseByteRanges("bytes=0-9, 900-1999, 2000-", 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, []ReadRange{{Begins: 0, Ends: 9}, {Begins: 900, Ends: 999}}, ranges)
+	assert.Equal(t, int64(100), ranges[1].Length())
+}
+
+func TestParseByteRanges_Ignored(t *testing.T) {
+	for _, value := range []string{"", "items=0-5", "bytes=5", "bytes=9-5", "bytes=a-b", "bytes=5--1"} {
+		ranges, err := ParseByteRanges(value, 1000)
+		assert.Nil(t, err, value)
+		assert.Nil(t, ranges, value)
+	}
+}
+
+func TestParseByteRanges_NotSatisfiable(t *testing.T) {
+	_, err := ParseByteRanges("bytes=1000-", 1000)
+	assert.Equal(t, errors.ErrRangeNotSatisfiable, err)
+
+	_, err = ParseByteRanges("bytes=-0", 1000)
+	assert.Equal(t, errors.ErrRangeNotSatisfiable, err)
+
+	_, err = ParseByteRanges("bytes=0-", 0)
+	assert.Equal(t, errors.ErrRangeNotSatisfiable, err)
+}
@@ -42,7 +42,7 @@ func NewCacheFileItemFromMap(cache map[string]string) *CacheFileItem {
 	return &CacheFileItem{
 		FileItem: SyncFileItem{
 			Sha512Hex: cache["sha512Hex"],
-			Usage:     uint16(usage),
+			Usage:     usage,
 			Size:      uint32(size),
 		},
 		ClusterId: cache["clusterId"],
@@ -55,7 +55,7 @@ func (c *CacheFileItem) Export() map[string]string {
 	export := make(map[string]string)
 
 	export["sha512Hex"] = c.FileItem.Sha512Hex
-	export["usage"] = strconv.FormatUint(uint64(c.FileItem.Usage), 10)
+	export["usage"] = strconv.FormatUint(c.FileItem.Usage, 10)
 	export["size"] = strconv.FormatUint(uint64(c.FileItem.Size), 10)
 
 	export["clusterId"] = c.ClusterId
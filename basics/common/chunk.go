@@ -1,11 +1,16 @@
 package common
 
+import "math"
+
+// MaxChunkSequence is the highest sequence number that a file particle can have
+const MaxChunkSequence = math.MaxUint32
+
 // Chunk struct is to hold the File particle information for the reservation operation
 // Sequence is the order number of the particle
 // Index is the particle starting point in the whole file
 // Size is the length of the particle
 type Chunk struct {
-	Sequence uint16 `json:"sequence"`
+	Sequence uint32 `json:"sequence"`
 	Index    uint64 `json:"index"`
 	Size     uint32 `json:"size"`
 }
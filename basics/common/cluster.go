@@ -56,6 +56,12 @@ type Cluster struct {
 	MaintainTopic Topics `json:"maintainTopic"`
 
 	Snapshots Snapshots `json:"snapshots"`
+	// SnapshotSchedule creates the snapshots automatically and deletes the expired ones. nil if it is not scheduled
+	SnapshotSchedule *SnapshotSchedule `json:"snapshotSchedule"`
+
+	// WriteQuorum is the count of the nodes including the master that should have the chunk before the creation
+	// is acknowledged. 0 or 1 acknowledges the creation when the master stores the chunk
+	WriteQuorum int `json:"writeQuorum"`
 }
 
 // Reservations point the type declaration
@@ -165,13 +171,16 @@ func (c *Cluster) Node(nodeId string) *Node {
 	return nil
 }
 
-// Delete deletes the node from the cluster.
+// Delete deletes the node from the cluster. Write quorum is lowered to the remaining node count.
 // masterChangedHandler executed when the deleted node is the
 // current master node and there are other nodes in the cluster
 func (c *Cluster) Delete(nodeId string, masterChangedHandler func(*Node) error) error {
 	for i, n := range c.Nodes {
 		if strings.Compare(n.Id, nodeId) == 0 {
 			c.Nodes = append(c.Nodes[:i], c.Nodes[i+1:]...)
+			if c.WriteQuorum > len(c.Nodes) {
+				c.WriteQuorum = len(c.Nodes)
+			}
 			if n.Master && len(c.Nodes) > 0 {
 				c.Nodes[0].Master = true
 				return masterChangedHandler(c.Nodes[0])
@@ -291,6 +300,14 @@ func (c *Cluster) Others(nodeId string) NodeList {
 	return others
 }
 
+// SyncReplicas returns the count of the nodes that should receive the chunk before the creation is acknowledged
+func (c *Cluster) SyncReplicas() int {
+	if c.WriteQuorum <= 1 {
+		return 0
+	}
+	return c.WriteQuorum - 1
+}
+
 func (c *Cluster) CanSchedule() bool {
 	return c.State == StateOnline && !c.Paralyzed && !c.Maintain
 }
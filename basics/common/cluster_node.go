@@ -11,6 +11,7 @@ type Node struct {
 	Master   bool      `json:"master"`
 	LeadTill time.Time `json:"leadTill"`
 	Quality  int64     `json:"quality"`
+	Throttle Throttle  `json:"throttle"`
 }
 
 func (n *Node) LeadershipExpired() bool {
@@ -43,3 +43,29 @@ func TestCluster_StateString(t *testing.T) {
 	cluster.Paralyzed = true
 	assert.Equal(t, "Offline", cluster.StateString())
 }
+
+func TestCluster_DeleteLowersWriteQuorum(t *testing.T) {
+	cluster := NewCluster("test")
+	cluster.Nodes = NodeList{
+		{Id: "master", Master: true},
+		{Id: "slave-1"},
+		{Id: "slave-2"},
+	}
+	cluster.WriteQuorum = 3
+	assert.Equal(t, 2, cluster.SyncReplicas())
+
+	promoted := ""
+	assert.Nil(t, cluster.Delete("master", func(node *Node) error {
+		promoted = node.Id
+		return nil
+	}))
+	assert.Equal(t, "slave-1", promoted)
+	assert.Equal(t, 2, cluster.WriteQuorum)
+	assert.Equal(t, 1, cluster.SyncReplicas())
+
+	// lower quorum is kept
+	cluster.WriteQuorum = 1
+	assert.Nil(t, cluster.Delete("slave-2", nil))
+	assert.Equal(t, 1, cluster.WriteQuorum)
+	assert.Equal(t, 0, cluster.SyncReplicas())
+}
@@ -0,0 +1,172 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit is the range that the next matching time is searched in
+const cronSearchLimit = time.Hour * 24 * 366 * 5
+
+var cronMacros = map[string]string{
+	"@yearly":  "0 0 1 1 *",
+	"@monthly": "0 0 1 * *",
+	"@weekly":  "0 0 * * 0",
+	"@daily":   "0 0 * * *",
+	"@hourly":  "0 * * * *",
+}
+
+type cronField struct {
+	min    int
+	max    int
+	values map[int]bool
+	any    bool
+}
+
+// CronExpression struct is to hold the parsed five field cron expression.
+// Fields are minute, hour, day of month, month and day of week. Times are evaluated in UTC
+type CronExpression struct {
+	minute     cronField
+	hour       cronField
+	dayOfMonth cronField
+	month      cronField
+	dayOfWeek  cronField
+}
+
+// ParseCronExpression parses the cron expression. Fields support `*`, values, ranges (`1-5`), lists (`1,3`) and
+// steps (`*/15`, `0-30/10`). `@yearly`, `@monthly`, `@weekly`, `@daily` and `@hourly` macros are also accepted
+func ParseCronExpression(expression string) (*CronExpression, error) {
+	expression = strings.TrimSpace(expression)
+	if macro, has := cronMacros[strings.ToLower(expression)]; has {
+		expression = macro
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression should have 5 fields (minute hour day-of-month month day-of-week)")
+	}
+
+	c := &CronExpression{}
+
+	definitions := []struct {
+		field *cronField
+		name  string
+		min   int
+		max   int
+	}{
+		{&c.minute, "minute", 0, 59},
+		{&c.hour, "hour", 0, 23},
+		{&c.dayOfMonth, "day of month", 1, 31},
+		{&c.month, "month", 1, 12},
+		{&c.dayOfWeek, "day of week", 0, 7},
+	}
+
+	for i, d := range definitions {
+		field, err := parseCronField(fields[i], d.min, d.max)
+		if err != nil {
+			return nil, fmt.Errorf("cron %s field is not valid: %s", d.name, err)
+		}
+		*d.field = *field
+	}
+
+	// 7 is also sunday
+	if c.dayOfWeek.values[7] {
+		c.dayOfWeek.values[0] = true
+	}
+
+	return c, nil
+}
+
+func parseCronField(value string, min int, max int) (*cronField, error) {
+	field := &cronField{
+		min:    min,
+		max:    max,
+		values: make(map[int]bool),
+		any:    strings.Compare(value, "*") == 0,
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		step := 1
+		if slashIdx := strings.Index(part, "/"); slashIdx > -1 {
+			var err error
+			step, err = strconv.Atoi(part[slashIdx+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("step of %s is wrong", part)
+			}
+			part = part[:slashIdx]
+		}
+
+		begins, ends := min, max
+		switch {
+		case strings.Compare(part, "*") == 0:
+		case strings.Contains(part, "-"):
+			dashIdx := strings.Index(part, "-")
+
+			var err error
+			if begins, err = strconv.Atoi(part[:dashIdx]); err != nil {
+				return nil, fmt.Errorf("range of %s is wrong", part)
+			}
+			if ends, err = strconv.Atoi(part[dashIdx+1:]); err != nil {
+				return nil, fmt.Errorf("range of %s is wrong", part)
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("%s is not numeric", part)
+			}
+			begins, ends = v, v
+		}
+
+		if begins < min || ends > max || begins > ends {
+			return nil, fmt.Errorf("%s is out of range %d-%d", part, min, max)
+		}
+
+		for v := begins; v <= ends; v += step {
+			field.values[v] = true
+		}
+	}
+
+	return field, nil
+}
+
+func (c *CronExpression) matchDay(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth.values[t.Day()]
+	dayOfWeek := c.dayOfWeek.values[int(t.Weekday())]
+
+	// When both of the day fields are restricted, matching one of them is enough
+	if !c.dayOfMonth.any && !c.dayOfWeek.any {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
+
+// Next returns the first matching time after the given time. It returns zero time if there is no match in the
+// next 5 years
+func (c *CronExpression) Next(after time.Time) time.Time {
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if !c.month.values[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.hour.values[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.minute.values[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
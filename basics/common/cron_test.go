@@ -0,0 +1,57 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCronExpression(t *testing.T) {
+	_, err := ParseCronExpression("0 3 * * *")
+	assert.Nil(t, err)
+
+	_, err = ParseCronExpression("*/15 1-5,22 1 */2 1-5")
+	assert.Nil(t, err)
+
+	_, err = ParseCronExpression("@daily")
+	assert.Nil(t, err)
+
+	_, err = ParseCronExpression("0 3 * *")
+	assert.NotNil(t, err)
+
+	_, err = ParseCronExpression("60 3 * * *")
+	assert.NotNil(t, err)
+
+	_, err = ParseCronExpression("0 5-3 * * *")
+	assert.NotNil(t, err)
+
+	_, err = ParseCronExpression("*/0 3 * * *")
+	assert.NotNil(t, err)
+}
+
+func TestCronExpression_Next(t *testing.T) {
+	base := time.Date(2024, 1, 31, 10, 20, 30, 0, time.UTC) // wednesday
+
+	c, _ := ParseCronExpression("0 3 * * *")
+	assert.Equal(t, time.Date(2024, 2, 1, 3, 0, 0, 0, time.UTC), c.Next(base))
+
+	c, _ = ParseCronExpression("*/15 * * * *")
+	assert.Equal(t, time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC), c.Next(base))
+
+	c, _ = ParseCronExpression("30 1 * * 0")
+	assert.Equal(t, time.Date(2024, 2, 4, 1, 30, 0, 0, time.UTC), c.Next(base))
+
+	c, _ = ParseCronExpression("30 1 * * 7")
+	assert.Equal(t, time.Date(2024, 2, 4, 1, 30, 0, 0, time.UTC), c.Next(base))
+
+	c, _ = ParseCronExpression("0 0 29 2 *")
+	assert.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), c.Next(base))
+
+	// day of month or day of week
+	c, _ = ParseCronExpression("0 0 15 * 5")
+	assert.Equal(t, time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC), c.Next(base))
+
+	c, _ = ParseCronExpression("0 0 31 2 *")
+	assert.True(t, c.Next(base).IsZero())
+}
@@ -1,17 +1,27 @@
 package common
 
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+)
+
 // DataChunk struct is to hold the block File particle information
 type DataChunk struct {
-	Sequence uint16 `json:"sequence"`
+	Sequence uint32 `json:"sequence"`
 	Size     uint32 `json:"size"`
 	Hash     string `json:"hash"`
+	// Inline chunks keep their content in the metadata instead of the data nodes. bson key is different
+	// because "inline" is a reserved option of the bson struct tags
+	Inline bool   `json:"inline,omitempty" bson:"inlined,omitempty"`
+	Data   []byte `json:"-" bson:"data,omitempty"`
 }
 
 // DataChunks is the definition of the pointer array of DataChunk struct
 type DataChunks []*DataChunk
 
 // NewDataChunk initialises a new DataChunk using the given information
-func NewDataChunk(sequence uint16, size uint32, sha512 string) *DataChunk {
+func NewDataChunk(sequence uint32, size uint32, sha512 string) *DataChunk {
 	return &DataChunk{
 		Sequence: sequence,
 		Size:     size,
@@ -19,6 +29,37 @@ func NewDataChunk(sequence uint16, size uint32, sha512 string) *DataChunk {
 	}
 }
 
+// NewInlineDataChunk initialises a new DataChunk that keeps the given data in itself
+func NewInlineDataChunk(sequence uint32, data []byte) *DataChunk {
+	chunk := NewDataChunk(sequence, uint32(len(data)), inlineHash(data))
+	chunk.Inline = true
+	chunk.Data = data
+
+	return chunk
+}
+
+// Intact checks if the inline chunk content is matching with its size and hash
+func (d *DataChunk) Intact() bool {
+	return d.Inline && uint32(len(d.Data)) == d.Size && strings.Compare(inlineHash(d.Data), d.Hash) == 0
+}
+
+func inlineHash(data []byte) string {
+	hash := sha512.New512_256()
+	_, _ = hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
 func (d DataChunks) Len() int           { return len(d) }
 func (d DataChunks) Less(i, j int) bool { return d[i].Sequence < d[j].Sequence }
 func (d DataChunks) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+
+// Remote returns the chunks that are placed in the data nodes
+func (d DataChunks) Remote() DataChunks {
+	remote := make(DataChunks, 0, len(d))
+	for _, c := range d {
+		if !c.Inline {
+			remote = append(remote, c)
+		}
+	}
+	return remote
+}
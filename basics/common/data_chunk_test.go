@@ -0,0 +1,82 @@
+package common
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDataChunk_Sequence(t *testing.T) {
+	var chunk DataChunk
+	assert.Nil(t, json.Unmarshal([]byte(`{"sequence":65535,"size":1024,"hash":"x"}`), &chunk))
+	assert.Equal(t, uint32(65535), chunk.Sequence)
+
+	raw, err := json.Marshal(NewDataChunk(math.MaxUint32, 1024, "x"))
+	assert.Nil(t, err)
+	assert.Nil(t, json.Unmarshal(raw, &chunk))
+	assert.Equal(t, uint32(math.MaxUint32), chunk.Sequence)
+}
+
+func TestCreateJoinedFile_Sequence(t *testing.T) {
+	file1 := newFile("1")
+	file1.Zombie = false
+	file1.Lock = nil
+	file1.Chunks = DataChunks{NewDataChunk(1, 10, "b"), NewDataChunk(0, 10, "a")}
+
+	file2 := newFile("2")
+	file2.Zombie = false
+	file2.Lock = nil
+	file2.Chunks = DataChunks{NewDataChunk(0, 10, "c")}
+
+	joinedFile, err := CreateJoinedFile(Files{file1, file2})
+	assert.Nil(t, err)
+	assert.Len(t, joinedFile.Chunks, 3)
+	assert.Equal(t, "a", joinedFile.Chunks[0].Hash)
+	assert.Equal(t, uint32(2), joinedFile.Chunks[2].Sequence)
+	assert.Equal(t, "c", joinedFile.Chunks[2].Hash)
+}
+
+func TestDataChunk_Inline(t *testing.T) {
+	chunk := NewInlineDataChunk(0, []byte("inline"))
+	assert.True(t, chunk.Inline)
+	assert.Equal(t, uint32(6), chunk.Size)
+	assert.True(t, chunk.Intact())
+
+	chunk.Data = []byte("changed")
+	assert.False(t, chunk.Intact())
+
+	raw, err := json.Marshal(chunk)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(raw), "changed")
+
+	chunks := DataChunks{NewDataChunk(0, 10, "a"), chunk}
+	assert.Len(t, chunks.Remote(), 1)
+	assert.Equal(t, "a", chunks.Remote()[0].Hash)
+}
+
+func TestCreateJoinedFile_Checksum(t *testing.T) {
+	file1 := newFile("1")
+	file1.Zombie = false
+	file1.Lock = nil
+	file1.Size = 10
+	file1.Checksum = "checksum1"
+	file1.Chunks = DataChunks{NewDataChunk(0, 10, "a")}
+
+	file2 := newFile("2")
+	file2.Zombie = false
+	file2.Lock = nil
+	file2.Size = 10
+	file2.Checksum = "checksum2"
+	file2.Chunks = DataChunks{NewDataChunk(0, 10, "b")}
+
+	joinedFile, err := CreateJoinedFile(Files{file1})
+	assert.Nil(t, err)
+	assert.Equal(t, "checksum1", joinedFile.Checksum)
+
+	joinedFile, err = CreateJoinedFile(Files{file1, file2})
+	assert.Nil(t, err)
+	assert.Empty(t, joinedFile.Checksum)
+	assert.Equal(t, uint64(20), joinedFile.Size)
+}
@@ -0,0 +1,50 @@
+package common
+
+// Extraction entry statuses
+const (
+	ESCreated     = "created"
+	ESOverwritten = "overwritten"
+	ESExists      = "exists"
+	ESSkipped     = "skipped"
+	ESFailed      = "failed"
+)
+
+// ExtractionEntry struct is to hold the result of an archive entry extraction
+// Path is the full path of the entry in dos
+// Status is one of created, overwritten, exists, skipped or failed
+// Error is the failure reason when the status is failed or skipped
+type ExtractionEntry struct {
+	Path   string `json:"path"`
+	Folder bool   `json:"folder"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ExtractionResult struct is to hold the per entry report of the archive extraction
+type ExtractionResult struct {
+	Entries []*ExtractionEntry `json:"entries"`
+	Failed  int                `json:"failed"`
+}
+
+// NewExtractionResult initialises the new empty ExtractionResult struct
+func NewExtractionResult() *ExtractionResult {
+	return &ExtractionResult{
+		Entries: make([]*ExtractionEntry, 0),
+	}
+}
+
+// Add appends the entry result to the report
+func (e *ExtractionResult) Add(path string, folder bool, status string, err error) {
+	entry := &ExtractionEntry{
+		Path:   path,
+		Folder: folder,
+		Status: status,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if status == ESFailed {
+		e.Failed++
+	}
+	e.Entries = append(e.Entries, entry)
+}
@@ -3,6 +3,7 @@ package common
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
 	"sort"
 	"strings"
 	"time"
@@ -22,6 +23,8 @@ type File struct {
 	Missing  DataChunks `json:"missing"`
 	Lock     *FileLock  `json:"lock"`
 	Zombie   bool       `json:"zombie"`
+	// ChecksumState keeps the state of the checksum calculation to extend the checksum on append
+	ChecksumState []byte `json:"-" bson:"checksumstate,omitempty"`
 }
 
 // Files is the definition of the pointer array of File struct
@@ -31,13 +34,15 @@ func (f Files) Len() int           { return len(f) }
 func (f Files) Less(i, j int) bool { return strings.Compare(f[i].Name, f[j].Name) < 0 }
 func (f Files) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
 
-// CreateJoinedFile creates a file struct from multiple files and behave like a single file
+// CreateJoinedFile creates a file struct from multiple files and behave like a single file. Returns
+// errors.ErrTooLarge if the chunks of the files exceed the chunk sequence limit
 func CreateJoinedFile(files Files) (*File, error) {
 	hash := md5.New()
 
 	mime := ""
-	sequenceCount := uint16(0)
+	sequenceCount := uint64(0)
 	joinedFile := newFile("")
+	joinedFile.Modified = time.Time{}
 	for _, f := range files {
 		if f.Locked() {
 			return nil, errors.ErrLock
@@ -49,12 +54,19 @@ func CreateJoinedFile(files Files) (*File, error) {
 			return nil, err
 		}
 		joinedFile.Size += f.Size
+		if f.Modified.After(joinedFile.Modified) {
+			joinedFile.Modified = f.Modified
+		}
 
 		sort.Sort(f.Chunks)
 		for _, c := range f.Chunks {
+			if sequenceCount > MaxChunkSequence {
+				return nil, errors.ErrTooLarge
+			}
+
 			shadow := *c
 
-			shadow.Sequence = sequenceCount
+			shadow.Sequence = uint32(sequenceCount)
 			sequenceCount++
 
 			joinedFile.Chunks = append(joinedFile.Chunks, &shadow)
@@ -73,6 +85,14 @@ func CreateJoinedFile(files Files) (*File, error) {
 	joinedFile.Mime = mime
 	joinedFile.Name = hex.EncodeToString(hash.Sum(nil))
 
+	// the checksum of the joined content can not be derived from the source checksums,
+	// it is left empty to be calculated by reading the content
+	joinedFile.Checksum = ""
+	if len(files) == 1 {
+		joinedFile.Checksum = files[0].Checksum
+		joinedFile.ChecksumState = files[0].ChecksumState
+	}
+
 	return joinedFile, nil
 }
 
@@ -181,6 +201,7 @@ func (f *File) Reset(mime string, size uint64) {
 	f.Mime = mime
 	f.Size = size
 	f.Checksum = EmptyChecksum()
+	f.ChecksumState = nil
 	f.Created = time.Now().UTC()
 	f.Modified = time.Now().UTC()
 	f.Chunks = make(DataChunks, 0)
@@ -189,6 +210,20 @@ func (f *File) Reset(mime string, size uint64) {
 	f.Zombie = false
 }
 
+// ETag creates the strong entity tag of the file content. It is calculated from the chunk hashes,
+// so it also works for the joined files
+func (f *File) ETag() string {
+	chunks := make(DataChunks, len(f.Chunks))
+	copy(chunks, f.Chunks)
+	sort.Sort(chunks)
+
+	hash := md5.New()
+	for _, c := range chunks {
+		_, _ = hash.Write([]byte(c.Hash))
+	}
+	return fmt.Sprintf("\"%s\"", hex.EncodeToString(hash.Sum(nil)))
+}
+
 // CloneInto copies the file struct in to a target file struct
 func (f *File) CloneInto(target *File) {
 	if target == nil {
@@ -198,6 +233,7 @@ func (f *File) CloneInto(target *File) {
 	target.Mime = f.Mime
 	target.Size = f.Size
 	target.Checksum = f.Checksum
+	target.ChecksumState = f.ChecksumState
 	target.Lock = f.Lock
 
 	target.Chunks = make(DataChunks, 0)
@@ -20,7 +20,7 @@ type Folder struct {
 	Modified time.Time     `json:"modified"`
 	Size     uint64        `json:"size" bson:"-"`
 	Folders  FolderShadows `json:"folders"`
-	Files    Files         `json:"files"`
+	Files    Files         `json:"files" bson:"-"`
 	Hooks    hooks.Hooks   `json:"hooks,omitempty"`
 }
 
@@ -0,0 +1,196 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSearchPageSize = 1000
+const maxSearchPageSize = 10000
+
+// SearchQuery struct is to hold the filtering details of the file search in the namespace
+// Root is the folder path that search will be applied recursively
+// Name is the glob pattern for the file name, NameRegex is the regular expression alternative of it
+// Mime is the glob pattern for the file mime type. Ex: video/*
+// Nil fields are not applied to the search
+type SearchQuery struct {
+	Root           string
+	Name           string
+	NameRegex      string
+	Mime           string
+	SizeMin        *uint64
+	SizeMax        *uint64
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	ModifiedAfter  *time.Time
+	ModifiedBefore *time.Time
+	Zombie         *bool
+	Locked         *bool
+	PageSize       int
+	Continue       string
+}
+
+// SearchEntry struct is to hold the found file with its folder
+type SearchEntry struct {
+	Folder string `json:"folder"`
+	*File
+}
+
+// Path returns the full path of the found file
+func (s *SearchEntry) Path() string {
+	return Join(s.Folder, s.Name)
+}
+
+// SearchResult struct is to hold the page of the search result
+// Continue is the token to be used to query the next page. It is empty when there is no more page
+// Incomplete is set when there are folders under the root that are not migrated to the separated files
+// layout yet. Files of these folders are not in the result till the migration is completed
+type SearchResult struct {
+	Entries    []*SearchEntry `json:"entries"`
+	Continue   string         `json:"continue,omitempty"`
+	Incomplete bool           `json:"incomplete,omitempty"`
+}
+
+// NewSearchQuery creates an empty SearchQuery for the root path
+func NewSearchQuery(root string) *SearchQuery {
+	return &SearchQuery{
+		Root:     CorrectPath(root),
+		PageSize: defaultSearchPageSize,
+	}
+}
+
+// Validate checks and normalises the query fields
+func (s *SearchQuery) Validate() error {
+	if !ValidatePath(s.Root) {
+		return fmt.Errorf("root should be an absolute path")
+	}
+	s.Root = CorrectPath(s.Root)
+
+	if len(s.NameRegex) > 0 {
+		if _, err := regexp.Compile(s.NameRegex); err != nil {
+			return fmt.Errorf("name regex is not valid: %s", err)
+		}
+	}
+
+	if s.SizeMin != nil && s.SizeMax != nil && *s.SizeMin > *s.SizeMax {
+		return fmt.Errorf("size range is not valid")
+	}
+	if s.CreatedAfter != nil && s.CreatedBefore != nil && s.CreatedAfter.After(*s.CreatedBefore) {
+		return fmt.Errorf("created range is not valid")
+	}
+	if s.ModifiedAfter != nil && s.ModifiedBefore != nil && s.ModifiedAfter.After(*s.ModifiedBefore) {
+		return fmt.Errorf("modified range is not valid")
+	}
+
+	if s.PageSize <= 0 {
+		s.PageSize = defaultSearchPageSize
+	}
+	if s.PageSize > maxSearchPageSize {
+		s.PageSize = maxSearchPageSize
+	}
+
+	return nil
+}
+
+// GlobToRegex converts the glob pattern to the anchored regular expression.
+// Supported wildcards are `*` (any sequence), `?` (any single char) and `[...]` (char class)
+func GlobToRegex(pattern string) string {
+	builder := strings.Builder{}
+	builder.WriteString("^")
+
+	inClass := false
+	for _, r := range pattern {
+		if inClass {
+			switch r {
+			case ']':
+				inClass = false
+				builder.WriteRune(r)
+			case '\\':
+				builder.WriteString("\\\\")
+			default:
+				builder.WriteRune(r)
+			}
+			continue
+		}
+
+		switch r {
+		case '*':
+			builder.WriteString(".*")
+		case '?':
+			builder.WriteString(".")
+		case '[':
+			inClass = true
+			builder.WriteRune(r)
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	builder.WriteString("$")
+
+	return builder.String()
+}
+
+// ParseSizeRange parses the size range in `min-max` format. Any side of the range can be empty
+func ParseSizeRange(value string) (*uint64, *uint64, error) {
+	dashIdx := strings.Index(value, "-")
+	if dashIdx == -1 {
+		return nil, nil, fmt.Errorf("size range is not valid")
+	}
+
+	parseFunc := func(v string) (*uint64, error) {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			return nil, nil
+		}
+		size, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("size range is not valid")
+		}
+		return &size, nil
+	}
+
+	sizeMin, err := parseFunc(value[:dashIdx])
+	if err != nil {
+		return nil, nil, err
+	}
+	sizeMax, err := parseFunc(value[dashIdx+1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return sizeMin, sizeMax, nil
+}
+
+// ParseTimeRange parses the time range in `after,before` format with RFC3339 dates.
+// Any side of the range can be empty
+func ParseTimeRange(value string) (*time.Time, *time.Time, error) {
+	commaIdx := strings.Index(value, ",")
+	if commaIdx == -1 {
+		return nil, nil, fmt.Errorf("time range is not valid")
+	}
+
+	parseFunc := func(v string) (*time.Time, error) {
+		v = strings.TrimSpace(v)
+		if len(v) == 0 {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("time range is not valid")
+		}
+		t = t.UTC()
+		return &t, nil
+	}
+
+	after, err := parseFunc(value[:commaIdx])
+	if err != nil {
+		return nil, nil, err
+	}
+	before, err := parseFunc(value[commaIdx+1:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return after, before, nil
+}
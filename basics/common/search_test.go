@@ -0,0 +1,66 @@
+package common
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobToRegex(t *testing.T) {
+	matchFunc := func(pattern string, value string) bool {
+		return regexp.MustCompile(GlobToRegex(pattern)).MatchString(value)
+	}
+
+	assert.True(t, matchFunc("*.mp4", "movie.mp4"))
+	assert.False(t, matchFunc("*.mp4", "movie.mp4.part"))
+	assert.True(t, matchFunc("2023-??-*.log", "2023-01-app.log"))
+	assert.False(t, matchFunc("2023-??-*.log", "2023-1-app.log"))
+	assert.True(t, matchFunc("file[0-9].txt", "file7.txt"))
+	assert.False(t, matchFunc("file[0-9].txt", "fileA.txt"))
+	assert.True(t, matchFunc("a+b(1).txt", "a+b(1).txt"))
+	assert.True(t, matchFunc("video/*", "video/mp4"))
+}
+
+func TestParseSizeRange(t *testing.T) {
+	sizeMin, sizeMax, err := ParseSizeRange("1024-2048")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1024), *sizeMin)
+	assert.Equal(t, uint64(2048), *sizeMax)
+
+	sizeMin, sizeMax, err = ParseSizeRange("1024-")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1024), *sizeMin)
+	assert.Nil(t, sizeMax)
+
+	sizeMin, sizeMax, err = ParseSizeRange("-2048")
+	assert.Nil(t, err)
+	assert.Nil(t, sizeMin)
+	assert.Equal(t, uint64(2048), *sizeMax)
+
+	_, _, err = ParseSizeRange("1024")
+	assert.NotNil(t, err)
+}
+
+func TestParseTimeRange(t *testing.T) {
+	after, before, err := ParseTimeRange("2020-01-11T21:15:55Z,")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2020, 1, 11, 21, 15, 55, 0, time.UTC), *after)
+	assert.Nil(t, before)
+
+	_, _, err = ParseTimeRange("yesterday,today")
+	assert.NotNil(t, err)
+}
+
+func TestSearchQuery_Validate(t *testing.T) {
+	query := NewSearchQuery("/media")
+	query.PageSize = maxSearchPageSize + 1
+	assert.Nil(t, query.Validate())
+	assert.Equal(t, maxSearchPageSize, query.PageSize)
+
+	sizeMin, sizeMax := uint64(10), uint64(5)
+	query.SizeMin = &sizeMin
+	query.SizeMax = &sizeMax
+	assert.NotNil(t, query.Validate())
+}
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SnapshotSchedule struct is to hold the automatic snapshot creation and the retention policy of the cluster.
+// Expression is the cron expression of the creation times in UTC. Retention keeps the newest KeepLast snapshots,
+// the newest snapshot of each day for KeepDaily days and the newest snapshot of each week for KeepWeekly weeks.
+// When all the retention values are 0, snapshots are never deleted automatically. Retention is only applied to
+// the Created snapshots, the snapshots that are created by hand are never deleted by the schedule
+type SnapshotSchedule struct {
+	Expression string `json:"expression"`
+	KeepLast   int    `json:"keepLast"`
+	KeepDaily  int    `json:"keepDaily"`
+	KeepWeekly int    `json:"keepWeekly"`
+
+	NextRun    time.Time `json:"nextRun"`
+	LastRun    time.Time `json:"lastRun"`
+	LastStatus string    `json:"lastStatus"`
+
+	LastPrune       time.Time `json:"lastPrune"`
+	LastPruneStatus string    `json:"lastPruneStatus"`
+
+	Created Snapshots `json:"created,omitempty"`
+}
+
+// NewSnapshotSchedule validates the expression and the retention values and creates the schedule
+func NewSnapshotSchedule(expression string, keepLast int, keepDaily int, keepWeekly int) (*SnapshotSchedule, error) {
+	cron, err := ParseCronExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	if keepLast < 0 || keepDaily < 0 || keepWeekly < 0 {
+		return nil, fmt.Errorf("retention values should be 0 or positive")
+	}
+
+	return &SnapshotSchedule{
+		Expression: expression,
+		KeepLast:   keepLast,
+		KeepDaily:  keepDaily,
+		KeepWeekly: keepWeekly,
+		NextRun:    cron.Next(time.Now().UTC()),
+	}, nil
+}
+
+// Due checks if the snapshot creation time is reached
+func (s *SnapshotSchedule) Due(now time.Time) bool {
+	return !s.NextRun.IsZero() && !now.Before(s.NextRun)
+}
+
+// Schedule calculates the next creation time after the given time
+func (s *SnapshotSchedule) Schedule(after time.Time) error {
+	cron, err := ParseCronExpression(s.Expression)
+	if err != nil {
+		return err
+	}
+	s.NextRun = cron.Next(after)
+	return nil
+}
+
+// Retains checks if any retention rule is defined
+func (s *SnapshotSchedule) Retains() bool {
+	return s.KeepLast > 0 || s.KeepDaily > 0 || s.KeepWeekly > 0
+}
+
+// Track adds the snapshots that are in current but not in previous to the created snapshots of the schedule.
+// Created snapshots that are not in current anymore are forgotten
+func (s *SnapshotSchedule) Track(previous Snapshots, current Snapshots) {
+	created := make(Snapshots, 0)
+	for _, snapshot := range current {
+		if s.Created.Contains(snapshot) || !previous.Contains(snapshot) {
+			created = append(created, snapshot)
+		}
+	}
+	sort.Sort(created)
+
+	s.Created = created
+}
+
+// Expired returns the snapshots that are created by the schedule and not kept by any retention rule, the oldest first
+func (s *SnapshotSchedule) Expired(snapshots Snapshots, now time.Time) Snapshots {
+	expired := make(Snapshots, 0)
+	if !s.Retains() {
+		return expired
+	}
+
+	ordered := make(Snapshots, 0)
+	for _, snapshot := range snapshots {
+		if s.Created.Contains(snapshot) {
+			ordered = append(ordered, snapshot)
+		}
+	}
+	sort.Sort(sort.Reverse(ordered))
+
+	kept := make(map[int]bool)
+	for i := 0; i < s.KeepLast && i < len(ordered); i++ {
+		kept[i] = true
+	}
+
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	keepNewestInPeriods := func(periodCount int, periodStart func(t time.Time) time.Time, periodLength time.Duration) {
+		if periodCount == 0 {
+			return
+		}
+
+		oldestPeriod := periodStart(today).Add(-periodLength * time.Duration(periodCount-1))
+		periods := make(map[time.Time]bool)
+
+		for i, snapshot := range ordered {
+			period := periodStart(snapshot.UTC())
+			if period.Before(oldestPeriod) || periods[period] {
+				continue
+			}
+			periods[period] = true
+			kept[i] = true
+		}
+	}
+
+	keepNewestInPeriods(s.KeepDaily, func(t time.Time) time.Time {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}, time.Hour*24)
+	keepNewestInPeriods(s.KeepWeekly, func(t time.Time) time.Time {
+		// Weeks start on monday
+		weekday := (int(t.Weekday()) + 6) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-weekday, 0, 0, 0, 0, time.UTC)
+	}, time.Hour*24*7)
+
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if !kept[i] {
+			expired = append(expired, ordered[i])
+		}
+	}
+
+	return expired
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotSchedule_Due(t *testing.T) {
+	schedule, err := NewSnapshotSchedule("0 * * * *", 0, 0, 0)
+	assert.Nil(t, err)
+
+	assert.False(t, schedule.Due(schedule.NextRun.Add(-time.Second)))
+	assert.True(t, schedule.Due(schedule.NextRun))
+
+	_, err = NewSnapshotSchedule("0 * * * *", -1, 0, 0)
+	assert.NotNil(t, err)
+}
+
+func TestSnapshotSchedule_Expired(t *testing.T) {
+	now := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC) // wednesday
+
+	snapshots := make(Snapshots, 0)
+	for i := 0; i < 30; i++ {
+		snapshots = append(snapshots,
+			now.Add(-time.Hour*24*time.Duration(i)).Add(-time.Hour*2),
+			now.Add(-time.Hour*24*time.Duration(i)).Add(-time.Hour*4),
+		)
+	}
+
+	schedule := &SnapshotSchedule{Created: snapshots}
+	assert.Len(t, schedule.Expired(snapshots, now), 0)
+
+	schedule = &SnapshotSchedule{KeepLast: 3, Created: snapshots}
+	expired := schedule.Expired(snapshots, now)
+	assert.Len(t, expired, 57)
+	assert.Equal(t, snapshots[59], expired[0])
+
+	schedule = &SnapshotSchedule{KeepDaily: 7, Created: snapshots}
+	expired = schedule.Expired(snapshots, now)
+	assert.Len(t, expired, 53)
+	assert.NotContains(t, expired, snapshots[0])
+	assert.Contains(t, expired, snapshots[1])
+
+	// 2 days of this week, 4 previous weeks that one of them is cut by the 30 days
+	schedule = &SnapshotSchedule{KeepWeekly: 5, Created: snapshots}
+	expired = schedule.Expired(snapshots, now)
+	assert.Len(t, expired, 55)
+
+	// newest of the last 2 days are already in the last 3
+	schedule = &SnapshotSchedule{KeepLast: 3, KeepDaily: 2, Created: snapshots}
+	expired = schedule.Expired(snapshots, now)
+	assert.Len(t, expired, 57)
+
+	schedule = &SnapshotSchedule{KeepLast: 1, KeepDaily: 2, Created: snapshots}
+	expired = schedule.Expired(snapshots, now)
+	assert.Len(t, expired, 58)
+}
+
+func TestSnapshotSchedule_ExpiredSkipsManual(t *testing.T) {
+	now := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+
+	manual := now.Add(-time.Hour * 24 * 10)
+	scheduled := Snapshots{
+		now.Add(-time.Hour * 24 * 3),
+		now.Add(-time.Hour * 24 * 2),
+		now.Add(-time.Hour * 24),
+	}
+	snapshots := append(Snapshots{manual}, scheduled...)
+
+	schedule := &SnapshotSchedule{KeepLast: 1, Created: scheduled}
+	expired := schedule.Expired(snapshots, now)
+	assert.Equal(t, Snapshots{scheduled[0], scheduled[1]}, expired)
+	assert.NotContains(t, expired, manual)
+}
+
+func TestSnapshotSchedule_Track(t *testing.T) {
+	now := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+
+	manual := now.Add(-time.Hour * 48)
+	first := now.Add(-time.Hour * 24)
+	second := now
+
+	schedule := &SnapshotSchedule{}
+	schedule.Track(Snapshots{manual}, Snapshots{manual, first})
+	assert.Equal(t, Snapshots{first}, schedule.Created)
+
+	schedule.Track(Snapshots{manual, first}, Snapshots{manual, first, second})
+	assert.Equal(t, Snapshots{first, second}, schedule.Created)
+
+	// deleted snapshots are forgotten
+	schedule.Track(Snapshots{manual, second}, Snapshots{manual, second})
+	assert.Equal(t, Snapshots{second}, schedule.Created)
+}
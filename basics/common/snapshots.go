@@ -7,3 +7,13 @@ type Snapshots []time.Time
 func (s Snapshots) Len() int           { return len(s) }
 func (s Snapshots) Less(i, j int) bool { return s[i].Before(s[j]) }
 func (s Snapshots) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// Contains checks if the snapshot is in the list
+func (s Snapshots) Contains(snapshot time.Time) bool {
+	for _, t := range s {
+		if t.Equal(snapshot) {
+			return true
+		}
+	}
+	return false
+}
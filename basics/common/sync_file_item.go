@@ -5,7 +5,7 @@ import "strings"
 // SyncFileItem struct is to hold and export/serialize the file creation/deletion/sync operation across the dos farm
 type SyncFileItem struct {
 	Sha512Hex string `json:"sha512Hex"`
-	Usage     uint16 `json:"usage"`
+	Usage     uint64 `json:"usage"`
 	Size      uint32 `json:"size"`
 	Shadow    bool   `json:"shadow"`
 }
@@ -0,0 +1,55 @@
+package common
+
+import "fmt"
+
+type ThrottleOperations string
+
+const (
+	ThrottleSync ThrottleOperations = "sync"
+	ThrottleMove ThrottleOperations = "move"
+)
+
+// ParseThrottleOperation validates the throttle operation name
+func ParseThrottleOperation(operation string) (ThrottleOperations, error) {
+	switch ThrottleOperations(operation) {
+	case ThrottleSync, ThrottleMove:
+		return ThrottleOperations(operation), nil
+	}
+	return "", fmt.Errorf("throttle operation should be sync or move")
+}
+
+// ThrottleLimit struct is to hold the limits of the background transfer traffic of an operation type.
+// Rate is bytes per second and Concurrency is the count of the parallel transfers. 0 is unlimited
+type ThrottleLimit struct {
+	Rate        uint64 `json:"rate"`
+	Concurrency int    `json:"concurrency"`
+}
+
+// Unlimited checks if the limit is not restricting the transfers
+func (t ThrottleLimit) Unlimited() bool {
+	return t.Rate == 0 && t.Concurrency == 0
+}
+
+// Throttle struct is to hold the background transfer traffic limits of the data node.
+// Sync limits the replication between the nodes of the cluster, Move limits the balance and move transfers
+type Throttle struct {
+	Sync ThrottleLimit `json:"sync"`
+	Move ThrottleLimit `json:"move"`
+}
+
+// Limit returns the limit of the operation
+func (t *Throttle) Limit(operation ThrottleOperations) ThrottleLimit {
+	if operation == ThrottleMove {
+		return t.Move
+	}
+	return t.Sync
+}
+
+// SetLimit replaces the limit of the operation
+func (t *Throttle) SetLimit(operation ThrottleOperations, limit ThrottleLimit) {
+	if operation == ThrottleMove {
+		t.Move = limit
+		return
+	}
+	t.Sync = limit
+}
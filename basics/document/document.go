@@ -0,0 +1,37 @@
+package document
+
+import (
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Folder is the stored shape of the folder. Files field is only filled
+// for the documents which are still in the legacy (embedded files) layout
+type Folder struct {
+	common.Folder `bson:",inline"`
+	Files         common.Files `bson:"files,omitempty"`
+}
+
+// File is the stored shape of the file in the separated files collection
+type File struct {
+	Parent      string `bson:"parent"`
+	common.File `bson:",inline"`
+}
+
+// Decode decodes the stored folder. Legacy folders are returned with their embedded files and the
+// legacy state, the state is nil for the others and their files should be read from the files collection
+func Decode(raw bson.Raw) (*common.Folder, *State, error) {
+	var document Folder
+	if err := bson.Unmarshal(raw, &document); err != nil {
+		return nil, nil, err
+	}
+	folder := &document.Folder
+
+	if document.Files == nil {
+		return folder, nil, nil
+	}
+	folder.Files = document.Files
+
+	state, err := NewState(folder, true)
+	return folder, state, err
+}
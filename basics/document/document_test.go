@@ -0,0 +1,145 @@
+package document
+
+import (
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func newTestFolder(names ...string) *common.Folder {
+	folder := &common.Folder{
+		Full:  "/test",
+		Name:  "test",
+		Files: make(common.Files, 0),
+	}
+	for _, name := range names {
+		folder.Files = append(folder.Files, &common.File{
+			Name:     name,
+			Mime:     "text/plain",
+			Size:     10,
+			Modified: time.Unix(1700000000, 0).UTC(),
+			Chunks:   common.DataChunks{common.NewDataChunk(0, 10, name)},
+		})
+	}
+	return folder
+}
+
+func TestState_Changes(t *testing.T) {
+	stored := newTestFolder("a", "b", "c")
+	state, err := NewState(stored, false)
+	assert.Nil(t, err)
+	assert.False(t, state.Legacy())
+
+	changes, err := state.Changes(newTestFolder("a", "b", "c"))
+	assert.Nil(t, err)
+	assert.Empty(t, changes.Upserts)
+	assert.Empty(t, changes.Deletes)
+	assert.False(t, changes.Cleanup)
+	assert.Empty(t, changes.WriteModels("/test"))
+
+	// add d, remove b, change c, rename a to e
+	folder := newTestFolder("c", "d")
+	folder.Files[0].Size = 20
+	renamed := *stored.Files[0]
+	renamed.Name = "e"
+	folder.Files = append(folder.Files, &renamed)
+
+	changes, err = state.Changes(folder)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, changes.Deletes)
+	assert.Len(t, changes.Upserts, 3)
+	assert.Equal(t, "c", changes.Upserts[0].Name)
+	assert.Equal(t, "d", changes.Upserts[1].Name)
+	assert.Equal(t, "e", changes.Upserts[2].Name)
+	assert.False(t, changes.Cleanup)
+
+	models := changes.WriteModels("/test")
+	assert.Len(t, models, 5)
+	for _, model := range models[:3] {
+		assert.IsType(t, &mongo.ReplaceOneModel{}, model)
+	}
+	for _, model := range models[3:] {
+		assert.IsType(t, &mongo.DeleteOneModel{}, model)
+	}
+}
+
+func TestState_ChangesWithoutState(t *testing.T) {
+	var state *State
+	assert.True(t, state.Legacy())
+
+	changes, err := state.Changes(newTestFolder("a", "b"))
+	assert.Nil(t, err)
+	assert.Len(t, changes.Upserts, 2)
+	assert.Empty(t, changes.Deletes)
+	assert.False(t, changes.Cleanup)
+}
+
+func TestState_ChangesOfEmptyState(t *testing.T) {
+	state := EmptyState()
+	assert.False(t, state.Legacy())
+
+	changes, err := state.Changes(newTestFolder("a"))
+	assert.Nil(t, err)
+	assert.Len(t, changes.Upserts, 1)
+	assert.Empty(t, changes.Deletes)
+	assert.False(t, changes.Cleanup)
+}
+
+func TestDecode_Legacy(t *testing.T) {
+	legacy := newTestFolder("a", "b")
+	raw, err := bson.Marshal(Folder{Folder: *legacy, Files: legacy.Files})
+	assert.Nil(t, err)
+
+	folder, state, err := Decode(raw)
+	assert.Nil(t, err)
+	assert.NotNil(t, state)
+	assert.True(t, state.Legacy())
+	assert.Len(t, folder.Files, 2)
+
+	// migration writes every embedded file and drops the leftovers of any interrupted migration
+	changes, err := state.Changes(folder)
+	assert.Nil(t, err)
+	assert.Len(t, changes.Upserts, 2)
+	assert.True(t, changes.Cleanup)
+	assert.Equal(t, []string{"a", "b"}, changes.Names)
+
+	models := changes.WriteModels("/test")
+	assert.Len(t, models, 3)
+	assert.IsType(t, &mongo.DeleteManyModel{}, models[2])
+
+	raw, err = bson.Marshal(Folder{Folder: *legacy})
+	assert.Nil(t, err)
+
+	folder, state, err = Decode(raw)
+	assert.Nil(t, err)
+	assert.Nil(t, state)
+	assert.Empty(t, folder.Files)
+}
+
+func TestFile_InlineChunk(t *testing.T) {
+	file := common.File{
+		Name: "tiny.txt",
+		Size: 4,
+		Chunks: common.DataChunks{
+			common.NewInlineDataChunk(0, []byte("tiny")),
+			common.NewDataChunk(1, 10, "remote"),
+		},
+	}
+
+	raw, err := bson.Marshal(File{Parent: "/", File: file})
+	assert.Nil(t, err)
+
+	var document File
+	assert.Nil(t, bson.Unmarshal(raw, &document))
+	assert.Equal(t, "/", document.Parent)
+	assert.Len(t, document.Chunks, 2)
+	assert.True(t, document.Chunks[0].Inline)
+	assert.True(t, document.Chunks[0].Intact())
+	assert.Equal(t, []byte("tiny"), document.Chunks[0].Data)
+	assert.False(t, document.Chunks[1].Inline)
+	assert.Nil(t, document.Chunks[1].Data)
+}
@@ -0,0 +1,118 @@
+package document
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// State keeps the stored version of the folder files to be able to write only the changed ones.
+// legacy folders do not have stored files yet, so whole files are written and the leftovers are cleaned.
+// A folder without state (newly placed in the path) writes its files without touching the existing ones
+type State struct {
+	legacy bool
+	files  map[string][]byte
+}
+
+// NewState creates the state of the folder files as they are stored
+func NewState(folder *common.Folder, legacy bool) (*State, error) {
+	if legacy {
+		return &State{legacy: true}, nil
+	}
+
+	files := make(map[string][]byte)
+	for _, file := range folder.Files {
+		raw, err := bson.Marshal(file)
+		if err != nil {
+			return nil, err
+		}
+		files[file.Name] = raw
+	}
+	return &State{files: files}, nil
+}
+
+// EmptyState creates the state of the folder that is just inserted, it does not have any stored file
+func EmptyState() *State {
+	return &State{files: make(map[string][]byte)}
+}
+
+// Legacy checks if the folder files are still embedded in the folder document. The nil state is
+// handled as legacy to drop the embedded files of the folders that are placed without reading
+func (s *State) Legacy() bool {
+	return s == nil || s.legacy
+}
+
+// Changes is the difference between the folder files and the stored state of the folder
+type Changes struct {
+	Upserts common.Files
+	Deletes []string
+	// Cleanup drops the stored files that are not in the folder anymore. It is used for legacy folders
+	// because their stored files are not known
+	Cleanup bool
+	Names   []string
+}
+
+// Changes compares the folder files with the stored state. Renamed files appear as a delete of the old name
+// and an upsert of the new name
+func (s *State) Changes(folder *common.Folder) (*Changes, error) {
+	changes := &Changes{
+		Upserts: make(common.Files, 0),
+		Deletes: make([]string, 0),
+		Names:   make([]string, 0),
+	}
+
+	current := make(map[string]bool)
+	for _, file := range folder.Files {
+		changes.Names = append(changes.Names, file.Name)
+		current[file.Name] = true
+
+		if s != nil && s.files != nil {
+			raw, err := bson.Marshal(file)
+			if err != nil {
+				return nil, err
+			}
+			if stored, has := s.files[file.Name]; has && bytes.Equal(stored, raw) {
+				continue
+			}
+		}
+		changes.Upserts = append(changes.Upserts, file)
+	}
+
+	if s == nil {
+		return changes, nil
+	}
+	changes.Cleanup = s.legacy
+
+	for name := range s.files {
+		if current[name] {
+			continue
+		}
+		changes.Deletes = append(changes.Deletes, name)
+	}
+	sort.Strings(changes.Deletes)
+
+	return changes, nil
+}
+
+// WriteModels creates the bulk write models of the files collection to apply the changes of the folder
+func (c *Changes) WriteModels(folderPath string) []mongo.WriteModel {
+	models := make([]mongo.WriteModel, 0)
+	for _, file := range c.Upserts {
+		models = append(models,
+			mongo.NewReplaceOneModel().
+				SetFilter(bson.M{"parent": folderPath, "name": file.Name}).
+				SetReplacement(File{Parent: folderPath, File: *file}).
+				SetUpsert(true),
+		)
+	}
+	if c.Cleanup {
+		models = append(models, mongo.NewDeleteManyModel().SetFilter(bson.M{"parent": folderPath, "name": bson.M{"$nin": c.Names}}))
+	}
+	for _, name := range c.Deletes {
+		models = append(models, mongo.NewDeleteOneModel().SetFilter(bson.M{"parent": folderPath, "name": name}))
+	}
+	return models
+}
@@ -20,6 +20,8 @@ var (
 	ErrSync                  = errors.New("syncing is failed")
 	ErrTooManyErrors         = errors.New("too many error occurred, operation is canceled")
 	ErrSnapshot              = errors.New("snapshot operation is failed")
+	ErrRangeNotSatisfiable   = errors.New("requested range is not satisfiable")
+	ErrTooLarge              = errors.New("file exceeds the chunk sequence limit")
 
 	ErrExists                       = errors.New("cluster is already exists")
 	ErrPing                         = errors.New("node is not reachable")
@@ -5,7 +5,7 @@ import "time"
 // ActionInfo struct holds the action details that should be used by the Action provider
 type ActionInfo struct {
 	Time        time.Time `json:"time"`
-	Action      string    `json:"action"`               // created, copied, moved, deleted
+	Action      string    `json:"action"`               // created, copied, moved, appended, deleted
 	SourcePath  string    `json:"sourcePath"`           // full path of the source file/folder that took action
 	TargetPath  *string   `json:"targetPath,omitempty"` // full path of the target file/folder that took action (only copy, move)
 	Folder      bool      `json:"folder"`               // path is a folder or not
@@ -66,6 +66,15 @@ func NewActionInfoForMovedFile(sourcePath string, targetPath string, overwritten
 	}
 }
 
+func NewActionInfoForAppended(appendedPath string) *ActionInfo {
+	return &ActionInfo{
+		Time:       time.Now().UTC(),
+		Action:     "appended",
+		SourcePath: appendedPath,
+		Folder:     false,
+	}
+}
+
 func NewActionInfoForDeleted(deletedPath string, folder bool) *ActionInfo {
 	return &ActionInfo{
 		Time:       time.Now().UTC(),
@@ -13,7 +13,7 @@ type RunOn int
 const (
 	All     RunOn = 1 // is executed in anyway
 	Created RunOn = 2 // Folder/File or SubFolder/SubFile (if recursive) is newly Created
-	Updated RunOn = 3 // Folder/File or SubFolder/File is Copied or Moved (Renamed)
+	Updated RunOn = 3 // Folder/File or SubFolder/File is Copied, Moved (Renamed) or File is Appended
 	Deleted RunOn = 4 // Folder/File or SubFolder/SubFile (if recursive) is completely Deleted
 )
 
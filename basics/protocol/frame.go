@@ -0,0 +1,115 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Negotiate is the command that switches the data node connection to the framed protocol. It is followed by
+// the highest version the client supports and answered with '+' and the version the node will speak
+const Negotiate = "PRV2"
+
+// Version2 is the multiplexed protocol version. Requests and responses are carried in frames with request ids,
+// so many requests can be pipelined over a single long-lived connection
+const Version2 byte = 2
+
+// MaxPayload is the upper limit of the frame payload to reject the corrupted frame headers before allocation
+const MaxPayload = 64 * 1024 * 1024 // 64mb
+
+const headerSize = 13 // id (4) + command (4) + status (1) + payload length (4)
+
+// Frame statuses
+const (
+	StatusRequest byte = 0
+	StatusSuccess byte = '+'
+	StatusFailure byte = '-'
+	// StatusMissing is the reply of the create request without data when the block is not on the node
+	StatusMissing byte = '?'
+	// StatusPartial carries a part of the reply. More frames follow with the same id till the frame that has
+	// the final status
+	StatusPartial byte = '>'
+)
+
+// ErrChecksum is returned when the frame content does not match its checksum. The connection should not be used
+// anymore because the stream position can not be trusted
+var ErrChecksum = fmt.Errorf("frame checksum mismatch")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Frame is the unit of the multiplexed protocol. Id pairs the response with its request
+type Frame struct {
+	Id      uint32
+	Command string
+	Status  byte
+	Payload []byte
+}
+
+// Message returns the payload as the error message of the failed frame
+func (f *Frame) Message() string {
+	if len(f.Payload) == 0 {
+		return "unknown error"
+	}
+	return string(f.Payload)
+}
+
+// WriteFrame writes the frame with the trailing crc32 (castagnoli) checksum of the header and payload
+func WriteFrame(w io.Writer, f *Frame) error {
+	if len(f.Command) != 4 {
+		return fmt.Errorf("frame command should be 4 characters")
+	}
+	if len(f.Payload) > MaxPayload {
+		return fmt.Errorf("frame payload can not be more than %d", MaxPayload)
+	}
+
+	buffer := make([]byte, headerSize+len(f.Payload)+4)
+	binary.LittleEndian.PutUint32(buffer[0:4], f.Id)
+	copy(buffer[4:8], f.Command)
+	buffer[8] = f.Status
+	binary.LittleEndian.PutUint32(buffer[9:13], uint32(len(f.Payload)))
+	copy(buffer[headerSize:], f.Payload)
+
+	checksum := crc32.Checksum(buffer[:headerSize+len(f.Payload)], crcTable)
+	binary.LittleEndian.PutUint32(buffer[headerSize+len(f.Payload):], checksum)
+
+	_, err := w.Write(buffer)
+	return err
+}
+
+// ReadFrame reads the next frame and validates its checksum. payloadHandler is called with the payload length
+// before the payload is read, it can be used to extend the connection deadline and can be nil
+func ReadFrame(r io.Reader, payloadHandler func(length uint32) error) (*Frame, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[9:13])
+	if length > MaxPayload {
+		return nil, fmt.Errorf("frame payload (%d) is more than %d", length, MaxPayload)
+	}
+	if payloadHandler != nil {
+		if err := payloadHandler(length); err != nil {
+			return nil, err
+		}
+	}
+
+	content := make([]byte, length+4)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	payload := content[:length]
+
+	checksum := crc32.Update(crc32.Checksum(header, crcTable), crcTable, payload)
+	if checksum != binary.LittleEndian.Uint32(content[length:]) {
+		return nil, ErrChecksum
+	}
+
+	return &Frame{
+		Id:      binary.LittleEndian.Uint32(header[0:4]),
+		Command: string(header[4:8]),
+		Status:  header[8],
+		Payload: payload,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package protocol
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrame(t *testing.T) {
+	buffer := &bytes.Buffer{}
+
+	err := WriteFrame(buffer, &Frame{Id: 7, Command: "READ", Status: StatusSuccess, Payload: []byte("chunk data")})
+	assert.Nil(t, err)
+	err = WriteFrame(buffer, &Frame{Id: 8, Command: "PING"})
+	assert.Nil(t, err)
+
+	var lengths []uint32
+	frame, err := ReadFrame(buffer, func(length uint32) error {
+		lengths = append(lengths, length)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(7), frame.Id)
+	assert.Equal(t, "READ", frame.Command)
+	assert.Equal(t, StatusSuccess, frame.Status)
+	assert.Equal(t, []byte("chunk data"), frame.Payload)
+	assert.Equal(t, []uint32{10}, lengths)
+
+	frame, err = ReadFrame(buffer, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(8), frame.Id)
+	assert.Len(t, frame.Payload, 0)
+
+	err = WriteFrame(buffer, &Frame{Command: "CRE"})
+	assert.NotNil(t, err)
+}
+
+func TestFrameChecksum(t *testing.T) {
+	buffer := &bytes.Buffer{}
+
+	err := WriteFrame(buffer, &Frame{Id: 1, Command: "CREA", Payload: []byte("block")})
+	assert.Nil(t, err)
+
+	corrupted := buffer.Bytes()
+	corrupted[headerSize+1] ^= 0xff
+
+	_, err = ReadFrame(bytes.NewReader(corrupted), nil)
+	assert.Equal(t, ErrChecksum, err)
+}
@@ -14,15 +14,17 @@ import (
 )
 
 const dialTimeout = time.Second * 30
-const commandSyncRead = "SYRD"
-const commandSyncList = "SYLS"
+
+// sync commands that carry the usage in 8 bytes
+const commandSyncRead = "SXRD"
+const commandSyncList = "SXLS"
 const chunkSize = 1024 * 1024 // 1mb
 
 type DataNode interface {
 	SyncList(snapshotTime *time.Time) (*common.SyncContainer, error)
 	SyncRead(snapshotTime *time.Time, sha512Hex string, drop bool,
 		dataHandler func(data []byte) error,
-		verifyHandler func(usage uint16) bool,
+		verifyHandler func(usage uint64) bool,
 	) error
 }
 
@@ -122,7 +124,7 @@ func (d *dataNode) SyncList(snapshotTime *time.Time) (*common.SyncContainer, err
 				break
 			}
 
-			var usage uint16
+			var usage uint64
 			if err := binary.Read(conn, binary.LittleEndian, &usage); err != nil {
 				return err
 			}
@@ -152,7 +154,7 @@ func (d *dataNode) SyncList(snapshotTime *time.Time) (*common.SyncContainer, err
 	return container, nil
 }
 
-func (d *dataNode) SyncRead(snapshotTime *time.Time, sha512Hex string, drop bool, dataHandler func([]byte) error, verifyHandler func(usage uint16) bool) error {
+func (d *dataNode) SyncRead(snapshotTime *time.Time, sha512Hex string, drop bool, dataHandler func([]byte) error, verifyHandler func(usage uint64) bool) error {
 	return d.connect(func(conn net.Conn) error {
 		if _, err := conn.Write([]byte(commandSyncRead)); err != nil {
 			return err
@@ -188,7 +190,7 @@ func (d *dataNode) SyncRead(snapshotTime *time.Time, sha512Hex string, drop bool
 			return err
 		}
 
-		var usage uint16
+		var usage uint64
 		if err := binary.Read(conn, binary.LittleEndian, &usage); err != nil {
 			return err
 		}
@@ -2,27 +2,136 @@ package common
 
 import (
 	"os"
+	"path"
+	"strings"
 )
 
-func Traverse(p string, fileHandler func(info os.FileInfo) error) error {
+// MaxLayoutDepth is the deepest fan-out directory level that can be used for block files
+const MaxLayoutDepth = 4
+
+const shardNameLength = 2
+
+// BlockPath creates the location of the block file in the fan-out directory layout. Every level uses the next
+// two characters of the hash, so depth 2 stores the block as ab/cd/<hash>. Depth 0 is the flat layout
+func BlockPath(root string, sha512Hex string, depth int) string {
+	parts := make([]string, 0, depth+2)
+	parts = append(parts, root)
+	for i := 0; i < depth && (i+1)*shardNameLength <= len(sha512Hex); i++ {
+		parts = append(parts, sha512Hex[i*shardNameLength:(i+1)*shardNameLength])
+	}
+	parts = append(parts, sha512Hex)
+
+	return path.Join(parts...)
+}
+
+// Traverse walks the block files under the path including the fan-out directories of any depth. Directories
+// that are not fan-out levels (like snapshots) are skipped
+func Traverse(p string, fileHandler func(filePath string, info os.FileInfo) error) error {
+	return traverse(p, 0, blockName, fileHandler)
+}
+
+// TraverseSuffix walks the files that have the block name with the suffix under the path including the fan-out
+// directories of any depth. It is used to find the leftovers of the interrupted block file operations
+func TraverseSuffix(p string, suffix string, fileHandler func(filePath string, info os.FileInfo) error) error {
+	return traverse(p, 0, func(name string) bool {
+		return strings.HasSuffix(name, suffix) && blockName(strings.TrimSuffix(name, suffix))
+	}, fileHandler)
+}
+
+func traverse(p string, level int, accept func(name string) bool, fileHandler func(filePath string, info os.FileInfo) error) error {
 	infos, err := os.ReadDir(p)
 	if err != nil {
 		return err
 	}
 
 	for _, info := range infos {
-		if info.IsDir() || len(info.Name()) != 64 {
+		name := info.Name()
+
+		if info.IsDir() {
+			if level >= MaxLayoutDepth || !shardName(name) {
+				continue
+			}
+			if err := traverse(path.Join(p, name), level+1, accept, fileHandler); err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return err
+			}
+			continue
+		}
+
+		if !accept(name) {
 			continue
 		}
 
 		fi, err := info.Info()
 		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
 			return err
 		}
 
-		if err := fileHandler(fi); err != nil {
+		if err := fileHandler(path.Join(p, name), fi); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// Prune removes the empty fan-out directories that are deeper than the depth. Directories of the depth
+// are kept, new block files can be placed in them at any time
+func Prune(p string, depth int) error {
+	return prune(p, 0, depth)
+}
+
+func prune(p string, level int, depth int) error {
+	infos, err := os.ReadDir(p)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		name := info.Name()
+		if !info.IsDir() || level >= MaxLayoutDepth || !shardName(name) {
+			continue
+		}
+
+		shardPath := path.Join(p, name)
+		if err := prune(shardPath, level+1, depth); err != nil {
+			return err
+		}
+
+		if level < depth {
+			continue
+		}
+
+		entries, err := os.ReadDir(shardPath)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			continue
+		}
+		if err := os.Remove(shardPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func shardName(name string) bool {
+	if len(name) != shardNameLength {
+		return false
+	}
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func blockName(name string) bool {
+	return len(name) == 64
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testHash = "abcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789"
+
+func TestBlockPath(t *testing.T) {
+	assert.Equal(t, "/data/"+testHash, BlockPath("/data", testHash, 0))
+	assert.Equal(t, "/data/ab/"+testHash, BlockPath("/data", testHash, 1))
+	assert.Equal(t, "/data/ab/cd/"+testHash, BlockPath("/data", testHash, 2))
+	assert.Equal(t, "/data/ab/cd/ef/01/"+testHash, BlockPath("/data", testHash, MaxLayoutDepth))
+
+	// short names do not have enough characters for the deeper levels
+	assert.Equal(t, "/data/ab/abc", BlockPath("/data", "abc", 3))
+}
+
+func TestTraverse(t *testing.T) {
+	root := t.TempDir()
+
+	hashes := []string{
+		strings.Repeat("1", 64),
+		strings.Repeat("2", 64),
+		strings.Repeat("3", 64),
+	}
+	createTestFile(t, BlockPath(root, hashes[0], 0))
+	createTestFile(t, BlockPath(root, hashes[1], 1))
+	createTestFile(t, BlockPath(root, hashes[2], 2))
+
+	// snapshots, non fan-out directories and non block files are skipped
+	createTestFile(t, path.Join(root, "snapshot.20200101120000", hashes[0]))
+	createTestFile(t, BlockPath(path.Join(root, "snapshot.20200101120000"), strings.Repeat("4", 64), 2))
+	createTestFile(t, path.Join(root, "temp", strings.Repeat("5", 64)))
+	createTestFile(t, path.Join(root, "zz", strings.Repeat("6", 64)))
+	createTestFile(t, path.Join(root, "layout"))
+	createTestFile(t, path.Join(root, "22", "header"))
+
+	found := make([]string, 0)
+	err := Traverse(root, func(filePath string, info os.FileInfo) error {
+		assert.Equal(t, info.Name(), path.Base(filePath))
+		found = append(found, filePath)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	sort.Strings(found)
+	assert.Equal(t, []string{
+		BlockPath(root, hashes[0], 0),
+		BlockPath(root, hashes[1], 1),
+		BlockPath(root, hashes[2], 2),
+	}, found)
+}
+
+func TestTraverseSuffix(t *testing.T) {
+	root := t.TempDir()
+
+	hash := strings.Repeat("1", 64)
+	createTestFile(t, BlockPath(root, hash, 1))
+	createTestFile(t, BlockPath(root, hash, 1)+".upgrade")
+	createTestFile(t, BlockPath(root, hash, 0)+".upgrade")
+	createTestFile(t, path.Join(root, "short.upgrade"))
+	createTestFile(t, path.Join(root, "snapshot.20200101120000", hash+".upgrade"))
+
+	found := make([]string, 0)
+	err := TraverseSuffix(root, ".upgrade", func(filePath string, _ os.FileInfo) error {
+		found = append(found, filePath)
+		return nil
+	})
+	assert.Nil(t, err)
+
+	sort.Strings(found)
+	assert.Equal(t, []string{
+		BlockPath(root, hash, 1) + ".upgrade",
+		BlockPath(root, hash, 0) + ".upgrade",
+	}, found)
+}
+
+func TestTraverse_NotExists(t *testing.T) {
+	err := Traverse(path.Join(t.TempDir(), "missing"), func(_ string, _ os.FileInfo) error {
+		return nil
+	})
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPrune(t *testing.T) {
+	root := t.TempDir()
+
+	hash := strings.Repeat("1", 64)
+	createTestFile(t, BlockPath(root, hash, 2))
+	assert.Nil(t, os.MkdirAll(path.Join(root, "22", "33"), 0777))
+	assert.Nil(t, os.MkdirAll(path.Join(root, "44"), 0777))
+	assert.Nil(t, os.MkdirAll(path.Join(root, "snapshot.20200101120000", "55"), 0777))
+
+	assert.Nil(t, Prune(root, 1))
+
+	// directories of the depth and the non empty ones are kept
+	assert.DirExists(t, path.Join(root, "22"))
+	assert.DirExists(t, path.Join(root, "44"))
+	assert.FileExists(t, BlockPath(root, hash, 2))
+	assert.NoDirExists(t, path.Join(root, "22", "33"))
+	assert.DirExists(t, path.Join(root, "snapshot.20200101120000", "55"))
+
+	assert.Nil(t, os.Remove(BlockPath(root, hash, 2)))
+	assert.Nil(t, Prune(root, 0))
+
+	assert.NoDirExists(t, path.Join(root, "11"))
+	assert.NoDirExists(t, path.Join(root, "22"))
+	assert.NoDirExists(t, path.Join(root, "44"))
+	assert.DirExists(t, path.Join(root, "snapshot.20200101120000", "55"))
+}
+
+func createTestFile(t *testing.T, filePath string) {
+	assert.Nil(t, os.MkdirAll(path.Dir(filePath), 0777))
+	assert.Nil(t, os.WriteFile(filePath, []byte("block"), 0666))
+}
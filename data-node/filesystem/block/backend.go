@@ -0,0 +1,141 @@
+package block
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/freakmaxi/kertish-dos/data-node/common"
+	"go.uber.org/zap"
+)
+
+// Backend interface provides the block managers of the storage. Data path is the root or a snapshot path of
+// the data node, backends that do not use the local file system map it to their own addressing
+type Backend interface {
+	Manager(dataPath string) (Manager, error)
+	Remove(dataPath string) error
+}
+
+type localBackend struct {
+	options Options
+	logger  *zap.Logger
+}
+
+// NewLocalBackend creates the backend that keeps the blocks as files under the data path
+func NewLocalBackend(options Options, logger *zap.Logger) (Backend, error) {
+	if options.Depth != RecordedDepth && (options.Depth < 0 || options.Depth > common.MaxLayoutDepth) {
+		return nil, fmt.Errorf("layout depth should be between 0 and %d", common.MaxLayoutDepth)
+	}
+	if options.PackLimit > MaxPackLimit {
+		return nil, fmt.Errorf("pack limit can not be more than %d", MaxPackLimit)
+	}
+
+	return &localBackend{
+		options: options,
+		logger:  logger,
+	}, nil
+}
+
+func (l *localBackend) Manager(dataPath string) (Manager, error) {
+	return NewManager(dataPath, l.options, l.logger)
+}
+
+// Remove releases the resources of the data path, files are removed with the data path itself
+func (l *localBackend) Remove(dataPath string) error {
+	Release(dataPath)
+	return nil
+}
+
+type memoryBackend struct {
+	logger *zap.Logger
+
+	storesMutex sync.Mutex
+	stores      map[string]*memoryStore
+}
+
+// NewMemoryBackend creates the backend that keeps the blocks in memory. It is volatile and suitable for tests
+func NewMemoryBackend(logger *zap.Logger) Backend {
+	return &memoryBackend{
+		logger:      logger,
+		storesMutex: sync.Mutex{},
+		stores:      make(map[string]*memoryStore),
+	}
+}
+
+func (m *memoryBackend) Manager(dataPath string) (Manager, error) {
+	m.storesMutex.Lock()
+	defer m.storesMutex.Unlock()
+
+	s, has := m.stores[dataPath]
+	if !has {
+		s = newMemoryStore()
+		m.stores[dataPath] = s
+	}
+
+	return newRecordManager(s, m.logger), nil
+}
+
+func (m *memoryBackend) Remove(dataPath string) error {
+	m.storesMutex.Lock()
+	defer m.storesMutex.Unlock()
+
+	delete(m.stores, dataPath)
+	return nil
+}
+
+type objectBackend struct {
+	rootPath string
+	client   *objectClient
+	logger   *zap.Logger
+}
+
+// NewObjectBackend creates the backend that keeps the blocks in the S3 compatible object storage. Data paths
+// under the root path are kept under the prefix of the same relative path
+func NewObjectBackend(rootPath string, config ObjectConfig, logger *zap.Logger) (Backend, error) {
+	client, err := newObjectClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &objectBackend{
+		rootPath: rootPath,
+		client:   client,
+		logger:   logger,
+	}, nil
+}
+
+func (o *objectBackend) prefix(dataPath string) (string, error) {
+	rel, err := filepath.Rel(o.rootPath, dataPath)
+	if err != nil {
+		return "", err
+	}
+	if strings.Compare(rel, ".") == 0 {
+		rel = ""
+	}
+
+	return strings.Trim(path.Join(o.client.config.Prefix, filepath.ToSlash(rel)), "/"), nil
+}
+
+func (o *objectBackend) Manager(dataPath string) (Manager, error) {
+	prefix, err := o.prefix(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRecordManager(newObjectStore(o.client, prefix), o.logger), nil
+}
+
+func (o *objectBackend) Remove(dataPath string) error {
+	prefix, err := o.prefix(dataPath)
+	if err != nil {
+		return err
+	}
+
+	return newObjectStore(o.client, prefix).wipe()
+}
+
+var _ Backend = &localBackend{}
+var _ Backend = &memoryBackend{}
+var _ Backend = &objectBackend{}
@@ -0,0 +1,292 @@
+package block
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const objectTestBucket = "kertish"
+const objectTestPageSize = 2
+
+func TestBackend_Memory(t *testing.T) {
+	testBackendConformance(t, NewMemoryBackend(zap.NewNop()), "/data")
+}
+
+func TestBackend_Object(t *testing.T) {
+	storage := newObjectTestStorage()
+	server := httptest.NewServer(storage)
+	defer server.Close()
+
+	backend, err := NewObjectBackend("/data", ObjectConfig{
+		Endpoint:  server.URL,
+		Bucket:    objectTestBucket,
+		AccessKey: "access",
+		SecretKey: "secret",
+		Prefix:    "blocks",
+	}, zap.NewNop())
+	assert.Nil(t, err)
+
+	testBackendConformance(t, backend, "/data")
+
+	assert.Greater(t, storage.pagedLists, 0)
+	assert.False(t, storage.unsigned)
+}
+
+func testBackendConformance(t *testing.T, backend Backend, dataPath string) {
+	m, err := backend.Manager(dataPath)
+	assert.Nil(t, err)
+
+	// create and read
+	sha512Hex := writeTestBlock(t, m, []byte("conformance block"))
+	assert.Equal(t, []byte("conformance block"), readTestBlock(t, m, sha512Hex))
+
+	assert.Nil(t, m.File(sha512Hex, func(file File) error {
+		size, err := file.Size()
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(len("conformance block")), size)
+
+		content := make([]byte, 0)
+		return file.Read(12, 17, func(data []byte) error {
+			content = append(content, data...)
+			return nil
+		}, func(inconsistency bool) error {
+			assert.False(t, inconsistency)
+			assert.Equal(t, "block", string(content))
+			return nil
+		})
+	}))
+
+	// usage
+	assert.Nil(t, m.LockFile(sha512Hex, func(file File) error {
+		assert.Equal(t, uint64(1), file.Usage())
+		return file.IncreaseUsage()
+	}))
+	assert.Nil(t, m.LockFile(sha512Hex, func(file File) error {
+		assert.Equal(t, uint64(2), file.Usage())
+		return file.ResetUsage(70000)
+	}))
+	assert.Nil(t, m.File(sha512Hex, func(file File) error {
+		assert.Equal(t, uint64(70000), file.Usage())
+		return nil
+	}))
+
+	// delete decreases the usage and removes the block with the last usage
+	assert.Nil(t, m.LockFile(sha512Hex, func(file File) error {
+		if err := file.ResetUsage(2); err != nil {
+			return err
+		}
+		return file.Delete()
+	}))
+	assert.Nil(t, m.LockFile(sha512Hex, func(file File) error {
+		assert.False(t, file.Temporary())
+		assert.Equal(t, uint64(1), file.Usage())
+		return file.Delete()
+	}))
+	assert.Nil(t, m.File(sha512Hex, func(file File) error {
+		assert.True(t, file.Temporary())
+		return nil
+	}))
+
+	// traverse walks all the blocks of the data path over the list pages
+	expected := make(map[string]uint64)
+	for i := 0; i < objectTestPageSize*2+1; i++ {
+		data := []byte(fmt.Sprintf("traverse block %d", i))
+		expected[writeTestBlock(t, m, data)] = uint64(len(data))
+	}
+
+	snapshotPath := path.Join(dataPath, "snapshot.20200101120000")
+	snapshot, err := backend.Manager(snapshotPath)
+	assert.Nil(t, err)
+	snapshotHex := writeTestBlock(t, snapshot, []byte("snapshot block"))
+
+	assert.Equal(t, expected, traverseTestBlocks(t, m))
+	assert.Equal(t, map[string]uint64{snapshotHex: uint64(len("snapshot block"))}, traverseTestBlocks(t, snapshot))
+
+	// removing the snapshot does not touch the data path
+	assert.Nil(t, backend.Remove(snapshotPath))
+	snapshot, err = backend.Manager(snapshotPath)
+	assert.Nil(t, err)
+	assert.Empty(t, traverseTestBlocks(t, snapshot))
+	assert.Equal(t, expected, traverseTestBlocks(t, m))
+
+	assert.Nil(t, m.Wipe())
+	assert.Empty(t, traverseTestBlocks(t, m))
+}
+
+func traverseTestBlocks(t *testing.T, m Manager) map[string]uint64 {
+	found := make(map[string]uint64)
+	assert.Nil(t, m.Traverse(func(sha512Hex string, size uint64) error {
+		found[sha512Hex] = size
+		return nil
+	}))
+	return found
+}
+
+type objectTestEntry struct {
+	data  []byte
+	usage string
+}
+
+// objectTestStorage is the S3 stand-in that serves the requests of the object client in the path-style addressing.
+// List responses are paged by objectTestPageSize to walk the continuation tokens
+type objectTestStorage struct {
+	mutex   sync.Mutex
+	objects map[string]*objectTestEntry
+
+	pagedLists int
+	unsigned   bool
+}
+
+func newObjectTestStorage() *objectTestStorage {
+	return &objectTestStorage{
+		objects: make(map[string]*objectTestEntry),
+	}
+}
+
+func (o *objectTestStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") ||
+		len(r.Header.Get("x-amz-date")) == 0 || len(r.Header.Get("x-amz-content-sha256")) == 0 {
+		o.unsigned = true
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	bucketPath := "/" + objectTestBucket
+	if !strings.HasPrefix(r.URL.Path, bucketPath) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, bucketPath), "/")
+
+	if len(key) == 0 && r.Method == http.MethodGet {
+		o.list(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead:
+		entry, has := o.objects[key]
+		if !has {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set(objectUsageMeta, entry.usage)
+		w.Header().Set("Content-Length", strconv.Itoa(len(entry.data)))
+	case http.MethodGet:
+		o.get(w, r, key)
+	case http.MethodPut:
+		if copySource := r.Header.Get("x-amz-copy-source"); len(copySource) > 0 {
+			entry, has := o.objects[strings.TrimPrefix(copySource, bucketPath+"/")]
+			if !has {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			o.objects[key] = &objectTestEntry{data: entry.data, usage: r.Header.Get(objectUsageMeta)}
+			return
+		}
+
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		o.objects[key] = &objectTestEntry{data: data, usage: r.Header.Get(objectUsageMeta)}
+	case http.MethodDelete:
+		delete(o.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (o *objectTestStorage) get(w http.ResponseWriter, r *http.Request, key string) {
+	entry, has := o.objects[key]
+	if !has {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var begins, ends int
+	if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &begins, &ends); err != nil {
+		_, _ = w.Write(entry.data)
+		return
+	}
+	if begins >= len(entry.data) {
+		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	if ends >= len(entry.data) {
+		ends = len(entry.data) - 1
+	}
+
+	w.WriteHeader(http.StatusPartialContent)
+	_, _ = w.Write(entry.data[begins : ends+1])
+}
+
+func (o *objectTestStorage) list(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if strings.Compare(query.Get("list-type"), "2") != 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+
+	keys := make([]string, 0)
+	for key := range o.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if len(delimiter) > 0 && strings.Contains(strings.TrimPrefix(key, prefix), delimiter) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	begins := 0
+	if token := query.Get("continuation-token"); len(token) > 0 {
+		begins = sort.SearchStrings(keys, token)
+		o.pagedLists++
+	}
+	ends := begins + objectTestPageSize
+	if ends > len(keys) {
+		ends = len(keys)
+	}
+
+	type content struct {
+		Key  string `xml:"Key"`
+		Size int    `xml:"Size"`
+	}
+	result := struct {
+		XMLName               xml.Name  `xml:"ListBucketResult"`
+		Contents              []content `xml:"Contents"`
+		IsTruncated           bool      `xml:"IsTruncated"`
+		NextContinuationToken string    `xml:"NextContinuationToken,omitempty"`
+	}{}
+	for _, key := range keys[begins:ends] {
+		result.Contents = append(result.Contents, content{Key: key, Size: len(o.objects[key].data)})
+	}
+	if ends < len(keys) {
+		result.IsTruncated = true
+		result.NextContinuationToken = keys[ends]
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
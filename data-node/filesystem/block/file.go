@@ -3,18 +3,23 @@ package block
 import (
 	"crypto/sha512"
 	"encoding/hex"
+	"fmt"
 	"hash"
 	"io"
 	"os"
 	"path"
 	"strings"
 
+	"github.com/freakmaxi/kertish-dos/data-node/common"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
 const chunkSize uint32 = 1024 * 1024 // 1mb
 
+// upgradeSuffix is added to the name of the block file while it is rewritten with the current header version
+const upgradeSuffix = ".upgrade"
+
 // File handler for block file operations
 type File interface {
 	Temporary() bool
@@ -23,13 +28,13 @@ type File interface {
 	Verify() bool
 	VerifyForce() bool
 
-	Seek(offset int64) error
+	SeekTo(offset int64) error
 	Read(begins uint32, ends uint32, readHandler func(data []byte) error, completedHandler func(inconsistency bool) error) error
 
 	Id() string
-	Usage() uint16
+	Usage() uint64
 	IncreaseUsage() error
-	ResetUsage(uint16) error
+	ResetUsage(uint64) error
 	Size() (uint32, error)
 
 	Delete() error
@@ -54,12 +59,13 @@ type file struct {
 	logger     *zap.Logger
 }
 
-// NewFile provides the file interface for (new) block file operations
-func NewFile(root string, sha512Hex string, logger *zap.Logger) (File, error) {
+// NewFile provides the file interface for (new) block file operations. depth is the fan-out directory level
+// of the block file location under the root
+func NewFile(root string, sha512Hex string, depth int, logger *zap.Logger) (File, error) {
 	file := &file{
 		sha512:     sha512.New512_256(),
 		sha512Hex:  sha512Hex,
-		targetPath: path.Join(root, sha512Hex),
+		targetPath: common.BlockPath(root, sha512Hex, depth),
 		verified:   true,
 		canceled:   false,
 		logger:     logger,
@@ -131,14 +137,14 @@ func (f *file) VerifyForce() bool {
 	return f.verified
 }
 
-func (f *file) Seek(offset int64) error {
+func (f *file) SeekTo(offset int64) error {
 	_, err := f.inner.Seek(f.header.Size()+offset, io.SeekStart)
 	return err
 }
 
 func (f *file) Read(begins uint32, ends uint32, readHandler func(data []byte) error, completedHandler func(inconsistency bool) error) error {
 	if begins > 0 {
-		if err := f.Seek(int64(begins)); err != nil {
+		if err := f.SeekTo(int64(begins)); err != nil {
 			return err
 		}
 	}
@@ -176,18 +182,77 @@ func (f *file) Id() string {
 	return f.sha512Hex
 }
 
-func (f *file) Usage() uint16 {
+func (f *file) Usage() uint64 {
 	return f.header.Usage()
 }
 
 func (f *file) IncreaseUsage() error {
+	if !f.header.Fits(f.header.Usage() + 1) {
+		if err := f.upgrade(); err != nil {
+			return err
+		}
+	}
 	return f.header.IncreaseUsage()
 }
 
-func (f *file) ResetUsage(usage uint16) error {
+func (f *file) ResetUsage(usage uint64) error {
+	if !f.header.Fits(usage) {
+		if err := f.upgrade(); err != nil {
+			return err
+		}
+	}
 	return f.header.ResetUsage(usage)
 }
 
+// upgrade rewrites the block file with the current header version. The upgraded copy replaces the block file
+// with rename, so the readers of the legacy file and the snapshot links of it stay intact
+func (f *file) upgrade() error {
+	if !f.header.Legacy() || f.Temporary() {
+		return nil
+	}
+
+	upgradePath := fmt.Sprintf("%s%s", f.targetPath, upgradeSuffix)
+	u, err := os.OpenFile(upgradePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+
+	header := NewFileHeader(u)
+	header.usage = f.header.Usage()
+
+	if err := func() error {
+		if err := header.save(); err != nil {
+			return err
+		}
+		if _, err := f.inner.Seek(f.header.Size(), io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.Copy(u, f.inner); err != nil {
+			return err
+		}
+		// the upgraded copy should be on the disk before it replaces the block file
+		if err := u.Sync(); err != nil {
+			return err
+		}
+		if err := os.Rename(upgradePath, f.targetPath); err != nil {
+			return err
+		}
+		return syncDir(path.Dir(f.targetPath))
+	}(); err != nil {
+		_ = u.Close()
+		_ = os.Remove(upgradePath)
+		return err
+	}
+
+	_ = f.inner.Close()
+
+	f.inner = u
+	f.header = header
+
+	_, err = f.inner.Seek(f.header.Size(), io.SeekStart)
+	return err
+}
+
 func (f *file) Size() (uint32, error) {
 	info, err := f.inner.Stat()
 	if err != nil {
@@ -250,6 +315,10 @@ func (f *file) move(source string, target string) error {
 	}
 	defer func() { _ = sourceFile.Close() }()
 
+	if err := os.MkdirAll(path.Dir(target), 0777); err != nil {
+		return err
+	}
+
 	targetFile, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
@@ -263,4 +332,15 @@ func (f *file) move(source string, target string) error {
 	return nil
 }
 
+// syncDir flushes the directory entries, so the renamed file is kept with its new name after a crash
+func syncDir(dirPath string) error {
+	d, err := os.Open(dirPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = d.Close() }()
+
+	return d.Sync()
+}
+
 var _ File = &file{}
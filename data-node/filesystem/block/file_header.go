@@ -1,48 +1,87 @@
 package block
 
 import (
+	"bytes"
 	"encoding/binary"
 	"io"
+	"math"
 	"os"
 )
 
-const headerSize int64 = 2
+// HeaderVersion is the current version of the block file header
+const HeaderVersion byte = 2
 
+const legacyHeaderSize int64 = 2 // usage (2)
+const headerSize int64 = 16      // magic (4) + version (1) + reserved (3) + usage (8)
+
+// headerMagic starts the versioned block file header. Legacy headers never start with zero usage, so the magic
+// can not be confused with the legacy usage
+var headerMagic = []byte{0x00, 0x00, 'K', 'D'}
+
+// FileHeader keeps the usage of the block at the beginning of the block file. Legacy block files have 2 bytes
+// usage without any version, they are readable as they are and upgraded to the current version when the usage
+// does not fit or the header migration reaches to them
 type FileHeader struct {
 	inner *os.File
 
-	usage uint16 // 2 bytes
+	version byte
+	usage   uint64
 }
 
 func NewFileHeader(file *os.File) *FileHeader {
 	return &FileHeader{
-		inner: file,
-		usage: 1,
+		inner:   file,
+		version: HeaderVersion,
+		usage:   1,
 	}
 }
 
 func (h *FileHeader) Size() int64 {
+	if h.Legacy() {
+		return legacyHeaderSize
+	}
 	return headerSize
 }
 
+func (h *FileHeader) Version() byte {
+	return h.version
+}
+
+// Legacy checks if the header is in the unversioned 2 bytes usage format
+func (h *FileHeader) Legacy() bool {
+	return h.version < HeaderVersion
+}
+
+// Fits checks if the usage can be kept in the header without the upgrade
+func (h *FileHeader) Fits(usage uint64) bool {
+	return !h.Legacy() || usage <= math.MaxUint16
+}
+
 func (h *FileHeader) Load() error {
-	if _, err := h.inner.Seek(0, io.SeekStart); err != nil {
+	header := make([]byte, headerSize)
+	n, err := h.inner.ReadAt(header, 0)
+	if err != nil && err != io.EOF {
 		return err
 	}
 
-	var usage uint16
-	if err := binary.Read(h.inner, binary.LittleEndian, &usage); err != nil {
-		if err == io.EOF {
-			return h.save()
-		}
-		return err
+	switch {
+	case n == 0:
+		return h.save()
+	case n == int(headerSize) && bytes.Equal(header[:len(headerMagic)], headerMagic):
+		h.version = header[len(headerMagic)]
+		h.usage = binary.LittleEndian.Uint64(header[8:16])
+	case n >= int(legacyHeaderSize):
+		h.version = 1
+		h.usage = uint64(binary.LittleEndian.Uint16(header[:legacyHeaderSize]))
+	default:
+		return io.ErrUnexpectedEOF
 	}
-	h.usage = usage
 
-	return nil
+	_, err = h.inner.Seek(h.Size(), io.SeekStart)
+	return err
 }
 
-func (h *FileHeader) Usage() uint16 {
+func (h *FileHeader) Usage() uint64 {
 	return h.usage
 }
 
@@ -60,7 +99,7 @@ func (h *FileHeader) DecreaseUsage() error {
 	return h.save()
 }
 
-func (h *FileHeader) ResetUsage(usage uint16) error {
+func (h *FileHeader) ResetUsage(usage uint64) error {
 	if usage < 1 {
 		usage = 1
 	}
@@ -74,9 +113,18 @@ func (h *FileHeader) save() error {
 		return err
 	}
 
-	if err := binary.Write(h.inner, binary.LittleEndian, h.usage); err != nil {
-		return err
+	if h.Legacy() {
+		return binary.Write(h.inner, binary.LittleEndian, uint16(h.usage))
 	}
 
-	return nil
+	_, err := h.inner.Write(h.bytes())
+	return err
+}
+
+func (h *FileHeader) bytes() []byte {
+	header := make([]byte, headerSize)
+	copy(header, headerMagic)
+	header[len(headerMagic)] = h.version
+	binary.LittleEndian.PutUint64(header[8:16], h.usage)
+	return header
 }
@@ -1,7 +1,11 @@
 package block
 
 import (
+	"fmt"
 	"os"
+	"path"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/freakmaxi/kertish-dos/data-node/common"
@@ -14,26 +18,109 @@ type Manager interface {
 	LockFile(sha512Hex string, fileHandler func(file File) error) error
 
 	Traverse(hexHandler func(sha512Hex string, size uint64) error) error
+	Migrate() error
+	Upgrade() error
 
 	Wipe() error
 }
 
+// MaxPackLimit is the highest chunk size that can be packed in to the volume files
+const MaxPackLimit uint32 = 1024 * 1024 * 16 // 16mb
+
+// Options defines how the block files are placed in the data path
+type Options struct {
+	// Depth is the fan-out directory level of the standalone block files. RecordedDepth keeps the layout of the data path
+	Depth int
+	// PackLimit is the biggest chunk size to be packed in to the volume files. 0 disables packing
+	PackLimit uint32
+}
+
+// RecordedDepth keeps the block files in the layout that is recorded in the data path, the data path without layout
+// file is flat. Blocks are migrated only if a depth is set explicitly
+const RecordedDepth = -1
+
+// layoutFile keeps the fan-out directory depth that the block files of the data path are placed in
+const layoutFile = "layout"
+
+// headerFile keeps the block header version that all the block files of the data path are upgraded to
+const headerFile = "header"
+
+// locker keeps the block level locks of the manager
+type locker struct {
+	blockLockMutex sync.Mutex
+	blockLock      map[string]*sync.Mutex
+}
+
+func newLocker() locker {
+	return locker{
+		blockLockMutex: sync.Mutex{},
+		blockLock:      make(map[string]*sync.Mutex),
+	}
+}
+
+func (l *locker) lock(sha512Hex string) {
+	l.blockLockMutex.Lock()
+	m, has := l.blockLock[sha512Hex]
+	if !has {
+		m = &sync.Mutex{}
+		l.blockLock[sha512Hex] = m
+	}
+	l.blockLockMutex.Unlock()
+
+	m.Lock()
+}
+
+func (l *locker) unlock(sha512Hex string) {
+	l.blockLockMutex.Lock()
+	defer l.blockLockMutex.Unlock()
+
+	l.blockLock[sha512Hex].Unlock()
+}
+
 type manager struct {
+	locker
+
 	dataPath string
+	depth    int
 	logger   *zap.Logger
 
-	blockLockMutex sync.Mutex
-	blockLock      map[string]*sync.Mutex
+	migratingMutex sync.Mutex
+	migrating      bool
+	upgrading      bool
+}
+
+// NewManager creates the Manager interface for file operation handling. Block files are placed in the fan-out
+// directories of the depth. If the data path is created with another depth, files are found in their
+// current location until Migrate moves them. Chunks up to the pack limit are packed in to the volume files
+func NewManager(dataPath string, options Options, logger *zap.Logger) (Manager, error) {
+	m, err := newManager(dataPath, options.Depth, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	packed := options.PackLimit > 0
+	if !packed {
+		// volumes that are created before should stay accessible
+		packed, err = hasVolumes(dataPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !packed {
+		return m, nil
+	}
+
+	return newPackedManager(m, options.PackLimit)
 }
 
-// NewManager creates the Manager interface for file operation handling
-func NewManager(dataPath string, logger *zap.Logger) (Manager, error) {
+func newManager(dataPath string, depth int, logger *zap.Logger) (*manager, error) {
 	m := &manager{
+		locker:   newLocker(),
 		dataPath: dataPath,
+		depth:    depth,
 		logger:   logger,
 
-		blockLockMutex: sync.Mutex{},
-		blockLock:      make(map[string]*sync.Mutex),
+		migratingMutex: sync.Mutex{},
 	}
 
 	if err := m.prepare(); err != nil {
@@ -44,37 +131,143 @@ func NewManager(dataPath string, logger *zap.Logger) (Manager, error) {
 }
 
 func (m *manager) prepare() error {
+	if m.depth == RecordedDepth {
+		depth, err := m.readLayout()
+		if err != nil {
+			return err
+		}
+		if depth < 0 {
+			depth = 0
+		}
+		m.depth = depth
+	}
+
 	_, err := os.Stat(m.dataPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return os.MkdirAll(m.dataPath, 0777)
+			if err := os.MkdirAll(m.dataPath, 0777); err != nil {
+				return err
+			}
+			if err := m.saveLayout(); err != nil {
+				return err
+			}
+			return m.saveHeaderVersion()
 		}
 		return err
 	}
+
+	depth, err := m.readLayout()
+	if err != nil {
+		return err
+	}
+	m.migrating = depth != m.depth
+
+	version, err := m.readHeaderVersion()
+	if err != nil {
+		return err
+	}
+	m.upgrading = version < HeaderVersion
+
+	if m.migrating || m.upgrading {
+		// empty data path does not have anything to migrate
+		infos, err := os.ReadDir(m.dataPath)
+		if err != nil {
+			return err
+		}
+		if len(infos) == 0 {
+			m.migrating = false
+			m.upgrading = false
+			if err := m.saveLayout(); err != nil {
+				return err
+			}
+			return m.saveHeaderVersion()
+		}
+	}
+
 	return nil
 }
 
-func (m *manager) lock(sha512Hex string) {
-	m.blockLockMutex.Lock()
-	l, has := m.blockLock[sha512Hex]
-	if !has {
-		l = &sync.Mutex{}
-		m.blockLock[sha512Hex] = l
+// readLayout returns the depth of the data path layout. The data path without layout file is flat
+func (m *manager) readLayout() (int, error) {
+	content, err := os.ReadFile(path.Join(m.dataPath, layoutFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return -1, err
 	}
-	m.blockLockMutex.Unlock()
 
-	l.Lock()
+	depth, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return -1, nil
+	}
+	return depth, nil
 }
 
-func (m *manager) unlock(sha512Hex string) {
-	m.blockLockMutex.Lock()
-	defer m.blockLockMutex.Unlock()
+func (m *manager) saveLayout() error {
+	return os.WriteFile(path.Join(m.dataPath, layoutFile), []byte(strconv.Itoa(m.depth)), 0666)
+}
 
-	m.blockLock[sha512Hex].Unlock()
+// readHeaderVersion returns the block header version of the data path. The data path without header file may
+// have legacy block files
+func (m *manager) readHeaderVersion() (byte, error) {
+	content, err := os.ReadFile(path.Join(m.dataPath, headerFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	version, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 8)
+	if err != nil {
+		return 0, nil
+	}
+	return byte(version), nil
+}
+
+func (m *manager) saveHeaderVersion() error {
+	return os.WriteFile(path.Join(m.dataPath, headerFile), []byte(strconv.Itoa(int(HeaderVersion))), 0666)
+}
+
+// legacy marks the data path to have legacy block files, they are upgraded in the next header migration
+func (m *manager) legacy() error {
+	m.migratingMutex.Lock()
+	defer m.migratingMutex.Unlock()
+
+	if m.upgrading {
+		return nil
+	}
+	m.upgrading = true
+
+	if err := os.Remove(path.Join(m.dataPath, headerFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (m *manager) isUpgrading() bool {
+	m.migratingMutex.Lock()
+	defer m.migratingMutex.Unlock()
+
+	return m.upgrading
+}
+
+func (m *manager) isMigrating() bool {
+	m.migratingMutex.Lock()
+	defer m.migratingMutex.Unlock()
+
+	return m.migrating
 }
 
 func (m *manager) File(sha512Hex string, fileHandler func(file File) error) error {
-	file, err := NewFile(m.dataPath, sha512Hex, m.logger)
+	if m.isMigrating() {
+		if err := m.locate(sha512Hex); err != nil {
+			return err
+		}
+	}
+
+	file, err := NewFile(m.dataPath, sha512Hex, m.depth, m.logger)
 	if err != nil {
 		return err
 	}
@@ -91,7 +284,7 @@ func (m *manager) LockFile(sha512Hex string, fileHandler func(file File) error)
 }
 
 func (m *manager) Traverse(hexHandler func(sha512Hex string, size uint64) error) error {
-	return common.Traverse(m.dataPath, func(info os.FileInfo) error {
+	return common.Traverse(m.dataPath, func(_ string, info os.FileInfo) error {
 		sha512Hex := info.Name()
 
 		m.lock(sha512Hex)
@@ -107,7 +300,7 @@ func (m *manager) compileSha512HexList() ([]string, error) {
 
 	sha512HexList := make([]string, 0)
 
-	if err := common.Traverse(m.dataPath, func(info os.FileInfo) error {
+	if err := common.Traverse(m.dataPath, func(_ string, info os.FileInfo) error {
 		sha512HexList = append(sha512HexList, info.Name())
 		return nil
 	}); err != nil {
@@ -138,4 +331,243 @@ func (m *manager) Wipe() error {
 	return nil
 }
 
+func (m *manager) blockPath(sha512Hex string) string {
+	return common.BlockPath(m.dataPath, sha512Hex, m.depth)
+}
+
+// exists checks if the standalone block file is in the data path
+func (m *manager) exists(sha512Hex string) (bool, error) {
+	if m.isMigrating() {
+		if err := m.locate(sha512Hex); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := os.Stat(m.blockPath(sha512Hex)); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// locate moves the block file to the current layout if it is still in the location of another depth
+func (m *manager) locate(sha512Hex string) error {
+	targetPath := m.blockPath(sha512Hex)
+	if _, err := os.Stat(targetPath); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+
+	for depth := 0; depth <= common.MaxLayoutDepth; depth++ {
+		if depth == m.depth {
+			continue
+		}
+
+		sourcePath := common.BlockPath(m.dataPath, sha512Hex, depth)
+		if _, err := os.Stat(sourcePath); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		return m.relocate(sourcePath, sha512Hex)
+	}
+
+	return nil
+}
+
+// relocate renames the block file in to the location of the current layout. Rename keeps the opened
+// file handles valid, so it is safe to run while the file is read
+func (m *manager) relocate(sourcePath string, sha512Hex string) error {
+	targetPath := m.blockPath(sha512Hex)
+	if strings.Compare(sourcePath, targetPath) == 0 {
+		return nil
+	}
+
+	if _, err := os.Stat(targetPath); err == nil {
+		m.logger.Warn(
+			"Block file exists in both layouts, dropping the one in the old location",
+			zap.String("sha512Hex", sha512Hex),
+			zap.String("path", sourcePath),
+		)
+		if err := os.Remove(sourcePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(path.Dir(targetPath), 0777); err != nil {
+		return err
+	}
+
+	if err := os.Rename(sourcePath, targetPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Migrate moves the block files of the data path in to the current layout while the node keeps serving.
+// Files that are requested during the migration are moved on demand
+func (m *manager) Migrate() error {
+	if !m.isMigrating() {
+		return nil
+	}
+
+	m.logger.Info(fmt.Sprintf("Migrating block layout of %s to depth %d...", m.dataPath, m.depth))
+
+	moved := 0
+	if err := common.Traverse(m.dataPath, func(filePath string, info os.FileInfo) error {
+		sha512Hex := info.Name()
+
+		m.lock(sha512Hex)
+		defer m.unlock(sha512Hex)
+
+		if strings.Compare(filePath, m.blockPath(sha512Hex)) == 0 {
+			return nil
+		}
+
+		if err := m.relocate(filePath, sha512Hex); err != nil {
+			return err
+		}
+		moved++
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := common.Prune(m.dataPath, m.depth); err != nil {
+		return err
+	}
+
+	if err := m.saveLayout(); err != nil {
+		return err
+	}
+
+	m.migratingMutex.Lock()
+	m.migrating = false
+	m.migratingMutex.Unlock()
+
+	m.logger.Info(fmt.Sprintf("Block layout migration of %s is completed, %d files are moved", m.dataPath, moved))
+
+	return nil
+}
+
+// Upgrade rewrites the legacy block files of the data path with the current header version while the node keeps
+// serving. Files that need a wider usage before the upgrade reaches them are upgraded on demand
+func (m *manager) Upgrade() error {
+	if !m.isUpgrading() {
+		return nil
+	}
+
+	m.logger.Info(fmt.Sprintf("Upgrading block headers of %s to version %d...", m.dataPath, HeaderVersion))
+
+	if err := m.sweepUpgrades(); err != nil {
+		return err
+	}
+
+	upgraded := 0
+	if err := m.Traverse(func(sha512Hex string, _ uint64) error {
+		return m.File(sha512Hex, func(blockFile File) error {
+			f, ok := blockFile.(*file)
+			if !ok || f.Temporary() || !f.header.Legacy() {
+				return nil
+			}
+
+			if err := f.upgrade(); err != nil {
+				return err
+			}
+			upgraded++
+
+			return nil
+		})
+	}); err != nil {
+		return err
+	}
+
+	if err := m.saveHeaderVersion(); err != nil {
+		return err
+	}
+
+	m.migratingMutex.Lock()
+	m.upgrading = false
+	m.migratingMutex.Unlock()
+
+	m.logger.Info(fmt.Sprintf("Block header upgrade of %s is completed, %d files are upgraded", m.dataPath, upgraded))
+
+	return nil
+}
+
+// sweepUpgrades removes the upgraded copies that are left by the interrupted upgrades. The block file is replaced
+// only after its upgraded copy is completed, so the leftovers are incomplete and the block file is still intact
+func (m *manager) sweepUpgrades() error {
+	return common.TraverseSuffix(m.dataPath, upgradeSuffix, func(filePath string, info os.FileInfo) error {
+		sha512Hex := strings.TrimSuffix(info.Name(), upgradeSuffix)
+
+		m.lock(sha512Hex)
+		defer m.unlock(sha512Hex)
+
+		m.logger.Warn("Removing the leftover of the interrupted block header upgrade", zap.String("path", filePath))
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// Clone places the block of the source manager in to the target manager. Standalone block files are hard linked,
+// packed blocks are copied
+func Clone(source Manager, target Manager, sha512Hex string) error {
+	return source.File(sha512Hex, func(sourceFile File) error {
+		if sourceFile.Temporary() {
+			return os.ErrNotExist
+		}
+
+		if sf, ok := sourceFile.(*file); ok {
+			if tm := localManager(target); tm != nil {
+				if sf.header.Legacy() {
+					if err := tm.legacy(); err != nil {
+						return err
+					}
+				}
+
+				targetPath := tm.blockPath(sha512Hex)
+				if err := os.MkdirAll(path.Dir(targetPath), 0777); err != nil {
+					return err
+				}
+				return os.Link(sf.targetPath, targetPath)
+			}
+		}
+
+		return target.LockFile(sha512Hex, func(targetFile File) error {
+			if !targetFile.Temporary() {
+				return targetFile.ResetUsage(sourceFile.Usage())
+			}
+
+			if err := sourceFile.Read(0, 0, targetFile.Write, func(_ bool) error { return nil }); err != nil {
+				return err
+			}
+			if err := targetFile.ResetUsage(sourceFile.Usage()); err != nil {
+				return err
+			}
+			if !targetFile.Verify() {
+				return fmt.Errorf("cloned block is not verified: %s", sha512Hex)
+			}
+			return nil
+		})
+	})
+}
+
+func localManager(m Manager) *manager {
+	switch t := m.(type) {
+	case *manager:
+		return t
+	case *packedManager:
+		return t.manager
+	}
+	return nil
+}
+
 var _ Manager = &manager{}
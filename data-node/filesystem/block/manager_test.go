@@ -0,0 +1,253 @@
+package block
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/freakmaxi/kertish-dos/data-node/common"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestManager_Migrate(t *testing.T) {
+	dataPath := path.Join(t.TempDir(), "data")
+
+	m0, err := newManager(dataPath, 0, zap.NewNop())
+	assert.Nil(t, err)
+	assert.False(t, m0.isMigrating())
+
+	first := writeTestBlock(t, m0, []byte("first block"))
+	second := writeTestBlock(t, m0, []byte("second block"))
+	assert.FileExists(t, common.BlockPath(dataPath, first, 0))
+	assert.FileExists(t, common.BlockPath(dataPath, second, 0))
+
+	// 0 -> 2, requested blocks are located on demand before the migration
+	m2, err := newManager(dataPath, 2, zap.NewNop())
+	assert.Nil(t, err)
+	assert.True(t, m2.isMigrating())
+
+	assert.Equal(t, []byte("first block"), readTestBlock(t, m2, first))
+	assert.FileExists(t, common.BlockPath(dataPath, first, 2))
+	assert.NoFileExists(t, common.BlockPath(dataPath, first, 0))
+	assert.FileExists(t, common.BlockPath(dataPath, second, 0))
+
+	assert.Nil(t, m2.Migrate())
+	assert.False(t, m2.isMigrating())
+	assert.FileExists(t, common.BlockPath(dataPath, second, 2))
+	assert.NoFileExists(t, common.BlockPath(dataPath, second, 0))
+	assert.Equal(t, 2, readTestLayout(t, m2))
+
+	// 2 -> 1, deeper fan-out directories are pruned
+	m1, err := newManager(dataPath, 1, zap.NewNop())
+	assert.Nil(t, err)
+	assert.True(t, m1.isMigrating())
+
+	assert.Nil(t, m1.Migrate())
+	assert.False(t, m1.isMigrating())
+	assert.Equal(t, 1, readTestLayout(t, m1))
+
+	for _, sha512Hex := range []string{first, second} {
+		assert.FileExists(t, common.BlockPath(dataPath, sha512Hex, 1))
+		assert.NoFileExists(t, common.BlockPath(dataPath, sha512Hex, 2))
+		assert.NoDirExists(t, path.Dir(common.BlockPath(dataPath, sha512Hex, 2)))
+	}
+	assert.Equal(t, []byte("first block"), readTestBlock(t, m1, first))
+	assert.Equal(t, []byte("second block"), readTestBlock(t, m1, second))
+
+	// completed migration is not repeated
+	m1, err = newManager(dataPath, 1, zap.NewNop())
+	assert.Nil(t, err)
+	assert.False(t, m1.isMigrating())
+}
+
+func TestManager_RecordedDepth(t *testing.T) {
+	dataPath := path.Join(t.TempDir(), "data")
+
+	// new data path without an explicit depth is flat
+	m, err := newManager(dataPath, RecordedDepth, zap.NewNop())
+	assert.Nil(t, err)
+	assert.False(t, m.isMigrating())
+	assert.Equal(t, 0, readTestLayout(t, m))
+
+	sha512Hex := writeTestBlock(t, m, []byte("recorded block"))
+	assert.FileExists(t, common.BlockPath(dataPath, sha512Hex, 0))
+
+	// data path of the previous versions does not have the layout file
+	assert.Nil(t, os.Remove(path.Join(dataPath, layoutFile)))
+	m, err = newManager(dataPath, RecordedDepth, zap.NewNop())
+	assert.Nil(t, err)
+	assert.False(t, m.isMigrating())
+	assert.Equal(t, 0, m.depth)
+
+	// explicit depth migrates and the recorded depth keeps the migrated layout
+	m2, err := newManager(dataPath, 2, zap.NewNop())
+	assert.Nil(t, err)
+	assert.True(t, m2.isMigrating())
+	assert.Nil(t, m2.Migrate())
+
+	m, err = newManager(dataPath, RecordedDepth, zap.NewNop())
+	assert.Nil(t, err)
+	assert.False(t, m.isMigrating())
+	assert.Equal(t, 2, m.depth)
+	assert.Equal(t, []byte("recorded block"), readTestBlock(t, m, sha512Hex))
+}
+
+func TestManager_LocateBothLayouts(t *testing.T) {
+	dataPath := path.Join(t.TempDir(), "data")
+
+	m0, err := newManager(dataPath, 0, zap.NewNop())
+	assert.Nil(t, err)
+	sha512Hex := writeTestBlock(t, m0, []byte("duplicated block"))
+
+	oldPath := common.BlockPath(dataPath, sha512Hex, 0)
+	newPath := common.BlockPath(dataPath, sha512Hex, 1)
+	assert.Nil(t, os.MkdirAll(path.Dir(newPath), 0777))
+	assert.Nil(t, os.Link(oldPath, newPath))
+
+	m1, err := newManager(dataPath, 1, zap.NewNop())
+	assert.Nil(t, err)
+
+	exists, err := m1.exists(sha512Hex)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.FileExists(t, newPath)
+
+	// the block in the current layout is served, the copy in the old location is left to the migration
+	assert.Nil(t, m1.locate(sha512Hex))
+	assert.Equal(t, []byte("duplicated block"), readTestBlock(t, m1, sha512Hex))
+	assert.FileExists(t, oldPath)
+
+	assert.Nil(t, m1.Migrate())
+	assert.FileExists(t, newPath)
+	assert.NoFileExists(t, oldPath)
+	assert.Equal(t, []byte("duplicated block"), readTestBlock(t, m1, sha512Hex))
+}
+
+func TestManager_Relocate(t *testing.T) {
+	dataPath := path.Join(t.TempDir(), "data")
+
+	m0, err := newManager(dataPath, 0, zap.NewNop())
+	assert.Nil(t, err)
+	sha512Hex := writeTestBlock(t, m0, []byte("relocated block"))
+	oldPath := common.BlockPath(dataPath, sha512Hex, 0)
+
+	// already in place
+	assert.Nil(t, m0.relocate(oldPath, sha512Hex))
+	assert.FileExists(t, oldPath)
+
+	m2, err := newManager(dataPath, 2, zap.NewNop())
+	assert.Nil(t, err)
+	assert.Nil(t, m2.relocate(oldPath, sha512Hex))
+	assert.NoFileExists(t, oldPath)
+	assert.FileExists(t, common.BlockPath(dataPath, sha512Hex, 2))
+
+	// source is already moved by another request
+	assert.Nil(t, m2.relocate(oldPath, sha512Hex))
+	assert.FileExists(t, common.BlockPath(dataPath, sha512Hex, 2))
+}
+
+func TestManager_TraverseSkipsSnapshots(t *testing.T) {
+	dataPath := path.Join(t.TempDir(), "data")
+
+	m, err := newManager(dataPath, 1, zap.NewNop())
+	assert.Nil(t, err)
+	sha512Hex := writeTestBlock(t, m, []byte("block"))
+
+	snapshotPath := common.BlockPath(path.Join(dataPath, "snapshot.20200101120000"), sha512Hex, 1)
+	assert.Nil(t, os.MkdirAll(path.Dir(snapshotPath), 0777))
+	assert.Nil(t, os.Link(common.BlockPath(dataPath, sha512Hex, 1), snapshotPath))
+
+	found := make([]string, 0)
+	assert.Nil(t, m.Traverse(func(hex string, _ uint64) error {
+		found = append(found, hex)
+		return nil
+	}))
+	assert.Equal(t, []string{sha512Hex}, found)
+}
+
+func TestManager_InterruptedUpgrade(t *testing.T) {
+	dataPath := path.Join(t.TempDir(), "data")
+
+	m, err := newManager(dataPath, 1, zap.NewNop())
+	assert.Nil(t, err)
+	assert.Nil(t, os.Remove(path.Join(dataPath, headerFile)))
+
+	// legacy block file with 2 bytes usage
+	data := []byte("legacy block")
+	sum := sha512.Sum512_256(data)
+	sha512Hex := hex.EncodeToString(sum[:])
+
+	blockPath := common.BlockPath(dataPath, sha512Hex, 1)
+	legacy := append([]byte{3, 0}, data...)
+	assert.Nil(t, os.MkdirAll(path.Dir(blockPath), 0777))
+	assert.Nil(t, os.WriteFile(blockPath, legacy, 0666))
+
+	// the node is stopped while the upgraded copy is written
+	upgradePath := blockPath + upgradeSuffix
+	assert.Nil(t, os.WriteFile(upgradePath, NewFileHeader(nil).bytes()[:10], 0666))
+
+	m, err = newManager(dataPath, 1, zap.NewNop())
+	assert.Nil(t, err)
+	assert.True(t, m.isUpgrading())
+
+	// block file is intact and readable till the upgrade reaches it
+	assert.Equal(t, data, readTestBlock(t, m, sha512Hex))
+
+	assert.Nil(t, m.Upgrade())
+	assert.False(t, m.isUpgrading())
+	assert.NoFileExists(t, upgradePath)
+
+	assert.Nil(t, m.File(sha512Hex, func(blockFile File) error {
+		f := blockFile.(*file)
+		assert.False(t, f.header.Legacy())
+		assert.Equal(t, uint64(3), f.Usage())
+		return nil
+	}))
+	assert.Equal(t, data, readTestBlock(t, m, sha512Hex))
+
+	content, err := os.ReadFile(blockPath)
+	assert.Nil(t, err)
+	assert.Equal(t, int(headerSize)+len(data), len(content))
+}
+
+func writeTestBlock(t *testing.T, m Manager, data []byte) string {
+	sum := sha512.Sum512_256(data)
+	sha512Hex := hex.EncodeToString(sum[:])
+
+	err := m.LockFile(sha512Hex, func(file File) error {
+		assert.True(t, file.Temporary())
+		if err := file.Write(data); err != nil {
+			return err
+		}
+		assert.True(t, file.Verify())
+		return nil
+	})
+	assert.Nil(t, err)
+
+	return sha512Hex
+}
+
+func readTestBlock(t *testing.T, m Manager, sha512Hex string) []byte {
+	content := &bytes.Buffer{}
+
+	err := m.File(sha512Hex, func(file File) error {
+		assert.False(t, file.Temporary())
+		return file.Read(0, 0, func(data []byte) error {
+			_, err := content.Write(data)
+			return err
+		}, func(_ bool) error { return nil })
+	})
+	assert.Nil(t, err)
+
+	return content.Bytes()
+}
+
+func readTestLayout(t *testing.T, m *manager) int {
+	depth, err := m.readLayout()
+	assert.Nil(t, err)
+	return depth
+}
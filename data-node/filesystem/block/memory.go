@@ -0,0 +1,104 @@
+package block
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+type memoryRecord struct {
+	data  []byte
+	usage uint64
+}
+
+// memoryStore keeps the blocks in memory. It is volatile and suitable for tests
+type memoryStore struct {
+	mutex   sync.RWMutex
+	records map[string]*memoryRecord
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		mutex:   sync.RWMutex{},
+		records: make(map[string]*memoryRecord),
+	}
+}
+
+func (s *memoryStore) stat(sha512Hex string) (uint32, uint64, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	r, has := s.records[sha512Hex]
+	if !has {
+		return 0, 0, false, nil
+	}
+	return uint32(len(r.data)), r.usage, true, nil
+}
+
+func (s *memoryStore) readAt(sha512Hex string, offset int64, p []byte) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	r, has := s.records[sha512Hex]
+	if !has {
+		return 0, os.ErrNotExist
+	}
+
+	if offset >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	return copy(p, r.data[offset:]), nil
+}
+
+func (s *memoryStore) setUsage(sha512Hex string, usage uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	r, has := s.records[sha512Hex]
+	if !has {
+		return os.ErrNotExist
+	}
+
+	if usage == 0 {
+		delete(s.records, sha512Hex)
+		return nil
+	}
+	r.usage = usage
+
+	return nil
+}
+
+func (s *memoryStore) put(sha512Hex string, usage uint64, data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	d := make([]byte, len(data))
+	copy(d, data)
+
+	s.records[sha512Hex] = &memoryRecord{
+		data:  d,
+		usage: usage,
+	}
+	return nil
+}
+
+func (s *memoryStore) entries() (map[string]uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sizes := make(map[string]uint64)
+	for sha512Hex, r := range s.records {
+		sizes[sha512Hex] = uint64(len(r.data))
+	}
+	return sizes, nil
+}
+
+func (s *memoryStore) wipe() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.records = make(map[string]*memoryRecord)
+	return nil
+}
+
+var _ recordStore = &memoryStore{}
@@ -0,0 +1,101 @@
+package block
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// objectStore keeps the blocks as the objects under the prefix of the S3 compatible object storage. Usage of
+// the block is kept in the object metadata
+type objectStore struct {
+	client *objectClient
+	prefix string
+}
+
+func newObjectStore(client *objectClient, prefix string) *objectStore {
+	return &objectStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (s *objectStore) key(sha512Hex string) string {
+	if len(s.prefix) == 0 {
+		return sha512Hex
+	}
+	return path.Join(s.prefix, sha512Hex)
+}
+
+func (s *objectStore) stat(sha512Hex string) (uint32, uint64, bool, error) {
+	size, header, exists, err := s.client.head(s.key(sha512Hex))
+	if err != nil || !exists {
+		return 0, 0, false, err
+	}
+
+	usage, err := strconv.ParseUint(header.Get(objectUsageMeta), 10, 64)
+	if err != nil || usage == 0 {
+		usage = 1
+	}
+
+	return uint32(size), usage, true, nil
+}
+
+func (s *objectStore) readAt(sha512Hex string, offset int64, p []byte) (int, error) {
+	return s.client.get(s.key(sha512Hex), offset, p)
+}
+
+func (s *objectStore) setUsage(sha512Hex string, usage uint64) error {
+	if usage == 0 {
+		return s.client.delete(s.key(sha512Hex))
+	}
+
+	if err := s.client.replaceUsage(s.key(sha512Hex), usage); err != nil {
+		if _, _, exists, statErr := s.stat(sha512Hex); statErr == nil && !exists {
+			return os.ErrNotExist
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *objectStore) put(sha512Hex string, usage uint64, data []byte) error {
+	return s.client.put(s.key(sha512Hex), data, usage)
+}
+
+func (s *objectStore) entries() (map[string]uint64, error) {
+	listPrefix := ""
+	if len(s.prefix) > 0 {
+		listPrefix = s.prefix + "/"
+	}
+
+	sizes := make(map[string]uint64)
+	if err := s.client.list(listPrefix, func(key string, size int64) error {
+		sha512Hex := strings.TrimPrefix(key, listPrefix)
+		if len(sha512Hex) != 64 {
+			return nil
+		}
+		sizes[sha512Hex] = uint64(size)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return sizes, nil
+}
+
+func (s *objectStore) wipe() error {
+	sizes, err := s.entries()
+	if err != nil {
+		return err
+	}
+
+	for sha512Hex := range sizes {
+		if err := s.client.delete(s.key(sha512Hex)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ recordStore = &objectStore{}
@@ -0,0 +1,347 @@
+package block
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const objectRequestTimeout = time.Second * 30
+const objectUsageMeta = "x-amz-meta-usage"
+
+// ObjectConfig is the connection details of the S3 compatible object storage
+type ObjectConfig struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string
+}
+
+// objectClient is the minimal S3 compatible object storage client with the path-style addressing and
+// the signature version 4
+type objectClient struct {
+	endpoint *url.URL
+	config   ObjectConfig
+	client   *http.Client
+}
+
+type objectListResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func newObjectClient(config ObjectConfig) (*objectClient, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoint.Scheme) == 0 || len(endpoint.Host) == 0 {
+		return nil, fmt.Errorf("object storage endpoint should be in scheme://host[:port] format")
+	}
+	if len(config.Bucket) == 0 {
+		return nil, fmt.Errorf("object storage bucket should be specified")
+	}
+	if len(config.Region) == 0 {
+		config.Region = "us-east-1"
+	}
+
+	return &objectClient{
+		endpoint: endpoint,
+		config:   config,
+		client:   &http.Client{Timeout: objectRequestTimeout},
+	}, nil
+}
+
+func (c *objectClient) head(key string) (int64, http.Header, bool, error) {
+	resp, err := c.do(http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return 0, nil, false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, nil, false, nil
+	}
+	if err := c.check(resp); err != nil {
+		return 0, nil, false, err
+	}
+
+	return resp.ContentLength, resp.Header, true, nil
+}
+
+// get reads the object in the range. Returns io.EOF if the offset is not in the object
+func (c *objectClient) get(key string, offset int64, p []byte) (int, error) {
+	headers := map[string]string{
+		"range": fmt.Sprintf("bytes=%d-%d", offset, offset+int64(len(p))-1),
+	}
+
+	resp, err := c.do(http.MethodGet, key, nil, headers, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		return 0, io.EOF
+	}
+	if err := c.check(resp); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(resp.Body, p)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		return n, nil
+	}
+	return n, err
+}
+
+func (c *objectClient) put(key string, data []byte, usage uint64) error {
+	headers := map[string]string{
+		objectUsageMeta: strconv.FormatUint(usage, 10),
+	}
+
+	resp, err := c.do(http.MethodPut, key, nil, headers, data)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return c.check(resp)
+}
+
+// replaceUsage copies the object on to itself with the new usage metadata
+func (c *objectClient) replaceUsage(key string, usage uint64) error {
+	headers := map[string]string{
+		"x-amz-copy-source":        fmt.Sprintf("/%s/%s", c.config.Bucket, key),
+		"x-amz-metadata-directive": "REPLACE",
+		objectUsageMeta:            strconv.FormatUint(usage, 10),
+	}
+
+	resp, err := c.do(http.MethodPut, key, nil, headers, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	return c.check(resp)
+}
+
+func (c *objectClient) delete(key string) error {
+	resp, err := c.do(http.MethodDelete, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return c.check(resp)
+}
+
+// list walks the objects directly under the prefix. Objects in the sub-prefixes are not listed
+func (c *objectClient) list(prefix string, objectHandler func(key string, size int64) error) error {
+	continuationToken := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		query.Set("prefix", prefix)
+		query.Set("delimiter", "/")
+		if len(continuationToken) > 0 {
+			query.Set("continuation-token", continuationToken)
+		}
+
+		result, err := c.listPage(query)
+		if err != nil {
+			return err
+		}
+
+		for _, content := range result.Contents {
+			if err := objectHandler(content.Key, content.Size); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated || len(result.NextContinuationToken) == 0 {
+			return nil
+		}
+		continuationToken = result.NextContinuationToken
+	}
+}
+
+func (c *objectClient) listPage(query url.Values) (*objectListResult, error) {
+	resp, err := c.do(http.MethodGet, "", query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if err := c.check(resp); err != nil {
+		return nil, err
+	}
+
+	result := &objectListResult{}
+	if err := xml.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (c *objectClient) check(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf(
+		"object storage request (%s %s) is failed with %d: %s",
+		resp.Request.Method,
+		resp.Request.URL.Path,
+		resp.StatusCode,
+		strings.TrimSpace(string(body)),
+	)
+}
+
+func (c *objectClient) do(method string, key string, query url.Values, headers map[string]string, body []byte) (*http.Response, error) {
+	objectPath := fmt.Sprintf("/%s", c.config.Bucket)
+	if len(key) > 0 {
+		objectPath = fmt.Sprintf("%s/%s", objectPath, key)
+	}
+
+	u := *c.endpoint
+	u.Path = objectPath
+	u.RawPath = objectURIEncode(objectPath, false)
+	u.RawQuery = objectCanonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	c.sign(req, u.RawPath, u.RawQuery, body)
+
+	return c.client.Do(req)
+}
+
+// sign adds the signature version 4 authorization to the request
+func (c *objectClient) sign(req *http.Request, canonicalURI string, canonicalQuery string, body []byte) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256.Sum256(body)
+	payloadHashHex := hex.EncodeToString(payloadHash[:])
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHashHex)
+
+	signedHeaderMap := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lowerName := strings.ToLower(name)
+		if lowerName == "range" || strings.HasPrefix(lowerName, "x-amz-") {
+			signedHeaderMap[lowerName] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	signedHeaderNames := make([]string, 0, len(signedHeaderMap))
+	for name := range signedHeaderMap {
+		signedHeaderNames = append(signedHeaderNames, name)
+	}
+	sort.Strings(signedHeaderNames)
+
+	canonicalHeaders := strings.Builder{}
+	for _, name := range signedHeaderNames {
+		canonicalHeaders.WriteString(fmt.Sprintf("%s:%s\n", name, signedHeaderMap[name]))
+	}
+	signedHeaders := strings.Join(signedHeaderNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		canonicalQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHashHex,
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, c.config.Region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+
+	signingKey := objectHMAC([]byte(fmt.Sprintf("AWS4%s", c.config.SecretKey)), date)
+	signingKey = objectHMAC(signingKey, c.config.Region)
+	signingKey = objectHMAC(signingKey, "s3")
+	signingKey = objectHMAC(signingKey, "aws4_request")
+	signature := hex.EncodeToString(objectHMAC(signingKey, stringToSign))
+
+	req.Header.Set(
+		"Authorization",
+		fmt.Sprintf(
+			"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+			c.config.AccessKey, scope, signedHeaders, signature,
+		),
+	)
+}
+
+func objectHMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func objectCanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", objectURIEncode(key, true), objectURIEncode(query.Get(key), true)))
+	}
+	return strings.Join(pairs, "&")
+}
+
+// objectURIEncode encodes the value as described in the signature version 4 specification
+func objectURIEncode(value string, encodeSlash bool) string {
+	encoded := strings.Builder{}
+	for _, b := range []byte(value) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') ||
+			b == '-' || b == '_' || b == '.' || b == '~' || (b == '/' && !encodeSlash) {
+			encoded.WriteByte(b)
+			continue
+		}
+		encoded.WriteString(fmt.Sprintf("%%%02X", b))
+	}
+	return encoded.String()
+}
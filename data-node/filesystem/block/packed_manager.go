@@ -0,0 +1,105 @@
+package block
+
+import (
+	"fmt"
+)
+
+// packedManager keeps the chunks up to the pack limit in the volume files of the data path to avoid creating
+// a file for each small chunk. Bigger chunks are kept as standalone block files
+type packedManager struct {
+	*manager
+
+	store     *volumeStore
+	packLimit uint32
+}
+
+func newPackedManager(m *manager, packLimit uint32) (Manager, error) {
+	store, err := openVolumeStore(m.dataPath, m.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &packedManager{
+		manager:   m,
+		store:     store,
+		packLimit: packLimit,
+	}, nil
+}
+
+func (p *packedManager) File(sha512Hex string, fileHandler func(file File) error) error {
+	file, err := p.file(sha512Hex)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return fileHandler(file)
+}
+
+func (p *packedManager) file(sha512Hex string) (File, error) {
+	file, err := newRecordFile(p.store, sha512Hex, p.logger)
+	if err != nil {
+		return nil, err
+	}
+	if file.exists {
+		return file, nil
+	}
+
+	exists, err := p.exists(sha512Hex)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return NewFile(p.dataPath, sha512Hex, p.depth, p.logger)
+	}
+
+	file.spiller = p
+	file.limit = p.packLimit
+
+	return file, nil
+}
+
+func (p *packedManager) LockFile(sha512Hex string, fileHandler func(file File) error) error {
+	p.lock(sha512Hex)
+	defer p.unlock(sha512Hex)
+
+	return p.File(sha512Hex, fileHandler)
+}
+
+func (p *packedManager) Traverse(hexHandler func(sha512Hex string, size uint64) error) error {
+	if err := traverseRecords(p.store, &p.locker, hexHandler); err != nil {
+		return err
+	}
+	return p.manager.Traverse(hexHandler)
+}
+
+// Upgrade rewrites the legacy block files and compacts the legacy volumes of the data path while the node keeps
+// serving
+func (p *packedManager) Upgrade() error {
+	if err := p.manager.Upgrade(); err != nil {
+		return err
+	}
+	p.store.upgrade()
+
+	return nil
+}
+
+func (p *packedManager) Wipe() error {
+	if err := p.manager.Wipe(); err != nil {
+		return err
+	}
+
+	if err := p.store.wipe(); err != nil {
+		return fmt.Errorf("unable to wipe volumes: %s", err)
+	}
+
+	return nil
+}
+
+// spill creates the standalone block file for the chunk that exceeds the pack limit
+func (p *packedManager) spill(sha512Hex string) (File, error) {
+	return NewFile(p.dataPath, sha512Hex, p.depth, p.logger)
+}
+
+var _ Manager = &packedManager{}
+var _ spiller = &packedManager{}
@@ -0,0 +1,354 @@
+package block
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// recordStore keeps the blocks as records that are written at once. Usage 0 removes the record
+type recordStore interface {
+	stat(sha512Hex string) (length uint32, usage uint64, exists bool, err error)
+	readAt(sha512Hex string, offset int64, p []byte) (int, error)
+	setUsage(sha512Hex string, usage uint64) error
+	put(sha512Hex string, usage uint64, data []byte) error
+
+	entries() (map[string]uint64, error)
+	wipe() error
+}
+
+// spiller places the records that exceed the limit in to the standalone block files
+type spiller interface {
+	spill(sha512Hex string) (File, error)
+	exists(sha512Hex string) (bool, error)
+}
+
+// recordFile provides the file interface for the block that is kept in a record store. New blocks are buffered
+// in memory till they are closed and spilled in to a standalone block file if they exceed the limit
+type recordFile struct {
+	store     recordStore
+	spiller   spiller
+	limit     uint32
+	logger    *zap.Logger
+	sha512Hex string
+
+	exists bool
+	length uint32
+
+	sha512   hash.Hash
+	buffer   []byte
+	writing  bool
+	usage    uint64
+	position int64
+	verified bool
+	canceled bool
+
+	spill File
+}
+
+func newRecordFile(store recordStore, sha512Hex string, logger *zap.Logger) (*recordFile, error) {
+	length, usage, exists, err := store.stat(sha512Hex)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		usage = 1
+	}
+
+	return &recordFile{
+		store:     store,
+		logger:    logger,
+		sha512Hex: sha512Hex,
+		exists:    exists,
+		length:    length,
+		sha512:    sha512.New512_256(),
+		buffer:    make([]byte, 0),
+		usage:     usage,
+		verified:  exists,
+	}, nil
+}
+
+func (f *recordFile) Temporary() bool {
+	if f.spill != nil && !f.exists {
+		return f.spill.Temporary()
+	}
+	return !f.exists
+}
+
+func (f *recordFile) rewrite() {
+	f.writing = true
+	f.verified = false
+	f.buffer = make([]byte, 0)
+	f.sha512.Reset()
+}
+
+func (f *recordFile) Write(data []byte) error {
+	if f.spill != nil {
+		return f.spill.Write(data)
+	}
+
+	if !f.writing {
+		f.rewrite()
+	}
+	f.verified = false
+
+	if _, err := f.sha512.Write(data); err != nil {
+		return err
+	}
+	f.buffer = append(f.buffer, data...)
+
+	if f.spiller == nil || uint32(len(f.buffer)) <= f.limit {
+		return nil
+	}
+
+	spill, err := f.spiller.spill(f.sha512Hex)
+	if err != nil {
+		return err
+	}
+	f.spill = spill
+
+	if err := f.spill.Write(f.buffer); err != nil {
+		return err
+	}
+	f.buffer = make([]byte, 0)
+
+	return f.spill.ResetUsage(f.usage)
+}
+
+func (f *recordFile) Verify() bool {
+	if f.spill != nil {
+		return f.spill.Verify()
+	}
+
+	if f.verified || !f.writing {
+		return f.verified
+	}
+
+	result := hex.EncodeToString(f.sha512.Sum(nil))
+	f.verified = strings.Compare(result, f.sha512Hex) == 0
+	return f.verified
+}
+
+func (f *recordFile) VerifyForce() bool {
+	if f.spill != nil {
+		return f.spill.VerifyForce()
+	}
+
+	if f.writing {
+		f.verified = false
+		return f.Verify()
+	}
+
+	if !f.exists {
+		return false
+	}
+
+	h := sha512.New512_256()
+	if err := f.Read(0, 0,
+		func(data []byte) error {
+			_, err := h.Write(data)
+			return err
+		}, func(_ bool) error {
+			return nil
+		}); err != nil {
+		return false
+	}
+
+	f.verified = strings.Compare(hex.EncodeToString(h.Sum(nil)), f.sha512Hex) == 0
+	return f.verified
+}
+
+func (f *recordFile) SeekTo(offset int64) error {
+	if f.spill != nil {
+		return f.spill.SeekTo(offset)
+	}
+
+	f.position = offset
+	return nil
+}
+
+func (f *recordFile) Read(begins uint32, ends uint32, readHandler func(data []byte) error, completedHandler func(inconsistency bool) error) error {
+	if f.spill != nil {
+		return f.spill.Read(begins, ends, readHandler, completedHandler)
+	}
+
+	if !f.exists {
+		return completedHandler(ends > 0)
+	}
+
+	offset := f.position
+	if begins > 0 {
+		offset = int64(begins)
+	}
+
+	total := ^uint32(0) >> 1
+	if ends > 0 {
+		total = ends - begins
+	}
+
+	buffer := make([]byte, chunkSize)
+	for total > 0 {
+		size := len(buffer)
+		if total < uint32(size) {
+			size = int(total)
+		}
+
+		s, err := f.store.readAt(f.sha512Hex, offset, buffer[:size])
+		if s > 0 {
+			if err := readHandler(buffer[:s]); err != nil {
+				return err
+			}
+			offset += int64(s)
+			total -= uint32(s)
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return completedHandler(ends > 0 && total != 0)
+			}
+			return err
+		}
+	}
+	f.position = offset
+
+	return completedHandler(ends > 0 && total != 0)
+}
+
+func (f *recordFile) Id() string {
+	return f.sha512Hex
+}
+
+func (f *recordFile) Usage() uint64 {
+	if f.spill != nil {
+		return f.spill.Usage()
+	}
+	return f.usage
+}
+
+func (f *recordFile) IncreaseUsage() error {
+	if f.spill != nil {
+		return f.spill.IncreaseUsage()
+	}
+
+	return f.ResetUsage(f.usage + 1)
+}
+
+func (f *recordFile) ResetUsage(usage uint64) error {
+	if usage < 1 {
+		usage = 1
+	}
+
+	if f.spill != nil {
+		return f.spill.ResetUsage(usage)
+	}
+
+	if f.exists && !f.writing {
+		if err := f.store.setUsage(f.sha512Hex, usage); err != nil {
+			return err
+		}
+	}
+	f.usage = usage
+
+	return nil
+}
+
+func (f *recordFile) Size() (uint32, error) {
+	if f.spill != nil {
+		return f.spill.Size()
+	}
+
+	if f.writing {
+		return uint32(len(f.buffer)), nil
+	}
+	return f.length, nil
+}
+
+func (f *recordFile) Delete() error {
+	if f.spill != nil {
+		return f.spill.Delete()
+	}
+
+	if !f.exists {
+		return nil
+	}
+
+	if f.usage <= 1 {
+		return f.Wipe()
+	}
+
+	if err := f.store.setUsage(f.sha512Hex, f.usage-1); err != nil {
+		return err
+	}
+	f.usage--
+
+	return nil
+}
+
+func (f *recordFile) Wipe() error {
+	if f.spill != nil {
+		if err := f.spill.Wipe(); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if !f.exists {
+		return nil
+	}
+	return f.remove()
+}
+
+func (f *recordFile) remove() error {
+	if err := f.store.setUsage(f.sha512Hex, 0); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (f *recordFile) Truncate(blockSize uint32) error {
+	if f.spill != nil {
+		return f.spill.Truncate(blockSize)
+	}
+
+	f.rewrite()
+	f.usage = 1
+
+	return nil
+}
+
+func (f *recordFile) Cancel() {
+	f.canceled = true
+
+	if f.spill != nil {
+		f.spill.Cancel()
+	}
+}
+
+func (f *recordFile) Close() {
+	if f.spill != nil {
+		f.spill.Close()
+
+		// record is replaced by the standalone block file
+		if f.exists {
+			exists, err := f.spiller.exists(f.sha512Hex)
+			if err == nil && exists {
+				_ = f.remove()
+			}
+		}
+		return
+	}
+
+	if !f.writing || !f.verified || f.canceled {
+		return
+	}
+
+	if err := f.store.put(f.sha512Hex, f.usage, f.buffer); err != nil {
+		f.logger.Error("File creation is failed silently", zap.String("sha512Hex", f.sha512Hex), zap.Error(err))
+	}
+}
+
+var _ File = &recordFile{}
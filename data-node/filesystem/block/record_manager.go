@@ -0,0 +1,77 @@
+package block
+
+import (
+	"go.uber.org/zap"
+)
+
+// recordManager provides the Manager interface for the blocks that are kept in a record store
+type recordManager struct {
+	locker
+
+	store  recordStore
+	logger *zap.Logger
+}
+
+func newRecordManager(store recordStore, logger *zap.Logger) Manager {
+	return &recordManager{
+		locker: newLocker(),
+		store:  store,
+		logger: logger,
+	}
+}
+
+func (r *recordManager) File(sha512Hex string, fileHandler func(file File) error) error {
+	file, err := newRecordFile(r.store, sha512Hex, r.logger)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return fileHandler(file)
+}
+
+func (r *recordManager) LockFile(sha512Hex string, fileHandler func(file File) error) error {
+	r.lock(sha512Hex)
+	defer r.unlock(sha512Hex)
+
+	return r.File(sha512Hex, fileHandler)
+}
+
+func (r *recordManager) Traverse(hexHandler func(sha512Hex string, size uint64) error) error {
+	return traverseRecords(r.store, &r.locker, hexHandler)
+}
+
+// Migrate does nothing, record stores do not have a directory layout
+func (r *recordManager) Migrate() error {
+	return nil
+}
+
+// Upgrade does nothing, record stores keep the usage in its full size
+func (r *recordManager) Upgrade() error {
+	return nil
+}
+
+func (r *recordManager) Wipe() error {
+	return r.store.wipe()
+}
+
+func traverseRecords(store recordStore, l *locker, hexHandler func(sha512Hex string, size uint64) error) error {
+	sizes, err := store.entries()
+	if err != nil {
+		return err
+	}
+
+	for sha512Hex, size := range sizes {
+		if err := func() error {
+			l.lock(sha512Hex)
+			defer l.unlock(sha512Hex)
+
+			return hexHandler(sha512Hex, size)
+		}(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ Manager = &recordManager{}
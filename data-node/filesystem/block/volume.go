@@ -0,0 +1,603 @@
+package block
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const volumePrefix = "volume."
+const volumeSizeLimit int64 = 1024 * 1024 * 1024      // 1gb
+const volumeHeaderSize int64 = 8                      // magic (7) + version (1)
+const volumeRecordHeaderSize int64 = 32 + 8 + 4       // hash + usage + length
+const legacyVolumeRecordHeaderSize int64 = 32 + 2 + 4 // hash + usage + length
+
+// volumeMagic starts the versioned volume file. Legacy volumes start with the record hash directly
+var volumeMagic = []byte{'K', 'D', 'V', 'O', 'L', 0x00, 0x00}
+
+// volume is considered for compaction when the deleted records reach to the half of its size
+const volumeCompactionRatio = 0.5
+
+var volumeStoresMutex = sync.Mutex{}
+var volumeStores = make(map[string]*volumeStore)
+
+type volumeEntry struct {
+	volume uint32
+	offset int64
+	length uint32
+	usage  uint64
+}
+
+type volume struct {
+	id      uint32
+	file    *os.File
+	size    int64
+	garbage int64
+	legacy  bool
+}
+
+func (v *volume) recordHeaderSize() int64 {
+	if v.legacy {
+		return legacyVolumeRecordHeaderSize
+	}
+	return volumeRecordHeaderSize
+}
+
+// volumeStore keeps the small block files appended in to the large volume files. Every record in the volume
+// starts with the hash, the usage and the length of the block and followed by the block data. Index of the
+// records is created on load by scanning the volumes. Legacy volumes keep the usage in 2 bytes, they are
+// readable as they are and new records are always appended to the versioned volumes
+type volumeStore struct {
+	mutex sync.RWMutex
+
+	dataPath string
+	logger   *zap.Logger
+
+	volumes map[uint32]*volume
+	active  uint32
+	index   map[string]*volumeEntry
+
+	compactingMutex sync.Mutex
+	compacting      map[uint32]bool
+}
+
+// openVolumeStore provides the shared volume store of the data path
+func openVolumeStore(dataPath string, logger *zap.Logger) (*volumeStore, error) {
+	volumeStoresMutex.Lock()
+	defer volumeStoresMutex.Unlock()
+
+	if s, has := volumeStores[dataPath]; has {
+		return s, nil
+	}
+
+	s := &volumeStore{
+		dataPath:        dataPath,
+		logger:          logger,
+		volumes:         make(map[uint32]*volume),
+		index:           make(map[string]*volumeEntry),
+		compactingMutex: sync.Mutex{},
+		compacting:      make(map[uint32]bool),
+	}
+
+	if err := s.load(); err != nil {
+		s.close()
+		return nil, err
+	}
+	volumeStores[dataPath] = s
+
+	return s, nil
+}
+
+// Release closes the volume store of the data path. It should be called when the data path is removed
+func Release(dataPath string) {
+	volumeStoresMutex.Lock()
+	defer volumeStoresMutex.Unlock()
+
+	s, has := volumeStores[dataPath]
+	if !has {
+		return
+	}
+	s.close()
+
+	delete(volumeStores, dataPath)
+}
+
+func hasVolumes(dataPath string) (bool, error) {
+	ids, err := volumeIds(dataPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return len(ids) > 0, nil
+}
+
+func volumeIds(dataPath string) ([]uint32, error) {
+	infos, err := os.ReadDir(dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint32, 0)
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, volumePrefix) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(name[len(volumePrefix):], 10, 32)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, uint32(id))
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	return ids, nil
+}
+
+func (s *volumeStore) volumePath(id uint32) string {
+	return path.Join(s.dataPath, fmt.Sprintf("%s%d", volumePrefix, id))
+}
+
+func (s *volumeStore) load() error {
+	ids, err := volumeIds(s.dataPath)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		f, err := os.OpenFile(s.volumePath(id), os.O_RDWR, 0666)
+		if err != nil {
+			return err
+		}
+
+		v := &volume{id: id, file: f}
+		s.volumes[id] = v
+
+		if err := s.scan(v); err != nil {
+			return err
+		}
+
+		if id > s.active {
+			s.active = id
+		}
+	}
+
+	return nil
+}
+
+// scan reads the record headers of the volume and fills the index. Incomplete record at the end of the volume
+// is the result of an interrupted write, it is truncated
+func (s *volumeStore) scan(v *volume) error {
+	info, err := v.file.Stat()
+	if err != nil {
+		return err
+	}
+	volumeSize := info.Size()
+
+	offset := int64(0)
+	v.legacy = true
+
+	if volumeSize >= volumeHeaderSize {
+		volumeHeader := make([]byte, volumeHeaderSize)
+		if _, err := v.file.ReadAt(volumeHeader, 0); err != nil {
+			return err
+		}
+		if bytes.Equal(volumeHeader[:len(volumeMagic)], volumeMagic) {
+			v.legacy = false
+			offset = volumeHeaderSize
+		}
+	}
+
+	recordHeaderSize := v.recordHeaderSize()
+	header := make([]byte, recordHeaderSize)
+	for offset < volumeSize {
+		if offset+recordHeaderSize > volumeSize {
+			break
+		}
+		if _, err := v.file.ReadAt(header, offset); err != nil {
+			return err
+		}
+
+		sha512Hex := hex.EncodeToString(header[:32])
+		usage, length := decodeRecordHeader(v.legacy, header)
+
+		recordSize := recordHeaderSize + int64(length)
+		if offset+recordSize > volumeSize {
+			break
+		}
+
+		// record in the later position is the latest copy of the block (compaction, replacement). The deleted
+		// one also drops the earlier copies, they can be still alive if the previous compaction was interrupted
+		if previous, has := s.index[sha512Hex]; has {
+			pv := s.volumes[previous.volume]
+			pv.garbage += pv.recordHeaderSize() + int64(previous.length)
+			delete(s.index, sha512Hex)
+		}
+
+		if usage == 0 {
+			v.garbage += recordSize
+		} else {
+			s.index[sha512Hex] = &volumeEntry{
+				volume: v.id,
+				offset: offset,
+				length: length,
+				usage:  usage,
+			}
+		}
+
+		offset += recordSize
+	}
+
+	if offset < volumeSize {
+		s.logger.Warn(
+			fmt.Sprintf("Volume %d has an incomplete record at the end, truncating", v.id),
+			zap.String("dataPath", s.dataPath),
+			zap.Int64("offset", offset),
+		)
+		if err := v.file.Truncate(offset); err != nil {
+			return err
+		}
+	}
+	v.size = offset
+
+	return nil
+}
+
+func decodeRecordHeader(legacy bool, header []byte) (uint64, uint32) {
+	if legacy {
+		return uint64(binary.LittleEndian.Uint16(header[32:34])), binary.LittleEndian.Uint32(header[34:38])
+	}
+	return binary.LittleEndian.Uint64(header[32:40]), binary.LittleEndian.Uint32(header[40:44])
+}
+
+func (s *volumeStore) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, v := range s.volumes {
+		_ = v.file.Close()
+	}
+	s.volumes = make(map[uint32]*volume)
+	s.index = make(map[string]*volumeEntry)
+}
+
+func (s *volumeStore) stat(sha512Hex string) (uint32, uint64, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	e, has := s.index[sha512Hex]
+	if !has {
+		return 0, 0, false, nil
+	}
+	return e.length, e.usage, true, nil
+}
+
+// readAt reads the block data starting from the offset. Returns io.EOF when the offset reaches to the end
+func (s *volumeStore) readAt(sha512Hex string, offset int64, p []byte) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	e, has := s.index[sha512Hex]
+	if !has {
+		return 0, os.ErrNotExist
+	}
+
+	remaining := int64(e.length) - offset
+	if remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	v := s.volumes[e.volume]
+	return v.file.ReadAt(p, e.offset+v.recordHeaderSize()+offset)
+}
+
+// setUsage updates the usage of the record in place. Usage 0 deletes the record. The record in the legacy volume
+// is moved to the versioned volume if the usage does not fit in it
+func (s *volumeStore) setUsage(sha512Hex string, usage uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, has := s.index[sha512Hex]
+	if !has {
+		return os.ErrNotExist
+	}
+
+	if s.volumes[e.volume].legacy && usage > math.MaxUint16 {
+		return s.relocate(sha512Hex, e, usage)
+	}
+
+	if err := s.writeUsage(e, usage); err != nil {
+		return err
+	}
+	e.usage = usage
+
+	if usage == 0 {
+		s.drop(sha512Hex, e)
+	}
+
+	return nil
+}
+
+func (s *volumeStore) writeUsage(e *volumeEntry, usage uint64) error {
+	v := s.volumes[e.volume]
+
+	usageBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(usageBytes, usage)
+	if v.legacy {
+		usageBytes = usageBytes[:2]
+	}
+
+	_, err := v.file.WriteAt(usageBytes, e.offset+32)
+	return err
+}
+
+// relocate appends the record to the active volume with the usage and drops the previous one
+func (s *volumeStore) relocate(sha512Hex string, e *volumeEntry, usage uint64) error {
+	v := s.volumes[e.volume]
+
+	data := make([]byte, e.length)
+	if _, err := v.file.ReadAt(data, e.offset+v.recordHeaderSize()); err != nil {
+		return err
+	}
+
+	if err := s.append(sha512Hex, usage, data); err != nil {
+		return err
+	}
+
+	if err := s.writeUsage(e, 0); err != nil {
+		return err
+	}
+	v.garbage += v.recordHeaderSize() + int64(e.length)
+
+	return nil
+}
+
+// drop removes the record from the index and schedules the compaction of the volume if it is required
+func (s *volumeStore) drop(sha512Hex string, e *volumeEntry) {
+	delete(s.index, sha512Hex)
+
+	v := s.volumes[e.volume]
+	v.garbage += v.recordHeaderSize() + int64(e.length)
+
+	if v.id == s.active || float64(v.garbage) < float64(v.size)*volumeCompactionRatio {
+		return
+	}
+
+	s.compactingMutex.Lock()
+	defer s.compactingMutex.Unlock()
+
+	if s.compacting[v.id] {
+		return
+	}
+	s.compacting[v.id] = true
+
+	go s.compact(v.id)
+}
+
+// put appends the block to the active volume. If the block is already in the store, previous record is deleted
+func (s *volumeStore) put(sha512Hex string, usage uint64, data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous, has := s.index[sha512Hex]
+
+	if err := s.append(sha512Hex, usage, data); err != nil {
+		return err
+	}
+
+	if has {
+		if err := s.writeUsage(previous, 0); err != nil {
+			return err
+		}
+		pv := s.volumes[previous.volume]
+		pv.garbage += pv.recordHeaderSize() + int64(previous.length)
+	}
+
+	return nil
+}
+
+func (s *volumeStore) append(sha512Hex string, usage uint64, data []byte) error {
+	sha512HexBytes, err := hex.DecodeString(sha512Hex)
+	if err != nil {
+		return err
+	}
+
+	recordSize := volumeRecordHeaderSize + int64(len(data))
+
+	v, has := s.volumes[s.active]
+	if !has || v.legacy || (v.size > volumeHeaderSize && v.size+recordSize > volumeSizeLimit) {
+		v, err = s.createVolume(s.active + 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	record := make([]byte, recordSize)
+	copy(record, sha512HexBytes)
+	binary.LittleEndian.PutUint64(record[32:40], usage)
+	binary.LittleEndian.PutUint32(record[40:44], uint32(len(data)))
+	copy(record[volumeRecordHeaderSize:], data)
+
+	if _, err := v.file.WriteAt(record, v.size); err != nil {
+		// drop the partial write, volume size stays as it is
+		_ = v.file.Truncate(v.size)
+		return err
+	}
+
+	s.index[sha512Hex] = &volumeEntry{
+		volume: v.id,
+		offset: v.size,
+		length: uint32(len(data)),
+		usage:  usage,
+	}
+	v.size += recordSize
+
+	return nil
+}
+
+func (s *volumeStore) createVolume(id uint32) (*volume, error) {
+	f, err := os.OpenFile(s.volumePath(id), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, volumeHeaderSize)
+	copy(header, volumeMagic)
+	header[len(volumeMagic)] = HeaderVersion
+
+	if _, err := f.WriteAt(header, 0); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+
+	v := &volume{id: id, file: f, size: volumeHeaderSize}
+	s.volumes[id] = v
+	s.active = id
+
+	return v, nil
+}
+
+// compact moves the live records of the volume to the active volume one by one and removes the volume
+func (s *volumeStore) compact(id uint32) {
+	defer func() {
+		s.compactingMutex.Lock()
+		defer s.compactingMutex.Unlock()
+
+		delete(s.compacting, id)
+	}()
+
+	s.logger.Info(fmt.Sprintf("Compacting volume %d...", id), zap.String("dataPath", s.dataPath))
+
+	s.mutex.RLock()
+	sha512HexList := make([]string, 0)
+	for sha512Hex, e := range s.index {
+		if e.volume == id {
+			sha512HexList = append(sha512HexList, sha512Hex)
+		}
+	}
+	s.mutex.RUnlock()
+
+	for _, sha512Hex := range sha512HexList {
+		if err := s.move(id, sha512Hex); err != nil {
+			s.logger.Error(
+				fmt.Sprintf("Compacting volume %d is failed", id),
+				zap.String("dataPath", s.dataPath),
+				zap.String("sha512Hex", sha512Hex),
+				zap.Error(err),
+			)
+			return
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, has := s.volumes[id]
+	if !has {
+		return
+	}
+	_ = v.file.Close()
+	delete(s.volumes, id)
+
+	if err := os.Remove(s.volumePath(id)); err != nil {
+		s.logger.Error(fmt.Sprintf("Removing compacted volume %d is failed", id), zap.String("dataPath", s.dataPath), zap.Error(err))
+		return
+	}
+
+	s.logger.Info(fmt.Sprintf("Volume %d is compacted", id), zap.String("dataPath", s.dataPath))
+}
+
+// upgrade compacts the legacy volumes, so their records are moved to the versioned volumes
+func (s *volumeStore) upgrade() {
+	s.mutex.RLock()
+	legacyIds := make([]uint32, 0)
+	for id, v := range s.volumes {
+		if v.legacy {
+			legacyIds = append(legacyIds, id)
+		}
+	}
+	s.mutex.RUnlock()
+	sort.Slice(legacyIds, func(i, j int) bool { return legacyIds[i] < legacyIds[j] })
+
+	for _, id := range legacyIds {
+		s.compactingMutex.Lock()
+		if s.compacting[id] {
+			s.compactingMutex.Unlock()
+			continue
+		}
+		s.compacting[id] = true
+		s.compactingMutex.Unlock()
+
+		s.compact(id)
+	}
+}
+
+func (s *volumeStore) move(id uint32, sha512Hex string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, has := s.index[sha512Hex]
+	if !has || e.volume != id {
+		return nil
+	}
+
+	v := s.volumes[id]
+
+	data := make([]byte, e.length)
+	if _, err := v.file.ReadAt(data, e.offset+v.recordHeaderSize()); err != nil {
+		return err
+	}
+
+	if err := s.append(sha512Hex, e.usage, data); err != nil {
+		return err
+	}
+
+	// the moved record should not come back if the compaction is interrupted before the volume is removed
+	return s.writeUsage(e, 0)
+}
+
+func (s *volumeStore) entries() (map[string]uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sizes := make(map[string]uint64)
+	for sha512Hex, e := range s.index {
+		sizes[sha512Hex] = uint64(s.volumes[e.volume].recordHeaderSize()) + uint64(e.length)
+	}
+	return sizes, nil
+}
+
+func (s *volumeStore) wipe() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for id, v := range s.volumes {
+		_ = v.file.Close()
+		if err := os.Remove(s.volumePath(id)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(s.volumes, id)
+	}
+	s.index = make(map[string]*volumeEntry)
+
+	return nil
+}
+
+var _ recordStore = &volumeStore{}
@@ -0,0 +1,230 @@
+package block
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestVolumeStore_PutRead(t *testing.T) {
+	s, dataPath := openTestVolumeStore(t)
+
+	first, firstData := testRecord("first record")
+	second, secondData := testRecord("second record")
+
+	assert.Nil(t, s.put(first, 1, firstData))
+	assert.Nil(t, s.put(second, 3, secondData))
+
+	length, usage, has, err := s.stat(first)
+	assert.Nil(t, err)
+	assert.True(t, has)
+	assert.Equal(t, uint32(len(firstData)), length)
+	assert.Equal(t, uint64(1), usage)
+
+	assert.Equal(t, firstData, readTestRecord(t, s, first))
+	assert.Equal(t, secondData, readTestRecord(t, s, second))
+
+	// partial read from the offset
+	p := make([]byte, 6)
+	n, err := s.readAt(second, 7, p)
+	assert.Nil(t, err)
+	assert.Equal(t, "record", string(p[:n]))
+
+	_, err = s.readAt(second, int64(len(secondData)), p)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = s.readAt(hex.EncodeToString(make([]byte, 32)), 0, p)
+	assert.Equal(t, os.ErrNotExist, err)
+
+	// replacement keeps the latest copy after reopen
+	replaced := []byte("first record")
+	assert.Nil(t, s.put(first, 2, replaced))
+
+	s = reopenTestVolumeStore(t, dataPath)
+
+	_, usage, has, err = s.stat(first)
+	assert.Nil(t, err)
+	assert.True(t, has)
+	assert.Equal(t, uint64(2), usage)
+	assert.Equal(t, replaced, readTestRecord(t, s, first))
+	assert.Equal(t, secondData, readTestRecord(t, s, second))
+}
+
+func TestVolumeStore_SetUsage(t *testing.T) {
+	s, dataPath := openTestVolumeStore(t)
+
+	first, firstData := testRecord("first record")
+	second, secondData := testRecord("second record")
+
+	assert.Nil(t, s.put(first, 1, firstData))
+	assert.Nil(t, s.put(second, 1, secondData))
+
+	assert.Nil(t, s.setUsage(first, 70000))
+	assert.Nil(t, s.setUsage(second, 0))
+	assert.Equal(t, os.ErrNotExist, s.setUsage(second, 1))
+
+	_, _, has, err := s.stat(second)
+	assert.Nil(t, err)
+	assert.False(t, has)
+
+	s = reopenTestVolumeStore(t, dataPath)
+
+	_, usage, has, err := s.stat(first)
+	assert.Nil(t, err)
+	assert.True(t, has)
+	assert.Equal(t, uint64(70000), usage)
+
+	_, _, has, err = s.stat(second)
+	assert.Nil(t, err)
+	assert.False(t, has)
+
+	entries, err := s.entries()
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, uint64(volumeRecordHeaderSize)+uint64(len(firstData)), entries[first])
+}
+
+func TestVolumeStore_Compact(t *testing.T) {
+	s, dataPath := openTestVolumeStore(t)
+
+	live, liveData := testRecord(string(bytes.Repeat([]byte("l"), 1024)))
+	deleted, deletedData := testRecord("deleted record")
+
+	assert.Nil(t, s.put(live, 2, liveData))
+	assert.Nil(t, s.put(deleted, 1, deletedData))
+	assert.Nil(t, s.setUsage(deleted, 0))
+	sealTestVolume(t, s)
+
+	s.compact(1)
+
+	assert.NoFileExists(t, s.volumePath(1))
+	assert.FileExists(t, s.volumePath(2))
+
+	_, usage, has, err := s.stat(live)
+	assert.Nil(t, err)
+	assert.True(t, has)
+	assert.Equal(t, uint64(2), usage)
+	assert.Equal(t, liveData, readTestRecord(t, s, live))
+
+	s = reopenTestVolumeStore(t, dataPath)
+
+	assert.Equal(t, liveData, readTestRecord(t, s, live))
+	_, _, has, err = s.stat(deleted)
+	assert.Nil(t, err)
+	assert.False(t, has)
+}
+
+func TestVolumeStore_InterruptedCompaction(t *testing.T) {
+	s, dataPath := openTestVolumeStore(t)
+
+	moved, movedData := testRecord("moved record")
+	kept, keptData := testRecord("kept record")
+
+	assert.Nil(t, s.put(moved, 3, movedData))
+	assert.Nil(t, s.put(kept, 1, keptData))
+	sealTestVolume(t, s)
+
+	// compaction moves the record and the block is deleted before the compacted volume is removed
+	assert.Nil(t, s.move(1, moved))
+	assert.Nil(t, s.setUsage(moved, 0))
+	assert.FileExists(t, s.volumePath(1))
+
+	s = reopenTestVolumeStore(t, dataPath)
+
+	_, _, has, err := s.stat(moved)
+	assert.Nil(t, err)
+	assert.False(t, has)
+	assert.Equal(t, keptData, readTestRecord(t, s, kept))
+
+	// the deleted record in the later volume also drops the earlier copy that is left alive
+	assert.Nil(t, s.put(moved, 1, movedData))
+	sealTestVolume(t, s)
+	s.mutex.Lock()
+	assert.Nil(t, s.append(moved, 0, movedData))
+	s.mutex.Unlock()
+
+	s = reopenTestVolumeStore(t, dataPath)
+
+	_, _, has, err = s.stat(moved)
+	assert.Nil(t, err)
+	assert.False(t, has)
+	assert.Equal(t, keptData, readTestRecord(t, s, kept))
+}
+
+func TestVolumeStore_TruncatesIncompleteRecord(t *testing.T) {
+	s, dataPath := openTestVolumeStore(t)
+
+	sha512Hex, data := testRecord("complete record")
+	assert.Nil(t, s.put(sha512Hex, 1, data))
+
+	s.mutex.RLock()
+	size := s.volumes[s.active].size
+	s.mutex.RUnlock()
+
+	f, err := os.OpenFile(s.volumePath(1), os.O_WRONLY|os.O_APPEND, 0666)
+	assert.Nil(t, err)
+	_, err = f.Write(make([]byte, volumeRecordHeaderSize-1))
+	assert.Nil(t, err)
+	_ = f.Close()
+
+	s = reopenTestVolumeStore(t, dataPath)
+
+	info, err := os.Stat(s.volumePath(1))
+	assert.Nil(t, err)
+	assert.Equal(t, size, info.Size())
+	assert.Equal(t, data, readTestRecord(t, s, sha512Hex))
+}
+
+func openTestVolumeStore(t *testing.T) (*volumeStore, string) {
+	dataPath := path.Join(t.TempDir(), "data")
+	assert.Nil(t, os.MkdirAll(dataPath, 0777))
+	t.Cleanup(func() { Release(dataPath) })
+
+	s, err := openVolumeStore(dataPath, zap.NewNop())
+	assert.Nil(t, err)
+
+	return s, dataPath
+}
+
+func reopenTestVolumeStore(t *testing.T, dataPath string) *volumeStore {
+	Release(dataPath)
+
+	s, err := openVolumeStore(dataPath, zap.NewNop())
+	assert.Nil(t, err)
+
+	return s
+}
+
+// sealTestVolume makes the next records to be appended to a new volume
+func sealTestVolume(t *testing.T, s *volumeStore) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err := s.createVolume(s.active + 1)
+	assert.Nil(t, err)
+}
+
+func testRecord(content string) (string, []byte) {
+	data := []byte(content)
+	sum := sha512.Sum512_256(data)
+	return hex.EncodeToString(sum[:]), data
+}
+
+func readTestRecord(t *testing.T, s *volumeStore, sha512Hex string) []byte {
+	length, _, has, err := s.stat(sha512Hex)
+	assert.Nil(t, err)
+	assert.True(t, has)
+
+	p := make([]byte, length)
+	n, err := s.readAt(sha512Hex, 0, p)
+	assert.Nil(t, err)
+
+	return p[:n]
+}
@@ -2,10 +2,10 @@ package filesystem
 
 import (
 	"os"
-	"path"
 	"sync"
 
 	"github.com/freakmaxi/kertish-dos/data-node/filesystem/block"
+	"github.com/freakmaxi/kertish-dos/data-node/throttle"
 	"go.uber.org/zap"
 )
 
@@ -38,27 +38,74 @@ type manager struct {
 	managerMutex sync.Mutex
 }
 
-// NewManager creates the instance of data node operations manager
-func NewManager(rootPath string, logger *zap.Logger) (Manager, error) {
-	b, err := block.NewManager(rootPath, logger)
+// NewManager creates the instance of data node operations manager. Blocks are kept in the backend and the
+// snapshot details are kept under the root path. The existing blocks and snapshots are migrated to the layout
+// and the header version of the backend in background. The background transfers of the sync operations are
+// limited by the throttle
+func NewManager(rootPath string, backend block.Backend, throttle throttle.Governor, logger *zap.Logger) (Manager, error) {
+	if err := os.MkdirAll(rootPath, 0777); err != nil {
+		return nil, err
+	}
+
+	b, err := backend.Manager(rootPath)
 	if err != nil {
 		return nil, err
 	}
 
-	ss := NewSnapshot(rootPath, logger)
-	s, err := NewSynchronize(rootPath, ss, logger)
+	ss := NewSnapshot(rootPath, backend, logger)
+	s, err := NewSynchronize(rootPath, backend, ss, throttle, logger)
 	if err != nil {
 		return nil, err
 	}
 
-	return &manager{
+	m := &manager{
 		rootPath:     rootPath,
 		logger:       logger,
 		block:        b,
 		snapshot:     ss,
 		synchronize:  s,
 		managerMutex: sync.Mutex{},
-	}, nil
+	}
+	go m.migrate()
+
+	return m, nil
+}
+
+func (m *manager) migrate() {
+	if err := m.block.Migrate(); err != nil {
+		m.logger.Error("Block layout migration is failed", zap.Error(err))
+		return
+	}
+
+	snapshotDates, err := m.snapshot.Dates()
+	if err != nil {
+		m.logger.Error("Unable to get snapshot dates for block layout migration", zap.Error(err))
+		return
+	}
+
+	for _, snapshotDate := range snapshotDates {
+		snapshotBlock, err := m.snapshot.Block(snapshotDate)
+		if err != nil {
+			m.logger.Error("Unable to create snapshot block manager for block layout migration", zap.Error(err))
+			return
+		}
+
+		if err := snapshotBlock.Migrate(); err != nil {
+			m.logger.Error("Snapshot block layout migration is failed", zap.Time("snapshot", snapshotDate), zap.Error(err))
+			return
+		}
+
+		if err := m.snapshot.UpgradeHeaderBackup(snapshotDate); err != nil {
+			m.logger.Error("Snapshot header backup upgrade is failed", zap.Time("snapshot", snapshotDate), zap.Error(err))
+			return
+		}
+	}
+
+	// snapshot block files are kept as they are, they share the storage with the legacy block files till the
+	// snapshots are deleted
+	if err := m.block.Upgrade(); err != nil {
+		m.logger.Error("Block header upgrade is failed", zap.Error(err))
+	}
 }
 
 func (m *manager) wait() {
@@ -97,10 +144,7 @@ func (m *manager) Wipe() error {
 	m.managerMutex.Lock()
 	defer m.managerMutex.Unlock()
 
-	if err := m.block.Traverse(func(sha512Hex string, size uint64) error {
-		p := path.Join(m.rootPath, sha512Hex)
-		return os.Remove(p)
-	}); err != nil {
+	if err := m.block.Wipe(); err != nil {
 		return err
 	}
 
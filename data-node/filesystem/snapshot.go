@@ -1,6 +1,7 @@
 package filesystem
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -14,13 +15,17 @@ import (
 	"time"
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
-	dnc "github.com/freakmaxi/kertish-dos/data-node/common"
 	"github.com/freakmaxi/kertish-dos/data-node/filesystem/block"
 	"go.uber.org/zap"
 )
 
 const snapshotPrefix = "snapshot."
 const snapshotHeaderBackupFile = "headers.backup"
+const headerBackupHeaderSize = 8 // magic (7) + version (1)
+
+// headerBackupMagic starts the versioned header backup file that keeps the usages in 8 bytes. Legacy header
+// backup files start with the block hash directly and keep the usages in 2 bytes
+var headerBackupMagic = []byte{'K', 'D', 'H', 'D', 'R', 0x00, 0x00}
 
 type Snapshot interface {
 	Create(targetSnapshot *time.Time) (*time.Time, error)
@@ -31,6 +36,7 @@ type Snapshot interface {
 
 	ReadHeaderBackup(snapshot time.Time) (HeaderMap, error)
 	ReplaceHeaderBackup(snapshot time.Time, headerMap HeaderMap) error
+	UpgradeHeaderBackup(snapshot time.Time) error
 
 	Latest() (*time.Time, error)
 	Dates() (common.Snapshots, error)
@@ -40,88 +46,170 @@ type Snapshot interface {
 	ToUint(snapshot time.Time) uint64
 }
 
-type HeaderMap map[string]uint16
+type HeaderMap map[string]uint64
 
 type snapshot struct {
 	rootPath string
+	backend  block.Backend
 	logger   *zap.Logger
 
 	blocksMutex sync.Mutex
 	blocks      map[time.Time]block.Manager
 }
 
-func NewSnapshot(rootPath string, logger *zap.Logger) Snapshot {
+func NewSnapshot(rootPath string, backend block.Backend, logger *zap.Logger) Snapshot {
 	return &snapshot{
 		rootPath:    rootPath,
+		backend:     backend,
 		logger:      logger,
 		blocksMutex: sync.Mutex{},
 		blocks:      make(map[time.Time]block.Manager),
 	}
 }
 
+func (s *snapshot) headerBackupPath(snapshot time.Time) string {
+	return path.Join(s.rootPath, s.PathName(snapshot), snapshotHeaderBackupFile)
+}
+
 func (s *snapshot) ReadHeaderBackup(snapshot time.Time) (HeaderMap, error) {
-	headerMap := make(HeaderMap)
+	headerMap, _, err := s.readHeaderBackup(snapshot)
+	return headerMap, err
+}
 
-	snapshotPathName := s.PathName(snapshot)
-	snapshotPath := path.Join(s.rootPath, snapshotPathName)
+// readHeaderBackup reads the header backup of the snapshot and returns if it is in the legacy format
+func (s *snapshot) readHeaderBackup(snapshot time.Time) (HeaderMap, bool, error) {
+	headerMap := make(HeaderMap)
 
-	headerBackupFilePath := path.Join(snapshotPath, snapshotHeaderBackupFile)
-	headerFile, err := os.OpenFile(headerBackupFilePath, os.O_RDONLY, 0666)
+	headerFile, err := os.OpenFile(s.headerBackupPath(snapshot), os.O_RDONLY, 0666)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return headerMap, nil
+			return headerMap, false, nil
 		}
-		return nil, err
+		return nil, false, err
 	}
 	defer func() { _ = headerFile.Close() }()
 
-	sha512HexBytes := make([]byte, 32)
-	var usage uint16
+	legacy, err := readHeaderBackupVersion(headerFile)
+	if err != nil {
+		return nil, false, err
+	}
 
+	sha512HexBytes := make([]byte, 32)
 	for {
 		if _, err := io.ReadAtLeast(headerFile, sha512HexBytes, len(sha512HexBytes)); err != nil {
 			if err == io.EOF {
 				break
 			}
-			return nil, err
+			return nil, false, err
 		}
 
-		if err := binary.Read(headerFile, binary.LittleEndian, &usage); err != nil {
-			return nil, err
+		var usage uint64
+		if legacy {
+			var legacyUsage uint16
+			if err := binary.Read(headerFile, binary.LittleEndian, &legacyUsage); err != nil {
+				return nil, false, err
+			}
+			usage = uint64(legacyUsage)
+		} else {
+			if err := binary.Read(headerFile, binary.LittleEndian, &usage); err != nil {
+				return nil, false, err
+			}
 		}
 
 		sha512Hex := hex.EncodeToString(sha512HexBytes)
 		headerMap[sha512Hex] = usage
 	}
 
-	return headerMap, nil
+	return headerMap, legacy, nil
+}
+
+// readHeaderBackupVersion checks if the header backup file is in the legacy format and places the file position
+// to the first entry
+func readHeaderBackupVersion(headerFile *os.File) (bool, error) {
+	header := make([]byte, headerBackupHeaderSize)
+	n, err := io.ReadFull(headerFile, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+
+	if n == headerBackupHeaderSize && bytes.Equal(header[:len(headerBackupMagic)], headerBackupMagic) {
+		return false, nil
+	}
+
+	_, err = headerFile.Seek(0, io.SeekStart)
+	return true, err
+}
+
+func writeHeaderBackupVersion(headerFile *os.File) error {
+	header := make([]byte, headerBackupHeaderSize)
+	copy(header, headerBackupMagic)
+	header[len(headerBackupMagic)] = block.HeaderVersion
+
+	_, err := headerFile.Write(header)
+	return err
+}
+
+func writeHeaderBackupEntry(headerFile *os.File, sha512Hex string, usage uint64) error {
+	sha512HexBytes, err := hex.DecodeString(sha512Hex)
+	if err != nil {
+		return err
+	}
+	if _, err := headerFile.Write(sha512HexBytes); err != nil {
+		return err
+	}
+
+	return binary.Write(headerFile, binary.LittleEndian, usage)
 }
 
 func (s *snapshot) ReplaceHeaderBackup(snapshot time.Time, headerMap HeaderMap) error {
-	snapshotPathName := s.PathName(snapshot)
-	snapshotPath := path.Join(s.rootPath, snapshotPathName)
+	headerBackupFilePath := s.headerBackupPath(snapshot)
+	headerBackupTempPath := fmt.Sprintf("%s.temp", headerBackupFilePath)
 
-	headerBackupFilePath := path.Join(snapshotPath, snapshotHeaderBackupFile)
-	headerFile, err := os.OpenFile(headerBackupFilePath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	headerFile, err := os.OpenFile(headerBackupTempPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
-	defer func() { _ = headerFile.Close() }()
 
-	for sha512Hex, usage := range headerMap {
-		sha512HexBytes, err := hex.DecodeString(sha512Hex)
-		if err != nil {
-			return err
-		}
-		if _, err := headerFile.Write(sha512HexBytes); err != nil {
+	if err := func() error {
+		defer func() { _ = headerFile.Close() }()
+
+		if err := writeHeaderBackupVersion(headerFile); err != nil {
 			return err
 		}
 
-		if err := binary.Write(headerFile, binary.LittleEndian, usage); err != nil {
-			return err
+		for sha512Hex, usage := range headerMap {
+			if err := writeHeaderBackupEntry(headerFile, sha512Hex, usage); err != nil {
+				return err
+			}
 		}
+		return nil
+	}(); err != nil {
+		_ = os.Remove(headerBackupTempPath)
+		return err
 	}
 
+	return os.Rename(headerBackupTempPath, headerBackupFilePath)
+}
+
+// UpgradeHeaderBackup rewrites the legacy header backup of the snapshot with the 8 bytes usages
+func (s *snapshot) UpgradeHeaderBackup(snapshot time.Time) error {
+	headerMap, legacy, err := s.readHeaderBackup(snapshot)
+	if err != nil {
+		return err
+	}
+	if !legacy {
+		return nil
+	}
+
+	if err := s.ReplaceHeaderBackup(snapshot, headerMap); err != nil {
+		return err
+	}
+
+	s.logger.Info(
+		fmt.Sprintf("Header backup of snapshot (%d) is upgraded", s.ToUint(snapshot)),
+		zap.Time("snapshot", snapshot),
+	)
+
 	return nil
 }
 
@@ -142,6 +230,10 @@ func (s *snapshot) Create(targetSnapshot *time.Time) (snapshotTime *time.Time, s
 	if err := os.MkdirAll(nextSnapshotPath, 0777); err != nil {
 		return nil, err
 	}
+	snapshotBlock, err := s.backend.Manager(nextSnapshotPath)
+	if err != nil {
+		return nil, err
+	}
 	defer func() {
 		if snapshotErr == nil {
 			return
@@ -164,34 +256,29 @@ func (s *snapshot) Create(targetSnapshot *time.Time) (snapshotTime *time.Time, s
 	}
 	defer func() { _ = headerFile.Close() }()
 
-	s.logger.Info("Start traversing for snapshot creation")
-
-	if err := dnc.Traverse(s.rootPath, func(info os.FileInfo) error {
-		sha512Hex := info.Name()
+	if err := writeHeaderBackupVersion(headerFile); err != nil {
+		return nil, err
+	}
 
-		blockFile, err := block.NewFile(s.rootPath, sha512Hex, s.logger)
-		if err != nil {
-			return err
-		}
-		defer blockFile.Close()
+	s.logger.Info("Start traversing for snapshot creation")
 
-		if blockFile.Temporary() {
-			return nil
-		}
+	rootBlock, err := s.backend.Manager(s.rootPath)
+	if err != nil {
+		return nil, err
+	}
 
-		sha512HexBytes, err := hex.DecodeString(sha512Hex)
-		if err != nil {
-			return err
-		}
-		if _, err := headerFile.Write(sha512HexBytes); err != nil {
-			return err
-		}
+	if err := rootBlock.Traverse(func(sha512Hex string, _ uint64) error {
+		return rootBlock.File(sha512Hex, func(blockFile block.File) error {
+			if blockFile.Temporary() {
+				return nil
+			}
 
-		if err := binary.Write(headerFile, binary.LittleEndian, blockFile.Usage()); err != nil {
-			return err
-		}
+			if err := writeHeaderBackupEntry(headerFile, sha512Hex, blockFile.Usage()); err != nil {
+				return err
+			}
 
-		return os.Link(path.Join(s.rootPath, sha512Hex), path.Join(nextSnapshotPath, sha512Hex))
+			return block.Clone(rootBlock, snapshotBlock, sha512Hex)
+		})
 	}); err != nil {
 		return nil, err
 	}
@@ -205,6 +292,14 @@ func (s *snapshot) Delete(targetSnapshot time.Time) error {
 	targetSnapshotPathName := s.PathName(targetSnapshot)
 	targetSnapshotPath := path.Join(s.rootPath, targetSnapshotPathName)
 
+	s.blocksMutex.Lock()
+	delete(s.blocks, targetSnapshot)
+	s.blocksMutex.Unlock()
+
+	if err := s.backend.Remove(targetSnapshotPath); err != nil {
+		return err
+	}
+
 	return os.RemoveAll(targetSnapshotPath)
 }
 
@@ -222,7 +317,7 @@ func (s *snapshot) Restore(sourceSnapshot time.Time) error {
 		return err
 	}
 
-	targetBlock, err := block.NewManager(s.rootPath, s.logger)
+	targetBlock, err := s.backend.Manager(s.rootPath)
 	if err != nil {
 		return err
 	}
@@ -232,7 +327,7 @@ func (s *snapshot) Restore(sourceSnapshot time.Time) error {
 		return err
 	}
 
-	sourceBlock, err := block.NewManager(sourceSnapshotPath, s.logger)
+	sourceBlock, err := s.backend.Manager(sourceSnapshotPath)
 	if err != nil {
 		return err
 	}
@@ -242,10 +337,7 @@ func (s *snapshot) Restore(sourceSnapshot time.Time) error {
 	}
 
 	if err := sourceBlock.Traverse(func(sha512Hex string, _ uint64) error {
-		sourceFilePath := path.Join(sourceSnapshotPath, sha512Hex)
-		targetFilePath := path.Join(s.rootPath, sha512Hex)
-
-		if err := os.Link(sourceFilePath, targetFilePath); err != nil {
+		if err := block.Clone(sourceBlock, targetBlock, sha512Hex); err != nil {
 			return err
 		}
 
@@ -286,7 +378,7 @@ func (s *snapshot) Block(snapshot time.Time) (block.Manager, error) {
 		snapshotPath := path.Join(s.rootPath, snapshotPathName)
 
 		var err error
-		b, err = block.NewManager(snapshotPath, s.logger)
+		b, err = s.backend.Manager(snapshotPath)
 		if err != nil {
 			return nil, err
 		}
@@ -10,8 +10,8 @@ import (
 	"github.com/freakmaxi/kertish-dos/basics/common"
 	"github.com/freakmaxi/kertish-dos/basics/errors"
 	"github.com/freakmaxi/kertish-dos/data-node/cluster"
-	dnc "github.com/freakmaxi/kertish-dos/data-node/common"
 	"github.com/freakmaxi/kertish-dos/data-node/filesystem/block"
+	"github.com/freakmaxi/kertish-dos/data-node/throttle"
 	"go.uber.org/zap"
 )
 
@@ -24,21 +24,25 @@ const semaphoreLimit = 10
 type Synchronize interface {
 	List(snapshotTime *time.Time, itemHandler func(fileItem *common.SyncFileItem) error) error
 
-	Create(sourceAddr string, sha512Hex string, usage uint16)
-	Delete(sha512Hex string, usage uint16)
+	Create(sourceAddr string, sha512Hex string, usage uint64)
+	// CreateWait creates the block from the source node without queueing and returns when it is stored
+	CreateWait(sourceAddr string, sha512Hex string, usage uint64) error
+	Delete(sha512Hex string, usage uint64)
 	Full(sourceAddr string) error
 }
 
 type queueItem struct {
 	sourceAddr *string
 	sha512Hex  string
-	usage      uint16
+	usage      uint64
 	create     bool
 }
 
 type synchronize struct {
 	rootPath string
+	backend  block.Backend
 	snapshot Snapshot
+	throttle throttle.Governor
 	logger   *zap.Logger
 
 	nodeCacheMutex sync.Mutex
@@ -46,13 +50,16 @@ type synchronize struct {
 
 	syncMutex sync.Mutex
 	syncChan  chan queueItem
+	block     block.Manager
 }
 
 // NewSynchronize creates an instance for data node synchronize operation
-func NewSynchronize(rootPath string, snapshot Snapshot, logger *zap.Logger) (Synchronize, error) {
+func NewSynchronize(rootPath string, backend block.Backend, snapshot Snapshot, throttle throttle.Governor, logger *zap.Logger) (Synchronize, error) {
 	s := &synchronize{
 		rootPath: rootPath,
+		backend:  backend,
 		snapshot: snapshot,
+		throttle: throttle,
 		logger:   logger,
 
 		nodeCacheMutex: sync.Mutex{},
@@ -68,10 +75,11 @@ func NewSynchronize(rootPath string, snapshot Snapshot, logger *zap.Logger) (Syn
 }
 
 func (s *synchronize) start() error {
-	b, err := block.NewManager(s.rootPath, s.logger)
+	b, err := s.backend.Manager(s.rootPath)
 	if err != nil {
 		return err
 	}
+	s.block = b
 
 	go func() {
 		for nextItem := range s.syncChan {
@@ -136,7 +144,7 @@ func (s *synchronize) processQueueItem(b block.Manager, item queueItem) {
 			continue
 		}
 
-		if err := s.createBlockFile(sourceNode, nil, b, common.SyncFileItem{Sha512Hex: item.sha512Hex, Usage: item.usage}); err != nil {
+		if err := s.createThrottledBlockFile(sourceNode, nil, b, common.SyncFileItem{Sha512Hex: item.sha512Hex, Usage: item.usage}); err != nil {
 			s.logger.Error(
 				fmt.Sprintf("Queue sync cannot create %s", item.sha512Hex),
 				zap.Error(err),
@@ -166,13 +174,13 @@ func (s *synchronize) List(snapshotTime *time.Time, itemHandler func(fileItem *c
 }
 
 func (s *synchronize) iterateFileItems(dataPath string, headerMap HeaderMap, itemHandler func(fileItem *common.SyncFileItem) error) error {
-	b, err := block.NewManager(dataPath, s.logger)
+	b, err := s.backend.Manager(dataPath)
 	if err != nil {
 		return err
 	}
 
-	return dnc.Traverse(dataPath, func(info os.FileInfo) error {
-		return b.File(info.Name(), func(file block.File) error {
+	return b.Traverse(func(sha512Hex string, _ uint64) error {
+		return b.File(sha512Hex, func(file block.File) error {
 			size, err := file.Size()
 			if err != nil {
 				return err
@@ -194,7 +202,7 @@ func (s *synchronize) iterateFileItems(dataPath string, headerMap HeaderMap, ite
 	})
 }
 
-func (s *synchronize) Create(sourceAddr string, sha512Hex string, usage uint16) {
+func (s *synchronize) Create(sourceAddr string, sha512Hex string, usage uint64) {
 	s.syncChan <- queueItem{
 		sourceAddr: &sourceAddr,
 		sha512Hex:  sha512Hex,
@@ -203,7 +211,16 @@ func (s *synchronize) Create(sourceAddr string, sha512Hex string, usage uint16)
 	}
 }
 
-func (s *synchronize) Delete(sha512Hex string, usage uint16) {
+func (s *synchronize) CreateWait(sourceAddr string, sha512Hex string, usage uint64) error {
+	sourceNode, err := s.getSourceDataNode(sourceAddr)
+	if err != nil {
+		return err
+	}
+
+	return s.createBlockFile(sourceNode, nil, s.block, common.SyncFileItem{Sha512Hex: sha512Hex, Usage: usage}, nil)
+}
+
+func (s *synchronize) Delete(sha512Hex string, usage uint64) {
 	s.syncChan <- queueItem{
 		sourceAddr: nil,
 		sha512Hex:  sha512Hex,
@@ -293,7 +310,7 @@ func (s *synchronize) syncFileItems(sourceNode cluster.DataNode, snapshotTime *t
 
 	s.logger.Info(fmt.Sprintf("Sync (%s) will, create: %d / delete: %d", syncLoc, len(createList), len(wipeList)))
 
-	b, err := block.NewManager(dataPath, s.logger)
+	b, err := s.backend.Manager(dataPath)
 	if err != nil {
 		return err
 	}
@@ -502,7 +519,7 @@ func (s *synchronize) create(wg *sync.WaitGroup, sourceNode cluster.DataNode, sn
 			completedCreateFunc()
 		}()
 
-		if err := s.createBlockFile(sourceNode, snapshotTime, b, fileItem); err != nil && err != errors.ErrQuit {
+		if err := s.createThrottledBlockFile(sourceNode, snapshotTime, b, fileItem); err != nil && err != errors.ErrQuit {
 			currentCreatedCount := currentCreatedCountFunc()
 			s.logger.Error(
 				fmt.Sprintf("Sync cannot create %s - %d/%d", fileItem.Sha512Hex, currentCreatedCount, totalCreateCount),
@@ -537,7 +554,17 @@ func (s *synchronize) create(wg *sync.WaitGroup, sourceNode cluster.DataNode, sn
 	semaphoreWG.Wait()
 }
 
-func (s *synchronize) createBlockFile(sourceNode cluster.DataNode, snapshotTime *time.Time, b block.Manager, fileItem common.SyncFileItem) error {
+// createThrottledBlockFile creates the block in the sync throttle limits of the node
+func (s *synchronize) createThrottledBlockFile(sourceNode cluster.DataNode, snapshotTime *time.Time, b block.Manager, fileItem common.SyncFileItem) error {
+	release := s.throttle.Acquire(common.ThrottleSync)
+	defer release()
+
+	return s.createBlockFile(sourceNode, snapshotTime, b, fileItem, func(size int) {
+		s.throttle.Wait(common.ThrottleSync, size)
+	})
+}
+
+func (s *synchronize) createBlockFile(sourceNode cluster.DataNode, snapshotTime *time.Time, b block.Manager, fileItem common.SyncFileItem, waitHandler func(size int)) error {
 	return b.LockFile(fileItem.Sha512Hex, func(blockFile block.File) error {
 		if !blockFile.Temporary() {
 			if blockFile.VerifyForce() {
@@ -554,9 +581,12 @@ func (s *synchronize) createBlockFile(sourceNode cluster.DataNode, snapshotTime
 			fileItem.Sha512Hex,
 			false,
 			func(data []byte) error {
+				if waitHandler != nil {
+					waitHandler(len(data))
+				}
 				return blockFile.Write(data)
 			},
-			func(usage uint16) bool {
+			func(usage uint64) bool {
 				if err := blockFile.ResetUsage(usage); err != nil {
 					return false
 				}
@@ -11,9 +11,12 @@ import (
 
 	"github.com/freakmaxi/kertish-dos/basics/logging"
 	"github.com/freakmaxi/kertish-dos/data-node/cache"
+	dnc "github.com/freakmaxi/kertish-dos/data-node/common"
 	"github.com/freakmaxi/kertish-dos/data-node/filesystem"
+	"github.com/freakmaxi/kertish-dos/data-node/filesystem/block"
 	"github.com/freakmaxi/kertish-dos/data-node/manager"
 	"github.com/freakmaxi/kertish-dos/data-node/service"
+	"github.com/freakmaxi/kertish-dos/data-node/throttle"
 	"go.uber.org/zap"
 )
 
@@ -75,12 +78,14 @@ func main() {
 	}
 	logger.Info(fmt.Sprintf("ROOT_PATH: %s", rootPath))
 
-	m, err := filesystem.NewManager(rootPath, logger)
+	t := throttle.NewGovernor()
+
+	m, err := filesystem.NewManager(rootPath, createBlockBackend(rootPath, logger), t, logger)
 	if err != nil {
 		logger.Error("File System Manager creation is failed", zap.Error(err))
 		os.Exit(80)
 	}
-	n := manager.NewNode(hardwareAddr, bindAddr, size, strings.Split(managerAddress, ","), logger)
+	n := manager.NewNode(hardwareAddr, bindAddr, size, strings.Split(managerAddress, ","), t, logger)
 
 	cacheLifetime := 360
 	cacheLimitString := os.Getenv("CACHE_LIMIT")
@@ -115,7 +120,7 @@ func main() {
 
 	cc := cache.NewContainer(cacheLimit, time.Minute*time.Duration(cacheLifetime), logger)
 
-	c, err := service.NewCommander(m, cc, n, logger)
+	c, err := service.NewCommander(m, cc, n, t, logger)
 	if err != nil {
 		logger.Error("Commander creation is failed", zap.Error(err))
 		os.Exit(200)
@@ -157,6 +162,104 @@ func main() {
 	os.Exit(0)
 }
 
+func createBlockBackend(rootPath string, logger *zap.Logger) block.Backend {
+	storageBackend := strings.ToLower(os.Getenv("STORAGE_BACKEND"))
+	if len(storageBackend) == 0 {
+		storageBackend = "local"
+	}
+	logger.Info(fmt.Sprintf("STORAGE_BACKEND: %s", storageBackend))
+
+	switch storageBackend {
+	case "local":
+		layoutDepth := block.RecordedDepth
+		layoutDepthString := os.Getenv("BLOCK_LAYOUT_DEPTH")
+		if len(layoutDepthString) == 0 {
+			logger.Info("BLOCK_LAYOUT_DEPTH: recorded layout of the data path")
+		} else {
+			depth, err := strconv.ParseUint(layoutDepthString, 10, 8)
+			if err != nil {
+				logger.Error("Block Layout Depth is wrong", zap.Error(err))
+				os.Exit(70)
+			}
+			if depth > dnc.MaxLayoutDepth {
+				logger.Error(fmt.Sprintf("Block Layout Depth can not be more than %d", dnc.MaxLayoutDepth))
+				os.Exit(71)
+			}
+			layoutDepth = int(depth)
+			logger.Info(fmt.Sprintf("BLOCK_LAYOUT_DEPTH: %s", layoutDepthString))
+		}
+
+		packLimitString := os.Getenv("VOLUME_PACK_LIMIT")
+		if len(packLimitString) == 0 {
+			packLimitString = "0"
+		}
+		packLimit, err := strconv.ParseUint(packLimitString, 10, 32)
+		if err != nil {
+			logger.Error("Volume Pack Limit is wrong", zap.Error(err))
+			os.Exit(75)
+		}
+		if uint32(packLimit) > block.MaxPackLimit {
+			logger.Error(fmt.Sprintf("Volume Pack Limit can not be more than %d", block.MaxPackLimit))
+			os.Exit(76)
+		}
+		if packLimit == 0 {
+			logger.Info("Volume packing is disabled")
+		} else {
+			logger.Info(fmt.Sprintf("VOLUME_PACK_LIMIT: %s (%s Kb)", packLimitString, strconv.FormatUint(packLimit/1024, 10)))
+		}
+
+		b, err := block.NewLocalBackend(
+			block.Options{
+				Depth:     layoutDepth,
+				PackLimit: uint32(packLimit),
+			},
+			logger,
+		)
+		if err != nil {
+			logger.Error("Local Storage Backend creation is failed", zap.Error(err))
+			os.Exit(77)
+		}
+		return b
+	case "memory":
+		logger.Warn("Memory Storage Backend is volatile, blocks will be lost when the data node is stopped")
+		return block.NewMemoryBackend(logger)
+	case "s3":
+		config := block.ObjectConfig{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Prefix:    os.Getenv("S3_PREFIX"),
+		}
+		if len(config.Endpoint) == 0 {
+			logger.Error("S3_ENDPOINT have to be specified")
+			os.Exit(65)
+		}
+		if len(config.Bucket) == 0 {
+			logger.Error("S3_BUCKET have to be specified")
+			os.Exit(66)
+		}
+		logger.Info(fmt.Sprintf("S3_ENDPOINT: %s", config.Endpoint))
+		logger.Info(fmt.Sprintf("S3_BUCKET: %s", config.Bucket))
+		if len(config.Prefix) > 0 {
+			logger.Info(fmt.Sprintf("S3_PREFIX: %s", config.Prefix))
+		}
+
+		b, err := block.NewObjectBackend(rootPath, config, logger)
+		if err != nil {
+			logger.Error("S3 Storage Backend creation is failed", zap.Error(err))
+			os.Exit(67)
+		}
+		return b
+	}
+
+	logger.Error(fmt.Sprintf("STORAGE_BACKEND (%s) is not supported, use local, memory or s3", storageBackend))
+	os.Exit(60)
+
+	return nil
+}
+
 func findHardwareAddress() (string, error) {
 	interfaces, err := net.Interfaces()
 	if err != nil {
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	"time"
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/data-node/throttle"
 	"go.uber.org/zap"
 )
 
@@ -28,11 +30,13 @@ type Node interface {
 	Leave()
 	Handshake() error
 
-	Notify(sha512Hex string, usage uint16, size uint32, shadow bool, create bool) <-chan bool
+	Notify(sha512Hex string, usage uint64, size uint32, shadow bool, create bool) <-chan bool
 
 	ClusterId() string
 	NodeId() string
 	MasterAddress() string
+	// WriteQuorum returns the count of the nodes that should have the created block before it is acknowledged
+	WriteQuorum() int
 
 	HardwareAddr() string
 	BindAddr() string
@@ -46,11 +50,13 @@ type node struct {
 
 	client      http.Client
 	managerAddr []string
+	throttle    throttle.Governor
 	logger      *zap.Logger
 
 	clusterId     string
 	nodeId        string
 	masterAddress string
+	writeQuorum   int
 
 	notificationChan chan common.NotificationContainer
 	failureChan      chan common.NotificationContainerList
@@ -58,7 +64,7 @@ type node struct {
 	nextProcessList map[string]*common.NotificationContainer
 }
 
-func NewNode(hardwareAddr string, bindAddr string, nodeSize uint64, managerAddresses []string, logger *zap.Logger) Node {
+func NewNode(hardwareAddr string, bindAddr string, nodeSize uint64, managerAddresses []string, throttle throttle.Governor, logger *zap.Logger) Node {
 	node := &node{
 		hardwareAddr: hardwareAddr,
 		bindAddr:     bindAddr,
@@ -68,6 +74,7 @@ func NewNode(hardwareAddr string, bindAddr string, nodeSize uint64, managerAddre
 
 		client:      http.Client{},
 		managerAddr: managerAddresses,
+		throttle:    throttle,
 		logger:      logger,
 
 		notificationChan: make(chan common.NotificationContainer, notificationChannelLimit),
@@ -149,7 +156,8 @@ func (n *node) notifyBulk() {
 	pushFunc := func(wg *sync.WaitGroup, notificationContainerList common.NotificationContainerList) {
 		defer wg.Done()
 
-		if err := n.notify(notificationContainerList); err != nil {
+		quorumFailedMap, err := n.notify(notificationContainerList)
+		if err != nil {
 			n.logger.Warn("Bulk notification is failed", zap.Error(err))
 
 			switch et := err.(type) {
@@ -181,7 +189,7 @@ func (n *node) notifyBulk() {
 		}
 
 		for _, nc := range notificationContainerList {
-			nc.ResponseChan <- true
+			nc.ResponseChan <- !quorumFailedMap[nc.FileItem.Sha512Hex]
 		}
 	}
 
@@ -196,28 +204,30 @@ func (n *node) notifyBulk() {
 	wg.Wait()
 }
 
-func (n *node) notify(notificationContainerList common.NotificationContainerList) error {
+// notify pushes the notifications to the manager and returns the created blocks that could not reach the write
+// quorum of the cluster
+func (n *node) notify(notificationContainerList common.NotificationContainerList) (map[string]bool, error) {
 	body, err := json.Marshal(notificationContainerList)
 	if err != nil {
-		return common.NewNotificationError(notificationContainerList, err)
+		return nil, common.NewNotificationError(notificationContainerList, err)
 	}
 
 	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s%s", n.managerAddr[0], managerEndPoint), bytes.NewBuffer(body))
 	if err != nil {
-		return common.NewNotificationError(notificationContainerList, err)
+		return nil, common.NewNotificationError(notificationContainerList, err)
 	}
 	req.Header.Set("X-Action", "notify")
 	req.Header.Set("X-Options", n.nodeId)
 
 	res, err := n.client.Do(req)
 	if err != nil {
-		return common.NewNotificationError(notificationContainerList, err)
+		return nil, common.NewNotificationError(notificationContainerList, err)
 	}
 	defer func() { _ = res.Body.Close() }()
 
 	if res.StatusCode != 202 {
 		if res.StatusCode == 404 {
-			return fmt.Errorf("data node is not registered")
+			return nil, fmt.Errorf("data node is not registered")
 		}
 
 		var failedList common.NotificationContainerList
@@ -225,10 +235,24 @@ func (n *node) notify(notificationContainerList common.NotificationContainerList
 			n.logger.Error("Decoding the response of bulk notify request result is failed", zap.Error(err))
 		}
 
-		return common.NewNotificationError(failedList, fmt.Errorf("node manager notify request is failed: %d - %s", res.StatusCode, common.NewErrorFromReader(res.Body).Message))
+		return nil, common.NewNotificationError(failedList, fmt.Errorf("node manager notify request is failed: %d - %s", res.StatusCode, common.NewErrorFromReader(res.Body).Message))
 	}
 
-	return nil
+	quorumFailedMap := make(map[string]bool)
+
+	var quorumFailedList []string
+	if err := json.NewDecoder(res.Body).Decode(&quorumFailedList); err != nil {
+		if err == io.EOF {
+			return quorumFailedMap, nil
+		}
+		n.logger.Error("Decoding the quorum result of bulk notify request is failed", zap.Error(err))
+	}
+
+	for _, sha512Hex := range quorumFailedList {
+		quorumFailedMap[sha512Hex] = true
+	}
+
+	return quorumFailedMap, nil
 }
 
 func (n *node) Join(clusterId string, nodeId string, masterAddress string) error {
@@ -275,6 +299,7 @@ func (n *node) Leave() {
 	n.clusterId = ""
 	n.nodeId = ""
 	n.masterAddress = ""
+	n.writeQuorum = 0
 }
 
 func (n *node) Handshake() error {
@@ -307,6 +332,19 @@ func (n *node) Handshake() error {
 	}
 	n.masterAddress = res.Header.Get("X-Master")
 
+	if writeQuorum, err := strconv.Atoi(res.Header.Get("X-Write-Quorum")); err == nil {
+		n.writeQuorum = writeQuorum
+	}
+
+	if throttleHeader := res.Header.Get("X-Throttle"); len(throttleHeader) > 0 {
+		var limits common.Throttle
+		if err := json.Unmarshal([]byte(throttleHeader), &limits); err != nil {
+			n.logger.Error("Throttle limits of the handshake can not be parsed", zap.Error(err))
+		} else {
+			n.throttle.Apply(limits)
+		}
+	}
+
 	if !initialHandshake {
 		n.Mode(len(n.masterAddress) == 0)
 	}
@@ -314,7 +352,7 @@ func (n *node) Handshake() error {
 	return nil
 }
 
-func (n *node) Notify(sha512Hex string, usage uint16, size uint32, shadow bool, create bool) <-chan bool {
+func (n *node) Notify(sha512Hex string, usage uint64, size uint32, shadow bool, create bool) <-chan bool {
 	responseChan := make(chan bool, 1)
 
 	n.notificationChan <- common.NotificationContainer{
@@ -343,6 +381,10 @@ func (n *node) MasterAddress() string {
 	return n.masterAddress
 }
 
+func (n *node) WriteQuorum() int {
+	return n.writeQuorum
+}
+
 func (n *node) HardwareAddr() string {
 	return n.hardwareAddr
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"os"
 	"strings"
@@ -12,11 +13,13 @@ import (
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
 	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/protocol"
 	"github.com/freakmaxi/kertish-dos/data-node/cache"
 	"github.com/freakmaxi/kertish-dos/data-node/cluster"
 	"github.com/freakmaxi/kertish-dos/data-node/filesystem"
 	"github.com/freakmaxi/kertish-dos/data-node/filesystem/block"
 	"github.com/freakmaxi/kertish-dos/data-node/manager"
+	"github.com/freakmaxi/kertish-dos/data-node/throttle"
 	"go.uber.org/zap"
 )
 
@@ -24,34 +27,50 @@ const commandBuffer = 4             // 4b
 const defaultTransferSpeed = 625000 // bytes/s
 const notificationWaitDuration = time.Second * 30
 
+// quorumWaitDuration is longer than the quorum timeout of the manager (20 seconds) to receive the quorum result even
+// if the notification is retried
+const quorumWaitDuration = time.Second * 60
+
 type Commander interface {
 	Handler(net.Conn)
 }
 
 type commander struct {
-	fs     filesystem.Manager
-	cache  cache.Container
-	node   manager.Node
-	logger *zap.Logger
+	fs       filesystem.Manager
+	cache    cache.Container
+	node     manager.Node
+	throttle throttle.Governor
+	logger   *zap.Logger
+
+	notificationWait time.Duration
+	quorumWait       time.Duration
 }
 
-func NewCommander(fs filesystem.Manager, cc cache.Container, node manager.Node, logger *zap.Logger) (Commander, error) {
+func NewCommander(fs filesystem.Manager, cc cache.Container, node manager.Node, throttle throttle.Governor, logger *zap.Logger) (Commander, error) {
 	return &commander{
-		fs:     fs,
-		cache:  cc,
-		node:   node,
-		logger: logger,
+		fs:       fs,
+		cache:    cc,
+		node:     node,
+		throttle: throttle,
+		logger:   logger,
+
+		notificationWait: notificationWaitDuration,
+		quorumWait:       quorumWaitDuration,
 	}, nil
 }
 
-func (c *commander) setDeadline(conn net.Conn, expectedTransferSize int) error {
+func (c *commander) transferDuration(expectedTransferSize int) time.Duration {
 	seconds := expectedTransferSize / defaultTransferSpeed
 	if seconds < 0 {
 		seconds = 0
 	}
 	seconds += 30
 
-	return conn.SetDeadline(time.Now().Add(time.Second * time.Duration(seconds)))
+	return time.Second * time.Duration(seconds)
+}
+
+func (c *commander) setDeadline(conn net.Conn, expectedTransferSize int) error {
+	return conn.SetDeadline(time.Now().Add(c.transferDuration(expectedTransferSize)))
 }
 
 func (c *commander) readWithTimeout(conn net.Conn, buffer []byte, size int) error {
@@ -98,6 +117,11 @@ func (c *commander) Handler(conn net.Conn) {
 		return
 	}
 
+	if strings.Compare(string(buffer), protocol.Negotiate) == 0 {
+		c.negotiate(conn)
+		return
+	}
+
 	if err := c.process(string(buffer), conn); err != nil {
 		if err != errors.ErrQuit {
 			c.logger.Error(
@@ -130,20 +154,22 @@ func (c *commander) process(command string, conn net.Conn) error {
 		return c.mode(conn)
 	case "LEAV":
 		return c.leav()
-	case "SYCR":
-		return c.sycr(conn)
-	case "SYRD":
-		return c.syrd(conn)
-	case "SYDE":
-		return c.syde(conn)
+	case "SYCR", "SXCR":
+		return c.sycr(conn, wideUsage(command))
+	case "SYCW", "SXCW":
+		return c.sycw(conn, wideUsage(command))
+	case "SYRD", "SXRD":
+		return c.syrd(conn, wideUsage(command))
+	case "SYDE", "SXDE":
+		return c.syde(conn, wideUsage(command))
 	case "SYMV":
 		return c.symv(conn)
-	case "SYLS":
-		return c.syls(conn)
+	case "SYLS", "SXLS":
+		return c.syls(conn, wideUsage(command))
 	case "SYFL":
 		return c.syfl(conn)
-	case "SYUS":
-		return c.syus(conn)
+	case "SYUS", "SXUS":
+		return c.syus(conn, wideUsage(command))
 	case "SSCR":
 		return c.sscr()
 	case "SSDE":
@@ -165,6 +191,37 @@ func (c *commander) process(command string, conn net.Conn) error {
 	}
 }
 
+// wideUsage checks if the sync command carries the usage in 8 bytes. SY* commands are the legacy forms that
+// carry the usage in 2 bytes, they are served for the manager and data nodes of the older versions
+func wideUsage(command string) bool {
+	return strings.HasPrefix(command, "SX")
+}
+
+func (c *commander) readUsage(conn net.Conn, wide bool) (uint64, error) {
+	if wide {
+		var usage uint64
+		err := c.readBinaryWithTimeout(conn, &usage)
+		return usage, err
+	}
+
+	var usage uint16
+	err := c.readBinaryWithTimeout(conn, &usage)
+	return uint64(usage), err
+}
+
+// writeUsage writes the usage in the size of the command form. Usage is capped for the legacy form, the legacy
+// peer can not keep the bigger usage anyway
+func (c *commander) writeUsage(conn net.Conn, wide bool, usage uint64) error {
+	if wide {
+		return c.writeBinaryWithTimeout(conn, usage)
+	}
+
+	if usage > math.MaxUint16 {
+		usage = math.MaxUint16
+	}
+	return c.writeBinaryWithTimeout(conn, uint16(usage))
+}
+
 func (c *commander) hashAsHex(conn net.Conn) (string, error) {
 	h := make([]byte, 32)
 	err := c.readWithTimeout(conn, h, len(h))
@@ -189,10 +246,41 @@ func (c *commander) crea(conn net.Conn) error {
 		return err
 	}
 
-	var blockUsage uint16 = 1
+	blockUsage, blockSize, err := c.create(sha512Hex, func() ([]byte, error) {
+		if err := c.writeWithTimeout(conn, []byte{'+'}); err != nil {
+			return nil, err
+		}
+
+		var blockSize uint32
+		if err := c.readBinaryWithTimeout(conn, &blockSize); err != nil {
+			return nil, err
+		}
+
+		chunkBuffer := make([]byte, blockSize)
+		if err := c.readWithTimeout(conn, chunkBuffer, len(chunkBuffer)); err != nil {
+			return nil, err
+		}
+
+		return chunkBuffer, nil
+	})
+	if err != nil && err != errors.ErrQuit {
+		return err
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(c.quorumWait * 2))
+
+	return c.notifyCreate(sha512Hex, blockUsage, blockSize, err)
+}
+
+// create increases the usage of the block if it exists and returns errors.ErrQuit. Otherwise, it gets the block
+// data from the dataHandler and creates the block
+func (c *commander) create(sha512Hex string, dataHandler func() ([]byte, error)) (uint64, uint32, error) {
+	defer c.throttle.Client()()
+
+	var blockUsage uint64 = 1
 	var blockSize uint32
 
-	err = c.fs.Block(filesystem.Create).LockFile(sha512Hex, func(blockFile block.File) error {
+	err := c.fs.Block(filesystem.Create).LockFile(sha512Hex, func(blockFile block.File) error {
 		if !blockFile.Temporary() {
 			if err := blockFile.IncreaseUsage(); err != nil {
 				return err
@@ -207,18 +295,12 @@ func (c *commander) crea(conn net.Conn) error {
 
 			return errors.ErrQuit
 		}
-		if err := c.writeWithTimeout(conn, []byte{'+'}); err != nil {
-			return err
-		}
-
-		if err := c.readBinaryWithTimeout(conn, &blockSize); err != nil {
-			return err
-		}
 
-		chunkBuffer := make([]byte, blockSize)
-		if err := c.readWithTimeout(conn, chunkBuffer, len(chunkBuffer)); err != nil {
+		chunkBuffer, err := dataHandler()
+		if err != nil {
 			return err
 		}
+		blockSize = uint32(len(chunkBuffer))
 
 		if err := blockFile.Write(chunkBuffer); err != nil {
 			return err
@@ -233,14 +315,31 @@ func (c *commander) crea(conn net.Conn) error {
 
 		return nil
 	})
-	if err != nil && err != errors.ErrQuit {
-		return err
-	}
 
-	_ = conn.SetDeadline(time.Now().Add(notificationWaitDuration * 2))
+	return blockUsage, blockSize, err
+}
+
+// notifyCreate waits the manager notification of the created or shared block. err is the result of create.
+// If the manager can not satisfy or confirm the write quorum of the cluster, the block creation is reverted
+func (c *commander) notifyCreate(sha512Hex string, blockUsage uint64, blockSize uint32, err error) error {
+	// the manager replicates the blocks of the master synchronously when the write quorum is more than 1
+	quorum := len(c.node.MasterAddress()) == 0 && c.node.WriteQuorum() > 1
+
+	wait := c.notificationWait
+	if quorum {
+		wait = c.quorumWait
+	}
 
 	select {
-	case <-time.After(notificationWaitDuration):
+	case <-time.After(wait):
+		if quorum {
+			c.logger.Warn(
+				fmt.Sprintf("Notification timeout is reached for %s, write quorum is not confirmed. Block creation is reverted", sha512Hex),
+			)
+			c.revertCreate(sha512Hex)
+			return fmt.Errorf("write quorum is not confirmed for %s", sha512Hex)
+		}
+
 		if err == nil {
 			c.logger.Warn(
 				fmt.Sprintf(
@@ -255,12 +354,28 @@ func (c *commander) crea(conn net.Conn) error {
 				),
 			)
 		}
-	case <-c.node.Notify(sha512Hex, blockUsage, blockSize, err == errors.ErrQuit, true):
+	case accepted := <-c.node.Notify(sha512Hex, blockUsage, blockSize, err == errors.ErrQuit, true):
+		if !accepted {
+			c.revertCreate(sha512Hex)
+			return fmt.Errorf("write quorum is not satisfied for %s", sha512Hex)
+		}
 	}
 
 	return err
 }
 
+// revertCreate drops the usage that is added by the block creation and notifies the manager about it
+func (c *commander) revertCreate(sha512Hex string) {
+	blockUsage, blockSize, err := c.delete(sha512Hex)
+	if err != nil {
+		if err != errors.ErrQuit {
+			c.logger.Error("Unable to revert the block creation", zap.String("sha512Hex", sha512Hex), zap.Error(err))
+		}
+		return
+	}
+	c.notifyDelete(sha512Hex, blockUsage, blockSize)
+}
+
 func (c *commander) read(conn net.Conn) error {
 	sha512Hex, err := c.hashAsHex(conn)
 	if err != nil {
@@ -277,24 +392,34 @@ func (c *commander) read(conn net.Conn) error {
 		return err
 	}
 
-	// Check cache first
-	if content := c.cache.Query(sha512Hex, begins, ends); content != nil {
-		if err := c.writeWithTimeout(conn, []byte{'+'}); err != nil {
-			return err
-		}
+	return c.readBlock(sha512Hex, begins, ends,
+		func(size uint32) error {
+			if err := c.writeWithTimeout(conn, []byte{'+'}); err != nil {
+				return err
+			}
 
-		sizeBuffer := make([]byte, 4)
-		binary.LittleEndian.PutUint32(sizeBuffer, uint32(len(content)))
+			sizeBuffer := make([]byte, 4)
+			binary.LittleEndian.PutUint32(sizeBuffer, size)
 
-		if err := c.writeWithTimeout(conn, sizeBuffer); err != nil {
-			return err
-		}
+			return c.writeWithTimeout(conn, sizeBuffer)
+		},
+		func(data []byte) error {
+			return c.writeWithTimeout(conn, data)
+		})
+}
+
+// readBlock reads the range of the block from the cache or the block file. sizeHandler is called with the size
+// of the range before the data is streamed to the dataHandler
+func (c *commander) readBlock(sha512Hex string, begins uint32, ends uint32, sizeHandler func(size uint32) error, dataHandler func(data []byte) error) error {
+	defer c.throttle.Client()()
 
-		if err := c.writeWithTimeout(conn, content); err != nil {
+	// Check cache first
+	if content := c.cache.Query(sha512Hex, begins, ends); content != nil {
+		if err := sizeHandler(uint32(len(content))); err != nil {
 			return err
 		}
 
-		return nil
+		return dataHandler(content)
 	}
 
 	return c.fs.Block(filesystem.Read).File(sha512Hex, func(blockFile block.File) error {
@@ -317,10 +442,6 @@ func (c *commander) read(conn net.Conn) error {
 			return fmt.Errorf("range is not satisfied (begins > ends), begins: %d, ends: %d", begins, ends)
 		}
 
-		if err := c.writeWithTimeout(conn, []byte{'+'}); err != nil {
-			return err
-		}
-
 		if begins == 0 && ends == size {
 			ends = 0
 		}
@@ -328,10 +449,7 @@ func (c *commander) read(conn net.Conn) error {
 			size = ends - begins
 		}
 
-		sizeBuffer := make([]byte, 4)
-		binary.LittleEndian.PutUint32(sizeBuffer, size)
-
-		if err := c.writeBinaryWithTimeout(conn, sizeBuffer); err != nil {
+		if err := sizeHandler(size); err != nil {
 			return err
 		}
 
@@ -341,7 +459,7 @@ func (c *commander) read(conn net.Conn) error {
 				// Compile For Cache
 				cacheData = append(cacheData, data...)
 
-				return c.writeWithTimeout(conn, data)
+				return dataHandler(data)
 			},
 			func(inconsistency bool) error {
 				if inconsistency {
@@ -362,14 +480,34 @@ func (c *commander) dele(conn net.Conn) error {
 		return err
 	}
 
-	var blockUsage uint16
+	blockUsage, blockSize, err := c.delete(sha512Hex)
+	if err != nil {
+		if err == errors.ErrQuit {
+			return nil
+		}
+		return err
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(c.notificationWait * 2))
+
+	c.notifyDelete(sha512Hex, blockUsage, blockSize)
+
+	return nil
+}
+
+// delete decreases the usage of the block and removes it when it is not used anymore. Returns errors.ErrQuit if
+// the block does not exist
+func (c *commander) delete(sha512Hex string) (uint64, uint32, error) {
+	var blockUsage uint64
 	var blockSize uint32
 
-	if err := c.fs.Block(filesystem.Delete).LockFile(sha512Hex, func(blockFile block.File) error {
+	err := c.fs.Block(filesystem.Delete).LockFile(sha512Hex, func(blockFile block.File) error {
 		if blockFile.Temporary() {
 			return errors.ErrQuit
 		}
 
+		var err error
+
 		blockUsage = blockFile.Usage()
 		blockSize, err = blockFile.Size()
 		if err != nil {
@@ -386,17 +524,15 @@ func (c *commander) dele(conn net.Conn) error {
 		}
 
 		return nil
-	}); err != nil {
-		if err == errors.ErrQuit {
-			return nil
-		}
-		return err
-	}
+	})
 
-	_ = conn.SetDeadline(time.Now().Add(notificationWaitDuration * 2))
+	return blockUsage, blockSize, err
+}
 
+// notifyDelete waits the manager notification of the deleted block
+func (c *commander) notifyDelete(sha512Hex string, blockUsage uint64, blockSize uint32) {
 	select {
-	case <-time.After(notificationWaitDuration):
+	case <-time.After(c.notificationWait):
 		if blockUsage == 0 {
 			c.logger.Warn(
 				fmt.Sprintf(
@@ -411,9 +547,7 @@ func (c *commander) dele(conn net.Conn) error {
 				),
 			)
 		}
-		return nil
 	case <-c.node.Notify(sha512Hex, blockUsage, blockSize, blockUsage > 0, false):
-		return nil
 	}
 }
 
@@ -484,35 +618,55 @@ func (c *commander) leav() error {
 	return nil
 }
 
-func (c *commander) sycr(conn net.Conn) error {
-	sha512Hex, err := c.hashAsHex(conn)
+func (c *commander) sycr(conn net.Conn, wide bool) error {
+	sha512Hex, usage, sourceAddr, err := c.syncCreateOptions(conn, wide)
 	if err != nil {
 		return err
 	}
 
-	var usage uint16
-	if err := c.readBinaryWithTimeout(conn, &usage); err != nil {
+	return c.fs.Sync(func(sync filesystem.Synchronize) error {
+		sync.Create(sourceAddr, sha512Hex, usage)
+		return nil
+	})
+}
+
+// sycw creates the block from the source node before replying, it is used to satisfy the write quorum
+func (c *commander) sycw(conn net.Conn, wide bool) error {
+	sha512Hex, usage, sourceAddr, err := c.syncCreateOptions(conn, wide)
+	if err != nil {
 		return err
 	}
 
+	return c.fs.Sync(func(sync filesystem.Synchronize) error {
+		return sync.CreateWait(sourceAddr, sha512Hex, usage)
+	})
+}
+
+func (c *commander) syncCreateOptions(conn net.Conn, wide bool) (string, uint64, string, error) {
+	sha512Hex, err := c.hashAsHex(conn)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	usage, err := c.readUsage(conn, wide)
+	if err != nil {
+		return "", 0, "", err
+	}
+
 	var sourceAddrLength uint8
 	if err := c.readBinaryWithTimeout(conn, &sourceAddrLength); err != nil {
-		return err
+		return "", 0, "", err
 	}
 
 	sourceAddrBuf := make([]byte, sourceAddrLength)
 	if err := c.readWithTimeout(conn, sourceAddrBuf, len(sourceAddrBuf)); err != nil {
-		return err
+		return "", 0, "", err
 	}
-	sourceAddr := string(sourceAddrBuf)
 
-	return c.fs.Sync(func(sync filesystem.Synchronize) error {
-		sync.Create(sourceAddr, sha512Hex, usage)
-		return nil
-	})
+	return sha512Hex, usage, string(sourceAddrBuf), nil
 }
 
-func (c *commander) syrd(conn net.Conn) error {
+func (c *commander) syrd(conn net.Conn, wide bool) error {
 	sha512Hex, err := c.hashAsHex(conn)
 	if err != nil {
 		return err
@@ -560,8 +714,7 @@ func (c *commander) syrd(conn net.Conn) error {
 			return err
 		}
 
-		usage := blockFile.Usage()
-		if err := c.writeBinaryWithTimeout(conn, usage); err != nil {
+		if err := c.writeUsage(conn, wide, blockFile.Usage()); err != nil {
 			return err
 		}
 
@@ -584,14 +737,14 @@ func (c *commander) syrd(conn net.Conn) error {
 	})
 }
 
-func (c *commander) syde(conn net.Conn) error {
+func (c *commander) syde(conn net.Conn, wide bool) error {
 	sha512Hex, err := c.hashAsHex(conn)
 	if err != nil {
 		return err
 	}
 
-	var usage uint16
-	if err := c.readBinaryWithTimeout(conn, &usage); err != nil {
+	usage, err := c.readUsage(conn, wide)
+	if err != nil {
 		return err
 	}
 
@@ -618,6 +771,9 @@ func (c *commander) symv(conn net.Conn) error {
 	}
 	sourceAddr := string(sourceAddrBuf)
 
+	release := c.throttle.Acquire(common.ThrottleMove)
+	defer release()
+
 	return c.fs.Block(filesystem.Create).LockFile(sha512Hex, func(blockFile block.File) error {
 		dn, err := cluster.NewDataNode(sourceAddr)
 		if err != nil {
@@ -629,9 +785,10 @@ func (c *commander) symv(conn net.Conn) error {
 			sha512Hex,
 			true,
 			func(data []byte) error {
+				c.throttle.Wait(common.ThrottleMove, len(data))
 				return blockFile.Write(data)
 			},
-			func(usage uint16) bool {
+			func(usage uint64) bool {
 				if err := blockFile.ResetUsage(usage); err != nil {
 					return false
 				}
@@ -640,7 +797,7 @@ func (c *commander) symv(conn net.Conn) error {
 	})
 }
 
-func (c *commander) syls(conn net.Conn) error {
+func (c *commander) syls(conn net.Conn, wide bool) error {
 	var snapshotTimeUint uint64
 	if err := c.readBinaryWithTimeout(conn, &snapshotTimeUint); err != nil {
 		return err
@@ -691,7 +848,7 @@ func (c *commander) syls(conn net.Conn) error {
 				return err
 			}
 
-			if err := c.writeBinaryWithTimeout(conn, fileItem.Usage); err != nil {
+			if err := c.writeUsage(conn, wide, fileItem.Usage); err != nil {
 				return err
 			}
 
@@ -741,7 +898,7 @@ func (c *commander) syfl(conn net.Conn) error {
 	return nil
 }
 
-func (c *commander) syus(conn net.Conn) error {
+func (c *commander) syus(conn net.Conn, wide bool) error {
 	for {
 		sha512Hex, err := c.hashAsHex(conn)
 		if err != nil {
@@ -752,8 +909,8 @@ func (c *commander) syus(conn net.Conn) error {
 			return nil
 		}
 
-		var usage uint16
-		if err := c.readBinaryWithTimeout(conn, &usage); err != nil {
+		usage, err := c.readUsage(conn, wide)
+		if err != nil {
 			return err
 		}
 
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/data-node/cache"
+	"github.com/freakmaxi/kertish-dos/data-node/filesystem"
+	"github.com/freakmaxi/kertish-dos/data-node/filesystem/block"
+	"github.com/freakmaxi/kertish-dos/data-node/manager"
+	"github.com/freakmaxi/kertish-dos/data-node/throttle"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const testWait = time.Millisecond * 100
+
+// testNode replies the create notifications with the accepted value or never replies them when the value is nil.
+// Delete notifications are always replied
+type testNode struct {
+	manager.Node
+
+	masterAddress string
+	writeQuorum   int
+	accepted      *bool
+
+	mutex         sync.Mutex
+	notifications []testNotification
+}
+
+type testNotification struct {
+	sha512Hex string
+	usage     uint64
+	create    bool
+}
+
+func (n *testNode) Notify(sha512Hex string, usage uint64, _ uint32, _ bool, create bool) <-chan bool {
+	n.mutex.Lock()
+	n.notifications = append(n.notifications, testNotification{sha512Hex: sha512Hex, usage: usage, create: create})
+	n.mutex.Unlock()
+
+	responseChan := make(chan bool, 1)
+	if !create {
+		responseChan <- true
+	} else if n.accepted != nil {
+		responseChan <- *n.accepted
+	}
+	return responseChan
+}
+
+func (n *testNode) MasterAddress() string {
+	return n.masterAddress
+}
+
+func (n *testNode) WriteQuorum() int {
+	return n.writeQuorum
+}
+
+func (n *testNode) deletions() []testNotification {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	deletions := make([]testNotification, 0)
+	for _, notification := range n.notifications {
+		if !notification.create {
+			deletions = append(deletions, notification)
+		}
+	}
+	return deletions
+}
+
+func newTestCommander(t *testing.T, node manager.Node) *commander {
+	fs, err := filesystem.NewManager(t.TempDir(), block.NewMemoryBackend(zap.NewNop()), throttle.NewGovernor(), zap.NewNop())
+	assert.Nil(t, err)
+
+	return &commander{
+		fs:               fs,
+		cache:            cache.NewContainer(0, time.Minute, zap.NewNop()),
+		node:             node,
+		throttle:         throttle.NewGovernor(),
+		logger:           zap.NewNop(),
+		notificationWait: testWait,
+		quorumWait:       testWait,
+	}
+}
+
+func createTestBlock(t *testing.T, c *commander, data []byte) (string, error) {
+	sum := sha512.Sum512_256(data)
+	sha512Hex := hex.EncodeToString(sum[:])
+
+	blockUsage, blockSize, err := c.create(sha512Hex, func() ([]byte, error) {
+		return data, nil
+	})
+	if err != nil && err != errors.ErrQuit {
+		t.Fatal(err)
+	}
+
+	return sha512Hex, c.notifyCreate(sha512Hex, blockUsage, blockSize, err)
+}
+
+// testBlockUsage returns the usage of the block, 0 if it does not exist
+func testBlockUsage(t *testing.T, c *commander, sha512Hex string) uint64 {
+	var usage uint64
+	assert.Nil(t, c.fs.Block(filesystem.Read).File(sha512Hex, func(blockFile block.File) error {
+		if !blockFile.Temporary() {
+			usage = blockFile.Usage()
+		}
+		return nil
+	}))
+	return usage
+}
+
+func TestCommander_NotifyCreate(t *testing.T) {
+	accepted := true
+	rejected := false
+
+	t.Run("QuorumMet", func(t *testing.T) {
+		node := &testNode{writeQuorum: 2, accepted: &accepted}
+		c := newTestCommander(t, node)
+
+		sha512Hex, err := createTestBlock(t, c, []byte("quorum met"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), testBlockUsage(t, c, sha512Hex))
+		assert.Empty(t, node.deletions())
+
+		// shared block is reported as existing
+		_, err = createTestBlock(t, c, []byte("quorum met"))
+		assert.Equal(t, errors.ErrQuit, err)
+		assert.Equal(t, uint64(2), testBlockUsage(t, c, sha512Hex))
+	})
+
+	t.Run("QuorumMissed", func(t *testing.T) {
+		node := &testNode{writeQuorum: 2, accepted: &rejected}
+		c := newTestCommander(t, node)
+
+		sha512Hex, err := createTestBlock(t, c, []byte("quorum missed"))
+		assert.NotNil(t, err)
+		assert.Equal(t, uint64(0), testBlockUsage(t, c, sha512Hex))
+		assert.Equal(t, []testNotification{{sha512Hex: sha512Hex, usage: 0}}, node.deletions())
+	})
+
+	t.Run("QuorumMissedSharedBlock", func(t *testing.T) {
+		node := &testNode{writeQuorum: 2, accepted: &accepted}
+		c := newTestCommander(t, node)
+
+		sha512Hex, err := createTestBlock(t, c, []byte("shared block"))
+		assert.Nil(t, err)
+
+		// only the usage that is added by the failed creation is reverted
+		node.accepted = &rejected
+		_, err = createTestBlock(t, c, []byte("shared block"))
+		assert.NotNil(t, err)
+		assert.NotEqual(t, errors.ErrQuit, err)
+		assert.Equal(t, uint64(1), testBlockUsage(t, c, sha512Hex))
+		assert.Equal(t, []testNotification{{sha512Hex: sha512Hex, usage: 1}}, node.deletions())
+	})
+
+	t.Run("QuorumTimeout", func(t *testing.T) {
+		node := &testNode{writeQuorum: 2}
+		c := newTestCommander(t, node)
+
+		begins := time.Now()
+		sha512Hex, err := createTestBlock(t, c, []byte("quorum timeout"))
+		assert.NotNil(t, err)
+		assert.GreaterOrEqual(t, time.Since(begins), testWait)
+		assert.Equal(t, uint64(0), testBlockUsage(t, c, sha512Hex))
+		assert.Len(t, node.deletions(), 1)
+	})
+
+	t.Run("TimeoutWithoutQuorum", func(t *testing.T) {
+		node := &testNode{writeQuorum: 1}
+		c := newTestCommander(t, node)
+
+		sha512Hex, err := createTestBlock(t, c, []byte("no quorum"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), testBlockUsage(t, c, sha512Hex))
+		assert.Empty(t, node.deletions())
+	})
+
+	t.Run("TimeoutOnSlave", func(t *testing.T) {
+		// the manager replicates only the blocks of the master synchronously
+		node := &testNode{masterAddress: "127.0.0.1:9430", writeQuorum: 3}
+		c := newTestCommander(t, node)
+
+		sha512Hex, err := createTestBlock(t, c, []byte("slave block"))
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), testBlockUsage(t, c, sha512Hex))
+		assert.Empty(t, node.deletions())
+	})
+}
+
+func TestCommander_SyncCreateWait(t *testing.T) {
+	accepted := true
+
+	source := newTestCommander(t, &testNode{accepted: &accepted})
+	sha512Hex, err := createTestBlock(t, source, []byte("synchronous replica"))
+	assert.Nil(t, err)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer func() { _ = listener.Close() }()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go source.Handler(conn)
+		}
+	}()
+
+	sourceAddr := listener.Addr().String()
+	sha512Sum, _ := hex.DecodeString(sha512Hex)
+
+	for _, command := range []string{"SXCW", "SYCW"} {
+		t.Run(command, func(t *testing.T) {
+			target := newTestCommander(t, &testNode{masterAddress: sourceAddr})
+
+			server, client := net.Pipe()
+			go target.Handler(server)
+
+			_, err := client.Write([]byte(command))
+			assert.Nil(t, err)
+			_, err = client.Write(sha512Sum)
+			assert.Nil(t, err)
+			if wideUsage(command) {
+				assert.Nil(t, binary.Write(client, binary.LittleEndian, uint64(1)))
+			} else {
+				assert.Nil(t, binary.Write(client, binary.LittleEndian, uint16(1)))
+			}
+			assert.Nil(t, binary.Write(client, binary.LittleEndian, uint8(len(sourceAddr))))
+			_, err = client.Write([]byte(sourceAddr))
+			assert.Nil(t, err)
+
+			// the reply is sent after the block is stored
+			result := make([]byte, 1)
+			_, err = client.Read(result)
+			assert.Nil(t, err)
+			assert.Equal(t, "+", string(result))
+			_ = client.Close()
+
+			assert.Equal(t, uint64(1), testBlockUsage(t, target, sha512Hex))
+		})
+	}
+
+	t.Run("MissingSource", func(t *testing.T) {
+		target := newTestCommander(t, &testNode{masterAddress: sourceAddr})
+		missing := hex.EncodeToString(make([]byte, 32))
+
+		err := target.fs.Sync(func(sync filesystem.Synchronize) error {
+			return sync.CreateWait(sourceAddr, missing, 1)
+		})
+		assert.NotNil(t, err)
+		assert.Equal(t, uint64(0), testBlockUsage(t, target, missing))
+	})
+}
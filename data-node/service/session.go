@@ -0,0 +1,277 @@
+package service
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/protocol"
+	"go.uber.org/zap"
+)
+
+const sessionIdleTimeout = time.Minute * 5
+const sessionConcurrency = 64
+
+// sessionReadFrameSize is the payload limit of the read reply frames. Bigger ranges are sent in many frames, so
+// the replies of the other requests are not held behind a big block and the range is not buffered as a whole
+const sessionReadFrameSize = 1024 * 1024 // 1mb
+
+var errBlockMissing = fmt.Errorf("block is missing")
+
+// session serves the multiplexed protocol on the long-lived connection. Every request frame is processed in its
+// own go routine and the responses are written back as soon as they are ready, in any order
+type session struct {
+	commander *commander
+	conn      net.Conn
+
+	writeMutex sync.Mutex
+	semaphore  chan struct{}
+	wg         sync.WaitGroup
+}
+
+func (c *commander) negotiate(conn net.Conn) {
+	version := make([]byte, 1)
+	if err := c.readWithTimeout(conn, version, len(version)); err != nil {
+		return
+	}
+
+	if version[0] < protocol.Version2 {
+		_ = c.writeWithTimeout(conn, []byte("-"))
+		return
+	}
+
+	if err := c.writeWithTimeout(conn, []byte{'+', protocol.Version2}); err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	s := &session{
+		commander:  c,
+		conn:       conn,
+		writeMutex: sync.Mutex{},
+		semaphore:  make(chan struct{}, sessionConcurrency),
+		wg:         sync.WaitGroup{},
+	}
+	s.serve()
+}
+
+func (s *session) serve() {
+	defer s.wg.Wait()
+
+	for {
+		if err := s.conn.SetReadDeadline(time.Now().Add(sessionIdleTimeout)); err != nil {
+			return
+		}
+
+		frame, err := protocol.ReadFrame(s.conn, func(length uint32) error {
+			return s.conn.SetReadDeadline(time.Now().Add(s.commander.transferDuration(int(length))))
+		})
+		if err != nil {
+			if netErr, ok := err.(net.Error); err != io.EOF && (!ok || !netErr.Timeout()) {
+				s.commander.logger.Error(
+					"Session unable to read",
+					zap.String("connection", s.conn.RemoteAddr().String()),
+					zap.Error(err),
+				)
+			}
+			return
+		}
+
+		s.semaphore <- struct{}{}
+		s.wg.Add(1)
+
+		go func(frame *protocol.Frame) {
+			defer func() {
+				<-s.semaphore
+				s.wg.Done()
+			}()
+
+			_ = s.reply(s.process(frame))
+		}(frame)
+	}
+}
+
+func (s *session) reply(frame *protocol.Frame) error {
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.commander.transferDuration(len(frame.Payload)))); err != nil {
+		return err
+	}
+
+	if err := protocol.WriteFrame(s.conn, frame); err != nil {
+		s.commander.logger.Error(
+			"Session unable to write",
+			zap.String("connection", s.conn.RemoteAddr().String()),
+			zap.Error(err),
+		)
+		_ = s.conn.Close()
+		return err
+	}
+
+	return nil
+}
+
+func (s *session) process(frame *protocol.Frame) *protocol.Frame {
+	response := &protocol.Frame{
+		Id:      frame.Id,
+		Command: frame.Command,
+		Status:  protocol.StatusSuccess,
+	}
+
+	var err error
+	switch frame.Command {
+	case "CREA":
+		response.Payload, err = s.crea(frame.Payload)
+	case "READ":
+		response.Payload, err = s.read(frame)
+	case "DELE":
+		err = s.dele(frame.Payload)
+	case "PING":
+	default:
+		err = fmt.Errorf("not a meaningful command")
+	}
+
+	if err == nil {
+		return response
+	}
+
+	if err == errBlockMissing {
+		response.Status = protocol.StatusMissing
+		return response
+	}
+
+	s.commander.logger.Error(
+		"Unable to process command",
+		zap.String("command", frame.Command),
+		zap.String("connection", s.conn.RemoteAddr().String()),
+		zap.Error(err),
+	)
+
+	response.Status = protocol.StatusFailure
+	response.Payload = []byte(err.Error())
+
+	return response
+}
+
+func (s *session) hashAsHex(payload []byte) (string, error) {
+	if len(payload) < 32 {
+		return "", fmt.Errorf("payload is too short")
+	}
+	return hex.EncodeToString(payload[:32]), nil
+}
+
+// crea payload is the block hash and optionally the block data. When the data is not sent and the block does not
+// exist, the request is replied with missing status and the client should send it again with the data.
+// Successful reply has a single byte payload, 1 if the block is already exists and shared
+func (s *session) crea(payload []byte) ([]byte, error) {
+	sha512Hex, err := s.hashAsHex(payload)
+	if err != nil {
+		return nil, err
+	}
+	data := payload[32:]
+
+	blockUsage, blockSize, err := s.commander.create(sha512Hex, func() ([]byte, error) {
+		if len(data) == 0 {
+			return nil, errBlockMissing
+		}
+		return data, nil
+	})
+	if err != nil && err != errors.ErrQuit {
+		return nil, err
+	}
+
+	if err := s.commander.notifyCreate(sha512Hex, blockUsage, blockSize, err); err != nil {
+		if err == errors.ErrQuit {
+			return []byte{1}, nil
+		}
+		return nil, err
+	}
+
+	return []byte{0}, nil
+}
+
+// read payload is the block hash, begins and ends of the range. The data of the range is replied in the partial
+// frames of sessionReadFrameSize and the rest of it is the payload of the final frame
+func (s *session) read(frame *protocol.Frame) ([]byte, error) {
+	payload := frame.Payload
+
+	sha512Hex, err := s.hashAsHex(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) != 40 {
+		return nil, fmt.Errorf("payload is not in the expected size")
+	}
+
+	begins := binary.LittleEndian.Uint32(payload[32:36])
+	ends := binary.LittleEndian.Uint32(payload[36:40])
+
+	var data []byte
+	if err := s.commander.readBlock(sha512Hex, begins, ends,
+		func(size uint32) error {
+			if size > sessionReadFrameSize {
+				size = sessionReadFrameSize
+			}
+			data = make([]byte, 0, size)
+			return nil
+		},
+		func(d []byte) error {
+			for len(d) > 0 {
+				size := sessionReadFrameSize - len(data)
+				if size > len(d) {
+					size = len(d)
+				}
+				data = append(data, d[:size]...)
+				d = d[size:]
+
+				if len(data) < sessionReadFrameSize {
+					continue
+				}
+
+				if err := s.reply(&protocol.Frame{
+					Id:      frame.Id,
+					Command: frame.Command,
+					Status:  protocol.StatusPartial,
+					Payload: data,
+				}); err != nil {
+					return err
+				}
+				data = data[:0]
+			}
+			return nil
+		}); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("block is not exists")
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// dele payload is the block hash
+func (s *session) dele(payload []byte) error {
+	sha512Hex, err := s.hashAsHex(payload)
+	if err != nil {
+		return err
+	}
+
+	blockUsage, blockSize, err := s.commander.delete(sha512Hex)
+	if err != nil {
+		if err == errors.ErrQuit {
+			return nil
+		}
+		return err
+	}
+
+	s.commander.notifyDelete(sha512Hex, blockUsage, blockSize)
+
+	return nil
+}
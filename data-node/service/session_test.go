@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestSession serves the session on one end of the pipe and returns the other end as the client
+func newTestSession(t *testing.T) (*commander, net.Conn) {
+	c := newTestCommander(t, &testNode{writeQuorum: 1})
+
+	server, client := net.Pipe()
+	s := &session{
+		commander:  c,
+		conn:       server,
+		writeMutex: sync.Mutex{},
+		semaphore:  make(chan struct{}, sessionConcurrency),
+		wg:         sync.WaitGroup{},
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.serve()
+		close(done)
+	}()
+
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+		<-done
+	})
+
+	return c, client
+}
+
+func sessionRequest(t *testing.T, conn net.Conn, id uint32, command string, payload []byte) {
+	assert.Nil(t, conn.SetWriteDeadline(time.Now().Add(time.Second*5)))
+	assert.Nil(t, protocol.WriteFrame(conn, &protocol.Frame{Id: id, Command: command, Status: protocol.StatusRequest, Payload: payload}))
+}
+
+// sessionReply reads the frames of the reply till the final one
+func sessionReply(t *testing.T, conn net.Conn) []*protocol.Frame {
+	frames := make([]*protocol.Frame, 0)
+	for {
+		assert.Nil(t, conn.SetReadDeadline(time.Now().Add(time.Second*5)))
+
+		frame, err := protocol.ReadFrame(conn, nil)
+		if !assert.Nil(t, err) {
+			return frames
+		}
+		frames = append(frames, frame)
+
+		if frame.Status != protocol.StatusPartial {
+			return frames
+		}
+	}
+}
+
+func sessionCall(t *testing.T, conn net.Conn, command string, payload []byte) *protocol.Frame {
+	sessionRequest(t, conn, 1, command, payload)
+
+	frames := sessionReply(t, conn)
+	if !assert.Len(t, frames, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, uint32(1), frames[0].Id)
+	assert.Equal(t, command, frames[0].Command)
+
+	return frames[0]
+}
+
+func readPayload(data []byte, begins uint32, ends uint32) []byte {
+	sum := sha512.Sum512_256(data)
+
+	payload := make([]byte, 40)
+	copy(payload, sum[:])
+	binary.LittleEndian.PutUint32(payload[32:], begins)
+	binary.LittleEndian.PutUint32(payload[36:], ends)
+
+	return payload
+}
+
+func TestSession_Create(t *testing.T) {
+	c, conn := newTestSession(t)
+
+	data := []byte("session block")
+	sum := sha512.Sum512_256(data)
+
+	// the block is asked with the data when the node does not have it
+	frame := sessionCall(t, conn, "CREA", sum[:])
+	assert.Equal(t, protocol.StatusMissing, frame.Status)
+
+	frame = sessionCall(t, conn, "CREA", append(sum[:], data...))
+	assert.Equal(t, protocol.StatusSuccess, frame.Status)
+	assert.Equal(t, []byte{0}, frame.Payload)
+
+	// existing block is shared without the data
+	frame = sessionCall(t, conn, "CREA", sum[:])
+	assert.Equal(t, protocol.StatusSuccess, frame.Status)
+	assert.Equal(t, []byte{1}, frame.Payload)
+
+	assert.Equal(t, uint64(2), testBlockUsage(t, c, hex.EncodeToString(sum[:])))
+}
+
+func TestSession_Read(t *testing.T) {
+	c, conn := newTestSession(t)
+
+	data := []byte("0123456789")
+	_, err := createTestBlock(t, c, data)
+	assert.Nil(t, err)
+
+	frame := sessionCall(t, conn, "READ", readPayload(data, 0, 0))
+	assert.Equal(t, protocol.StatusSuccess, frame.Status)
+	assert.Equal(t, data, frame.Payload)
+
+	frame = sessionCall(t, conn, "READ", readPayload(data, 2, 5))
+	assert.Equal(t, protocol.StatusSuccess, frame.Status)
+	assert.Equal(t, []byte("234"), frame.Payload)
+
+	frame = sessionCall(t, conn, "READ", readPayload(data, 5, 20))
+	assert.Equal(t, protocol.StatusFailure, frame.Status)
+	assert.Contains(t, frame.Message(), "range is not satisfied")
+
+	frame = sessionCall(t, conn, "READ", readPayload([]byte("missing"), 0, 0))
+	assert.Equal(t, protocol.StatusFailure, frame.Status)
+	assert.Equal(t, "block is not exists", frame.Message())
+
+	frame = sessionCall(t, conn, "READ", readPayload(data, 0, 0)[:36])
+	assert.Equal(t, protocol.StatusFailure, frame.Status)
+}
+
+func TestSession_ReadFrames(t *testing.T) {
+	c, conn := newTestSession(t)
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), (sessionReadFrameSize*2+1024)/16)
+	_, err := createTestBlock(t, c, data)
+	assert.Nil(t, err)
+
+	sessionRequest(t, conn, 7, "READ", readPayload(data, 0, 0))
+	frames := sessionReply(t, conn)
+	if !assert.Len(t, frames, 3) {
+		return
+	}
+
+	received := make([]byte, 0, len(data))
+	for i, frame := range frames {
+		assert.Equal(t, uint32(7), frame.Id)
+		if i < 2 {
+			assert.Equal(t, protocol.StatusPartial, frame.Status)
+			assert.Len(t, frame.Payload, sessionReadFrameSize)
+		}
+		received = append(received, frame.Payload...)
+	}
+	assert.Equal(t, protocol.StatusSuccess, frames[2].Status)
+	assert.Len(t, frames[2].Payload, 1024)
+	assert.Equal(t, data, received)
+
+	// the range that fits the frame size exactly ends with the empty final frame
+	sessionRequest(t, conn, 8, "READ", readPayload(data, 0, sessionReadFrameSize))
+	frames = sessionReply(t, conn)
+	if assert.Len(t, frames, 2) {
+		assert.Equal(t, protocol.StatusPartial, frames[0].Status)
+		assert.Equal(t, data[:sessionReadFrameSize], frames[0].Payload)
+		assert.Equal(t, protocol.StatusSuccess, frames[1].Status)
+		assert.Empty(t, frames[1].Payload)
+	}
+}
+
+func TestSession_Delete(t *testing.T) {
+	c, conn := newTestSession(t)
+
+	data := []byte("deleted block")
+	sha512Hex, err := createTestBlock(t, c, data)
+	assert.Nil(t, err)
+
+	sum := sha512.Sum512_256(data)
+	frame := sessionCall(t, conn, "DELE", sum[:])
+	assert.Equal(t, protocol.StatusSuccess, frame.Status)
+	assert.Equal(t, uint64(0), testBlockUsage(t, c, sha512Hex))
+
+	frame = sessionCall(t, conn, "READ", readPayload(data, 0, 0))
+	assert.Equal(t, protocol.StatusFailure, frame.Status)
+}
+
+func TestSession_Commands(t *testing.T) {
+	_, conn := newTestSession(t)
+
+	frame := sessionCall(t, conn, "PING", nil)
+	assert.Equal(t, protocol.StatusSuccess, frame.Status)
+
+	frame = sessionCall(t, conn, "HWID", nil)
+	assert.Equal(t, protocol.StatusFailure, frame.Status)
+	assert.Equal(t, "not a meaningful command", frame.Message())
+
+	frame = sessionCall(t, conn, "DELE", []byte("short"))
+	assert.Equal(t, protocol.StatusFailure, frame.Status)
+	assert.Equal(t, "payload is too short", frame.Message())
+}
+
+func TestSession_Pipelining(t *testing.T) {
+	c, conn := newTestSession(t)
+
+	blocks := make([][]byte, 0)
+	for i := 0; i < 5; i++ {
+		data := bytes.Repeat([]byte{byte('a' + i)}, 100*(i+1))
+		_, err := createTestBlock(t, c, data)
+		assert.Nil(t, err)
+
+		blocks = append(blocks, data)
+	}
+
+	// all requests are sent before any reply is read, replies are paired by their ids
+	go func() {
+		for i, data := range blocks {
+			sessionRequest(t, conn, uint32(i+1), "READ", readPayload(data, 0, 0))
+		}
+	}()
+
+	for range blocks {
+		frames := sessionReply(t, conn)
+		if !assert.Len(t, frames, 1) {
+			return
+		}
+		assert.Equal(t, protocol.StatusSuccess, frames[0].Status)
+		assert.Equal(t, blocks[frames[0].Id-1], frames[0].Payload)
+	}
+}
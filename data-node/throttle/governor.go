@@ -0,0 +1,96 @@
+package throttle
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+)
+
+// priorityMaxDelay is the longest time that a background transfer waits the client requests to complete. It keeps
+// the background transfers alive under the continuous client traffic
+const priorityMaxDelay = time.Second
+const priorityCheckInterval = time.Millisecond * 10
+
+// Governor interface limits the background transfer traffic of the data node and keeps it behind the client
+// requests
+type Governor interface {
+	// Apply replaces the limits of the operations, waiting transfers are evaluated with the new limits
+	Apply(throttle common.Throttle)
+	// Limits returns the current limits of the operations
+	Limits() common.Throttle
+
+	// Client marks the client request as in progress till the returned func is called
+	Client() func()
+	// Acquire waits the concurrency limit of the operation and returns the func to release the transfer slot
+	Acquire(operation common.ThrottleOperations) func()
+	// Wait blocks till the client requests are completed and the rate limit of the operation allows the transfer
+	// of the size
+	Wait(operation common.ThrottleOperations, size int)
+}
+
+type governor struct {
+	clients int64
+
+	mutex    sync.Mutex
+	limiters map[common.ThrottleOperations]*limiter
+}
+
+// NewGovernor creates the throttle governor without any limits
+func NewGovernor() Governor {
+	return &governor{
+		mutex: sync.Mutex{},
+		limiters: map[common.ThrottleOperations]*limiter{
+			common.ThrottleSync: newLimiter(),
+			common.ThrottleMove: newLimiter(),
+		},
+	}
+}
+
+func (g *governor) limiter(operation common.ThrottleOperations) *limiter {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	return g.limiters[operation]
+}
+
+func (g *governor) Apply(throttle common.Throttle) {
+	g.limiter(common.ThrottleSync).apply(throttle.Sync)
+	g.limiter(common.ThrottleMove).apply(throttle.Move)
+}
+
+func (g *governor) Limits() common.Throttle {
+	return common.Throttle{
+		Sync: g.limiter(common.ThrottleSync).current(),
+		Move: g.limiter(common.ThrottleMove).current(),
+	}
+}
+
+func (g *governor) Client() func() {
+	atomic.AddInt64(&g.clients, 1)
+	return func() {
+		atomic.AddInt64(&g.clients, -1)
+	}
+}
+
+func (g *governor) Acquire(operation common.ThrottleOperations) func() {
+	l := g.limiter(operation)
+	l.acquire()
+	return l.release
+}
+
+func (g *governor) Wait(operation common.ThrottleOperations, size int) {
+	g.yield()
+	g.limiter(operation).wait(size)
+}
+
+// yield waits the client requests in progress to complete
+func (g *governor) yield() {
+	waitTill := time.Now().Add(priorityMaxDelay)
+	for atomic.LoadInt64(&g.clients) > 0 && time.Now().Before(waitTill) {
+		time.Sleep(priorityCheckInterval)
+	}
+}
+
+var _ Governor = &governor{}
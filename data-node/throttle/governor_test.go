@@ -0,0 +1,143 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Rate(t *testing.T) {
+	l := newLimiter()
+	l.apply(common.ThrottleLimit{Rate: 1000})
+
+	// bucket bursts up to one second of the transfer
+	delay, _ := l.reserve(1000)
+	assert.Equal(t, time.Duration(0), delay)
+
+	delay, _ = l.reserve(500)
+	assert.InDelta(t, float64(time.Millisecond*500), float64(delay), float64(time.Millisecond*50))
+
+	begins := time.Now()
+	l.wait(100)
+	assert.GreaterOrEqual(t, time.Since(begins), time.Millisecond*550)
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := newLimiter()
+
+	begins := time.Now()
+	for i := 0; i < 100; i++ {
+		l.wait(1024 * 1024)
+	}
+	assert.Less(t, time.Since(begins), time.Millisecond*100)
+}
+
+func TestLimiter_Concurrency(t *testing.T) {
+	l := newLimiter()
+	l.apply(common.ThrottleLimit{Concurrency: 2})
+
+	l.acquire()
+	l.acquire()
+
+	acquired := make(chan bool)
+	go func() {
+		l.acquire()
+		acquired <- true
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("concurrency limit is exceeded")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	l.release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("released slot is not acquired")
+	}
+}
+
+func TestGovernor_ApplyRuntime(t *testing.T) {
+	g := NewGovernor()
+	g.Apply(common.Throttle{Move: common.ThrottleLimit{Rate: 100, Concurrency: 1}})
+	assert.Equal(t, common.Throttle{Move: common.ThrottleLimit{Rate: 100, Concurrency: 1}}, g.Limits())
+
+	release := g.Acquire(common.ThrottleMove)
+	defer release()
+
+	acquired := make(chan bool)
+	go func() {
+		g.Acquire(common.ThrottleMove)()
+		acquired <- true
+	}()
+
+	waited := make(chan bool)
+	go func() {
+		// it takes 9 seconds with the initial rate
+		g.Wait(common.ThrottleMove, 1000)
+		waited <- true
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("concurrency limit is exceeded")
+	case <-waited:
+		t.Fatal("rate limit is exceeded")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	// waiting transfers continue with the new limits
+	g.Apply(common.Throttle{Move: common.ThrottleLimit{Concurrency: 2}})
+
+	for _, c := range []chan bool{acquired, waited} {
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatal("waiting transfer is not evaluated with the new limits")
+		}
+	}
+
+	// limits of the other operations are not affected
+	assert.True(t, g.Limits().Sync.Unlimited())
+}
+
+func TestGovernor_ClientPriority(t *testing.T) {
+	g := NewGovernor()
+
+	done := g.Client()
+
+	waited := make(chan time.Time)
+	go func() {
+		g.Wait(common.ThrottleSync, 1)
+		waited <- time.Now()
+	}()
+
+	time.Sleep(time.Millisecond * 100)
+	completed := time.Now()
+	done()
+
+	select {
+	case at := <-waited:
+		assert.False(t, at.Before(completed))
+	case <-time.After(priorityMaxDelay):
+		t.Fatal("background transfer is not continued after the client request")
+	}
+}
+
+func TestGovernor_ClientPriorityMaxDelay(t *testing.T) {
+	g := NewGovernor()
+
+	done := g.Client()
+	defer done()
+
+	begins := time.Now()
+	g.Wait(common.ThrottleSync, 1)
+
+	elapsed := time.Since(begins)
+	assert.GreaterOrEqual(t, elapsed, priorityMaxDelay)
+	assert.Less(t, elapsed, priorityMaxDelay+time.Millisecond*500)
+}
@@ -0,0 +1,116 @@
+package throttle
+
+import (
+	"sync"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+)
+
+// limiter keeps the concurrency and the transfer rate of an operation in the limits. The rate is controlled with
+// a token bucket that can burst up to one second of the transfer
+type limiter struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+
+	limit  common.ThrottleLimit
+	active int
+
+	tokens   float64
+	refilled time.Time
+	applied  chan struct{}
+}
+
+func newLimiter() *limiter {
+	l := &limiter{
+		mutex:    sync.Mutex{},
+		refilled: time.Now(),
+		applied:  make(chan struct{}),
+	}
+	l.cond = sync.NewCond(&l.mutex)
+
+	return l
+}
+
+func (l *limiter) apply(limit common.ThrottleLimit) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.limit = limit
+	l.tokens = float64(limit.Rate)
+	l.refilled = time.Now()
+
+	// transfers that wait for the tokens are reserved again with the new limit
+	close(l.applied)
+	l.applied = make(chan struct{})
+
+	l.cond.Broadcast()
+}
+
+func (l *limiter) current() common.ThrottleLimit {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.limit
+}
+
+func (l *limiter) acquire() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for l.limit.Concurrency > 0 && l.active >= l.limit.Concurrency {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+func (l *limiter) release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.active--
+	l.cond.Broadcast()
+}
+
+func (l *limiter) wait(size int) {
+	for {
+		delay, applied := l.reserve(size)
+		if delay == 0 {
+			return
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+			return
+		case <-applied:
+			timer.Stop()
+		}
+	}
+}
+
+// reserve takes the tokens of the size and returns the duration to wait for the missing tokens with the channel
+// that is closed when the limit is changed
+func (l *limiter) reserve(size int) (time.Duration, <-chan struct{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.limit.Rate == 0 {
+		return 0, l.applied
+	}
+	rate := float64(l.limit.Rate)
+
+	now := time.Now()
+	l.tokens += now.Sub(l.refilled).Seconds() * rate
+	if l.tokens > rate {
+		l.tokens = rate
+	}
+	l.refilled = now
+
+	l.tokens -= float64(size)
+	if l.tokens >= 0 {
+		return 0, l.applied
+	}
+
+	return time.Duration(-l.tokens / rate * float64(time.Second)), l.applied
+}
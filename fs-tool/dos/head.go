@@ -148,6 +148,8 @@ func Change(headAddresses []string, sources []string, target string, overwrite b
 		return fmt.Errorf("%s have conflicts between file(s)/folder(s)", sourcesErrorString(sources))
 	case 422:
 		return fmt.Errorf("%s and %s should be full and absolute paths", sourcesErrorString(sources), target)
+	case 523:
+		return fmt.Errorf("%s or %s is locked", sourcesErrorString(sources), target)
 	case 524:
 		return fmt.Errorf("%s is zombie or has zombie", sourcesErrorString(sources))
 	case 500:
@@ -319,7 +321,11 @@ func Pull(headAddresses []string, sources []string, target string, readRange *co
 
 	req.Header.Set("X-Path", createXPath(sources))
 	if readRange != nil {
-		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", readRange.Begins, readRange.Ends))
+		if readRange.Ends == -1 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", readRange.Begins))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", readRange.Begins, readRange.Ends))
+		}
 	}
 
 	res, err := client.Do(req)
@@ -338,6 +344,8 @@ func Pull(headAddresses []string, sources []string, target string, readRange *co
 			return fmt.Errorf("%s should be absolute path(s)", sourcesErrorString(sources))
 		}
 		return fmt.Errorf("combining dos folder(s) to local folder is not possible")
+	case 416:
+		return fmt.Errorf("requested range is not satisfiable for %s", sourcesErrorString(sources))
 	case 500:
 		return fmt.Errorf("unable to get %s", sourcesErrorString(sources))
 	case 503:
@@ -400,6 +408,57 @@ func Pull(headAddresses []string, sources []string, target string, readRange *co
 	return nil
 }
 
+func Stream(headAddresses []string, source string, readRange *common.ReadRange, w io.Writer) error {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", headAddresses[0], headEndPoint), nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("X-Path", createXPath([]string{source}))
+	if readRange != nil {
+		if readRange.Ends == -1 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", readRange.Begins))
+		} else {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", readRange.Begins, readRange.Ends))
+		}
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: head node is not reachable", headAddresses[0])
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	switch res.StatusCode {
+	case 404:
+		return fmt.Errorf("%s is not exists", source)
+	case 416:
+		return fmt.Errorf("requested range is not satisfiable for %s", source)
+	case 422:
+		return fmt.Errorf("%s should be an absolute path", source)
+	case 500:
+		return fmt.Errorf("unable to get %s", source)
+	case 503:
+		return fmt.Errorf("cluster(s) is/are unavailable to get %s", source)
+	case 523:
+		return fmt.Errorf("%s is locked", source)
+	case 524:
+		return fmt.Errorf("%s is zombie", source)
+	case 200, 206:
+	default:
+		return fmt.Errorf("dos head returned with an unrecognisable status code: %d", res.StatusCode)
+	}
+
+	if strings.Compare(res.Header.Get("X-Type"), "file") != 0 {
+		return fmt.Errorf("%s is a folder", source)
+	}
+
+	if _, err := io.Copy(w, res.Body); err != nil {
+		return fmt.Errorf("unsuccessful operation")
+	}
+	return nil
+}
+
 func createXPath(sources []string) string {
 	if len(sources) == 1 {
 		return url.QueryEscape(sources[0])
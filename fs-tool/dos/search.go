@@ -0,0 +1,92 @@
+package dos
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+)
+
+const searchEndPoint = "/client/search"
+
+func Search(headAddresses []string, query *common.SearchQuery) (*common.SearchResult, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s%s", headAddresses[0], searchEndPoint), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Path", url.QueryEscape(query.Root))
+	if len(query.Name) > 0 {
+		req.Header.Set("X-Name", url.QueryEscape(query.Name))
+	}
+	if len(query.NameRegex) > 0 {
+		req.Header.Set("X-Name-Regex", url.QueryEscape(query.NameRegex))
+	}
+	if len(query.Mime) > 0 {
+		req.Header.Set("X-Mime", query.Mime)
+	}
+	if query.SizeMin != nil || query.SizeMax != nil {
+		req.Header.Set("X-Size", fmt.Sprintf("%s-%s", formatUint64(query.SizeMin), formatUint64(query.SizeMax)))
+	}
+	if query.CreatedAfter != nil || query.CreatedBefore != nil {
+		req.Header.Set("X-Created", fmt.Sprintf("%s,%s", formatTime(query.CreatedAfter), formatTime(query.CreatedBefore)))
+	}
+	if query.ModifiedAfter != nil || query.ModifiedBefore != nil {
+		req.Header.Set("X-Modified", fmt.Sprintf("%s,%s", formatTime(query.ModifiedAfter), formatTime(query.ModifiedBefore)))
+	}
+	if query.Zombie != nil {
+		req.Header.Set("X-Zombie", strconv.FormatBool(*query.Zombie))
+	}
+	if query.Locked != nil {
+		req.Header.Set("X-Locked", strconv.FormatBool(*query.Locked))
+	}
+	if query.PageSize > 0 {
+		req.Header.Set("X-Page-Size", strconv.Itoa(query.PageSize))
+	}
+	if len(query.Continue) > 0 {
+		req.Header.Set("X-Continue", query.Continue)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s: head node is not reachable", headAddresses[0])
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	switch res.StatusCode {
+	case 404:
+		return nil, fmt.Errorf("%s is not exists", query.Root)
+	case 422:
+		return nil, fmt.Errorf("search query for %s is not valid", query.Root)
+	case 500:
+		return nil, fmt.Errorf("unable to search in %s", query.Root)
+	default:
+		if res.StatusCode != 200 {
+			return nil, fmt.Errorf("dos head returned with an unrecognisable status code: %d", res.StatusCode)
+		}
+	}
+
+	var result *common.SearchResult
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("unable to search in %s", query.Root)
+	}
+
+	return result, nil
+}
+
+func formatUint64(v *uint64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatUint(*v, 10)
+}
+
+func formatTime(v *time.Time) string {
+	if v == nil {
+		return ""
+	}
+	return v.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,484 @@
+package dos
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+)
+
+// Sync actions
+const (
+	SAUpload   = "upload"
+	SADownload = "download"
+	SACreate   = "mkdir"
+	SADelete   = "delete"
+)
+
+const defaultSyncWorkers = 4
+
+// SyncOptions struct is to define the behaviour of the sync operation
+// Delete removes the target entries that do not exist in the source
+// DryRun only reports the actions without applying them
+// Checksum compares the files with the checksum even if size and modification time are matching
+// Workers is the count of the parallel file transfers
+type SyncOptions struct {
+	Delete   bool
+	DryRun   bool
+	Checksum bool
+	Workers  int
+}
+
+// SyncAction struct is to hold the action that is applied to the target entry
+type SyncAction struct {
+	Type string
+	Path string
+	Size uint64
+}
+
+// SyncSummary struct is to hold the result of the sync operation
+type SyncSummary struct {
+	Transferred     int
+	TransferredSize uint64
+	Created         int
+	Deleted         int
+	Unchanged       int
+	Failed          map[string]error
+}
+
+// SyncProgressHandler is called for every action when it is completed. err is nil on success
+type SyncProgressHandler func(action *SyncAction, err error)
+
+type syncEntry struct {
+	folder   bool
+	size     uint64
+	modified time.Time
+	checksum string
+}
+
+type syncer struct {
+	headAddresses []string
+	localRoot     string
+	remoteRoot    string
+	upload        bool
+	options       SyncOptions
+	handler       SyncProgressHandler
+
+	mutex   sync.Mutex
+	summary *SyncSummary
+}
+
+// SyncUp uploads the differences of the local folder to the dos folder
+func SyncUp(headAddresses []string, localPath string, remotePath string, options SyncOptions, handler SyncProgressHandler) (*SyncSummary, error) {
+	return newSyncer(headAddresses, localPath, remotePath, true, options, handler).run()
+}
+
+// SyncDown downloads the differences of the dos folder to the local folder
+func SyncDown(headAddresses []string, remotePath string, localPath string, options SyncOptions, handler SyncProgressHandler) (*SyncSummary, error) {
+	return newSyncer(headAddresses, localPath, remotePath, false, options, handler).run()
+}
+
+func newSyncer(headAddresses []string, localRoot string, remoteRoot string, upload bool, options SyncOptions, handler SyncProgressHandler) *syncer {
+	if options.Workers <= 0 {
+		options.Workers = defaultSyncWorkers
+	}
+	if handler == nil {
+		handler = func(_ *SyncAction, _ error) {}
+	}
+
+	return &syncer{
+		headAddresses: headAddresses,
+		localRoot:     filepath.Clean(localRoot),
+		remoteRoot:    common.CorrectPath(remoteRoot),
+		upload:        upload,
+		options:       options,
+		handler:       handler,
+		summary: &SyncSummary{
+			Failed: make(map[string]error),
+		},
+	}
+}
+
+func (s *syncer) run() (*SyncSummary, error) {
+	localIndex, err := s.localIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	remoteIndex, err := s.remoteIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	sourceIndex, targetIndex := localIndex, remoteIndex
+	if !s.upload {
+		sourceIndex, targetIndex = remoteIndex, localIndex
+	}
+
+	sourcePaths := s.sortedPaths(sourceIndex)
+
+	conflicts := make([]string, 0)
+	transfers := make([]string, 0)
+	for _, relativePath := range sourcePaths {
+		sourceEntry := sourceIndex[relativePath]
+		targetEntry, has := targetIndex[relativePath]
+
+		if has && sourceEntry.folder != targetEntry.folder {
+			if !s.options.Delete {
+				s.fail(relativePath, fmt.Errorf("%s has a different type in the target, use delete option to replace", relativePath))
+				continue
+			}
+			conflicts = append(conflicts, relativePath)
+			has = false
+		}
+
+		if sourceEntry.folder {
+			if !has {
+				transfers = append(transfers, relativePath)
+			}
+			continue
+		}
+
+		if has && !s.changed(relativePath, sourceEntry, targetEntry) {
+			s.summary.Unchanged++
+			continue
+		}
+		transfers = append(transfers, relativePath)
+	}
+
+	deletions := conflicts
+	if s.options.Delete {
+		deletions = s.extraneous(sourceIndex, targetIndex, deletions)
+	}
+	s.delete(deletions)
+
+	files := make([]string, 0)
+	for _, relativePath := range transfers {
+		if sourceIndex[relativePath].folder {
+			s.createFolder(relativePath)
+			continue
+		}
+		files = append(files, relativePath)
+	}
+	s.transfer(files, sourceIndex)
+
+	return s.summary, nil
+}
+
+func (s *syncer) sortedPaths(index map[string]*syncEntry) []string {
+	paths := make([]string, 0, len(index))
+	for p := range index {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// extraneous appends the top most target entries that do not exist in the source to the deletions
+func (s *syncer) extraneous(sourceIndex map[string]*syncEntry, targetIndex map[string]*syncEntry, deletions []string) []string {
+	for _, relativePath := range s.sortedPaths(targetIndex) {
+		if _, has := sourceIndex[relativePath]; has {
+			continue
+		}
+
+		covered := false
+		for _, deletion := range deletions {
+			if strings.Index(relativePath, fmt.Sprintf("%s/", deletion)) == 0 {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			deletions = append(deletions, relativePath)
+		}
+	}
+	return deletions
+}
+
+func (s *syncer) changed(relativePath string, sourceEntry *syncEntry, targetEntry *syncEntry) bool {
+	if sourceEntry.size != targetEntry.size {
+		return true
+	}
+
+	if !s.options.Checksum {
+		if s.upload {
+			// dos keeps the upload time as modification time
+			if !targetEntry.modified.Before(sourceEntry.modified) {
+				return false
+			}
+		} else {
+			// downloaded files carry the dos modification time
+			if targetEntry.modified.Truncate(time.Second).Equal(sourceEntry.modified.Truncate(time.Second)) {
+				return false
+			}
+		}
+	}
+
+	localEntry, remoteEntry := sourceEntry, targetEntry
+	if !s.upload {
+		localEntry, remoteEntry = targetEntry, sourceEntry
+	}
+
+	checksum, err := s.localChecksum(relativePath)
+	if err != nil {
+		return true
+	}
+	localEntry.checksum = checksum
+
+	return strings.Compare(localEntry.checksum, remoteEntry.checksum) != 0
+}
+
+func (s *syncer) localChecksum(relativePath string) (string, error) {
+	file, err := os.Open(s.localPath(relativePath))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	hash := sha512.New512_256()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (s *syncer) delete(deletions []string) {
+	for _, relativePath := range deletions {
+		action := &SyncAction{Type: SADelete, Path: s.targetPath(relativePath)}
+
+		var err error
+		if !s.options.DryRun {
+			if s.upload {
+				err = Delete(s.headAddresses, action.Path, false)
+			} else {
+				err = os.RemoveAll(action.Path)
+			}
+		}
+		s.complete(relativePath, action, err)
+	}
+}
+
+func (s *syncer) createFolder(relativePath string) {
+	action := &SyncAction{Type: SACreate, Path: s.targetPath(relativePath)}
+
+	var err error
+	if !s.options.DryRun {
+		if s.upload {
+			err = MakeFolder(s.headAddresses, action.Path)
+		} else {
+			err = os.MkdirAll(action.Path, 0777)
+		}
+	}
+	s.complete(relativePath, action, err)
+}
+
+func (s *syncer) transfer(files []string, sourceIndex map[string]*syncEntry) {
+	transferChan := make(chan string, s.options.Workers)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < s.options.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for relativePath := range transferChan {
+				sourceEntry := sourceIndex[relativePath]
+
+				action := &SyncAction{Type: SADownload, Path: s.targetPath(relativePath), Size: sourceEntry.size}
+				if s.upload {
+					action.Type = SAUpload
+				}
+
+				var err error
+				if !s.options.DryRun {
+					if s.upload {
+						err = PutFile(s.headAddresses, s.localPath(relativePath), action.Path, true)
+					} else {
+						err = s.download(s.remotePath(relativePath), action.Path, sourceEntry.modified)
+					}
+				}
+				s.complete(relativePath, action, err)
+			}
+		}()
+	}
+
+	for _, relativePath := range files {
+		transferChan <- relativePath
+	}
+	close(transferChan)
+
+	wg.Wait()
+}
+
+// download pulls the file to a temporary location in the target folder and replaces the target when it is completed
+func (s *syncer) download(source string, target string, modified time.Time) error {
+	temp := fmt.Sprintf("%s.sync-part", target)
+	defer func() { _ = os.Remove(temp) }()
+
+	if err := Pull(s.headAddresses, []string{source}, temp, nil); err != nil {
+		return err
+	}
+	if err := os.Chtimes(temp, modified, modified); err != nil {
+		return err
+	}
+	return os.Rename(temp, target)
+}
+
+func (s *syncer) complete(relativePath string, action *SyncAction, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err != nil {
+		s.summary.Failed[relativePath] = err
+	} else {
+		switch action.Type {
+		case SAUpload, SADownload:
+			s.summary.Transferred++
+			s.summary.TransferredSize += action.Size
+		case SACreate:
+			s.summary.Created++
+		case SADelete:
+			s.summary.Deleted++
+		}
+	}
+	s.handler(action, err)
+}
+
+func (s *syncer) fail(relativePath string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.summary.Failed[relativePath] = err
+}
+
+func (s *syncer) localPath(relativePath string) string {
+	return filepath.Join(s.localRoot, filepath.FromSlash(relativePath))
+}
+
+func (s *syncer) remotePath(relativePath string) string {
+	return common.Join(s.remoteRoot, relativePath)
+}
+
+func (s *syncer) targetPath(relativePath string) string {
+	if s.upload {
+		return s.remotePath(relativePath)
+	}
+	return s.localPath(relativePath)
+}
+
+func (s *syncer) localIndex() (map[string]*syncEntry, error) {
+	index := make(map[string]*syncEntry)
+
+	info, err := os.Stat(s.localRoot)
+	if err != nil {
+		if os.IsNotExist(err) && !s.upload {
+			if s.options.DryRun {
+				return index, nil
+			}
+			if err := os.MkdirAll(s.localRoot, 0777); err != nil {
+				return nil, fmt.Errorf("unable to create %s", s.localRoot)
+			}
+			return index, nil
+		}
+		return nil, fmt.Errorf("unable to read %s", s.localRoot)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s should be a folder", s.localRoot)
+	}
+
+	err = filepath.Walk(s.localRoot, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.Compare(p, s.localRoot) == 0 {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(s.localRoot, p)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+
+		if !info.IsDir() && strings.HasSuffix(relativePath, ".sync-part") {
+			return nil
+		}
+
+		index[relativePath] = &syncEntry{
+			folder:   info.IsDir(),
+			size:     uint64(info.Size()),
+			modified: info.ModTime(),
+		}
+		if info.IsDir() {
+			index[relativePath].size = 0
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", s.localRoot, err.Error())
+	}
+
+	return index, nil
+}
+
+func (s *syncer) remoteIndex() (map[string]*syncEntry, error) {
+	index := make(map[string]*syncEntry)
+
+	folder, err := List(s.headAddresses, s.remoteRoot, false)
+	if err != nil {
+		if !s.upload {
+			return nil, err
+		}
+		// target folder may not exist, create it to start the sync
+		if s.options.DryRun {
+			return index, nil
+		}
+		if MakeFolder(s.headAddresses, s.remoteRoot) != nil {
+			return nil, err
+		}
+		return index, nil
+	}
+
+	if err := s.walkRemote(folder, index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+func (s *syncer) walkRemote(folder *common.Folder, index map[string]*syncEntry) error {
+	relativeFolder := strings.TrimPrefix(strings.TrimPrefix(folder.Full, s.remoteRoot), "/")
+
+	for _, file := range folder.Files {
+		index[path.Join(relativeFolder, file.Name)] = &syncEntry{
+			size:     file.Size,
+			modified: file.Modified,
+			checksum: file.Checksum,
+		}
+	}
+
+	for _, shadow := range folder.Folders {
+		index[path.Join(relativeFolder, shadow.Name)] = &syncEntry{
+			folder:   true,
+			modified: shadow.Created,
+		}
+
+		child, err := List(s.headAddresses, shadow.Full, false)
+		if err != nil {
+			return err
+		}
+		if err := s.walkRemote(child, index); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
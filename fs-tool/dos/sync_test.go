@@ -0,0 +1,376 @@
+package dos
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type testHeadFile struct {
+	content  []byte
+	modified time.Time
+}
+
+// testHead serves the dos requests of the sync from the memory. Uploaded files take the upload time as
+// modification time like the head node does
+type testHead struct {
+	mutex   sync.Mutex
+	folders map[string]bool
+	files   map[string]*testHeadFile
+}
+
+func newTestHead(t *testing.T) (*testHead, []string) {
+	h := &testHead{
+		folders: map[string]bool{"/": true},
+		files:   make(map[string]*testHeadFile),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(h.handle))
+	t.Cleanup(server.Close)
+
+	return h, []string{strings.TrimPrefix(server.URL, "http://")}
+}
+
+func (h *testHead) handle(w http.ResponseWriter, r *http.Request) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	p, err := url.QueryUnescape(r.Header.Get("X-Path"))
+	if err != nil {
+		w.WriteHeader(422)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, p)
+	case http.MethodPost:
+		if strings.Compare(r.Header.Get("X-Apply-To"), "folder") == 0 {
+			if h.folders[p] {
+				w.WriteHeader(409)
+				return
+			}
+			h.createFolder(p)
+			w.WriteHeader(202)
+			return
+		}
+
+		content, _ := io.ReadAll(r.Body)
+		parent, _ := common.Split(p)
+		h.createFolder(parent)
+		h.files[p] = &testHeadFile{content: content, modified: time.Now().UTC()}
+		w.WriteHeader(202)
+	case http.MethodDelete:
+		if !h.folders[p] && h.files[p] == nil {
+			w.WriteHeader(404)
+			return
+		}
+		for folderPath := range h.folders {
+			if strings.Compare(folderPath, p) == 0 || strings.HasPrefix(folderPath, p+"/") {
+				delete(h.folders, folderPath)
+			}
+		}
+		for filePath := range h.files {
+			if strings.Compare(filePath, p) == 0 || strings.HasPrefix(filePath, p+"/") {
+				delete(h.files, filePath)
+			}
+		}
+		w.WriteHeader(200)
+	}
+}
+
+func (h *testHead) get(w http.ResponseWriter, p string) {
+	if file, has := h.files[p]; has {
+		w.Header().Set("X-Type", "file")
+		_, _ = w.Write(file.content)
+		return
+	}
+
+	if !h.folders[p] {
+		w.WriteHeader(404)
+		return
+	}
+
+	folder := common.NewFolder(p)
+	for folderPath := range h.folders {
+		parent, name := common.Split(folderPath)
+		if strings.Compare(folderPath, p) != 0 && strings.Compare(parent, p) == 0 {
+			_, _ = folder.NewFolder(name)
+		}
+	}
+	for filePath, content := range h.files {
+		parent, name := common.Split(filePath)
+		if strings.Compare(parent, p) != 0 {
+			continue
+		}
+		file, _ := folder.NewFile(name)
+		file.Size = uint64(len(content.content))
+		file.Modified = content.modified
+		file.Checksum = testChecksum(content.content)
+	}
+
+	w.Header().Set("X-Type", "folder")
+	_ = json.NewEncoder(w).Encode(folder)
+}
+
+func (h *testHead) createFolder(p string) {
+	for len(p) > 0 {
+		h.folders[p] = true
+		if strings.Compare(p, "/") == 0 {
+			return
+		}
+		p, _ = common.Split(p)
+	}
+}
+
+func (h *testHead) put(p string, content string, modified time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	parent, _ := common.Split(p)
+	h.createFolder(parent)
+	h.files[p] = &testHeadFile{content: []byte(content), modified: modified}
+}
+
+func (h *testHead) content(p string) (string, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	file, has := h.files[p]
+	if !has {
+		return "", false
+	}
+	return string(file.content), true
+}
+
+func (h *testHead) hasFolder(p string) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.folders[p]
+}
+
+func testChecksum(content []byte) string {
+	sum := sha512.Sum512_256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeTestFile(t *testing.T, p string, content string, modified time.Time) {
+	assert.Nil(t, os.MkdirAll(filepath.Dir(p), 0777))
+	assert.Nil(t, os.WriteFile(p, []byte(content), 0666))
+	assert.Nil(t, os.Chtimes(p, modified, modified))
+}
+
+func readTestFile(t *testing.T, p string) string {
+	content, err := os.ReadFile(p)
+	assert.Nil(t, err)
+	return string(content)
+}
+
+// syncTestActions collects the completed actions as "type path" sorted
+type syncTestActions struct {
+	mutex   sync.Mutex
+	actions []string
+}
+
+func (s *syncTestActions) handler(action *SyncAction, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err != nil {
+		return
+	}
+	s.actions = append(s.actions, action.Type+" "+action.Path)
+}
+
+func (s *syncTestActions) sorted() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sort.Strings(s.actions)
+	return s.actions
+}
+
+func TestSyncUp(t *testing.T) {
+	head, headAddresses := newTestHead(t)
+	localRoot := t.TempDir()
+
+	past := time.Now().Add(-time.Hour)
+	writeTestFile(t, filepath.Join(localRoot, "a.txt"), "first", past)
+	writeTestFile(t, filepath.Join(localRoot, "docs", "b.txt"), "second", past)
+	assert.Nil(t, os.MkdirAll(filepath.Join(localRoot, "empty"), 0777))
+
+	actions := &syncTestActions{}
+	summary, err := SyncUp(headAddresses, localRoot, "/backup", SyncOptions{}, actions.handler)
+	assert.Nil(t, err)
+	assert.Empty(t, summary.Failed)
+	assert.Equal(t, 2, summary.Transferred)
+	assert.Equal(t, uint64(11), summary.TransferredSize)
+	assert.Equal(t, 2, summary.Created)
+	assert.Equal(t, []string{
+		"mkdir /backup/docs",
+		"mkdir /backup/empty",
+		"upload /backup/a.txt",
+		"upload /backup/docs/b.txt",
+	}, actions.sorted())
+
+	content, _ := head.content("/backup/docs/b.txt")
+	assert.Equal(t, "second", content)
+	assert.True(t, head.hasFolder("/backup/empty"))
+
+	// dos keeps the upload time, so the files are not changed on the second run
+	summary, err = SyncUp(headAddresses, localRoot, "/backup", SyncOptions{}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, summary.Transferred)
+	assert.Equal(t, 2, summary.Unchanged)
+}
+
+func TestSyncUp_Changes(t *testing.T) {
+	head, headAddresses := newTestHead(t)
+	localRoot := t.TempDir()
+
+	past := time.Now().Add(-time.Hour)
+	head.put("/backup/same.txt", "same", past)
+	head.put("/backup/content.txt", "remote", past)
+	head.put("/backup/size.txt", "remote", time.Now().Add(time.Hour))
+	head.put("/backup/newer.txt", "remote", time.Now().Add(time.Hour))
+
+	// same.txt is touched but it has the same content, content.txt has the same size but a different content
+	writeTestFile(t, filepath.Join(localRoot, "same.txt"), "same", time.Now())
+	writeTestFile(t, filepath.Join(localRoot, "content.txt"), "locals", time.Now())
+	writeTestFile(t, filepath.Join(localRoot, "size.txt"), "local", past)
+	writeTestFile(t, filepath.Join(localRoot, "newer.txt"), "locals", past)
+
+	summary, err := SyncUp(headAddresses, localRoot, "/backup", SyncOptions{}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, summary.Transferred)
+	assert.Equal(t, 2, summary.Unchanged)
+
+	content, _ := head.content("/backup/content.txt")
+	assert.Equal(t, "locals", content)
+	content, _ = head.content("/backup/size.txt")
+	assert.Equal(t, "local", content)
+
+	// the remote file is newer than the local one, only the checksum comparison notices the difference
+	content, _ = head.content("/backup/newer.txt")
+	assert.Equal(t, "remote", content)
+
+	summary, err = SyncUp(headAddresses, localRoot, "/backup", SyncOptions{Checksum: true}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, summary.Transferred)
+	content, _ = head.content("/backup/newer.txt")
+	assert.Equal(t, "locals", content)
+}
+
+func TestSync_ConflictDirection(t *testing.T) {
+	head, headAddresses := newTestHead(t)
+	localRoot := t.TempDir()
+
+	modified := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	head.put("/backup/file.txt", "remote", modified)
+	writeTestFile(t, filepath.Join(localRoot, "file.txt"), "locals", modified.Add(time.Minute))
+
+	// download makes the dos file the source and keeps its modification time
+	summary, err := SyncDown(headAddresses, "/backup", localRoot, SyncOptions{}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, summary.Transferred)
+	assert.Equal(t, "remote", readTestFile(t, filepath.Join(localRoot, "file.txt")))
+
+	info, err := os.Stat(filepath.Join(localRoot, "file.txt"))
+	assert.Nil(t, err)
+	assert.True(t, info.ModTime().Equal(modified))
+
+	summary, err = SyncDown(headAddresses, "/backup", localRoot, SyncOptions{}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, summary.Transferred)
+	assert.Equal(t, 1, summary.Unchanged)
+
+	// upload makes the local file the source
+	writeTestFile(t, filepath.Join(localRoot, "file.txt"), "locals", time.Now().Add(time.Hour))
+
+	summary, err = SyncUp(headAddresses, localRoot, "/backup", SyncOptions{}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, summary.Transferred)
+	content, _ := head.content("/backup/file.txt")
+	assert.Equal(t, "locals", content)
+}
+
+func TestSync_TypeConflict(t *testing.T) {
+	head, headAddresses := newTestHead(t)
+	localRoot := t.TempDir()
+
+	head.put("/backup/entry/inner.txt", "inner", time.Now())
+	writeTestFile(t, filepath.Join(localRoot, "entry"), "file", time.Now())
+
+	summary, err := SyncUp(headAddresses, localRoot, "/backup", SyncOptions{}, nil)
+	assert.Nil(t, err)
+	assert.Contains(t, summary.Failed, "entry")
+	assert.True(t, head.hasFolder("/backup/entry"))
+
+	summary, err = SyncUp(headAddresses, localRoot, "/backup", SyncOptions{Delete: true}, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, summary.Failed)
+	assert.Equal(t, 1, summary.Deleted)
+	assert.Equal(t, 1, summary.Transferred)
+	assert.False(t, head.hasFolder("/backup/entry"))
+	content, _ := head.content("/backup/entry")
+	assert.Equal(t, "file", content)
+
+	// the same conflict in the download direction replaces the local file with the dos folder
+	head.put("/mirror/entry/inner.txt", "inner", time.Now())
+
+	summary, err = SyncDown(headAddresses, "/mirror", localRoot, SyncOptions{Delete: true}, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, summary.Failed)
+	assert.Equal(t, "inner", readTestFile(t, filepath.Join(localRoot, "entry", "inner.txt")))
+}
+
+func TestSync_DeleteAndDryRun(t *testing.T) {
+	head, headAddresses := newTestHead(t)
+	localRoot := t.TempDir()
+
+	past := time.Now().Add(-time.Hour)
+	writeTestFile(t, filepath.Join(localRoot, "keep.txt"), "keep", past)
+	head.put("/backup/keep.txt", "keep", time.Now())
+	head.put("/backup/extra/one.txt", "one", time.Now())
+	head.put("/backup/extra/two.txt", "two", time.Now())
+	head.put("/backup/stale.txt", "stale", time.Now())
+
+	actions := &syncTestActions{}
+	summary, err := SyncUp(headAddresses, localRoot, "/backup", SyncOptions{Delete: true, DryRun: true}, actions.handler)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, summary.Deleted)
+	assert.Equal(t, []string{"delete /backup/extra", "delete /backup/stale.txt"}, actions.sorted())
+	assert.True(t, head.hasFolder("/backup/extra"))
+
+	summary, err = SyncUp(headAddresses, localRoot, "/backup", SyncOptions{Delete: true}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, summary.Deleted)
+	assert.Equal(t, 1, summary.Unchanged)
+	assert.False(t, head.hasFolder("/backup/extra"))
+	_, has := head.content("/backup/stale.txt")
+	assert.False(t, has)
+
+	// local extraneous entries are removed on download
+	writeTestFile(t, filepath.Join(localRoot, "local-only", "file.txt"), "local", past)
+
+	summary, err = SyncDown(headAddresses, "/backup", localRoot, SyncOptions{Delete: true}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, summary.Deleted)
+	_, err = os.Stat(filepath.Join(localRoot, "local-only"))
+	assert.True(t, os.IsNotExist(err))
+}
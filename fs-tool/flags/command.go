@@ -58,6 +58,11 @@ func (c *Command) printUsage() {
 	fmt.Println("  mv      Move file or folder.")
 	fmt.Println("  rm      Remove files and/or folders.")
 	fmt.Println("  tree    Print folders tree.")
+	fmt.Println("  find    Search files in folders.")
+	fmt.Println("  du      Print folders size.")
+	fmt.Println("  stat    Print file or folder details.")
+	fmt.Println("  cat     Print file content.")
+	fmt.Println("  sync    Synchronise folders between local and dos.")
 	fmt.Println("  sh      Enter shell mode of fs-tool.")
 	fmt.Println()
 }
@@ -92,7 +97,7 @@ func (c *Command) Parse() bool {
 		}
 
 		switch arg {
-		case "mkdir", "ls", "cp", "mv", "rm", "tree", "sh":
+		case "mkdir", "ls", "cp", "mv", "rm", "tree", "find", "du", "stat", "cat", "sync", "sh":
 			mrArgs := make([]string, 0)
 			if i+1 < len(c.args) {
 				mrArgs = c.args[i+1:]
@@ -0,0 +1,126 @@
+package flags
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/terminal"
+	"github.com/freakmaxi/kertish-dos/fs-tool/dos"
+)
+
+type concatenateCommand struct {
+	headAddresses []string
+	output        terminal.Output
+	basePath      string
+	args          []string
+
+	readRange *common.ReadRange
+	sources   []string
+}
+
+// NewConcatenate creates the execution of concatenate operation
+func NewConcatenate(headAddresses []string, output terminal.Output, basePath string, args []string) Execution {
+	return &concatenateCommand{
+		headAddresses: headAddresses,
+		output:        output,
+		basePath:      basePath,
+		args:          args,
+	}
+}
+
+func (c *concatenateCommand) Parse() error {
+	for len(c.args) > 0 {
+		arg := c.args[0]
+		switch arg {
+		case "-r":
+			c.args = c.args[1:]
+			if len(c.args) == 0 {
+				return fmt.Errorf("range argument needs value")
+			}
+			readRange, err := common.NewReadRange(c.args[0])
+			if err != nil {
+				return err
+			}
+			c.args = c.args[1:]
+			c.readRange = readRange
+			continue
+		case "-h":
+			return errors.ErrShowUsage
+		default:
+			if strings.Index(arg, "-") == 0 {
+				return fmt.Errorf("unsupported argument for cat command")
+			}
+		}
+		break
+	}
+
+	c.args = sourceTargetArguments(c.args)
+	c.args = cleanEmptyArguments(c.args)
+
+	if len(c.args) == 0 {
+		return fmt.Errorf("cat command needs source parameter")
+	}
+
+	if c.readRange != nil && len(c.args) > 1 {
+		return fmt.Errorf("range argument can be used only with a single source")
+	}
+
+	for _, arg := range c.args {
+		if !filepath.IsAbs(arg) {
+			arg = path.Join(c.basePath, arg)
+		}
+		c.sources = append(c.sources, arg)
+	}
+
+	return nil
+}
+
+func (c *concatenateCommand) PrintUsage() {
+	c.output.Println("  cat         Print the file content.")
+	c.output.Println("              Ex: cat [arguments] [source] [source] ...")
+	c.output.Println("")
+	c.output.Println("arguments:")
+	c.output.Println("  -r value    prints only defined range of the file.")
+	c.output.Println("              Ex: cat -r [byteBegins]->[byteEnds] [source]")
+	c.output.Println("")
+	c.output.Refresh()
+}
+
+func (c *concatenateCommand) Name() string {
+	return "cat"
+}
+
+func (c *concatenateCommand) Execute() error {
+	writer := &outputWriter{output: c.output}
+
+	for _, source := range c.sources {
+		if strings.Index(source, local) == 0 {
+			return fmt.Errorf("please use O/S native commands to print local files")
+		}
+
+		if err := dos.Stream(c.headAddresses, source, c.readRange, writer); err != nil {
+			return err
+		}
+	}
+	c.output.Refresh()
+
+	return nil
+}
+
+// outputWriter passes the streamed content to the terminal output
+type outputWriter struct {
+	output terminal.Output
+}
+
+func (o *outputWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		o.output.Print(string(p))
+	}
+	return len(p), nil
+}
+
+var _ Execution = &concatenateCommand{}
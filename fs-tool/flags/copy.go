@@ -79,7 +79,7 @@ func (c *copyCommand) Parse() error {
 		return fmt.Errorf("cp command needs source and target parameters")
 	}
 
-	if !c.join && len(c.args) > 2 {
+	if !c.join && len(c.args) > 2 && !hasAnyGlob(c.args[:len(c.args)-1]) {
 		return fmt.Errorf("cp command needs join flag to combine sources to target")
 	}
 
@@ -94,6 +94,7 @@ func (c *copyCommand) PrintUsage() {
 	c.output.Println("              Ex: cp [arguments] [source] [target]          # Copy in dos")
 	c.output.Println("              Ex: cp [arguments] local:[source] [target]    # Copy from local to dos")
 	c.output.Println("              Ex: cp [arguments] [source] local:[target]    # Copy from dos to local")
+	c.output.Println("              Ex: cp [arguments] /logs/*.log [target]       # Copy the matching entries into target folder")
 	c.output.Println("")
 	c.output.Println("arguments:")
 	c.output.Println("  -f          overwrites the existent file / folder")
@@ -111,6 +112,22 @@ func (c *copyCommand) Name() string {
 }
 
 func (c *copyCommand) Execute() error {
+	if hasAnyGlob(c.sources) {
+		sources, err := newGlobber(c.headAddresses, c.basePath).expand(c.sources)
+		if err != nil {
+			return err
+		}
+		c.sources = sources
+
+		if !c.join && len(c.sources) > 1 {
+			return c.executeEach()
+		}
+	}
+
+	return c.execute()
+}
+
+func (c *copyCommand) execute() error {
 	onlyLocal := false
 	for _, source := range c.sources {
 		if strings.Index(source, local) == 0 {
@@ -275,4 +292,23 @@ func (c *copyCommand) localToRemote() error {
 	return nil
 }
 
+// executeEach copys the matched sources one by one into the target folder
+func (c *copyCommand) executeEach() error {
+	for _, source := range c.sources {
+		target, err := globTarget(c.basePath, source, c.target)
+		if err != nil {
+			return err
+		}
+
+		each := *c
+		each.sources = []string{source}
+		each.target = target
+
+		if err := each.execute(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var _ Execution = &copyCommand{}
@@ -0,0 +1,142 @@
+package flags
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/terminal"
+	"github.com/freakmaxi/kertish-dos/fs-tool/dos"
+)
+
+type diskUsageCommand struct {
+	headAddresses []string
+	output        terminal.Output
+	basePath      string
+	args          []string
+
+	depth  uint8
+	bytes  bool
+	source string
+}
+
+// NewDiskUsage creates the execution of disk usage operation
+func NewDiskUsage(headAddresses []string, output terminal.Output, basePath string, args []string) Execution {
+	return &diskUsageCommand{
+		headAddresses: headAddresses,
+		output:        output,
+		basePath:      basePath,
+		args:          args,
+	}
+}
+
+func (d *diskUsageCommand) Parse() error {
+	d.depth = 255
+
+	for len(d.args) > 0 {
+		arg := d.args[0]
+		switch arg {
+		case "-d":
+			d.args = d.args[1:]
+			if len(d.args) == 0 {
+				return fmt.Errorf("depth argument needs value")
+			}
+			depth, err := strconv.ParseUint(d.args[0], 10, 8)
+			if err != nil {
+				return fmt.Errorf("depth argument value is not valid")
+			}
+			d.args = d.args[1:]
+			d.depth = uint8(depth)
+			continue
+		case "-s":
+			d.args = d.args[1:]
+			d.depth = 0
+			continue
+		case "-b":
+			d.args = d.args[1:]
+			d.bytes = true
+			continue
+		case "-h":
+			return errors.ErrShowUsage
+		default:
+			if strings.Index(arg, "-") == 0 {
+				return fmt.Errorf("unsupported argument for du command")
+			}
+		}
+		break
+	}
+
+	d.args = sourceTargetArguments(d.args)
+	d.args = cleanEmptyArguments(d.args)
+
+	d.source = d.basePath
+	if len(d.args) > 0 {
+		if !filepath.IsAbs(d.args[0]) {
+			d.source = path.Join(d.basePath, d.args[0])
+		} else {
+			d.source = d.args[0]
+		}
+	}
+
+	return nil
+}
+
+func (d *diskUsageCommand) PrintUsage() {
+	d.output.Println("  du          Print the logical size of folders.")
+	d.output.Println("              Ex: du [arguments] [target]")
+	d.output.Println("")
+	d.output.Println("arguments:")
+	d.output.Println("  -d depth    prints the folders only until the depth")
+	d.output.Println("  -s          prints only the total size of the target, same as -d 0")
+	d.output.Println("  -b          prints the sizes in bytes")
+	d.output.Println("")
+	d.output.Refresh()
+}
+
+func (d *diskUsageCommand) Name() string {
+	return "du"
+}
+
+func (d *diskUsageCommand) Execute() error {
+	if strings.Index(d.source, local) == 0 {
+		return fmt.Errorf("please use O/S native commands to get the disk usage of folders")
+	}
+
+	anim := common.NewAnimation(d.output, "calculating...")
+	anim.Start()
+
+	tree, err := dos.Tree(d.headAddresses, d.source, true)
+	if err != nil {
+		anim.Cancel()
+		return err
+	}
+	anim.Stop()
+
+	d.print(tree, 0)
+	d.output.Refresh()
+
+	return nil
+}
+
+// print writes the sub folders before the folder itself like the native du output
+func (d *diskUsageCommand) print(tree *common.TreeShadow, level uint8) {
+	if level < d.depth {
+		for _, folder := range tree.Folders {
+			d.print(folder, level+1)
+		}
+	}
+	d.output.Printf("%-10s %s\n", d.sizeToString(tree.Size), tree.Full)
+}
+
+func (d *diskUsageCommand) sizeToString(size uint64) string {
+	if d.bytes {
+		return strconv.FormatUint(size, 10)
+	}
+	return sizeToString(size)
+}
+
+var _ Execution = &diskUsageCommand{}
@@ -34,6 +34,16 @@ func newExecution(headAddresses []string, output terminal.Output, command string
 		return NewRemove(headAddresses, output, basePath, args), nil
 	case "tree":
 		return NewTree(headAddresses, output, basePath, args), nil
+	case "find":
+		return NewFind(headAddresses, output, basePath, args), nil
+	case "du":
+		return NewDiskUsage(headAddresses, output, basePath, args), nil
+	case "stat":
+		return NewStat(headAddresses, output, basePath, args), nil
+	case "cat":
+		return NewConcatenate(headAddresses, output, basePath, args), nil
+	case "sync":
+		return NewSync(headAddresses, output, basePath, args), nil
 	case "sh":
 		return NewShell(headAddresses, version), nil
 	}
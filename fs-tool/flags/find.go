@@ -0,0 +1,237 @@
+package flags
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/terminal"
+	"github.com/freakmaxi/kertish-dos/fs-tool/dos"
+)
+
+type findCommand struct {
+	headAddresses []string
+	output        terminal.Output
+	basePath      string
+	args          []string
+
+	listing bool
+	query   *common.SearchQuery
+}
+
+// NewFind creates the execution of find operation
+func NewFind(headAddresses []string, output terminal.Output, basePath string, args []string) Execution {
+	return &findCommand{
+		headAddresses: headAddresses,
+		output:        output,
+		basePath:      basePath,
+		args:          args,
+		query:         common.NewSearchQuery(basePath),
+	}
+}
+
+func (f *findCommand) Parse() error {
+	for len(f.args) > 0 {
+		arg := f.args[0]
+		switch arg {
+		case "-name", "-regex", "-mime", "-size", "-mtime", "-ctime":
+			if len(f.args) < 2 {
+				return fmt.Errorf("%s requires value", arg)
+			}
+			if err := f.parseValue(arg, f.args[1]); err != nil {
+				return err
+			}
+			f.args = f.args[2:]
+			continue
+		case "-zombie":
+			f.args = f.args[1:]
+			zombie := true
+			f.query.Zombie = &zombie
+			continue
+		case "-locked":
+			f.args = f.args[1:]
+			locked := true
+			f.query.Locked = &locked
+			continue
+		case "-l":
+			f.args = f.args[1:]
+			f.listing = true
+			continue
+		case "-h":
+			return errors.ErrShowUsage
+		default:
+			if strings.Index(arg, "-") == 0 {
+				return fmt.Errorf("unsupported argument for find command")
+			}
+		}
+		break
+	}
+
+	f.args = sourceTargetArguments(f.args)
+	f.args = cleanEmptyArguments(f.args)
+
+	if len(f.args) > 0 {
+		if !filepath.IsAbs(f.args[0]) {
+			f.query.Root = path.Join(f.basePath, f.args[0])
+		} else {
+			f.query.Root = f.args[0]
+		}
+	}
+
+	return f.query.Validate()
+}
+
+func (f *findCommand) parseValue(arg string, value string) error {
+	switch arg {
+	case "-name":
+		f.query.Name = value
+	case "-regex":
+		f.query.NameRegex = value
+	case "-mime":
+		f.query.Mime = value
+	case "-size":
+		size, err := f.parseSize(strings.TrimLeft(value, "+-"))
+		if err != nil {
+			return err
+		}
+		if strings.Index(value, "-") == 0 {
+			f.query.SizeMax = &size
+		} else {
+			f.query.SizeMin = &size
+		}
+	case "-mtime", "-ctime":
+		days, err := strconv.ParseUint(strings.TrimLeft(value, "+-"), 10, 32)
+		if err != nil {
+			return fmt.Errorf("%s value is not valid", arg)
+		}
+		point := time.Now().UTC().Add(-time.Duration(days) * 24 * time.Hour)
+
+		older := strings.Index(value, "+") == 0
+		if strings.Compare(arg, "-mtime") == 0 {
+			if older {
+				f.query.ModifiedBefore = &point
+			} else {
+				f.query.ModifiedAfter = &point
+			}
+		} else {
+			if older {
+				f.query.CreatedBefore = &point
+			} else {
+				f.query.CreatedAfter = &point
+			}
+		}
+	}
+	return nil
+}
+
+func (f *findCommand) parseSize(value string) (uint64, error) {
+	multiplier := uint64(1)
+	if len(value) > 0 {
+		switch strings.ToLower(value[len(value)-1:]) {
+		case "k":
+			multiplier = 1024
+		case "m":
+			multiplier = 1024 * 1024
+		case "g":
+			multiplier = 1024 * 1024 * 1024
+		case "t":
+			multiplier = 1024 * 1024 * 1024 * 1024
+		}
+		if multiplier > 1 {
+			value = value[:len(value)-1]
+		}
+	}
+
+	size, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("-size value is not valid")
+	}
+	return size * multiplier, nil
+}
+
+func (f *findCommand) PrintUsage() {
+	f.output.Println("  find        Search files recursively in the folder.")
+	f.output.Println("              Ex: find [arguments] [target]")
+	f.output.Println("")
+	f.output.Println("arguments:")
+	f.output.Println("  -name       file name glob pattern. Ex: -name \"*.mp4\"")
+	f.output.Println("  -regex      file name regular expression. Ex: -regex \"^[0-9]+\\.log$\"")
+	f.output.Println("  -mime       file mime type glob pattern. Ex: -mime \"video/*\"")
+	f.output.Println("  -size       [+|-]N[k|m|g|t], + at least, - at most. Can be used twice for a range")
+	f.output.Println("  -mtime      [+|-]N days, -N modified in last N days, +N modified before N days")
+	f.output.Println("  -ctime      [+|-]N days, -N created in last N days, +N created before N days")
+	f.output.Println("  -zombie     only zombie files")
+	f.output.Println("  -locked     only locked files")
+	f.output.Println("  -l          shows in a listing format")
+	f.output.Println("")
+	f.output.Println("marking:")
+	f.output.Println("  -           file")
+	f.output.Println("  •           locked")
+	f.output.Println("  ↯           zombie")
+	f.output.Println("")
+	f.output.Refresh()
+}
+
+func (f *findCommand) Name() string {
+	return "find"
+}
+
+func (f *findCommand) Execute() error {
+	if strings.Index(f.query.Root, local) == 0 {
+		return fmt.Errorf("please use O/S native commands to find files")
+	}
+
+	anim := common.NewAnimation(f.output, "searching...")
+	anim.Start()
+
+	entries := make([]*common.SearchEntry, 0)
+	incomplete := false
+	for {
+		result, err := dos.Search(f.headAddresses, f.query)
+		if err != nil {
+			anim.Cancel()
+			return err
+		}
+		entries = append(entries, result.Entries...)
+		incomplete = incomplete || result.Incomplete
+
+		if len(result.Continue) == 0 {
+			break
+		}
+		f.query.Continue = result.Continue
+	}
+	anim.Stop()
+
+	for _, e := range entries {
+		if !f.listing {
+			f.output.Println(e.Path())
+			continue
+		}
+
+		name := e.Path()
+		fileChar := "-"
+		if e.Locked() {
+			fileChar = "•"
+			name = fmt.Sprintf("%s (locked till %s)", name, e.Lock.Till.Local().Format(common.FriendlyTimeFormat))
+		} else if e.ZombieCheck() {
+			fileChar = "↯"
+		}
+		f.output.Printf("%s %7v %s %s\n", fileChar, sizeToString(e.Size), e.Modified.Local().Format(common.FriendlyTimeFormat), name)
+	}
+	if f.listing {
+		f.output.Printf("total %d\n", len(entries))
+	}
+	if incomplete {
+		f.output.Println("warning: metadata migration is in progress, some files may be missing in the result")
+	}
+	f.output.Refresh()
+
+	return nil
+}
+
+var _ Execution = &findCommand{}
@@ -0,0 +1,307 @@
+package flags
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/fs-tool/dos"
+)
+
+const globRecursive = "**"
+
+// globMatch holds the matched dos entry, only one of Folder and File is filled
+type globMatch struct {
+	Path   string
+	Folder *common.FolderShadow
+	File   *common.File
+}
+
+type globLister func(folderPath string) (*common.Folder, error)
+
+// globber expands the shell style patterns (*, ?, [...] and **) in dos and local locations.
+// Listed folders are cached during the expansion
+type globber struct {
+	headAddresses []string
+	basePath      string
+
+	cache map[string]*common.Folder
+}
+
+func newGlobber(headAddresses []string, basePath string) *globber {
+	return &globber{
+		headAddresses: headAddresses,
+		basePath:      basePath,
+		cache:         make(map[string]*common.Folder),
+	}
+}
+
+// hasGlob checks if the value has any unescaped pattern character
+func hasGlob(value string) bool {
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyGlob(values []string) bool {
+	for _, value := range values {
+		if hasGlob(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// expand replaces the arguments that have pattern with the matched paths. Dos paths are returned as absolute
+// paths and local paths keep the local prefix
+func (g *globber) expand(args []string) ([]string, error) {
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !hasGlob(arg) {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		if strings.Index(arg, local) == 0 {
+			matches, err := g.expandLocal(arg[len(local):])
+			if err != nil {
+				return nil, err
+			}
+			for _, match := range matches {
+				expanded = append(expanded, fmt.Sprintf("%s%s", local, match))
+			}
+			continue
+		}
+
+		matches, err := g.expandDos(arg)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			expanded = append(expanded, match.Path)
+		}
+	}
+	return expanded, nil
+}
+
+func (g *globber) expandDos(pattern string) ([]*globMatch, error) {
+	if !filepath.IsAbs(pattern) {
+		pattern = common.Join(g.basePath, pattern)
+	}
+
+	matches, err := g.match(pattern, g.listDos)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no matches found: %s", pattern)
+	}
+	return matches, nil
+}
+
+func (g *globber) expandLocal(pattern string) ([]string, error) {
+	if strings.Index(pattern, "~") == 0 {
+		u, err := user.Current()
+		if err == nil {
+			pattern = path.Join(u.HomeDir, pattern[1:])
+		}
+	}
+	if !filepath.IsAbs(pattern) {
+		pwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		pattern = filepath.Join(pwd, pattern)
+	}
+
+	matches, err := g.match(filepath.ToSlash(pattern), g.listLocal)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no matches found: %s", pattern)
+	}
+
+	paths := make([]string, 0, len(matches))
+	for _, match := range matches {
+		paths = append(paths, filepath.FromSlash(match.Path))
+	}
+	return paths, nil
+}
+
+func (g *globber) match(pattern string, lister globLister) ([]*globMatch, error) {
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(pattern, "/") {
+		if len(segment) == 0 {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	matchesMap := make(map[string]*globMatch)
+	if err := g.matchSegments("/", segments, lister, matchesMap); err != nil {
+		return nil, err
+	}
+
+	matches := make([]*globMatch, 0, len(matchesMap))
+	for _, match := range matchesMap {
+		matches = append(matches, match)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return strings.Compare(matches[i].Path, matches[j].Path) < 0
+	})
+	return matches, nil
+}
+
+func (g *globber) matchSegments(folderPath string, segments []string, lister globLister, matches map[string]*globMatch) error {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	segment := segments[0]
+	last := len(segments) == 1
+
+	// literal folder names do not need listing
+	if !last && !hasGlob(segment) {
+		return g.matchSegments(common.Join(folderPath, strings.ReplaceAll(segment, "\\", "")), segments[1:], lister, matches)
+	}
+
+	folder, err := lister(folderPath)
+	if err != nil {
+		// not reachable folders do not have any match
+		return nil
+	}
+
+	if strings.Compare(segment, globRecursive) == 0 {
+		if last {
+			// trailing ** matches every entry under the folder
+			for _, shadow := range folder.Folders {
+				childPath := common.Join(folderPath, shadow.Name)
+				matches[childPath] = &globMatch{Path: childPath, Folder: shadow}
+			}
+			for _, file := range folder.Files {
+				childPath := common.Join(folderPath, file.Name)
+				matches[childPath] = &globMatch{Path: childPath, File: file}
+			}
+		} else if err := g.matchSegments(folderPath, segments[1:], lister, matches); err != nil {
+			return err
+		}
+
+		for _, shadow := range folder.Folders {
+			if err := g.matchSegments(common.Join(folderPath, shadow.Name), segments, lister, matches); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, shadow := range folder.Folders {
+		matched, err := path.Match(segment, shadow.Name)
+		if err != nil {
+			return fmt.Errorf("pattern is not valid: %s", segment)
+		}
+		if !matched {
+			continue
+		}
+
+		childPath := common.Join(folderPath, shadow.Name)
+		if last {
+			matches[childPath] = &globMatch{Path: childPath, Folder: shadow}
+			continue
+		}
+		if err := g.matchSegments(childPath, segments[1:], lister, matches); err != nil {
+			return err
+		}
+	}
+
+	if !last {
+		return nil
+	}
+
+	for _, file := range folder.Files {
+		matched, err := path.Match(segment, file.Name)
+		if err != nil {
+			return fmt.Errorf("pattern is not valid: %s", segment)
+		}
+		if !matched {
+			continue
+		}
+
+		childPath := common.Join(folderPath, file.Name)
+		matches[childPath] = &globMatch{Path: childPath, File: file}
+	}
+
+	return nil
+}
+
+func (g *globber) listDos(folderPath string) (*common.Folder, error) {
+	if folder, has := g.cache[folderPath]; has {
+		return folder, nil
+	}
+
+	folder, err := dos.List(g.headAddresses, folderPath, false)
+	if err != nil {
+		return nil, err
+	}
+	g.cache[folderPath] = folder
+
+	return folder, nil
+}
+
+func (g *globber) listLocal(folderPath string) (*common.Folder, error) {
+	entries, err := os.ReadDir(filepath.FromSlash(folderPath))
+	if err != nil {
+		return nil, err
+	}
+
+	folder := common.NewFolder(folderPath)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			folder.Folders = append(folder.Folders, common.NewFolderShadow(common.Join(folderPath, entry.Name())))
+			continue
+		}
+		folder.Files = append(folder.Files, &common.File{Name: entry.Name()})
+	}
+	return folder, nil
+}
+
+// globTarget creates the target path of the source when multiple matched sources are copied or moved into
+// the target folder. Local target should be an existent folder
+func globTarget(basePath string, source string, target string) (string, error) {
+	if strings.Index(target, local) == 0 {
+		localTarget := target[len(local):]
+		if strings.Index(localTarget, "~") == 0 {
+			u, err := user.Current()
+			if err == nil {
+				localTarget = path.Join(u.HomeDir, localTarget[1:])
+			}
+		}
+
+		info, err := os.Stat(localTarget)
+		if err != nil || !info.IsDir() {
+			return "", fmt.Errorf("target %s should be a folder for multiple sources", localTarget)
+		}
+		return target, nil
+	}
+
+	if !filepath.IsAbs(target) {
+		target = common.Join(basePath, target)
+	}
+
+	if strings.Index(source, local) == 0 {
+		return common.Join(target, filepath.Base(source[len(local):])), nil
+	}
+	_, name := path.Split(source)
+	return common.Join(target, name), nil
+}
@@ -0,0 +1,147 @@
+package flags
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestGlobber creates the globber that lists the dos folders from the fake listing below. Head address is
+// not reachable, so any folder that is missing in the listing fails like a not existent folder
+//
+//	/readme.md, /notes.txt
+//	/docs/a.txt, /docs/b.md
+//	/docs/old/c.txt
+//	/photos/x.jpg, /photos/y.png
+func newTestGlobber(basePath string) *globber {
+	g := newGlobber([]string{"127.0.0.1:1"}, basePath)
+
+	root := common.NewFolder("/")
+	_, _ = root.NewFolder("docs")
+	_, _ = root.NewFolder("photos")
+	_, _ = root.NewFile("readme.md")
+	_, _ = root.NewFile("notes.txt")
+
+	docs := common.NewFolder("/docs")
+	_, _ = docs.NewFolder("old")
+	_, _ = docs.NewFile("a.txt")
+	_, _ = docs.NewFile("b.md")
+
+	old := common.NewFolder("/docs/old")
+	_, _ = old.NewFile("c.txt")
+
+	photos := common.NewFolder("/photos")
+	_, _ = photos.NewFile("x.jpg")
+	_, _ = photos.NewFile("y.png")
+
+	for _, folder := range []*common.Folder{root, docs, old, photos} {
+		g.cache[folder.Full] = folder
+	}
+
+	return g
+}
+
+func TestHasGlob(t *testing.T) {
+	assert.True(t, hasGlob("*.txt"))
+	assert.True(t, hasGlob("file-?.txt"))
+	assert.True(t, hasGlob("[ab].txt"))
+	assert.False(t, hasGlob("plain.txt"))
+	assert.False(t, hasGlob("escaped\\*.txt"))
+
+	assert.True(t, hasAnyGlob([]string{"plain", "/docs/*"}))
+	assert.False(t, hasAnyGlob([]string{"plain", "/docs/a.txt"}))
+}
+
+func TestGlobber_ExpandDos(t *testing.T) {
+	g := newTestGlobber("/")
+
+	for pattern, expected := range map[string][]string{
+		"/docs/*.txt":            {"/docs/a.txt"},
+		"/*":                     {"/docs", "/notes.txt", "/photos", "/readme.md"},
+		"/*/*.md":                {"/docs/b.md"},
+		"/p?otos/[xz].jpg":       {"/photos/x.jpg"},
+		"/**/*.txt":              {"/docs/a.txt", "/docs/old/c.txt", "/notes.txt"},
+		"/docs/**":               {"/docs/a.txt", "/docs/b.md", "/docs/old", "/docs/old/c.txt"},
+		"/docs/old/../old/*.txt": {"/docs/old/c.txt"},
+	} {
+		matches, err := g.expandDos(pattern)
+		if !assert.Nil(t, err, pattern) {
+			continue
+		}
+
+		paths := make([]string, 0)
+		for _, match := range matches {
+			paths = append(paths, match.Path)
+		}
+		assert.Equal(t, expected, paths, pattern)
+	}
+}
+
+func TestGlobber_ExpandDosMatchTypes(t *testing.T) {
+	g := newTestGlobber("/")
+
+	matches, err := g.expandDos("/docs/*")
+	assert.Nil(t, err)
+	assert.Len(t, matches, 3)
+
+	assert.NotNil(t, matches[0].File)
+	assert.Nil(t, matches[0].Folder)
+	assert.Equal(t, "/docs/old", matches[2].Path)
+	assert.NotNil(t, matches[2].Folder)
+	assert.Nil(t, matches[2].File)
+}
+
+func TestGlobber_ExpandDosFailures(t *testing.T) {
+	g := newTestGlobber("/")
+
+	_, err := g.expandDos("/docs/*.jpg")
+	assert.EqualError(t, err, "no matches found: /docs/*.jpg")
+
+	// the folder that can not be listed does not have any match
+	_, err = g.expandDos("/missing/*")
+	assert.EqualError(t, err, "no matches found: /missing/*")
+
+	_, err = g.expandDos("/docs/[a")
+	assert.EqualError(t, err, "pattern is not valid: [a")
+}
+
+func TestGlobber_Expand(t *testing.T) {
+	g := newTestGlobber("/docs")
+
+	expanded, err := g.expand([]string{"*.md", "/photos/x.jpg", "old/*", "escaped\\*"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"/docs/b.md", "/photos/x.jpg", "/docs/old/c.txt", "escaped\\*"}, expanded)
+
+	localPath := t.TempDir()
+	assert.Nil(t, os.WriteFile(filepath.Join(localPath, "one.log"), []byte("1"), 0666))
+	assert.Nil(t, os.WriteFile(filepath.Join(localPath, "two.log"), []byte("2"), 0666))
+	assert.Nil(t, os.WriteFile(filepath.Join(localPath, "three.txt"), []byte("3"), 0666))
+
+	expanded, err = g.expand([]string{local + filepath.Join(localPath, "*.log")})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{local + filepath.Join(localPath, "one.log"), local + filepath.Join(localPath, "two.log")}, expanded)
+
+	_, err = g.expand([]string{local + filepath.Join(localPath, "*.bin")})
+	assert.NotNil(t, err)
+}
+
+func TestGlobTarget(t *testing.T) {
+	target, err := globTarget("/docs", "/photos/x.jpg", "old")
+	assert.Nil(t, err)
+	assert.Equal(t, "/docs/old/x.jpg", target)
+
+	target, err = globTarget("/", local+"/tmp/file.txt", "/backup")
+	assert.Nil(t, err)
+	assert.Equal(t, "/backup/file.txt", target)
+
+	localPath := t.TempDir()
+	target, err = globTarget("/", "/photos/x.jpg", local+localPath)
+	assert.Nil(t, err)
+	assert.Equal(t, local+localPath, target)
+
+	_, err = globTarget("/", "/photos/x.jpg", local+filepath.Join(localPath, "missing"))
+	assert.NotNil(t, err)
+}
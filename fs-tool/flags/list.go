@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
-	"strconv"
 	"strings"
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
@@ -74,6 +73,7 @@ func (l *listCommand) Parse() error {
 func (l *listCommand) PrintUsage() {
 	l.output.Println("  ls          List files and folders.")
 	l.output.Println("              Ex: ls [arguments] [target]")
+	l.output.Println("              Ex: ls [arguments] /logs/2023-*              # List the matching entries")
 	l.output.Println("")
 	l.output.Println("arguments:")
 	l.output.Println("  -l          shows in a listing format")
@@ -100,7 +100,13 @@ func (l *listCommand) Execute() error {
 	anim := common.NewAnimation(l.output, "processing...")
 	anim.Start()
 
-	folder, err := dos.List(l.headAddresses, l.source, l.usage)
+	var folder *common.Folder
+	var err error
+	if hasGlob(l.source) {
+		folder, err = l.listMatches()
+	} else {
+		folder, err = dos.List(l.headAddresses, l.source, l.usage)
+	}
 	if err != nil {
 		anim.Cancel()
 		return err
@@ -115,10 +121,51 @@ func (l *listCommand) Execute() error {
 	return nil
 }
 
+// listMatches creates a virtual folder that contains the entries matching with the source pattern
+func (l *listCommand) listMatches() (*common.Folder, error) {
+	matches, err := newGlobber(l.headAddresses, l.basePath).expandDos(l.source)
+	if err != nil {
+		return nil, err
+	}
+
+	relative := len(l.args) > 0 && !filepath.IsAbs(l.args[0])
+
+	folder := common.NewFolder(l.basePath)
+	for _, match := range matches {
+		name := match.Path
+		if relative {
+			name = strings.TrimPrefix(strings.TrimPrefix(match.Path, l.basePath), "/")
+		}
+
+		if match.Folder != nil {
+			shadow := *match.Folder
+			shadow.Name = name
+
+			if l.usage {
+				usage, err := dos.List(l.headAddresses, match.Path, true)
+				if err != nil {
+					return nil, err
+				}
+				shadow.Size = usage.Size
+			}
+			folder.Folders = append(folder.Folders, &shadow)
+			folder.Size += shadow.Size
+			continue
+		}
+
+		file := *match.File
+		file.Name = name
+		folder.Files = append(folder.Files, &file)
+		folder.Size += file.Size
+	}
+
+	return folder, nil
+}
+
 func (l *listCommand) printAsSummary(folder *common.Folder) {
 	for _, f := range folder.Folders {
 		if l.usage {
-			l.output.Printf("> %s (%s)   ", f.Name, l.sizeToString(f.Size))
+			l.output.Printf("> %s (%s)   ", f.Name, sizeToString(f.Size))
 			continue
 		}
 		l.output.Printf("> %s   ", f.Name)
@@ -134,13 +181,13 @@ func (l *listCommand) printAsList(folder *common.Folder) {
 	total := len(folder.Folders) + len(folder.Files)
 
 	if l.usage && total > 1 {
-		l.output.Printf("total %d (%s)\n", total, l.sizeToString(folder.Size))
+		l.output.Printf("total %d (%s)\n", total, sizeToString(folder.Size))
 	} else {
 		l.output.Printf("total %d\n", total)
 	}
 
 	for _, f := range folder.Folders {
-		l.output.Printf("d %7v %s %s\n", l.sizeToString(f.Size), f.Created.Format(common.FriendlyTimeFormat), f.Name)
+		l.output.Printf("d %7v %s %s\n", sizeToString(f.Size), f.Created.Format(common.FriendlyTimeFormat), f.Name)
 	}
 
 	for _, f := range folder.Files {
@@ -152,38 +199,10 @@ func (l *listCommand) printAsList(folder *common.Folder) {
 		} else if f.ZombieCheck() {
 			fileChar = "↯"
 		}
-		l.output.Printf("%s %7v %s %s\n", fileChar, l.sizeToString(f.Size), f.Modified.Local().Format(common.FriendlyTimeFormat), name)
+		l.output.Printf("%s %7v %s %s\n", fileChar, sizeToString(f.Size), f.Modified.Local().Format(common.FriendlyTimeFormat), name)
 	}
 
 	l.output.Refresh()
 }
 
-func (l *listCommand) sizeToString(size uint64) string {
-	calculatedSize := size
-	divideCount := 0
-	for {
-		calculatedSizeString := strconv.FormatUint(calculatedSize, 10)
-		if len(calculatedSizeString) < 6 {
-			break
-		}
-		calculatedSize /= 1024
-		divideCount++
-	}
-
-	switch divideCount {
-	case 0:
-		return fmt.Sprintf("%sb", strconv.FormatUint(calculatedSize, 10))
-	case 1:
-		return fmt.Sprintf("%skb", strconv.FormatUint(calculatedSize, 10))
-	case 2:
-		return fmt.Sprintf("%smb", strconv.FormatUint(calculatedSize, 10))
-	case 3:
-		return fmt.Sprintf("%sgb", strconv.FormatUint(calculatedSize, 10))
-	case 4:
-		return fmt.Sprintf("%stb", strconv.FormatUint(calculatedSize, 10))
-	}
-
-	return "N/A"
-}
-
 var _ Execution = &listCommand{}
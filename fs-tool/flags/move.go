@@ -66,7 +66,7 @@ func (m *moveCommand) Parse() error {
 		return fmt.Errorf("mv command needs source and target parameters")
 	}
 
-	if !m.join && len(m.args) > 2 {
+	if !m.join && len(m.args) > 2 && !hasAnyGlob(m.args[:len(m.args)-1]) {
 		return fmt.Errorf("mv command needs join flag to combine sources to target")
 	}
 
@@ -81,6 +81,7 @@ func (m *moveCommand) PrintUsage() {
 	m.output.Println("              Ex: mv [arguments] [source] [target]          # Move in dos")
 	m.output.Println("              Ex: mv [arguments] local:[source] [target]    # Move from local to dos")
 	m.output.Println("              Ex: mv [arguments] [source] local:[target]    # Move from dos to local")
+	m.output.Println("              Ex: mv [arguments] /logs/*.log [target]       # Move the matching entries into target folder")
 	m.output.Println("")
 	m.output.Println("arguments:")
 	m.output.Println("  -f          overwrites the existent file / folder")
@@ -94,6 +95,22 @@ func (m *moveCommand) Name() string {
 }
 
 func (m *moveCommand) Execute() error {
+	if hasAnyGlob(m.sources) {
+		sources, err := newGlobber(m.headAddresses, m.basePath).expand(m.sources)
+		if err != nil {
+			return err
+		}
+		m.sources = sources
+
+		if !m.join && len(m.sources) > 1 {
+			return m.executeEach()
+		}
+	}
+
+	return m.execute()
+}
+
+func (m *moveCommand) execute() error {
 	onlyLocal := false
 	for _, source := range m.sources {
 		if strings.Index(source, local) == 0 {
@@ -272,4 +289,23 @@ func (m *moveCommand) localToRemote() error {
 	return nil
 }
 
+// executeEach moves the matched sources one by one into the target folder
+func (m *moveCommand) executeEach() error {
+	for _, source := range m.sources {
+		target, err := globTarget(m.basePath, source, m.target)
+		if err != nil {
+			return err
+		}
+
+		each := *m
+		each.sources = []string{source}
+		each.target = target
+
+		if err := each.execute(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 var _ Execution = &moveCommand{}
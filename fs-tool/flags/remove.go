@@ -70,6 +70,7 @@ func (r *removeCommand) Parse() error {
 func (r *removeCommand) PrintUsage() {
 	r.output.Println("  rm          Remove files and/or folders.")
 	r.output.Println("              Ex: rm [arguments] [target] [target] [target] ...")
+	r.output.Println("              Ex: rm [arguments] /logs/2023-*               # Remove the matching entries")
 	r.output.Println("")
 	r.output.Println("arguments:")
 	r.output.Println("  -f          skip confirmation and removes")
@@ -83,6 +84,14 @@ func (r *removeCommand) Name() string {
 }
 
 func (r *removeCommand) Execute() error {
+	if hasAnyGlob(r.targets) {
+		targets, err := newGlobber(r.headAddresses, r.basePath).expand(r.targets)
+		if err != nil {
+			return err
+		}
+		r.targets = targets
+	}
+
 	if r.confirm {
 		list := ""
 		for _, d := range r.targets {
@@ -17,6 +17,8 @@ import (
 
 const rootPath = "/"
 
+var shellCommands = []string{"cd", "mkdir", "ls", "cp", "mv", "rm", "tree", "find", "du", "stat", "cat", "sync", "help", "exit"}
+
 type shellCommand struct {
 	headAddresses []string
 	version       string
@@ -78,6 +80,11 @@ func (s *shellCommand) printHelp() {
 	s.output.Println("  mv      Move file or folder.")
 	s.output.Println("  rm      Remove files and/or folders.")
 	s.output.Println("  tree    Print folders tree.")
+	s.output.Println("  find    Search files in folders.")
+	s.output.Println("  du      Print folders size.")
+	s.output.Println("  stat    Print file or folder details.")
+	s.output.Println("  cat     Print file content.")
+	s.output.Println("  sync    Synchronise folders between local and dos.")
 	s.output.Println("  help    Show this screen.")
 	s.output.Println("          Ex: help [command] or help shortcuts")
 	s.output.Println("  exit    Exit from shell.")
@@ -104,7 +111,7 @@ func (s *shellCommand) printShortcuts() {
 	s.output.Println("  Right     :   Move cursor to next char")
 	s.output.Println("  Alt+Right :   Jump to next word")
 	s.output.Println("  Ctrl+R    :   Refresh terminal cache")
-	s.output.Println("  Tab       :   Complete path")
+	s.output.Println("  Tab       :   Complete command or path")
 	s.output.Println("  Enter     :   Execute command")
 	s.output.Refresh()
 }
@@ -353,7 +360,7 @@ func (s *shellCommand) processCommand() bool {
 			s.output.Println(err.Error())
 		} else {
 			switch e.Name() {
-			case "cp", "mkdir", "mv", "rm", "tree":
+			case "cp", "mkdir", "mv", "rm", "tree", "sync":
 				s.rebuildActiveFolderAndCaches()
 			}
 		}
@@ -438,7 +445,7 @@ func (s *shellCommand) parse(args []string) (bool, bool, Execution) {
 		return true, false, nil
 	case "exit":
 		return true, true, nil
-	case "mkdir", "ls", "cp", "mv", "rm", "tree":
+	case "mkdir", "ls", "cp", "mv", "rm", "tree", "find", "du", "stat", "cat", "sync":
 		mrArgs := make([]string, 0)
 		if len(args) > 1 {
 			mrArgs = args[1:]
@@ -511,6 +518,7 @@ func (s *shellCommand) handleBackspace() {
 func (s *shellCommand) handleTab(cdRequest bool) {
 	bufParts := strings.Split(s.buffer, " ")
 	if len(bufParts) == 1 {
+		s.completeCommand(bufParts[0])
 		return
 	}
 
@@ -574,6 +582,46 @@ func (s *shellCommand) handleTab(cdRequest bool) {
 	s.buffer = s.output.ActiveCommand()
 }
 
+func (s *shellCommand) completeCommand(v string) {
+	if len(v) == 0 {
+		return
+	}
+
+	matches := make([][]string, 0)
+	for _, command := range shellCommands {
+		if strings.Index(command, v) != 0 {
+			continue
+		}
+		matches = append(matches, []string{"command", command})
+	}
+
+	if len(matches) == 0 {
+		return
+	}
+
+	if len(matches) == 1 {
+		s.output.Print(fmt.Sprintf("%s ", matches[0][1][len(v):]))
+		s.output.Refresh()
+		s.buffer = s.output.ActiveCommand()
+		return
+	}
+
+	match, matches := s.matchReduce(matches)
+
+	s.output.Println("")
+	for _, m := range matches {
+		s.output.Printf("%s   ", m[1])
+	}
+	s.output.Println("")
+	s.readyLine()
+
+	if len(match) > len(v) {
+		s.output.Print(match[len(v):])
+		s.output.Refresh()
+		s.buffer = s.output.ActiveCommand()
+	}
+}
+
 func (s *shellCommand) joinQuoteLive(args []string) []string {
 	output := make([]string, 0)
 	combinedArg := ""
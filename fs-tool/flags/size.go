@@ -0,0 +1,35 @@
+package flags
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// sizeToString converts the size in bytes to the human readable form with the largest fitting unit
+func sizeToString(size uint64) string {
+	calculatedSize := size
+	divideCount := 0
+	for {
+		calculatedSizeString := strconv.FormatUint(calculatedSize, 10)
+		if len(calculatedSizeString) < 6 {
+			break
+		}
+		calculatedSize /= 1024
+		divideCount++
+	}
+
+	switch divideCount {
+	case 0:
+		return fmt.Sprintf("%sb", strconv.FormatUint(calculatedSize, 10))
+	case 1:
+		return fmt.Sprintf("%skb", strconv.FormatUint(calculatedSize, 10))
+	case 2:
+		return fmt.Sprintf("%smb", strconv.FormatUint(calculatedSize, 10))
+	case 3:
+		return fmt.Sprintf("%sgb", strconv.FormatUint(calculatedSize, 10))
+	case 4:
+		return fmt.Sprintf("%stb", strconv.FormatUint(calculatedSize, 10))
+	}
+
+	return "N/A"
+}
@@ -0,0 +1,176 @@
+package flags
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/terminal"
+	"github.com/freakmaxi/kertish-dos/fs-tool/dos"
+)
+
+type statCommand struct {
+	headAddresses []string
+	output        terminal.Output
+	basePath      string
+	args          []string
+
+	chunks bool
+	source string
+}
+
+// NewStat creates the execution of stat operation
+func NewStat(headAddresses []string, output terminal.Output, basePath string, args []string) Execution {
+	return &statCommand{
+		headAddresses: headAddresses,
+		output:        output,
+		basePath:      basePath,
+		args:          args,
+	}
+}
+
+func (s *statCommand) Parse() error {
+	for len(s.args) > 0 {
+		arg := s.args[0]
+		switch arg {
+		case "-c":
+			s.args = s.args[1:]
+			s.chunks = true
+			continue
+		case "-h":
+			return errors.ErrShowUsage
+		default:
+			if strings.Index(arg, "-") == 0 {
+				return fmt.Errorf("unsupported argument for stat command")
+			}
+		}
+		break
+	}
+
+	s.args = sourceTargetArguments(s.args)
+	s.args = cleanEmptyArguments(s.args)
+
+	if len(s.args) == 0 {
+		return fmt.Errorf("stat command needs target parameter")
+	}
+
+	if !filepath.IsAbs(s.args[0]) {
+		s.source = path.Join(s.basePath, s.args[0])
+	} else {
+		s.source = s.args[0]
+	}
+
+	return nil
+}
+
+func (s *statCommand) PrintUsage() {
+	s.output.Println("  stat        Print the details of file or folder.")
+	s.output.Println("              Ex: stat [arguments] [target]")
+	s.output.Println("")
+	s.output.Println("arguments:")
+	s.output.Println("  -c          prints the chunks of the file")
+	s.output.Println("")
+	s.output.Refresh()
+}
+
+func (s *statCommand) Name() string {
+	return "stat"
+}
+
+func (s *statCommand) Execute() error {
+	if strings.Index(s.source, local) == 0 {
+		return fmt.Errorf("please use O/S native commands to get the details of files/folders")
+	}
+
+	anim := common.NewAnimation(s.output, "processing...")
+	anim.Start()
+
+	folder, folderErr := dos.List(s.headAddresses, s.source, true)
+	if folderErr == nil {
+		anim.Stop()
+		s.printFolder(folder)
+		return nil
+	}
+
+	folderPath, filename := path.Split(s.source)
+	if len(filename) == 0 {
+		anim.Cancel()
+		return folderErr
+	}
+
+	parent, err := dos.List(s.headAddresses, folderPath, false)
+	if err != nil {
+		anim.Cancel()
+		return folderErr
+	}
+	anim.Stop()
+
+	file := parent.File(filename)
+	if file == nil {
+		return fmt.Errorf("%s is not exists", s.source)
+	}
+	s.printFile(folderPath, file)
+
+	return nil
+}
+
+func (s *statCommand) printFolder(folder *common.Folder) {
+	s.output.Printf("    Path: %s\n", folder.Full)
+	s.output.Println("    Type: folder")
+	s.output.Printf("    Size: %s (%d bytes)\n", sizeToString(folder.Size), folder.Size)
+	s.output.Printf(" Folders: %d\n", len(folder.Folders))
+	s.output.Printf("   Files: %d\n", len(folder.Files))
+	s.output.Printf(" Created: %s\n", folder.Created.Local().Format(common.FriendlyTimeFormat))
+	s.output.Printf("Modified: %s\n", folder.Modified.Local().Format(common.FriendlyTimeFormat))
+	s.output.Refresh()
+}
+
+func (s *statCommand) printFile(folderPath string, file *common.File) {
+	lock := "no"
+	if file.Locked() {
+		lock = fmt.Sprintf("till %s", file.Lock.Till.Local().Format(common.FriendlyTimeFormat))
+	}
+	zombie := "no"
+	if file.ZombieCheck() {
+		zombie = "yes"
+	}
+
+	s.output.Printf("    Path: %s\n", common.Join(folderPath, file.Name))
+	s.output.Println("    Type: file")
+	s.output.Printf("    Mime: %s\n", file.Mime)
+	s.output.Printf("    Size: %s (%d bytes)\n", sizeToString(file.Size), file.Size)
+	s.output.Printf("Checksum: %s\n", file.Checksum)
+	s.output.Printf("  Chunks: %d\n", len(file.Chunks))
+	s.output.Printf(" Missing: %d\n", len(file.Missing))
+	s.output.Printf("    Lock: %s\n", lock)
+	s.output.Printf("  Zombie: %s\n", zombie)
+	s.output.Printf(" Created: %s\n", file.Created.Local().Format(common.FriendlyTimeFormat))
+	s.output.Printf("Modified: %s\n", file.Modified.Local().Format(common.FriendlyTimeFormat))
+
+	if s.chunks {
+		s.printChunks("chunks", file.Chunks)
+		s.printChunks("missing chunks", file.Missing)
+	}
+	s.output.Refresh()
+}
+
+func (s *statCommand) printChunks(title string, chunks common.DataChunks) {
+	if len(chunks) == 0 {
+		return
+	}
+
+	s.output.Println("")
+	s.output.Printf("%s:\n", title)
+	for _, chunk := range chunks {
+		if chunk.Inline {
+			s.output.Printf("  %5d %10d %s (inline)\n", chunk.Sequence, chunk.Size, chunk.Hash)
+			continue
+		}
+		s.output.Printf("  %5d %10d %s\n", chunk.Sequence, chunk.Size, chunk.Hash)
+	}
+}
+
+var _ Execution = &statCommand{}
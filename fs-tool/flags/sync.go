@@ -0,0 +1,211 @@
+package flags
+
+import (
+	"fmt"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/terminal"
+	"github.com/freakmaxi/kertish-dos/fs-tool/dos"
+)
+
+type syncCommand struct {
+	headAddresses []string
+	output        terminal.Output
+	basePath      string
+	args          []string
+
+	options dos.SyncOptions
+	quiet   bool
+	source  string
+	target  string
+
+	outputMutex sync.Mutex
+}
+
+// NewSync creates the execution of sync operation
+func NewSync(headAddresses []string, output terminal.Output, basePath string, args []string) Execution {
+	return &syncCommand{
+		headAddresses: headAddresses,
+		output:        output,
+		basePath:      basePath,
+		args:          args,
+	}
+}
+
+func (s *syncCommand) Parse() error {
+	for len(s.args) > 0 {
+		arg := s.args[0]
+		switch arg {
+		case "-d":
+			s.args = s.args[1:]
+			s.options.Delete = true
+			continue
+		case "-n":
+			s.args = s.args[1:]
+			s.options.DryRun = true
+			continue
+		case "-c":
+			s.args = s.args[1:]
+			s.options.Checksum = true
+			continue
+		case "-q":
+			s.args = s.args[1:]
+			s.quiet = true
+			continue
+		case "-w":
+			s.args = s.args[1:]
+			if len(s.args) == 0 {
+				return fmt.Errorf("workers argument needs value")
+			}
+			workers, err := strconv.ParseUint(s.args[0], 10, 8)
+			if err != nil || workers == 0 {
+				return fmt.Errorf("workers argument value is not valid")
+			}
+			s.args = s.args[1:]
+			s.options.Workers = int(workers)
+			continue
+		case "-h":
+			return errors.ErrShowUsage
+		default:
+			if strings.Index(arg, "-") == 0 {
+				return fmt.Errorf("unsupported argument for sync command")
+			}
+		}
+		break
+	}
+
+	s.args = sourceTargetArguments(s.args)
+	s.args = cleanEmptyArguments(s.args)
+
+	if len(s.args) != 2 {
+		return fmt.Errorf("sync command needs source and target parameters")
+	}
+
+	s.source = s.args[0]
+	s.target = s.args[1]
+
+	sourceLocal := strings.Index(s.source, local) == 0
+	targetLocal := strings.Index(s.target, local) == 0
+	if sourceLocal == targetLocal {
+		return fmt.Errorf("sync command needs one local and one dos location")
+	}
+
+	return nil
+}
+
+func (s *syncCommand) PrintUsage() {
+	s.output.Println("  sync        Synchronise folders between local and dos.")
+	s.output.Println("              Ex: sync [arguments] local:[source] [target]    # Sync from local to dos")
+	s.output.Println("              Ex: sync [arguments] [source] local:[target]    # Sync from dos to local")
+	s.output.Println("")
+	s.output.Println("              Files are compared by size, modification time and checksum.")
+	s.output.Println("              Only the differences are transferred.")
+	s.output.Println("")
+	s.output.Println("arguments:")
+	s.output.Println("  -d          deletes the target entries that do not exist in the source")
+	s.output.Println("  -n          dry-run, shows the actions without applying them")
+	s.output.Println("  -c          compares the files with checksum even if size and time are matching")
+	s.output.Println("  -w value    count of the parallel file transfers. Default: 4")
+	s.output.Println("  -q          prints only the summary")
+	s.output.Println("")
+	s.output.Refresh()
+}
+
+func (s *syncCommand) Name() string {
+	return "sync"
+}
+
+func (s *syncCommand) Execute() error {
+	var summary *dos.SyncSummary
+	var err error
+
+	if strings.Index(s.source, local) == 0 {
+		localPath := s.localPath(s.source)
+		remotePath := s.remotePath(s.target)
+
+		summary, err = dos.SyncUp(s.headAddresses, localPath, remotePath, s.options, s.progress)
+	} else {
+		remotePath := s.remotePath(s.source)
+		localPath := s.localPath(s.target)
+
+		summary, err = dos.SyncDown(s.headAddresses, remotePath, localPath, s.options, s.progress)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.printSummary(summary)
+	return nil
+}
+
+func (s *syncCommand) localPath(p string) string {
+	p = p[len(local):]
+	if strings.Index(p, "~") == 0 {
+		u, err := user.Current()
+		if err == nil {
+			p = path.Join(u.HomeDir, p[1:])
+		}
+	}
+	return p
+}
+
+func (s *syncCommand) remotePath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return path.Join(s.basePath, p)
+}
+
+func (s *syncCommand) progress(action *dos.SyncAction, err error) {
+	// failures are listed in the summary
+	if s.quiet || err != nil {
+		return
+	}
+
+	s.outputMutex.Lock()
+	defer s.outputMutex.Unlock()
+
+	s.output.Printf("%-8s %s\n", action.Type, action.Path)
+	s.output.Refresh()
+}
+
+func (s *syncCommand) printSummary(summary *dos.SyncSummary) {
+	transferType := "downloaded"
+	if strings.Index(s.source, local) == 0 {
+		transferType = "uploaded"
+	}
+
+	if s.options.DryRun {
+		s.output.Println("dry-run, no changes are applied")
+	}
+	s.output.Printf(
+		"%s %d file(s) (%s), created %d folder(s), deleted %d entry(ies), %d unchanged, %d failed\n",
+		transferType,
+		summary.Transferred,
+		sizeToString(summary.TransferredSize),
+		summary.Created,
+		summary.Deleted,
+		summary.Unchanged,
+		len(summary.Failed),
+	)
+
+	failedPaths := make([]string, 0, len(summary.Failed))
+	for p := range summary.Failed {
+		failedPaths = append(failedPaths, p)
+	}
+	sort.Strings(failedPaths)
+
+	for _, p := range failedPaths {
+		s.output.Printf("  %s: %s\n", p, summary.Failed[p].Error())
+	}
+	s.output.Refresh()
+}
+
+var _ Execution = &syncCommand{}
@@ -157,7 +157,7 @@ func (t *treeCommand) printWithIndentationsChildren(folders common.TreeShadows,
 
 		if len(folders) != i+1 {
 			if t.usage {
-				t.output.Printf("├── [%7s]  %s\n", t.sizeToString(folder.Size), folder.Name)
+				t.output.Printf("├── [%7s]  %s\n", sizeToString(folder.Size), folder.Name)
 			} else {
 				t.output.Printf("├── %s\n", folder.Name)
 			}
@@ -165,7 +165,7 @@ func (t *treeCommand) printWithIndentationsChildren(folders common.TreeShadows,
 			continue
 		}
 		if t.usage {
-			t.output.Printf("└── [%7s]  %s\n", t.sizeToString(folder.Size), folder.Name)
+			t.output.Printf("└── [%7s]  %s\n", sizeToString(folder.Size), folder.Name)
 		} else {
 			t.output.Printf("└── %s\n", folder.Name)
 		}
@@ -196,7 +196,7 @@ func (t *treeCommand) printWithoutIndentationsChildren(folders common.TreeShadow
 	for i, folder := range folders {
 		if len(folders) != i+1 {
 			if t.usage {
-				t.output.Printf("[%7s]  %s\n", t.sizeToString(folder.Size), folder.Name)
+				t.output.Printf("[%7s]  %s\n", sizeToString(folder.Size), folder.Name)
 			} else {
 				t.output.Printf("%s\n", folder.Name)
 			}
@@ -204,7 +204,7 @@ func (t *treeCommand) printWithoutIndentationsChildren(folders common.TreeShadow
 			continue
 		}
 		if t.usage {
-			t.output.Printf("[%7s]  %s\n", t.sizeToString(folder.Size), folder.Name)
+			t.output.Printf("[%7s]  %s\n", sizeToString(folder.Size), folder.Name)
 		} else {
 			t.output.Printf("%s\n", folder.Name)
 		}
@@ -212,32 +212,4 @@ func (t *treeCommand) printWithoutIndentationsChildren(folders common.TreeShadow
 	}
 }
 
-func (t *treeCommand) sizeToString(size uint64) string {
-	calculatedSize := size
-	divideCount := 0
-	for {
-		calculatedSizeString := strconv.FormatUint(calculatedSize, 10)
-		if len(calculatedSizeString) < 6 {
-			break
-		}
-		calculatedSize /= 1024
-		divideCount++
-	}
-
-	switch divideCount {
-	case 0:
-		return fmt.Sprintf("%sb", strconv.FormatUint(calculatedSize, 10))
-	case 1:
-		return fmt.Sprintf("%skb", strconv.FormatUint(calculatedSize, 10))
-	case 2:
-		return fmt.Sprintf("%smb", strconv.FormatUint(calculatedSize, 10))
-	case 3:
-		return fmt.Sprintf("%sgb", strconv.FormatUint(calculatedSize, 10))
-	case 4:
-		return fmt.Sprintf("%stb", strconv.FormatUint(calculatedSize, 10))
-	}
-
-	return "N/A"
-}
-
 var _ Execution = &treeCommand{}
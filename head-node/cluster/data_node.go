@@ -27,6 +27,7 @@ type DataNode interface {
 
 type dataNode struct {
 	address *net.TCPAddr
+	pool    *pool
 }
 
 func NewDataNode(address string) (DataNode, error) {
@@ -37,6 +38,7 @@ func NewDataNode(address string) (DataNode, error) {
 
 	return &dataNode{
 		address: addr,
+		pool:    newPool(addr),
 	}, nil
 }
 
@@ -59,7 +61,51 @@ func (d *dataNode) result(conn net.Conn) bool {
 	return strings.Compare("+", string(b)) == 0
 }
 
-func (d *dataNode) Create(data []byte) (exists bool, sha512Hex string, err error) {
+func (d *dataNode) Create(data []byte) (bool, string, error) {
+	c, err := d.pool.acquire()
+	if err != nil {
+		if err == errLegacy {
+			return d.createLegacy(data)
+		}
+		return false, "", err
+	}
+	return d.create(c, data)
+}
+
+func (d *dataNode) CreateShadow(sha512Hex string) error {
+	c, err := d.pool.acquire()
+	if err != nil {
+		if err == errLegacy {
+			return d.createShadowLegacy(sha512Hex)
+		}
+		return err
+	}
+	return d.createShadow(c, sha512Hex)
+}
+
+func (d *dataNode) Read(sha512Hex string, begins uint32, ends uint32, readHandler func([]byte) error) error {
+	c, err := d.pool.acquire()
+	if err != nil {
+		if err == errLegacy {
+			return d.readLegacy(sha512Hex, begins, ends, readHandler)
+		}
+		return err
+	}
+	return d.read(c, sha512Hex, begins, ends, readHandler)
+}
+
+func (d *dataNode) Delete(sha512Hex string) error {
+	c, err := d.pool.acquire()
+	if err != nil {
+		if err == errLegacy {
+			return d.deleteLegacy(sha512Hex)
+		}
+		return err
+	}
+	return d.delete(c, sha512Hex)
+}
+
+func (d *dataNode) createLegacy(data []byte) (exists bool, sha512Hex string, err error) {
 	err = d.connect(func(conn net.Conn) error {
 		if _, err := conn.Write([]byte(commandCreate)); err != nil {
 			return err
@@ -99,7 +145,7 @@ func (d *dataNode) Create(data []byte) (exists bool, sha512Hex string, err error
 	return
 }
 
-func (d *dataNode) CreateShadow(sha512Hex string) error {
+func (d *dataNode) createShadowLegacy(sha512Hex string) error {
 	return d.connect(func(conn net.Conn) error {
 		if _, err := conn.Write([]byte(commandCreate)); err != nil {
 			return err
@@ -118,7 +164,7 @@ func (d *dataNode) CreateShadow(sha512Hex string) error {
 	})
 }
 
-func (d *dataNode) Read(sha512Hex string, begins uint32, ends uint32, readHandler func([]byte) error) error {
+func (d *dataNode) readLegacy(sha512Hex string, begins uint32, ends uint32, readHandler func([]byte) error) error {
 	return d.connect(func(conn net.Conn) error {
 		if _, err := conn.Write([]byte(commandRead)); err != nil {
 			return err
@@ -176,7 +222,7 @@ func (d *dataNode) Read(sha512Hex string, begins uint32, ends uint32, readHandle
 	})
 }
 
-func (d *dataNode) Delete(sha512Hex string) error {
+func (d *dataNode) deleteLegacy(sha512Hex string) error {
 	return d.connect(func(conn net.Conn) error {
 		if _, err := conn.Write([]byte(commandDelete)); err != nil {
 			return err
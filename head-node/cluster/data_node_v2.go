@@ -0,0 +1,110 @@
+package cluster
+
+import (
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/protocol"
+)
+
+// inlineCreateLimit is the size of the block that is sent with the first create request. Bigger blocks are
+// sent only if the data node does not have them
+const inlineCreateLimit = 64 * 1024 // 64kb
+
+func (d *dataNode) create(c *muxConn, data []byte) (bool, string, error) {
+	sha512Hash := sha512.New512_256()
+	_, _ = sha512Hash.Write(data)
+
+	sha512Sum := sha512Hash.Sum(nil)
+	sha512Hex := hex.EncodeToString(sha512Sum)
+
+	payload := sha512Sum
+	if len(data) <= inlineCreateLimit {
+		payload = append(payload, data...)
+	}
+
+	frame, err := c.request(commandCreate, payload, createTimeout(len(payload)), nil)
+	if err != nil {
+		return false, "", err
+	}
+
+	if frame.Status == protocol.StatusMissing {
+		payload = append(sha512Sum, data...)
+
+		frame, err = c.request(commandCreate, payload, createTimeout(len(payload)), nil)
+		if err != nil {
+			return false, "", err
+		}
+		if frame.Status != protocol.StatusSuccess {
+			return false, "", fmt.Errorf("create command is failed on data node")
+		}
+	}
+
+	return len(frame.Payload) > 0 && frame.Payload[0] == 1, sha512Hex, nil
+}
+
+func (d *dataNode) createShadow(c *muxConn, sha512Hex string) error {
+	sha512Sum, err := hex.DecodeString(sha512Hex)
+	if err != nil {
+		return err
+	}
+
+	frame, err := c.request(commandCreate, sha512Sum, createTimeout(0), nil)
+	if err != nil {
+		return err
+	}
+
+	if frame.Status == protocol.StatusMissing {
+		return errors.ErrCreate
+	}
+
+	return nil
+}
+
+func (d *dataNode) read(c *muxConn, sha512Hex string, begins uint32, ends uint32, readHandler func([]byte) error) error {
+	sha512Sum, err := hex.DecodeString(sha512Hex)
+	if err != nil {
+		return err
+	}
+
+	payload := make([]byte, len(sha512Sum)+8)
+	copy(payload, sha512Sum)
+	binary.LittleEndian.PutUint32(payload[len(sha512Sum):], begins)
+	binary.LittleEndian.PutUint32(payload[len(sha512Sum)+4:], ends)
+
+	expectedSize := protocol.MaxPayload
+	if ends > begins {
+		expectedSize = int(ends - begins)
+	}
+
+	// the range comes in many frames when it is big, readHandler still gets the whole range at once
+	var data []byte
+	frame, err := c.request(commandRead, payload, transferTimeout(expectedSize), func(part []byte) error {
+		if data == nil && ends > begins {
+			data = make([]byte, 0, ends-begins)
+		}
+		data = append(data, part...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		return readHandler(frame.Payload)
+	}
+	return readHandler(append(data, frame.Payload...))
+}
+
+func (d *dataNode) delete(c *muxConn, sha512Hex string) error {
+	sha512Sum, err := hex.DecodeString(sha512Hex)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.request(commandDelete, sha512Sum, transferTimeout(0), nil)
+	return err
+}
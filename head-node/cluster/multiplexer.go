@@ -0,0 +1,340 @@
+package cluster
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/protocol"
+)
+
+const poolSize = 4
+const poolStreamLimit = 16
+const poolIdleTimeout = time.Minute
+const legacyRetryInterval = time.Minute * 5
+const transferSpeed = 625000 // bytes/s
+
+// errLegacy is returned when the data node does not speak the multiplexed protocol. The requests should be sent
+// with the single request per connection commands
+var errLegacy = fmt.Errorf("data node does not support the multiplexed protocol")
+
+// quorumWaitTimeout covers the time that the master data node waits for the write quorum result of the cluster
+const quorumWaitTimeout = time.Minute
+
+func transferTimeout(expectedTransferSize int) time.Duration {
+	return dialTimeout + time.Second*time.Duration(expectedTransferSize/transferSpeed)
+}
+
+// createTimeout is the transfer timeout of the create requests, data node replies after the write quorum result
+func createTimeout(expectedTransferSize int) time.Duration {
+	return transferTimeout(expectedTransferSize) + quorumWaitTimeout
+}
+
+// pool keeps the long-lived multiplexed connections of the data node. A new connection is opened only when
+// the existing ones are busy with many requests
+type pool struct {
+	address *net.TCPAddr
+
+	mutex       sync.Mutex
+	conns       []*muxConn
+	legacyUntil time.Time
+}
+
+func newPool(address *net.TCPAddr) *pool {
+	return &pool{
+		address: address,
+		mutex:   sync.Mutex{},
+		conns:   make([]*muxConn, 0),
+	}
+}
+
+func (p *pool) acquire() (*muxConn, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if time.Now().Before(p.legacyUntil) {
+		return nil, errLegacy
+	}
+
+	var best *muxConn
+	alive := make([]*muxConn, 0, len(p.conns))
+	for _, c := range p.conns {
+		if c.broken() {
+			continue
+		}
+		if c.idle(poolIdleTimeout) {
+			c.close()
+			continue
+		}
+		alive = append(alive, c)
+
+		if best == nil || c.streams() < best.streams() {
+			best = c
+		}
+	}
+	p.conns = alive
+
+	if best != nil && (best.streams() < poolStreamLimit || len(p.conns) >= poolSize) {
+		best.touch()
+		return best, nil
+	}
+
+	c, err := dialMux(p.address)
+	if err != nil {
+		if err == errLegacy {
+			p.legacyUntil = time.Now().Add(legacyRetryInterval)
+			return nil, err
+		}
+		if best != nil {
+			best.touch()
+			return best, nil
+		}
+		return nil, err
+	}
+	p.conns = append(p.conns, c)
+
+	return c, nil
+}
+
+// muxConn is the connection that carries many requests at the same time. Responses are paired with the requests
+// by the frame id
+type muxConn struct {
+	conn       net.Conn
+	writeMutex sync.Mutex
+
+	mutex    sync.Mutex
+	nextId   uint32
+	pending  map[uint32]*muxStream
+	lastUsed time.Time
+	err      error
+	failed   chan struct{}
+}
+
+// muxStream receives the reply frames of the request. Partial frames are followed by the final one
+type muxStream struct {
+	frames chan *protocol.Frame
+	done   chan struct{}
+}
+
+func dialMux(address *net.TCPAddr) (*muxConn, error) {
+	conn, err := net.DialTimeout(address.Network(), address.String(), dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := negotiate(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return newMuxConn(conn), nil
+}
+
+func newMuxConn(conn net.Conn) *muxConn {
+	c := &muxConn{
+		conn:       conn,
+		writeMutex: sync.Mutex{},
+		mutex:      sync.Mutex{},
+		pending:    make(map[uint32]*muxStream),
+		lastUsed:   time.Now(),
+		failed:     make(chan struct{}),
+	}
+	go c.receive()
+
+	return c
+}
+
+// negotiate asks the data node to switch to the multiplexed protocol. The data nodes that do not know
+// the negotiation command refuse it as an unknown command
+func negotiate(conn net.Conn) error {
+	if err := conn.SetDeadline(time.Now().Add(dialTimeout)); err != nil {
+		return err
+	}
+
+	if _, err := conn.Write(append([]byte(protocol.Negotiate), protocol.Version2)); err != nil {
+		return err
+	}
+
+	result := make([]byte, 1)
+	if _, err := io.ReadFull(conn, result); err != nil {
+		if err == io.EOF {
+			return errLegacy
+		}
+		return err
+	}
+	if result[0] != '+' {
+		return errLegacy
+	}
+
+	if _, err := io.ReadFull(conn, result); err != nil {
+		return err
+	}
+	if result[0] != protocol.Version2 {
+		return errLegacy
+	}
+
+	return conn.SetDeadline(time.Time{})
+}
+
+func (c *muxConn) receive() {
+	for {
+		frame, err := protocol.ReadFrame(c.conn, nil)
+		if err != nil {
+			c.fail(err)
+			return
+		}
+
+		c.mutex.Lock()
+		stream, has := c.pending[frame.Id]
+		if has && frame.Status != protocol.StatusPartial {
+			delete(c.pending, frame.Id)
+		}
+		c.mutex.Unlock()
+
+		if !has {
+			continue
+		}
+
+		select {
+		case stream.frames <- frame:
+		case <-stream.done:
+		}
+	}
+}
+
+// fail closes the connection and releases the waiting requests
+func (c *muxConn) fail(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err != nil {
+		return
+	}
+	if err == io.EOF {
+		err = fmt.Errorf("data node closed the connection")
+	}
+	c.err = err
+
+	_ = c.conn.Close()
+	close(c.failed)
+
+	for id := range c.pending {
+		delete(c.pending, id)
+	}
+}
+
+func (c *muxConn) close() {
+	c.fail(fmt.Errorf("connection is closed"))
+}
+
+func (c *muxConn) broken() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.err != nil
+}
+
+func (c *muxConn) streams() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.pending)
+}
+
+func (c *muxConn) idle(timeout time.Duration) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.pending) == 0 && time.Since(c.lastUsed) > timeout
+}
+
+func (c *muxConn) touch() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.lastUsed = time.Now()
+}
+
+// request sends the frame and waits its response till the timeout. The failure status is returned as error.
+// The payloads of the partial frames are passed to partHandler before the final frame is returned
+func (c *muxConn) request(command string, payload []byte, timeout time.Duration, partHandler func([]byte) error) (*protocol.Frame, error) {
+	stream := &muxStream{
+		frames: make(chan *protocol.Frame, 1),
+		done:   make(chan struct{}),
+	}
+
+	c.mutex.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mutex.Unlock()
+		return nil, err
+	}
+	c.nextId++
+	id := c.nextId
+	c.pending[id] = stream
+	c.lastUsed = time.Now()
+	c.mutex.Unlock()
+
+	defer func() {
+		close(stream.done)
+
+		c.mutex.Lock()
+		delete(c.pending, id)
+		c.mutex.Unlock()
+	}()
+
+	if err := c.write(&protocol.Frame{Id: id, Command: command, Status: protocol.StatusRequest, Payload: payload}, timeout); err != nil {
+		c.fail(err)
+		return nil, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		var frame *protocol.Frame
+
+		select {
+		case frame = <-stream.frames:
+		case <-c.failed:
+			// the frame that is received before the failure is still served
+			select {
+			case frame = <-stream.frames:
+			default:
+				c.mutex.Lock()
+				defer c.mutex.Unlock()
+
+				return nil, c.err
+			}
+		case <-timer.C:
+			return nil, fmt.Errorf("%s command is timed out on data node", command)
+		}
+
+		switch frame.Status {
+		case protocol.StatusPartial:
+			if partHandler == nil {
+				return nil, fmt.Errorf("%s command is not expected to have partial reply", command)
+			}
+			if err := partHandler(frame.Payload); err != nil {
+				return nil, err
+			}
+			continue
+		case protocol.StatusFailure:
+			return nil, fmt.Errorf("%s command is failed on data node: %s", command, frame.Message())
+		}
+
+		return frame, nil
+	}
+}
+
+func (c *muxConn) write(frame *protocol.Frame, timeout time.Duration) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	if err := c.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	return protocol.WriteFrame(c.conn, frame)
+}
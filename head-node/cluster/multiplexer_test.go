@@ -0,0 +1,378 @@
+package cluster
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDataNode speaks the multiplexed protocol and passes every request frame to the handler. The data nodes
+// that are legacy refuse the negotiation by closing the connection
+type testDataNode struct {
+	listener net.Listener
+	legacy   bool
+	handler  func(conn *testDataNodeConn, frame *protocol.Frame)
+
+	mutex sync.Mutex
+	conns []*testDataNodeConn
+}
+
+type testDataNodeConn struct {
+	conn       net.Conn
+	writeMutex sync.Mutex
+}
+
+func (c *testDataNodeConn) reply(frame *protocol.Frame, status byte, payload []byte) {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	_ = protocol.WriteFrame(c.conn, &protocol.Frame{Id: frame.Id, Command: frame.Command, Status: status, Payload: payload})
+}
+
+func newTestDataNode(t *testing.T, handler func(conn *testDataNodeConn, frame *protocol.Frame)) *testDataNode {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := &testDataNode{listener: listener, handler: handler}
+	t.Cleanup(n.close)
+
+	go n.accept()
+
+	return n
+}
+
+func (n *testDataNode) address() *net.TCPAddr {
+	return n.listener.Addr().(*net.TCPAddr)
+}
+
+func (n *testDataNode) accept() {
+	for {
+		conn, err := n.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		c := &testDataNodeConn{conn: conn}
+
+		n.mutex.Lock()
+		n.conns = append(n.conns, c)
+		n.mutex.Unlock()
+
+		go n.serve(c)
+	}
+}
+
+func (n *testDataNode) serve(c *testDataNodeConn) {
+	defer func() { _ = c.conn.Close() }()
+
+	negotiation := make([]byte, len(protocol.Negotiate)+1)
+	if _, err := io.ReadFull(c.conn, negotiation); err != nil {
+		return
+	}
+	if n.legacy {
+		return
+	}
+	if _, err := c.conn.Write([]byte{'+', protocol.Version2}); err != nil {
+		return
+	}
+
+	for {
+		frame, err := protocol.ReadFrame(c.conn, nil)
+		if err != nil {
+			return
+		}
+		go n.handler(c, frame)
+	}
+}
+
+func (n *testDataNode) connections() int {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return len(n.conns)
+}
+
+func (n *testDataNode) close() {
+	_ = n.listener.Close()
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	for _, c := range n.conns {
+		_ = c.conn.Close()
+	}
+}
+
+// echo replies the request with its own payload
+func echo(conn *testDataNodeConn, frame *protocol.Frame) {
+	conn.reply(frame, protocol.StatusSuccess, frame.Payload)
+}
+
+func TestMuxConn_Demux(t *testing.T) {
+	// replies are sent in the reverse order of the requests
+	var mutex sync.Mutex
+	received := make([]*protocol.Frame, 0)
+
+	node := newTestDataNode(t, func(conn *testDataNodeConn, frame *protocol.Frame) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		received = append(received, frame)
+		if len(received) < 3 {
+			return
+		}
+		for i := len(received) - 1; i >= 0; i-- {
+			echo(conn, received[i])
+		}
+	})
+
+	c, err := dialMux(node.address())
+	assert.Nil(t, err)
+	defer c.close()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			payload := []byte(fmt.Sprintf("request %d", i))
+			frame, err := c.request("PING", payload, time.Second*5, nil)
+			if assert.Nil(t, err) {
+				assert.Equal(t, payload, frame.Payload)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 0, c.streams())
+}
+
+func TestMuxConn_Failure(t *testing.T) {
+	node := newTestDataNode(t, func(conn *testDataNodeConn, frame *protocol.Frame) {
+		conn.reply(frame, protocol.StatusFailure, []byte("block is not exists"))
+	})
+
+	c, err := dialMux(node.address())
+	assert.Nil(t, err)
+	defer c.close()
+
+	_, err = c.request(commandRead, nil, time.Second*5, nil)
+	assert.EqualError(t, err, "READ command is failed on data node: block is not exists")
+
+	// the failure of the request does not break the connection
+	assert.False(t, c.broken())
+}
+
+func TestMuxConn_Timeout(t *testing.T) {
+	node := newTestDataNode(t, func(*testDataNodeConn, *protocol.Frame) {})
+
+	c, err := dialMux(node.address())
+	assert.Nil(t, err)
+	defer c.close()
+
+	_, err = c.request("PING", nil, time.Millisecond*100, nil)
+	assert.EqualError(t, err, "PING command is timed out on data node")
+	assert.Equal(t, 0, c.streams())
+	assert.False(t, c.broken())
+}
+
+func TestMuxConn_Partial(t *testing.T) {
+	node := newTestDataNode(t, func(conn *testDataNodeConn, frame *protocol.Frame) {
+		conn.reply(frame, protocol.StatusPartial, []byte("part 1, "))
+		conn.reply(frame, protocol.StatusPartial, []byte("part 2, "))
+		conn.reply(frame, protocol.StatusSuccess, []byte("last part"))
+	})
+
+	c, err := dialMux(node.address())
+	assert.Nil(t, err)
+	defer c.close()
+
+	parts := make([]string, 0)
+	frame, err := c.request(commandRead, nil, time.Second*5, func(part []byte) error {
+		parts = append(parts, string(part))
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"part 1, ", "part 2, "}, parts)
+	assert.Equal(t, "last part", string(frame.Payload))
+
+	_, err = c.request(commandRead, nil, time.Second*5, func([]byte) error {
+		return fmt.Errorf("disk is full")
+	})
+	assert.EqualError(t, err, "disk is full")
+
+	_, err = c.request(commandDelete, nil, time.Second*5, nil)
+	assert.EqualError(t, err, "DELE command is not expected to have partial reply")
+
+	// the parts of the abandoned requests do not block the other requests on the connection
+	frame, err = c.request(commandRead, nil, time.Second*5, func([]byte) error { return nil })
+	assert.Nil(t, err)
+	assert.Equal(t, "last part", string(frame.Payload))
+}
+
+func TestMuxConn_ConnectionLost(t *testing.T) {
+	var mutex sync.Mutex
+	received := 0
+
+	node := newTestDataNode(t, func(conn *testDataNodeConn, frame *protocol.Frame) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		received++
+		if received == 1 {
+			echo(conn, frame)
+			return
+		}
+		if received == 3 {
+			_ = conn.conn.Close()
+		}
+	})
+
+	c, err := dialMux(node.address())
+	assert.Nil(t, err)
+
+	_, err = c.request("PING", nil, time.Second*5, nil)
+	assert.Nil(t, err)
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := c.request("PING", nil, time.Second*5, nil)
+			errs <- err
+		}()
+	}
+
+	// the requests in flight are released with the connection error instead of waiting till the timeout
+	begins := time.Now()
+	for i := 0; i < 2; i++ {
+		assert.EqualError(t, <-errs, "data node closed the connection")
+	}
+	assert.Less(t, time.Since(begins), time.Second*5)
+
+	assert.True(t, c.broken())
+	assert.Equal(t, 0, c.streams())
+
+	_, err = c.request("PING", nil, time.Second*5, nil)
+	assert.EqualError(t, err, "data node closed the connection")
+}
+
+func TestPool_Acquire(t *testing.T) {
+	node := newTestDataNode(t, echo)
+	p := newPool(node.address())
+
+	c1, err := p.acquire()
+	assert.Nil(t, err)
+	c2, err := p.acquire()
+	assert.Nil(t, err)
+
+	// the connection is reused while it is not busy
+	assert.Same(t, c1, c2)
+	assert.Equal(t, 1, node.connections())
+
+	busy := func(c *muxConn) {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		for i := 0; i < poolStreamLimit; i++ {
+			c.pending[uint32(1000+i)] = &muxStream{}
+		}
+	}
+
+	// busy connections are not used when the pool has room for a new one
+	conns := map[*muxConn]bool{c1: true}
+	busy(c1)
+	for i := 1; i < poolSize; i++ {
+		c, err := p.acquire()
+		assert.Nil(t, err)
+		assert.False(t, conns[c])
+
+		conns[c] = true
+		busy(c)
+	}
+	assert.Len(t, p.conns, poolSize)
+
+	// the pool is full, the least busy connection is shared
+	c, err := p.acquire()
+	assert.Nil(t, err)
+	assert.True(t, conns[c])
+	assert.Len(t, p.conns, poolSize)
+
+	// broken connection is dropped from the pool
+	for c := range conns {
+		c.close()
+	}
+	c, err = p.acquire()
+	assert.Nil(t, err)
+	assert.False(t, conns[c])
+	assert.Len(t, p.conns, 1)
+	assert.Equal(t, poolSize+1, node.connections())
+}
+
+func TestPool_AcquireIdle(t *testing.T) {
+	node := newTestDataNode(t, echo)
+	p := newPool(node.address())
+
+	c1, err := p.acquire()
+	assert.Nil(t, err)
+
+	c1.mutex.Lock()
+	c1.lastUsed = time.Now().Add(-poolIdleTimeout * 2)
+	c1.mutex.Unlock()
+
+	c2, err := p.acquire()
+	assert.Nil(t, err)
+	assert.NotSame(t, c1, c2)
+	assert.True(t, c1.broken())
+}
+
+func TestPool_Legacy(t *testing.T) {
+	node := newTestDataNode(t, echo)
+	node.legacy = true
+
+	p := newPool(node.address())
+
+	_, err := p.acquire()
+	assert.Equal(t, errLegacy, err)
+	assert.True(t, p.legacyUntil.After(time.Now()))
+
+	// the node is not probed again till the retry interval
+	_, err = p.acquire()
+	assert.Equal(t, errLegacy, err)
+	assert.Equal(t, 1, node.connections())
+}
+
+func TestDataNode_ReadPartial(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	node := newTestDataNode(t, func(conn *testDataNodeConn, frame *protocol.Frame) {
+		for i := 0; i < 4; i++ {
+			conn.reply(frame, protocol.StatusPartial, data[i*2000:(i+1)*2000])
+		}
+		conn.reply(frame, protocol.StatusSuccess, data[8000:])
+	})
+
+	d, err := NewDataNode(node.address().String())
+	assert.Nil(t, err)
+
+	sum := sha512.Sum512_256(data)
+
+	calls := 0
+	assert.Nil(t, d.Read(hex.EncodeToString(sum[:]), 0, uint32(len(data)), func(d []byte) error {
+		calls++
+		assert.Equal(t, data, d)
+		return nil
+	}))
+	assert.Equal(t, 1, calls)
+}
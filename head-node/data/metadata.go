@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/document"
 	"github.com/freakmaxi/kertish-dos/basics/errors"
 	"github.com/freakmaxi/locking-center-client-go/mutex"
 	"go.mongodb.org/mongo-driver/bson"
@@ -26,6 +27,14 @@ type Metadata interface {
 
 	SaveBlock(folderPaths []string, saveHandler func(folders map[string]*common.Folder) (bool, error)) error
 	SaveChain(folderPath string, saveHandler func(folder *common.Folder) (bool, error)) error
+
+	// Search finds the files under the query root recursively and returns the requested page
+	Search(query *common.SearchQuery) (*common.SearchResult, error)
+
+	// Migrate moves the embedded folder files to the separated files collection and returns the migrated folder count
+	Migrate() (int, error)
+	// InlineFiles returns the paths of the files that have inline chunks bigger than the limit
+	InlineFiles(limit uint64) ([]string, error)
 }
 
 const metadataCollection = "metadata"
@@ -35,19 +44,25 @@ type metadata struct {
 	mutex mutex.LockingCenter
 	conn  *Connection
 	col   *mongo.Collection
+	files *mongo.Collection
 }
 
 func NewMetadata(mutex mutex.LockingCenter, conn *Connection, database string) (Metadata, error) {
 	dosCol := conn.client.Database(database).Collection(metadataCollection)
+	filesCol := conn.client.Database(database).Collection(metadataFilesCollection)
 
 	m := &metadata{
 		mutex: mutex,
 		conn:  conn,
 		col:   dosCol,
+		files: filesCol,
 	}
 	if err := m.setupIndices(); err != nil {
 		return nil, err
 	}
+	if err := m.setupFileIndices(); err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
@@ -75,42 +90,44 @@ func (m *metadata) find(filter interface{}, opts ...*options.FindOptions) (*mong
 	return m.col.Find(ctx, filter, opts...)
 }
 
-func (m *metadata) findOne(parentContext context.Context, folderPath string, opts ...*options.FindOneOptions) (*common.Folder, error) {
+func (m *metadata) findOne(parentContext context.Context, folderPath string, opts ...*options.FindOneOptions) (*common.Folder, *document.State, error) {
 	ctx, cancelFunc := m.context(parentContext)
 	defer cancelFunc()
 
-	var folder *common.Folder
-	if err := m.col.FindOne(ctx, bson.M{"full": folderPath}, opts...).Decode(&folder); err != nil {
+	raw, err := m.col.FindOne(ctx, bson.M{"full": folderPath}, opts...).Raw()
+	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, os.ErrNotExist
+			return nil, nil, os.ErrNotExist
 		}
-		return nil, err
+		return nil, nil, err
 	}
-	return folder, nil
+	return m.decodeFolder(parentContext, raw)
 }
 
-func (m *metadata) next(cursor *mongo.Cursor) (*common.Folder, error) {
+func (m *metadata) nextRaw(cursor *mongo.Cursor) (bson.Raw, error) {
 	ctx, cancelFunc := m.context(context.Background())
 	defer cancelFunc()
 
 	if !cursor.Next(ctx) {
 		return nil, io.EOF
 	}
-
-	var folder *common.Folder
-	if err := cursor.Decode(&folder); err != nil {
-		return nil, err
-	}
-	return folder, nil
+	return cursor.Current, nil
 }
 
-func (m *metadata) updateOne(parentContext context.Context, folderPath string, folder common.Folder) error {
+func (m *metadata) updateOne(parentContext context.Context, folderPath string, folder common.Folder, state *document.State) error {
 	ctx, cancelFunc := m.context(parentContext)
 	defer cancelFunc()
 
+	update := bson.M{"$set": folder}
+	if state.Legacy() {
+		update["$unset"] = bson.M{"files": ""}
+	}
+
 	opts := (&options.UpdateOptions{}).SetUpsert(true)
-	_, err := m.col.UpdateOne(ctx, bson.M{"full": folderPath}, bson.M{"$set": folder}, opts)
-	return err
+	if _, err := m.col.UpdateOne(ctx, bson.M{"full": folderPath}, update, opts); err != nil {
+		return err
+	}
+	return m.syncFiles(parentContext, folderPath, &folder, state)
 }
 
 func (m *metadata) Get(folderPaths []string) ([]*common.Folder, error) {
@@ -118,7 +135,7 @@ func (m *metadata) Get(folderPaths []string) ([]*common.Folder, error) {
 
 	folders := make([]*common.Folder, 0)
 	for _, folderPath := range folderPaths {
-		folder, err := m.findOne(context.Background(), folderPath)
+		folder, _, err := m.findOne(context.Background(), folderPath)
 		if err != nil {
 			return nil, err
 		}
@@ -188,16 +205,40 @@ func (m *metadata) ChildrenTree(folderPath string, includeItself bool, reverseSo
 	}()
 
 	folders := make([]*common.Folder, 0)
+	legacyFiles := make(map[string]common.Files)
 	for {
-		folder, err := m.next(cursor)
+		raw, err := m.nextRaw(cursor)
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 			return nil, err
 		}
+
+		folder, state, err := document.Decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		// only legacy folders are decoded with a state, their files are embedded in the document
+		if state != nil {
+			legacyFiles[folder.Full] = folder.Files
+		}
 		folders = append(folders, folder)
 	}
+
+	filesMap, err := m.findFiles(context.Background(), m.childrenFilesFilter(folderPath, includeItself))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, folder := range folders {
+		if files, has := legacyFiles[folder.Full]; has {
+			folder.Files = files
+			continue
+		}
+		folder.Files = m.filesOf(filesMap, folder.Full)
+	}
+
 	return folders, nil
 }
 
@@ -216,17 +257,19 @@ func (m *metadata) SaveBlock(folderPaths []string, saveHandler func(folders map[
 	}()
 
 	folders := make(map[string]*common.Folder)
+	states := make(map[string]*document.State)
 	for _, folderPath := range folderPaths {
-		folder, err := m.findOne(context.Background(), folderPath)
+		folder, state, err := m.findOne(context.Background(), folderPath)
 		if err != nil {
 			return err
 		}
 		folders[folderPath] = folder
+		states[folderPath] = state
 	}
 
 	save, err := saveHandler(folders)
 	if save {
-		if err := m.overwrite(folders); err != nil {
+		if err := m.overwrite(folders, states); err != nil {
 			return err
 		}
 	}
@@ -268,6 +311,7 @@ func (m *metadata) SaveChain(folderPath string, saveHandler func(folder *common.
 	}
 
 	var folder *common.Folder
+	var state *document.State
 
 	ctxS1, cancelS1Func := m.context(context.Background())
 	defer cancelS1Func()
@@ -283,10 +327,11 @@ func (m *metadata) SaveChain(folderPath string, saveHandler func(folder *common.
 		}
 
 		var parentFolder *common.Folder
+		var parentState *document.State
 		for len(folderTree) > 0 {
 			folderPath := folderTree[0]
 
-			folder, err = m.findOne(parentContext, folderPath)
+			folder, state, err = m.findOne(parentContext, folderPath)
 			if err != nil {
 				if err != os.ErrNotExist {
 					return err
@@ -294,6 +339,7 @@ func (m *metadata) SaveChain(folderPath string, saveHandler func(folder *common.
 
 				if parentFolder == nil {
 					parentFolder = common.NewFolder("/")
+					parentState = document.EmptyState()
 
 					if err := insertOneFunc(parentContext, *parentFolder); err != nil {
 						return err
@@ -313,12 +359,13 @@ func (m *metadata) SaveChain(folderPath string, saveHandler func(folder *common.
 					return err
 				}
 
-				if err := m.updateOne(parentContext, parentFolder.Full, *parentFolder); err != nil {
+				if err := m.updateOne(parentContext, parentFolder.Full, *parentFolder, parentState); err != nil {
 					return err
 				}
 				if err := insertOneFunc(parentContext, *folder); err != nil {
 					return err
 				}
+				state = document.EmptyState()
 			}
 
 			if len(folderTree) == 1 {
@@ -326,6 +373,7 @@ func (m *metadata) SaveChain(folderPath string, saveHandler func(folder *common.
 			}
 
 			parentFolder = folder
+			parentState = state
 			folderTree = folderTree[1:]
 
 			m.mutex.Unlock(parentFolder.Full)
@@ -351,14 +399,14 @@ func (m *metadata) SaveChain(folderPath string, saveHandler func(folder *common.
 		return err
 	}
 
-	if err := m.updateOne(context.Background(), folder.Full, *folder); err != nil {
+	if err := m.updateOne(context.Background(), folder.Full, *folder, state); err != nil {
 		return err
 	}
 
 	return err
 }
 
-func (m *metadata) overwrite(folders map[string]*common.Folder) error {
+func (m *metadata) overwrite(folders map[string]*common.Folder, states map[string]*document.State) error {
 	deleteOneFunc := func(parentContext context.Context, folderPath string) error {
 		ctx, cancelFunc := m.context(parentContext)
 		defer cancelFunc()
@@ -395,10 +443,13 @@ func (m *metadata) overwrite(folders map[string]*common.Folder) error {
 				if err := deleteOneFunc(parentContext, folderPath); err != nil && err != os.ErrNotExist {
 					return err
 				}
+				if err := m.dropFiles(parentContext, folderPath); err != nil {
+					return err
+				}
 				continue
 			}
 
-			if err := m.updateOne(parentContext, folderPath, *folder); err != nil {
+			if err := m.updateOne(parentContext, folderPath, *folder, states[folderPath]); err != nil {
 				return err
 			}
 		}
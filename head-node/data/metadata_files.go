@@ -0,0 +1,246 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/document"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const metadataFilesCollection = "metadata-files"
+
+func (m *metadata) setupFileIndices() error {
+	models := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "parent", Value: 1}, {Key: "name", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// search indices
+		{Keys: bson.D{{Key: "mime", Value: 1}}},
+		{Keys: bson.D{{Key: "size", Value: 1}}},
+		{Keys: bson.D{{Key: "created", Value: 1}}},
+		{Keys: bson.D{{Key: "modified", Value: 1}}},
+		{Keys: bson.D{{Key: "zombie", Value: 1}}},
+		{Keys: bson.D{{Key: "lock.till", Value: 1}}},
+		// inline promotion index
+		{
+			Keys:    bson.D{{Key: "chunks.inlined", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	}
+
+	ctx, cancelFunc := m.context(context.Background())
+	defer cancelFunc()
+
+	_, err := m.files.Indexes().CreateMany(ctx, models)
+	return err
+}
+
+func (m *metadata) decodeFolder(parentContext context.Context, raw bson.Raw) (*common.Folder, *document.State, error) {
+	folder, state, err := document.Decode(raw)
+	if err != nil || state != nil {
+		return folder, state, err
+	}
+
+	filesMap, err := m.findFiles(parentContext, bson.M{"parent": folder.Full})
+	if err != nil {
+		return nil, nil, err
+	}
+	folder.Files = m.filesOf(filesMap, folder.Full)
+
+	state, err = document.NewState(folder, false)
+	return folder, state, err
+}
+
+func (m *metadata) findFiles(parentContext context.Context, filter interface{}) (map[string]common.Files, error) {
+	ctx, cancelFunc := m.context(parentContext)
+	defer cancelFunc()
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "parent", Value: 1}, {Key: "name", Value: 1}})
+
+	cursor, err := m.files.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		ctx, cancelFunc := m.context(context.Background())
+		defer cancelFunc()
+
+		_ = cursor.Close(ctx)
+	}()
+
+	filesMap := make(map[string]common.Files)
+	for {
+		fileDocument, err := m.nextFile(cursor)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		file := fileDocument.File
+		filesMap[fileDocument.Parent] = append(filesMap[fileDocument.Parent], &file)
+	}
+	return filesMap, nil
+}
+
+func (m *metadata) nextFile(cursor *mongo.Cursor) (*document.File, error) {
+	ctx, cancelFunc := m.context(context.Background())
+	defer cancelFunc()
+
+	if !cursor.Next(ctx) {
+		return nil, io.EOF
+	}
+
+	var fileDocument *document.File
+	if err := cursor.Decode(&fileDocument); err != nil {
+		return nil, err
+	}
+	return fileDocument, nil
+}
+
+func (m *metadata) filesOf(filesMap map[string]common.Files, folderPath string) common.Files {
+	files, has := filesMap[folderPath]
+	if !has {
+		return make(common.Files, 0)
+	}
+	sort.Sort(files)
+	return files
+}
+
+// childrenFilesFilter creates the files filter matching the same folders with the ChildrenTree query
+func (m *metadata) childrenFilesFilter(folderPath string, includeItself bool) bson.M {
+	subFolderPath := folderPath
+	if strings.Compare(subFolderPath, "/") != 0 {
+		subFolderPath = fmt.Sprintf("%s/", subFolderPath)
+	}
+	filterContent := []interface{}{
+		bson.M{"parent": bson.M{"$regex": primitive.Regex{Pattern: fmt.Sprintf("^%s.+", subFolderPath)}}},
+	}
+	if includeItself {
+		filterContent = append(filterContent, bson.M{"parent": folderPath})
+	}
+	return bson.M{"$or": filterContent}
+}
+
+func (m *metadata) syncFiles(parentContext context.Context, folderPath string, folder *common.Folder, state *document.State) error {
+	changes, err := state.Changes(folder)
+	if err != nil {
+		return err
+	}
+
+	models := changes.WriteModels(folderPath)
+	if len(models) == 0 {
+		return nil
+	}
+
+	ctx, cancelFunc := m.context(parentContext)
+	defer cancelFunc()
+
+	_, err = m.files.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (m *metadata) dropFiles(parentContext context.Context, folderPath string) error {
+	ctx, cancelFunc := m.context(parentContext)
+	defer cancelFunc()
+
+	_, err := m.files.DeleteMany(ctx, bson.M{"parent": folderPath})
+	return err
+}
+
+// Migrate moves the files of the folders that are still in the embedded files layout to the
+// separated files collection. It works online, folders are locked one by one while they are migrating
+func (m *metadata) Migrate() (int, error) {
+	opts := options.Find()
+	opts.SetProjection(bson.M{"_id": 0, "full": 1})
+	opts.SetNoCursorTimeout(true)
+
+	cursor, err := m.find(bson.M{"files": bson.M{"$exists": true}}, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		ctx, cancelFunc := m.context(context.Background())
+		defer cancelFunc()
+
+		_ = cursor.Close(ctx)
+	}()
+
+	folderPaths := make([]string, 0)
+	for {
+		raw, err := m.nextRaw(cursor)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, err
+		}
+		folderPaths = append(folderPaths, raw.Lookup("full").StringValue())
+	}
+
+	migrated := 0
+	for _, folderPath := range folderPaths {
+		if err := m.SaveBlock([]string{folderPath}, func(folders map[string]*common.Folder) (bool, error) {
+			return true, nil
+		}); err != nil {
+			if err == os.ErrNotExist {
+				continue
+			}
+			return migrated, err
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// InlineFiles returns the paths of the files that have inline chunks bigger than the limit
+func (m *metadata) InlineFiles(limit uint64) ([]string, error) {
+	ctx, cancelFunc := m.context(context.Background())
+	defer cancelFunc()
+
+	opts := options.Find()
+	opts.SetProjection(bson.M{"_id": 0, "parent": 1, "name": 1})
+	opts.SetNoCursorTimeout(true)
+
+	filter := bson.M{
+		"chunks": bson.M{
+			"$elemMatch": bson.M{"inlined": true, "size": bson.M{"$gt": limit}},
+		},
+	}
+	cursor, err := m.files.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		ctx, cancelFunc := m.context(context.Background())
+		defer cancelFunc()
+
+		_ = cursor.Close(ctx)
+	}()
+
+	paths := make([]string, 0)
+	for {
+		fileDocument, err := m.nextFile(cursor)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		paths = append(paths, common.Join(fileDocument.Parent, fileDocument.Name))
+	}
+	return paths, nil
+}
@@ -0,0 +1,222 @@
+package data
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const continueSeparator = "\x00"
+
+func (m *metadata) Search(query *common.SearchQuery) (*common.SearchResult, error) {
+	filter, err := m.searchFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancelFunc := m.context(context.Background())
+	defer cancelFunc()
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "parent", Value: 1}, {Key: "name", Value: 1}})
+	opts.SetLimit(int64(query.PageSize + 1))
+
+	cursor, err := m.files.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		ctx, cancelFunc := m.context(context.Background())
+		defer cancelFunc()
+
+		_ = cursor.Close(ctx)
+	}()
+
+	result := &common.SearchResult{
+		Entries: make([]*common.SearchEntry, 0),
+	}
+	for {
+		document, err := m.nextFile(cursor)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		if len(result.Entries) == query.PageSize {
+			last := result.Entries[len(result.Entries)-1]
+			result.Continue = m.encodeContinue(last.Folder, last.Name)
+			break
+		}
+
+		file := document.File
+		result.Entries = append(result.Entries, &common.SearchEntry{
+			Folder: document.Parent,
+			File:   &file,
+		})
+	}
+
+	result.Incomplete, err = m.legacyExists(query.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// legacyExists checks if there is any folder under the root that still keeps its files embedded. Search only
+// queries the files collection so the files of these folders are not found till the migration is completed
+func (m *metadata) legacyExists(rootPath string) (bool, error) {
+	subPath := rootPath
+	if strings.Compare(subPath, "/") != 0 {
+		subPath = subPath + "/"
+	}
+
+	ctx, cancelFunc := m.context(context.Background())
+	defer cancelFunc()
+
+	count, err := m.col.CountDocuments(
+		ctx,
+		bson.M{
+			"files": bson.M{"$exists": true},
+			"$or": bson.A{
+				bson.M{"full": rootPath},
+				bson.M{"full": bson.M{"$regex": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(subPath)}}},
+			},
+		},
+		options.Count().SetLimit(1),
+	)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (m *metadata) searchFilter(query *common.SearchQuery) (bson.M, error) {
+	conditions := make(bson.A, 0)
+
+	rootPath := query.Root
+	subPath := rootPath
+	if strings.Compare(subPath, "/") != 0 {
+		subPath = subPath + "/"
+	}
+	conditions = append(conditions, bson.M{
+		"$or": bson.A{
+			bson.M{"parent": rootPath},
+			bson.M{"parent": bson.M{"$regex": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(subPath)}}},
+		},
+	})
+
+	if len(query.Name) > 0 {
+		conditions = append(conditions, bson.M{"name": bson.M{"$regex": primitive.Regex{Pattern: common.GlobToRegex(query.Name)}}})
+	}
+	if len(query.NameRegex) > 0 {
+		conditions = append(conditions, bson.M{"name": bson.M{"$regex": primitive.Regex{Pattern: query.NameRegex}}})
+	}
+	if len(query.Mime) > 0 {
+		conditions = append(conditions, bson.M{"mime": bson.M{"$regex": primitive.Regex{Pattern: common.GlobToRegex(query.Mime)}}})
+	}
+
+	if sizeRange := m.rangeFilter(query.SizeMin, query.SizeMax); sizeRange != nil {
+		conditions = append(conditions, bson.M{"size": sizeRange})
+	}
+	if createdRange := m.timeRangeFilter(query.CreatedAfter, query.CreatedBefore); createdRange != nil {
+		conditions = append(conditions, bson.M{"created": createdRange})
+	}
+	if modifiedRange := m.timeRangeFilter(query.ModifiedAfter, query.ModifiedBefore); modifiedRange != nil {
+		conditions = append(conditions, bson.M{"modified": modifiedRange})
+	}
+
+	if query.Zombie != nil {
+		if *query.Zombie {
+			conditions = append(conditions, bson.M{"$or": bson.A{
+				bson.M{"zombie": true},
+				bson.M{"chunks.0": bson.M{"$exists": false}},
+			}})
+		} else {
+			conditions = append(conditions, bson.M{"zombie": false, "chunks.0": bson.M{"$exists": true}})
+		}
+	}
+
+	if query.Locked != nil {
+		now := time.Now().UTC()
+		if *query.Locked {
+			conditions = append(conditions, bson.M{"lock.till": bson.M{"$gt": now}})
+		} else {
+			conditions = append(conditions, bson.M{"$or": bson.A{
+				bson.M{"lock": nil},
+				bson.M{"lock.till": bson.M{"$lte": now}},
+			}})
+		}
+	}
+
+	if len(query.Continue) > 0 {
+		parent, name, err := m.decodeContinue(query.Continue)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{"parent": bson.M{"$gt": parent}},
+			bson.M{"parent": parent, "name": bson.M{"$gt": name}},
+		}})
+	}
+
+	return bson.M{"$and": conditions}, nil
+}
+
+func (m *metadata) rangeFilter(min *uint64, max *uint64) bson.M {
+	if min == nil && max == nil {
+		return nil
+	}
+
+	filter := bson.M{}
+	if min != nil {
+		filter["$gte"] = int64(*min)
+	}
+	if max != nil {
+		filter["$lte"] = int64(*max)
+	}
+	return filter
+}
+
+func (m *metadata) timeRangeFilter(after *time.Time, before *time.Time) bson.M {
+	if after == nil && before == nil {
+		return nil
+	}
+
+	filter := bson.M{}
+	if after != nil {
+		filter["$gte"] = *after
+	}
+	if before != nil {
+		filter["$lte"] = *before
+	}
+	return filter
+}
+
+func (m *metadata) encodeContinue(parent string, name string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(parent + continueSeparator + name))
+}
+
+func (m *metadata) decodeContinue(value string) (string, string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return "", "", os.ErrInvalid
+	}
+
+	parts := strings.SplitN(string(decoded), continueSeparator, 2)
+	if len(parts) != 2 {
+		return "", "", os.ErrInvalid
+	}
+	return parts[0], parts[1], nil
+}
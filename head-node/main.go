@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/freakmaxi/kertish-dos/basics/hooks"
@@ -68,6 +69,50 @@ func main() {
 	mongoTransaction := os.Getenv("MONGO_TRANSACTION")
 	logger.Info(fmt.Sprintf("MONGO_TRANSACTION: %t", len(mongoTransaction) > 0))
 
+	presignSecret := os.Getenv("PRESIGN_SECRET")
+	logger.Info(fmt.Sprintf("PRESIGN_SECRET: %t", len(presignSecret) > 0))
+
+	hedgePercentileString := os.Getenv("READ_HEDGE_PERCENTILE")
+	if len(hedgePercentileString) == 0 {
+		hedgePercentileString = "95"
+	}
+	hedgePercentile, err := strconv.ParseUint(hedgePercentileString, 10, 8)
+	if err != nil || hedgePercentile > 99 {
+		logger.Error("Read Hedge Percentile should be between 0 and 99")
+		os.Exit(16)
+	}
+	if hedgePercentile == 0 {
+		logger.Info("Hedged reads are disabled")
+	} else {
+		logger.Info(fmt.Sprintf("READ_HEDGE_PERCENTILE: %s", hedgePercentileString))
+	}
+
+	prefetchString := os.Getenv("READ_PREFETCH")
+	if len(prefetchString) == 0 {
+		prefetchString = "1"
+	}
+	prefetch, err := strconv.ParseUint(prefetchString, 10, 8)
+	if err != nil {
+		logger.Error("Read Prefetch is wrong", zap.Error(err))
+		os.Exit(17)
+	}
+	logger.Info(fmt.Sprintf("READ_PREFETCH: %s", prefetchString))
+
+	inlineLimitString := os.Getenv("INLINE_LIMIT")
+	if len(inlineLimitString) == 0 {
+		inlineLimitString = "0"
+	}
+	inlineLimit, err := strconv.ParseUint(inlineLimitString, 10, 64)
+	if err != nil || inlineLimit > manager.MaxInlineLimit {
+		logger.Error(fmt.Sprintf("Inline Limit should be between 0 and %d bytes", manager.MaxInlineLimit))
+		os.Exit(23)
+	}
+	if inlineLimit == 0 {
+		logger.Info("Inline file storage is disabled")
+	} else {
+		logger.Info(fmt.Sprintf("INLINE_LIMIT: %s", inlineLimitString))
+	}
+
 	mutexConn := os.Getenv("LOCKING_CENTER")
 	if len(mutexConn) == 0 {
 		logger.Error("LOCKING_CENTER have to be specified")
@@ -94,25 +139,71 @@ func main() {
 		os.Exit(18)
 	}
 
-	cluster, err := manager.NewCluster([]string{managerAddress}, logger)
+	cluster, err := manager.NewCluster(
+		[]string{managerAddress},
+		manager.ReadOptions{
+			HedgePercentile: int(hedgePercentile),
+			Prefetch:        int(prefetch),
+		},
+		logger,
+	)
 	if err != nil {
 		logger.Error("Cluster Manager is failed", zap.Error(err))
 		os.Exit(20)
 	}
-	dos := manager.NewDos(metadata, cluster, logger)
+	dos := manager.NewDos(metadata, m, cluster, inlineLimit, logger)
 	// create root if not exists
 	if err := dos.CreateFolder("/"); err != nil && err != os.ErrExist {
 		logger.Error("Unable to create cluster root path", zap.Error(err))
 		os.Exit(21)
 	}
-	dosRouter := routing.NewDosRouter(dos, logger)
+
+	go func() {
+		migrated, err := metadata.Migrate()
+		if err != nil {
+			logger.Error("Metadata files migration is failed", zap.Int("migrated", migrated), zap.Error(err))
+			return
+		}
+		if migrated > 0 {
+			logger.Info(fmt.Sprintf("Metadata files migration is completed, %d folder(s) migrated", migrated))
+		}
+
+		// inline files are promoted after the migration to be able to reach all of them
+		promoted, err := dos.Promote()
+		if err != nil {
+			logger.Error("Inline files promotion is failed", zap.Int("promoted", promoted), zap.Error(err))
+			return
+		}
+		if promoted > 0 {
+			logger.Info(fmt.Sprintf("Inline files promotion is completed, %d file(s) promoted", promoted))
+		}
+	}()
+
+	var presign manager.Presign
+	if len(presignSecret) > 0 {
+		presign, err = manager.NewPresign(presignSecret)
+		if err != nil {
+			logger.Error("Presign setup is failed", zap.Error(err))
+			os.Exit(22)
+		}
+	}
+	dosRouter := routing.NewDosRouter(dos, presign, logger)
+
+	searchRouter := routing.NewSearchRouter(dos, logger)
+
+	webdavRouter := routing.NewWebdavRouter(dos, logger)
 
 	hook := manager.NewHook(metadata, logger)
 	hookRouter := routing.NewHookRouter(hook, logger)
 
 	routerManager := routing.NewManager()
 	routerManager.Add(dosRouter)
+	routerManager.Add(searchRouter)
 	routerManager.Add(hookRouter)
+	routerManager.Add(webdavRouter)
+	if presign != nil {
+		routerManager.Add(routing.NewPresignRouter(presign, logger))
+	}
 
 	proxy := services.NewProxy(bindAddr, routerManager, logger)
 	proxy.Start()
@@ -23,6 +23,9 @@ const managerEndPoint = "/client/manager"
 
 type Cluster interface {
 	Create(size uint64, reader io.Reader) (*common.CreationResult, error)
+	// CreateBatch creates the files under a single reservation. Results and errors are in the same order with sizes.
+	// The failure of a file does not fail the batch, it is reported in its own error slot
+	CreateBatch(sizes []uint64, readers []io.Reader) ([]*common.CreationResult, []error, error)
 	CreateShadow(chunks common.DataChunks) error
 	Read(chunks common.DataChunks) (func(w io.Writer, begins int64, ends int64) error, error)
 	Delete(chunks common.DataChunks) (*common.DeletionResult, error)
@@ -31,13 +34,14 @@ type Cluster interface {
 type cluster struct {
 	client      http.Client
 	managerAddr []string
+	router      *readRouter
 	logger      *zap.Logger
 
 	nodeCacheMutex sync.Mutex
 	nodeCache      map[string]cluster2.DataNode
 }
 
-func NewCluster(managerAddresses []string, logger *zap.Logger) (Cluster, error) {
+func NewCluster(managerAddresses []string, readOptions ReadOptions, logger *zap.Logger) (Cluster, error) {
 	if len(managerAddresses) == 0 {
 		return nil, os.ErrInvalid
 	}
@@ -45,6 +49,7 @@ func NewCluster(managerAddresses []string, logger *zap.Logger) (Cluster, error)
 	return &cluster{
 		client:         http.Client{},
 		managerAddr:    managerAddresses,
+		router:         newReadRouter(readOptions),
 		logger:         logger,
 		nodeCacheMutex: sync.Mutex{},
 		nodeCache:      make(map[string]cluster2.DataNode),
@@ -98,7 +103,66 @@ func (c *cluster) Create(size uint64, reader io.Reader) (*common.CreationResult,
 	return creationResult, nil
 }
 
+func (c *cluster) CreateBatch(sizes []uint64, readers []io.Reader) ([]*common.CreationResult, []error, error) {
+	if len(sizes) == 0 || len(sizes) != len(readers) {
+		return nil, nil, os.ErrInvalid
+	}
+
+	reservations, err := c.makeBatchReservation(sizes)
+	if err != nil {
+		return nil, nil, err
+	}
+	reservationId := reservations[0].Id
+
+	creationResults := make([]*common.CreationResult, len(sizes))
+	creationErrors := make([]error, len(sizes))
+	clusterUsageMap := make(map[string]uint64)
+	succeed := 0
+
+	for i, reservation := range reservations {
+		create := NewCreate(reservation, c.getDataNode, c.findCluster, c.logger)
+		creationResult, fileClusterUsageMap, err := create.process(readers[i])
+		if err != nil {
+			creationErrors[i] = err
+			continue
+		}
+		creationResults[i] = creationResult
+		succeed++
+
+		for clusterId, usage := range fileClusterUsageMap {
+			clusterUsageMap[clusterId] += usage
+		}
+	}
+
+	if succeed == 0 {
+		if err := c.discardReservation(reservationId); err != nil {
+			c.logger.Error(
+				"Discarding batch reservationMap is failed",
+				zap.String("reservationId", reservationId),
+				zap.Error(err),
+			)
+		}
+		return creationResults, creationErrors, nil
+	}
+
+	if err := c.commitReservation(reservationId, clusterUsageMap); err != nil {
+		c.logger.Error(
+			"Committing batch reservationMap is failed",
+			zap.String("reservationId", reservationId),
+			zap.Error(err),
+		)
+	}
+
+	return creationResults, creationErrors, nil
+}
+
 func (c *cluster) CreateShadow(chunks common.DataChunks) error {
+	// inline chunks are copied with the metadata
+	chunks = chunks.Remote()
+	if len(chunks) == 0 {
+		return nil
+	}
+
 	m, err := c.createClusterMap(chunks, common.MTCreate)
 	if err != nil {
 		if err == errors.ErrNotFound {
@@ -126,17 +190,23 @@ func (c *cluster) CreateShadow(chunks common.DataChunks) error {
 func (c *cluster) Read(chunks common.DataChunks) (func(w io.Writer, begins int64, ends int64) error, error) {
 	sort.Sort(chunks)
 
-	m, err := c.createClusterMap(chunks, common.MTRead)
-	if err != nil {
-		if err == errors.ErrNotFound {
-			return nil, errors.ErrZombie
+	m := make(map[string][]string)
+	if remoteChunks := chunks.Remote(); len(remoteChunks) > 0 {
+		var err error
+		m, err = c.createClusterMap(remoteChunks, common.MTRead)
+		if err != nil {
+			if err == errors.ErrNotFound {
+				return nil, errors.ErrZombie
+			}
+			return nil, err
 		}
-		return nil, err
 	}
 
 	// begins and ends came from Http Range Header Logic.
 	// however, request is transferred to the data-node in start index and end index (included) logic
 	return func(w io.Writer, begins int64, ends int64) error {
+		ranges := make([]chunkRange, 0)
+
 		chunkTotal := int64(0)
 		for _, chunk := range chunks {
 			chunkSize := int64(chunk.Size)
@@ -162,54 +232,37 @@ func (c *cluster) Read(chunks common.DataChunks) (func(w io.Writer, begins int64
 				}
 			}
 
+			if chunk.Inline {
+				ranges = append(ranges, chunkRange{
+					sha512Hex: chunk.Hash,
+					inline:    true,
+					data:      chunk.Data,
+					begins:    uint32(startPoint),
+					ends:      uint32(endPoint),
+				})
+				continue
+			}
+
 			addresses, has := m[chunk.Hash]
 			if !has {
 				return errors.ErrRepair
 			}
 
-			bulkErrors := errors.NewBulkError()
-			for _, address := range addresses {
-				dn, err := c.getDataNode(address)
-				if err != nil {
-					bulkErrors.Add(err)
-					continue
-				}
-
-				if err := dn.Read(chunk.Hash, uint32(startPoint), uint32(endPoint), func(buffer []byte) error {
-					if int64(len(buffer)) != endPoint-startPoint {
-						return errors.ErrRepair
-					}
-					_, err := w.Write(buffer)
-					if errors2.Is(err, syscall.EPIPE) {
-						return nil
-					}
-					return err
-				}); err != nil {
-					if errors.IsDialError(err) {
-						bulkErrors.Add(err)
-						continue
-					}
-					return err
-				}
-
-				if bulkErrors.HasError() {
-					c.logger.Warn(
-						"Read request for file chunk is successful with difficulties",
-						zap.String("sha512Hex", chunk.Hash),
-						zap.Error(bulkErrors),
-					)
-				}
-
-				bulkErrors = nil
-				break
-			}
-
-			if bulkErrors != nil {
-				return bulkErrors
-			}
+			ranges = append(ranges, chunkRange{
+				sha512Hex: chunk.Hash,
+				addresses: addresses,
+				begins:    uint32(startPoint),
+				ends:      uint32(endPoint),
+			})
 		}
 
-		return nil
+		return c.stream(ranges, func(data []byte) error {
+			_, err := w.Write(data)
+			if errors2.Is(err, syscall.EPIPE) {
+				return nil
+			}
+			return err
+		})
 	}, nil
 }
 
@@ -218,7 +271,22 @@ func (c *cluster) Delete(chunks common.DataChunks) (*common.DeletionResult, erro
 		return nil, errors.ErrZombie
 	}
 
-	m, err := c.createClusterMap(chunks, common.MTDelete)
+	deletionResult := common.NewDeletionResult()
+
+	// inline chunks are deleted with the metadata
+	remoteChunks := make(common.DataChunks, 0, len(chunks))
+	for _, chunk := range chunks {
+		if chunk.Inline {
+			deletionResult.Deleted = append(deletionResult.Deleted, chunk.Hash)
+			continue
+		}
+		remoteChunks = append(remoteChunks, chunk)
+	}
+	if len(remoteChunks) == 0 {
+		return &deletionResult, nil
+	}
+
+	m, err := c.createClusterMap(remoteChunks, common.MTDelete)
 	if err != nil {
 		if err == errors.ErrNotFound {
 			return nil, errors.ErrZombie
@@ -226,9 +294,7 @@ func (c *cluster) Delete(chunks common.DataChunks) (*common.DeletionResult, erro
 		return nil, err
 	}
 
-	deletionResult := common.NewDeletionResult()
-
-	for _, chunk := range chunks {
+	for _, chunk := range remoteChunks {
 		address, has := m[chunk.Hash]
 		if !has {
 			deletionResult.Missing = append(deletionResult.Missing, chunk.Hash)
@@ -273,6 +339,9 @@ func (c *cluster) makeReservation(size uint64) (*common.ReservationMap, error) {
 		if res.StatusCode == 507 {
 			return nil, errors.ErrNoSpace
 		}
+		if res.StatusCode == 413 {
+			return nil, errors.ErrTooLarge
+		}
 		return nil, fmt.Errorf("cluster manager request is failed (makeReservation): %d - %s", res.StatusCode, common.NewErrorFromReader(res.Body).Message)
 	}
 
@@ -284,6 +353,49 @@ func (c *cluster) makeReservation(size uint64) (*common.ReservationMap, error) {
 	return &reservationMap, nil
 }
 
+func (c *cluster) makeBatchReservation(sizes []uint64) ([]*common.ReservationMap, error) {
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s%s", c.managerAddr[0], managerEndPoint), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sizeList := make([]string, 0, len(sizes))
+	for _, size := range sizes {
+		sizeList = append(sizeList, strconv.FormatUint(size, 10))
+	}
+	req.Header.Set("X-Action", "reserveBatch")
+	req.Header.Set("X-Options", strings.Join(sizeList, ","))
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	if res.StatusCode != 200 {
+		if res.StatusCode == 503 {
+			return nil, errors.ErrNoAvailableClusterNode
+		}
+		if res.StatusCode == 507 {
+			return nil, errors.ErrNoSpace
+		}
+		if res.StatusCode == 413 {
+			return nil, errors.ErrTooLarge
+		}
+		return nil, fmt.Errorf("cluster manager request is failed (makeBatchReservation): %d - %s", res.StatusCode, common.NewErrorFromReader(res.Body).Message)
+	}
+
+	var reservationMaps []*common.ReservationMap
+	if err := json.NewDecoder(res.Body).Decode(&reservationMaps); err != nil {
+		return nil, err
+	}
+	if len(reservationMaps) != len(sizes) {
+		return nil, fmt.Errorf("cluster manager request is failed (makeBatchReservation): reservation count mismatch")
+	}
+
+	return reservationMaps, nil
+}
+
 func (c *cluster) discardReservation(reservationId string) error {
 	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s%s", c.managerAddr[0], managerEndPoint), nil)
 	if err != nil {
@@ -0,0 +1,314 @@
+package manager
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"go.uber.org/zap"
+)
+
+const readScoreWeight = 0.2            // weight of the new observation in the moving averages
+const readErrorPenalty = float64(1000) // ms, added to the node score for the full error rate
+const readSampleSize = 256             // latency samples kept for each size class
+const readHedgeMinSamples = 20         // samples required before the hedge threshold is trusted
+const readHedgeMinDelay = time.Millisecond * 5
+const readNormalizeSize = float64(1024 * 1024)
+
+// ReadOptions is the read routing settings of the cluster
+type ReadOptions struct {
+	// HedgePercentile is the latency percentile of the chunk size class after that a second read request is sent
+	// to another replica. 0 disables the hedged reads
+	HedgePercentile int
+	// Prefetch is the count of the chunks that are read ahead in parallel while streaming the file
+	Prefetch int
+}
+
+type nodeScore struct {
+	latency   float64 // ms per normalized size
+	errorRate float64
+}
+
+type latencySamples struct {
+	durations []time.Duration
+	next      int
+}
+
+// readRouter orders the replicas of the chunk by the observed latency and error rate of the data nodes and
+// calculates the hedge threshold from the latency distribution of the similar sized reads
+type readRouter struct {
+	options ReadOptions
+
+	mutex   sync.Mutex
+	scores  map[string]*nodeScore
+	samples map[int]*latencySamples
+}
+
+func newReadRouter(options ReadOptions) *readRouter {
+	return &readRouter{
+		options: options,
+		mutex:   sync.Mutex{},
+		scores:  make(map[string]*nodeScore),
+		samples: make(map[int]*latencySamples),
+	}
+}
+
+func (r *readRouter) sizeClass(size int64) int {
+	switch {
+	case size <= 64*1024:
+		return 0
+	case size <= 1024*1024:
+		return 1
+	case size <= 8*1024*1024:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func (r *readRouter) score(address string) float64 {
+	s, has := r.scores[address]
+	if !has {
+		return 0
+	}
+	return s.latency + s.errorRate*readErrorPenalty
+}
+
+// order sorts the addresses by the node scores. Nodes that are not observed yet come first to be measured,
+// equal scores keep the order of the manager
+func (r *readRouter) order(addresses []string) []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ordered := make([]string, len(addresses))
+	copy(ordered, addresses)
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return r.score(ordered[i]) < r.score(ordered[j])
+	})
+
+	return ordered
+}
+
+func (r *readRouter) observe(address string, size int64, duration time.Duration, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	s, has := r.scores[address]
+	if !has {
+		s = &nodeScore{}
+		r.scores[address] = s
+	}
+
+	if err != nil {
+		s.errorRate += (1 - s.errorRate) * readScoreWeight
+		return
+	}
+	s.errorRate -= s.errorRate * readScoreWeight
+
+	latency := float64(duration.Milliseconds()) / (1 + float64(size)/readNormalizeSize)
+	if !has {
+		s.latency = latency
+	} else {
+		s.latency += (latency - s.latency) * readScoreWeight
+	}
+
+	class := r.sizeClass(size)
+	samples, has := r.samples[class]
+	if !has {
+		samples = &latencySamples{
+			durations: make([]time.Duration, 0, readSampleSize),
+		}
+		r.samples[class] = samples
+	}
+
+	if len(samples.durations) < readSampleSize {
+		samples.durations = append(samples.durations, duration)
+		return
+	}
+	samples.durations[samples.next] = duration
+	samples.next = (samples.next + 1) % readSampleSize
+}
+
+// hedgeDelay returns the percentile latency of the size class. It returns false if the hedged reads are disabled
+// or there are not enough samples
+func (r *readRouter) hedgeDelay(size int64) (time.Duration, bool) {
+	if r.options.HedgePercentile <= 0 {
+		return 0, false
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	samples, has := r.samples[r.sizeClass(size)]
+	if !has || len(samples.durations) < readHedgeMinSamples {
+		return 0, false
+	}
+
+	durations := make([]time.Duration, len(samples.durations))
+	copy(durations, samples.durations)
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	index := len(durations) * r.options.HedgePercentile / 100
+	if index >= len(durations) {
+		index = len(durations) - 1
+	}
+
+	delay := durations[index]
+	if delay < readHedgeMinDelay {
+		delay = readHedgeMinDelay
+	}
+	return delay, true
+}
+
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// read gets the range of the chunk from the replicas. The best scored replica is requested first, the next one
+// is requested when the hedge threshold is passed or the previous one fails. First successful result wins
+func (c *cluster) read(sha512Hex string, addresses []string, begins uint32, ends uint32) ([]byte, error) {
+	size := int64(ends) - int64(begins)
+	ordered := c.router.order(addresses)
+
+	results := make(chan readResult, len(ordered))
+	next := 0
+	inFlight := 0
+
+	launch := func() {
+		address := ordered[next]
+		next++
+		inFlight++
+
+		go func() {
+			startedAt := time.Now()
+
+			var data []byte
+			dn, err := c.getDataNode(address)
+			if err == nil {
+				err = dn.Read(sha512Hex, begins, ends, func(buffer []byte) error {
+					if int64(len(buffer)) != size {
+						return errors.ErrRepair
+					}
+					data = buffer
+					return nil
+				})
+			}
+			c.router.observe(address, size, time.Since(startedAt), err)
+
+			results <- readResult{data: data, err: err}
+		}()
+	}
+	launch()
+
+	var hedge <-chan time.Time
+	if delay, ok := c.router.hedgeDelay(size); ok && next < len(ordered) {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		hedge = timer.C
+	}
+
+	bulkErrors := errors.NewBulkError()
+	var failure error
+
+	for inFlight > 0 {
+		select {
+		case <-hedge:
+			hedge = nil
+			if next < len(ordered) {
+				launch()
+			}
+		case result := <-results:
+			inFlight--
+
+			if result.err == nil {
+				if bulkErrors.HasError() {
+					c.logger.Warn(
+						"Read request for file chunk is successful with difficulties",
+						zap.String("sha512Hex", sha512Hex),
+						zap.Error(bulkErrors),
+					)
+				}
+				return result.data, nil
+			}
+			bulkErrors.Add(result.err)
+
+			// the data failures (like missing or inconsistent block) are kept to be reported
+			// if none of the replicas can serve the chunk
+			if !errors.IsDialError(result.err) {
+				failure = result.err
+			}
+
+			if next < len(ordered) {
+				launch()
+			}
+		}
+	}
+
+	if failure != nil {
+		return nil, failure
+	}
+	return nil, bulkErrors
+}
+
+type chunkFetch struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// stream reads the chunk ranges in order and writes them with the writeHandler. Next chunks are read ahead in
+// parallel as much as the prefetch setting allows
+func (c *cluster) stream(ranges []chunkRange, writeHandler func(data []byte) error) error {
+	fetches := make([]*chunkFetch, len(ranges))
+
+	fetch := func(index int) {
+		f := &chunkFetch{
+			done: make(chan struct{}),
+		}
+		fetches[index] = f
+
+		go func(r chunkRange) {
+			defer close(f.done)
+			if r.inline {
+				f.data = r.data[r.begins:r.ends]
+				return
+			}
+			f.data, f.err = c.read(r.sha512Hex, r.addresses, r.begins, r.ends)
+		}(ranges[index])
+	}
+
+	for i := range ranges {
+		for j := i; j <= i+c.router.options.Prefetch && j < len(ranges); j++ {
+			if fetches[j] == nil {
+				fetch(j)
+			}
+		}
+
+		f := fetches[i]
+		<-f.done
+		fetches[i] = &chunkFetch{}
+
+		if f.err != nil {
+			return f.err
+		}
+
+		if err := writeHandler(f.data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type chunkRange struct {
+	sha512Hex string
+	addresses []string
+	inline    bool
+	data      []byte
+	begins    uint32
+	ends      uint32
+}
@@ -0,0 +1,279 @@
+package manager
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	cluster2 "github.com/freakmaxi/kertish-dos/head-node/cluster"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// testReadDataNode serves the reads of the chunks from the memory after the delay
+type testReadDataNode struct {
+	cluster2.DataNode
+
+	delay  time.Duration
+	err    error
+	chunks map[string][]byte
+
+	mutex    sync.Mutex
+	reads    int
+	inFlight *inFlightCounter
+}
+
+type inFlightCounter struct {
+	mutex   sync.Mutex
+	current int
+	max     int
+}
+
+func (c *inFlightCounter) add(delta int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.current += delta
+	if c.current > c.max {
+		c.max = c.current
+	}
+}
+
+func (d *testReadDataNode) Read(sha512Hex string, begins uint32, ends uint32, readHandler func(data []byte) error) error {
+	d.mutex.Lock()
+	d.reads++
+	d.mutex.Unlock()
+
+	if d.inFlight != nil {
+		d.inFlight.add(1)
+		defer d.inFlight.add(-1)
+	}
+
+	time.Sleep(d.delay)
+
+	if d.err != nil {
+		return d.err
+	}
+
+	data, has := d.chunks[sha512Hex]
+	if !has {
+		return errors.ErrNotFound
+	}
+	if int(ends) > len(data) {
+		// the block is shorter than expected
+		return readHandler(data[begins:])
+	}
+	return readHandler(data[begins:ends])
+}
+
+func (d *testReadDataNode) readCount() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.reads
+}
+
+func newTestReadCluster(options ReadOptions, dataNodes map[string]*testReadDataNode) *cluster {
+	c := &cluster{
+		router:    newReadRouter(options),
+		logger:    zap.NewNop(),
+		nodeCache: make(map[string]cluster2.DataNode),
+	}
+	for address, dn := range dataNodes {
+		c.nodeCache[address] = dn
+	}
+	return c
+}
+
+func TestReadRouter_Order(t *testing.T) {
+	r := newReadRouter(ReadOptions{})
+
+	r.observe("slow", 1024, time.Millisecond*50, nil)
+	r.observe("fast", 1024, time.Millisecond*5, nil)
+	r.observe("failing", 1024, time.Millisecond*5, nil)
+	r.observe("failing", 1024, 0, fmt.Errorf("failed"))
+
+	// not observed nodes come first to be measured, equal scores keep the given order
+	assert.Equal(t, []string{"new-1", "new-2", "fast", "slow", "failing"},
+		r.order([]string{"slow", "new-1", "failing", "fast", "new-2"}))
+
+	// error rate drops with the successful reads
+	for i := 0; i < 30; i++ {
+		r.observe("failing", 1024, time.Millisecond*5, nil)
+	}
+	assert.Equal(t, []string{"fast", "failing", "slow"}, r.order([]string{"slow", "failing", "fast"}))
+}
+
+func TestReadRouter_Observe(t *testing.T) {
+	r := newReadRouter(ReadOptions{})
+
+	r.observe("node", 0, time.Millisecond*10, nil)
+	assert.Equal(t, float64(10), r.scores["node"].latency)
+
+	// moving average of the latency normalized by the size
+	r.observe("node", int64(readNormalizeSize), time.Millisecond*40, nil)
+	assert.InDelta(t, 10+(20-10)*readScoreWeight, r.scores["node"].latency, 0.001)
+
+	r.observe("node", 0, 0, fmt.Errorf("failed"))
+	assert.InDelta(t, readScoreWeight, r.scores["node"].errorRate, 0.001)
+	assert.InDelta(t, r.scores["node"].latency+readScoreWeight*readErrorPenalty, r.score("node"), 0.001)
+
+	// samples are kept per size class and only for the successful reads
+	assert.Len(t, r.samples[0].durations, 1)
+	assert.Len(t, r.samples[1].durations, 1)
+
+	for i := 0; i < readSampleSize+10; i++ {
+		r.observe("node", 0, time.Millisecond, nil)
+	}
+	assert.Len(t, r.samples[0].durations, readSampleSize)
+	assert.Equal(t, 11, r.samples[0].next)
+}
+
+func TestReadRouter_HedgeDelay(t *testing.T) {
+	disabled := newReadRouter(ReadOptions{})
+	for i := 0; i < readHedgeMinSamples; i++ {
+		disabled.observe("node", 1024, time.Millisecond*10, nil)
+	}
+	_, ok := disabled.hedgeDelay(1024)
+	assert.False(t, ok)
+
+	r := newReadRouter(ReadOptions{HedgePercentile: 90})
+	for i := 1; i < readHedgeMinSamples; i++ {
+		r.observe("node", 1024, time.Millisecond*time.Duration(i*10), nil)
+	}
+	_, ok = r.hedgeDelay(1024)
+	assert.False(t, ok, "not enough samples")
+
+	for i := readHedgeMinSamples; i <= 100; i++ {
+		r.observe("node", 1024, time.Millisecond*time.Duration(i*10), nil)
+	}
+	delay, ok := r.hedgeDelay(1024)
+	assert.True(t, ok)
+	assert.Equal(t, time.Millisecond*910, delay)
+
+	// other size class does not have samples
+	_, ok = r.hedgeDelay(4 * 1024 * 1024)
+	assert.False(t, ok)
+
+	fast := newReadRouter(ReadOptions{HedgePercentile: 100})
+	for i := 0; i < readHedgeMinSamples; i++ {
+		fast.observe("node", 1024, time.Microsecond, nil)
+	}
+	delay, ok = fast.hedgeDelay(1024)
+	assert.True(t, ok)
+	assert.Equal(t, readHedgeMinDelay, delay)
+}
+
+func TestCluster_ReadFallsThrough(t *testing.T) {
+	chunks := map[string][]byte{"chunk": []byte("content")}
+
+	c := newTestReadCluster(ReadOptions{}, map[string]*testReadDataNode{
+		"broken":  {err: errors.ErrRepair},
+		"missing": {chunks: map[string][]byte{}},
+		"healthy": {chunks: chunks},
+	})
+	c.router.observe("broken", 0, time.Millisecond, nil)
+	c.router.observe("missing", 0, time.Millisecond*2, nil)
+	c.router.observe("healthy", 0, time.Millisecond*3, nil)
+
+	data, err := c.read("chunk", []string{"healthy", "missing", "broken"}, 0, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(data))
+
+	// failures are observed for the routing of the next reads
+	assert.Equal(t, "healthy", c.router.order([]string{"broken", "missing", "healthy"})[0])
+}
+
+func TestCluster_ReadFailsOnAllReplicas(t *testing.T) {
+	c := newTestReadCluster(ReadOptions{}, map[string]*testReadDataNode{
+		"broken":   {err: errors.ErrRepair},
+		"shortage": {chunks: map[string][]byte{"chunk": []byte("con")}},
+	})
+
+	_, err := c.read("chunk", []string{"broken", "shortage"}, 0, 7)
+	assert.Equal(t, errors.ErrRepair, err)
+}
+
+func TestCluster_ReadHedge(t *testing.T) {
+	chunks := map[string][]byte{"chunk": []byte("content")}
+	slow := &testReadDataNode{delay: time.Millisecond * 500, chunks: chunks}
+	fast := &testReadDataNode{delay: time.Millisecond, chunks: chunks}
+
+	c := newTestReadCluster(ReadOptions{HedgePercentile: 50}, map[string]*testReadDataNode{"slow": slow, "fast": fast})
+	// the slow node is preferred by its history and the threshold of the size class is low
+	c.router.observe("slow", 7, time.Millisecond, nil)
+	c.router.observe("fast", 7, time.Millisecond*2, nil)
+	for i := 0; i < readHedgeMinSamples; i++ {
+		c.router.observe("other", 7, time.Millisecond*10, nil)
+	}
+
+	begins := time.Now()
+	data, err := c.read("chunk", []string{"slow", "fast"}, 0, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(data))
+	assert.Less(t, time.Since(begins), time.Millisecond*250)
+	assert.Equal(t, 1, slow.readCount())
+	assert.Equal(t, 1, fast.readCount())
+}
+
+func TestCluster_ReadWithoutHedge(t *testing.T) {
+	chunks := map[string][]byte{"chunk": []byte("content")}
+	first := &testReadDataNode{delay: time.Millisecond * 50, chunks: chunks}
+	second := &testReadDataNode{chunks: chunks}
+
+	c := newTestReadCluster(ReadOptions{}, map[string]*testReadDataNode{"first": first, "second": second})
+
+	data, err := c.read("chunk", []string{"first", "second"}, 0, 7)
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(data))
+	assert.Equal(t, 0, second.readCount())
+}
+
+func TestCluster_StreamPrefetch(t *testing.T) {
+	chunks := make(map[string][]byte)
+	ranges := make([]chunkRange, 0)
+	expected := ""
+	for i := 0; i < 10; i++ {
+		sha512Hex := fmt.Sprintf("chunk-%d", i)
+		chunks[sha512Hex] = []byte(fmt.Sprintf("<%d>", i))
+		ranges = append(ranges, chunkRange{sha512Hex: sha512Hex, addresses: []string{"node"}, begins: 0, ends: 3})
+		expected += fmt.Sprintf("<%d>", i)
+	}
+	ranges = append(ranges, chunkRange{sha512Hex: "inline", inline: true, data: []byte("[inline]"), begins: 1, ends: 7})
+	expected += "inline"
+
+	inFlight := &inFlightCounter{}
+	c := newTestReadCluster(ReadOptions{Prefetch: 2}, map[string]*testReadDataNode{
+		"node": {delay: time.Millisecond * 10, chunks: chunks, inFlight: inFlight},
+	})
+
+	written := ""
+	assert.Nil(t, c.stream(ranges, func(data []byte) error {
+		written += string(data)
+		return nil
+	}))
+
+	// chunks are written in order while the next ones are read ahead
+	assert.Equal(t, expected, written)
+	assert.Equal(t, 3, inFlight.max)
+}
+
+func TestCluster_StreamStopsOnFailure(t *testing.T) {
+	c := newTestReadCluster(ReadOptions{Prefetch: 1}, map[string]*testReadDataNode{
+		"node": {chunks: map[string][]byte{"first": []byte("abc")}},
+	})
+
+	written := ""
+	err := c.stream([]chunkRange{
+		{sha512Hex: "first", addresses: []string{"node"}, begins: 0, ends: 3},
+		{sha512Hex: "missing", addresses: []string{"node"}, begins: 0, ends: 3},
+		{sha512Hex: "first", addresses: []string{"node"}, begins: 0, ends: 3},
+	}, func(data []byte) error {
+		written += string(data)
+		return nil
+	})
+	assert.Equal(t, errors.ErrNotFound, err)
+	assert.Equal(t, "abc", written)
+}
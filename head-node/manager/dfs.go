@@ -3,9 +3,12 @@ package manager
 import (
 	"io"
 	"strings"
+	"time"
 
+	"github.com/freakmaxi/kertish-dos/basics/common"
 	"github.com/freakmaxi/kertish-dos/basics/hooks"
 	"github.com/freakmaxi/kertish-dos/head-node/data"
+	"github.com/freakmaxi/locking-center-client-go/mutex"
 	"go.uber.org/zap"
 )
 
@@ -13,30 +16,54 @@ import (
 type Dos interface {
 	CreateFolder(folderPath string) error
 	CreateFile(path string, mime string, size uint64, overwrite bool, contentReader io.Reader) error
+	// AppendFile adds the content to the end of the file, the file is created if it does not exist
+	AppendFile(path string, mime string, size uint64, compact bool, contentReader io.Reader) error
+	// Extract creates the folders and files of the archive under the folder path
+	Extract(folderPath string, archiveType ArchiveType, overwrite bool, reader io.Reader) (*common.ExtractionResult, error)
 
 	Read(paths []string, join bool) (ReadContainer, error)
 	Size(folderPath string) (uint64, error)
+	// Stat returns the folder or the file metadata of the path, only one of them is filled
+	Stat(path string) (*common.Folder, *common.File, error)
+	// Search finds the folders and files that match with the query in the namespace
+	Search(query *common.SearchQuery) (*common.SearchResult, error)
 
 	Change(sources []string, target string, join bool, overwrite bool, move bool) error
 
 	Delete(path string, killZombies bool) error
 
+	// Lock locks the file for the duration, the file can not be changed, moved or deleted while it is locked
+	Lock(path string, duration time.Duration) error
+	// Unlock releases the lock of the file before its duration ends
+	Unlock(path string) error
+
+	// Promote moves the inline chunks that exceed the inline limit to the data nodes and returns the file count
+	Promote() (int, error)
+
 	// ExecuteActions executes the hook actions in sync manner
 	ExecuteActions(aI *hooks.ActionInfo, actions []hooks.Action)
 }
 
+// MaxInlineLimit is the upper bound of the inline limit to keep the metadata documents in a reasonable size
+const MaxInlineLimit = 1024 * 1024
+
 type dos struct {
-	metadata data.Metadata
-	cluster  Cluster
-	logger   *zap.Logger
+	metadata    data.Metadata
+	mutex       mutex.LockingCenter
+	cluster     Cluster
+	inlineLimit uint64
+	logger      *zap.Logger
 }
 
-// NewDos creates the instance of file manipulation operations object for REST service request
-func NewDos(metadata data.Metadata, cluster Cluster, logger *zap.Logger) Dos {
+// NewDos creates the instance of file manipulation operations object for REST service request.
+// Files up to the inline limit are kept in the metadata instead of the data nodes, 0 disables the inline storage
+func NewDos(metadata data.Metadata, mutex mutex.LockingCenter, cluster Cluster, inlineLimit uint64, logger *zap.Logger) Dos {
 	return &dos{
-		metadata: metadata,
-		cluster:  cluster,
-		logger:   logger,
+		metadata:    metadata,
+		mutex:       mutex,
+		cluster:     cluster,
+		inlineLimit: inlineLimit,
+		logger:      logger,
 	}
 }
 
@@ -95,3 +122,18 @@ func (d *dos) compileHookActions(folderPath string, actionType hooks.RunOn) []ho
 }
 
 var _ Dos = &dos{}
+
+// drop deletes the chunks that are placed in the data nodes but could not be bound to a file
+func (d *dos) drop(chunks common.DataChunks) {
+	if len(chunks) == 0 {
+		return
+	}
+
+	if _, err := d.cluster.Delete(chunks); err != nil {
+		d.logger.Warn(
+			"Dropping unused chunks is failed, repair may require",
+			zap.Int("chunks", len(chunks)),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,232 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/hooks"
+	"go.uber.org/zap"
+)
+
+const appendLockPrefix = "append:"
+const appendLockWait = time.Second * 30
+const appendLockRetry = time.Millisecond * 100
+const appendCompactSize = 1024 * 1024 * 4 // trailing chunks smaller than this are compacted
+
+// AppendFile adds the content to the end of the file as new chunks, the file is created if it does not exist.
+// Concurrent appends are serialized with the append lock of the path in the locking center. The file itself is not
+// locked, so the committed content stays readable till the appended chunks are saved. compact uploads the trailing
+// small chunks of the file together with the content to have a single chunk instead of many small ones
+func (d *dos) AppendFile(path string, mime string, size uint64, compact bool, contentReader io.Reader) error {
+	path = common.CorrectPath(path) // It is required in here to eliminate wrong path format
+
+	folderPath, filename := common.Split(path)
+	if len(filename) == 0 {
+		return os.ErrInvalid
+	}
+
+	d.mutex.Lock(appendLockPrefix + path)
+	defer d.mutex.Unlock(appendLockPrefix + path)
+
+	file, err := d.appendState(folderPath, filename)
+	if err == os.ErrNotExist {
+		err = d.CreateFile(path, mime, size, false, contentReader)
+		if err != os.ErrExist {
+			return err
+		}
+		// another request created the file in the meantime
+		file, err = d.appendState(folderPath, filename)
+	}
+	if err != nil {
+		return err
+	}
+
+	if size == 0 {
+		return nil
+	}
+
+	if err := d.append(folderPath, filename, file, size, compact, contentReader); err != nil {
+		return err
+	}
+
+	actions := d.compileHookActions(folderPath, hooks.Updated)
+	d.ExecuteActions(hooks.NewActionInfoForAppended(path), actions)
+
+	return nil
+}
+
+// appendState waits for the file lock (user lock or an upload in progress) to be released and returns the state
+// of the file to append on
+func (d *dos) appendState(folderPath string, filename string) (*common.File, error) {
+	deadline := time.Now().Add(appendLockWait)
+
+	for {
+		folder, err := d.folder(folderPath)
+		if err != nil {
+			return nil, err
+		}
+
+		file := folder.File(filename)
+		if file == nil {
+			return nil, os.ErrNotExist
+		}
+		if file.ZombieCheck() {
+			return nil, errors.ErrZombie
+		}
+		if !file.Locked() {
+			return file, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.ErrLock
+		}
+
+		time.Sleep(appendLockRetry)
+	}
+}
+
+func (d *dos) append(folderPath string, filename string, file *common.File, size uint64, compact bool, contentReader io.Reader) error {
+	checksumHash, err := d.checksumHash(file)
+	if err != nil {
+		return err
+	}
+
+	sort.Sort(file.Chunks)
+
+	trailing := make(common.DataChunks, 0)
+	trailingContent := &bytes.Buffer{}
+	if compact {
+		trailing = d.trailingChunks(file.Chunks)
+		if len(trailing) > 0 {
+			streamHandler, err := d.cluster.Read(trailing)
+			if err != nil {
+				return err
+			}
+			if err := streamHandler(trailingContent, 0, -1); err != nil {
+				return err
+			}
+		}
+	}
+
+	uploadSize := uint64(trailingContent.Len()) + size
+	uploadReader := io.MultiReader(trailingContent, io.TeeReader(contentReader, checksumHash))
+
+	var creationResult *common.CreationResult
+	if d.inlineable(file.Size + size) {
+		creationResult, err = d.create(uploadSize, uploadReader)
+	} else {
+		creationResult, err = d.cluster.Create(uploadSize, uploadReader)
+	}
+	if err != nil {
+		return err
+	}
+
+	checksumState, err := checksumHash.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		d.drop(creationResult.Chunks)
+		return err
+	}
+	checksum := hex.EncodeToString(checksumHash.Sum(nil))
+
+	if err := d.metadata.SaveBlock([]string{folderPath}, func(folders map[string]*common.Folder) (bool, error) {
+		folder := folders[folderPath]
+		if folder == nil {
+			return false, os.ErrNotExist
+		}
+
+		current := folder.File(filename)
+		if current == nil {
+			return false, os.ErrNotExist
+		}
+		// the file is locked or changed by another request during the upload
+		if current.Locked() || strings.Compare(current.ETag(), file.ETag()) != 0 {
+			return false, errors.ErrLock
+		}
+
+		chunks := make(common.DataChunks, 0)
+		chunks = append(chunks, file.Chunks[:len(file.Chunks)-len(trailing)]...)
+
+		sort.Sort(creationResult.Chunks)
+		for _, chunk := range creationResult.Chunks {
+			if uint64(len(chunks)) > common.MaxChunkSequence {
+				return false, errors.ErrTooLarge
+			}
+
+			appended := *chunk
+			appended.Sequence = uint32(len(chunks))
+			chunks = append(chunks, &appended)
+		}
+
+		current.Size += size
+		current.Modified = time.Now().UTC()
+		current.Checksum = checksum
+		current.ChecksumState = checksumState
+		current.Chunks = chunks
+
+		return true, nil
+	}); err != nil {
+		d.drop(creationResult.Chunks)
+		return err
+	}
+
+	if len(trailing) > 0 {
+		if _, err := d.cluster.Delete(trailing); err != nil {
+			d.logger.Warn(
+				"Deleting compacted chunks is failed, repair may require",
+				zap.String("path", common.Join(folderPath, filename)),
+				zap.Error(err),
+			)
+		}
+	}
+
+	return nil
+}
+
+// checksumHash restores the checksum calculation of the file. If the file does not have the checksum state,
+// the calculation is restored by reading the file content
+func (d *dos) checksumHash(file *common.File) (hash.Hash, error) {
+	checksumHash := sha512.New512_256()
+
+	if len(file.ChecksumState) > 0 {
+		if err := checksumHash.(encoding.BinaryUnmarshaler).UnmarshalBinary(file.ChecksumState); err == nil {
+			return checksumHash, nil
+		}
+		checksumHash.Reset()
+	}
+
+	streamHandler, err := d.cluster.Read(file.Chunks)
+	if err != nil {
+		return nil, err
+	}
+	if err := streamHandler(checksumHash, 0, -1); err != nil {
+		return nil, err
+	}
+
+	return checksumHash, nil
+}
+
+// trailingChunks returns the small chunks at the end of the sorted chunks that fit in a single compacted chunk
+func (d *dos) trailingChunks(chunks common.DataChunks) common.DataChunks {
+	total := uint64(0)
+	begins := len(chunks)
+	for begins > 0 {
+		chunk := chunks[begins-1]
+		if chunk.Size >= appendCompactSize || total+uint64(chunk.Size) > appendCompactSize {
+			break
+		}
+		total += uint64(chunk.Size)
+		begins--
+	}
+
+	trailing := make(common.DataChunks, 0)
+	return append(trailing, chunks[begins:]...)
+}
@@ -0,0 +1,225 @@
+package manager
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/locking-center-client-go/mutex"
+	"github.com/stretchr/testify/assert"
+)
+
+// testLockingCenter locks the keys in the process
+type testLockingCenter struct {
+	mutex.LockingCenter
+
+	mutex sync.Mutex
+	keys  map[string]*sync.Mutex
+}
+
+func newTestLockingCenter() *testLockingCenter {
+	return &testLockingCenter{
+		keys: make(map[string]*sync.Mutex),
+	}
+}
+
+func (l *testLockingCenter) key(key string) *sync.Mutex {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	m, has := l.keys[key]
+	if !has {
+		m = &sync.Mutex{}
+		l.keys[key] = m
+	}
+	return m
+}
+
+func (l *testLockingCenter) Lock(key string) {
+	l.key(key).Lock()
+}
+
+func (l *testLockingCenter) Unlock(key string) {
+	l.key(key).Unlock()
+}
+
+func (l *testLockingCenter) Wait(key string) {
+	m := l.key(key)
+	m.Lock()
+	m.Unlock()
+}
+
+func newTestAppendDos(t *testing.T, content string) (*dos, *testMetadata, *testCluster) {
+	d, metadata, cluster := newTestDos()
+	d.mutex = newTestLockingCenter()
+
+	if len(content) > 0 {
+		assert.Nil(t, d.CreateFile("/folder/file", "text/plain", uint64(len(content)), false, strings.NewReader(content)))
+	}
+
+	return d, metadata, cluster
+}
+
+func appendTestFile(d *dos, content string, compact bool) error {
+	return d.AppendFile("/folder/file", "text/plain", uint64(len(content)), compact, strings.NewReader(content))
+}
+
+func testChecksum(content string) string {
+	sum := sha512.Sum512_256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDos_AppendFile(t *testing.T) {
+	d, metadata, cluster := newTestAppendDos(t, "abcdefgh")
+
+	// the file is created without the checksum state, so the content is read to restore the checksum
+	reads := cluster.reads
+	assert.Nil(t, appendTestFile(d, "ij", false))
+	assert.Equal(t, reads+1, cluster.reads)
+
+	file := metadata.file("/folder/file")
+	assert.NotEmpty(t, file.ChecksumState)
+	assert.Equal(t, testChecksum("abcdefghij"), file.Checksum)
+	assert.Len(t, file.Chunks, 3)
+
+	// the checksum state of the previous append is resumed without reading the content
+	reads = cluster.reads
+	assert.Nil(t, appendTestFile(d, "klm", false))
+	assert.Equal(t, reads, cluster.reads)
+
+	file = metadata.file("/folder/file")
+	assert.Equal(t, testChecksum("abcdefghijklm"), file.Checksum)
+	assert.Equal(t, uint64(13), file.Size)
+	assert.Equal(t, "abcdefghijklm", readTestFile(t, d, "/folder/file"))
+}
+
+func TestDos_AppendFileBrokenChecksumState(t *testing.T) {
+	d, metadata, cluster := newTestAppendDos(t, "abcdefgh")
+
+	assert.Nil(t, metadata.SaveBlock([]string{"/folder"}, func(folders map[string]*common.Folder) (bool, error) {
+		folders["/folder"].File("file").ChecksumState = []byte("broken")
+		return true, nil
+	}))
+
+	reads := cluster.reads
+	assert.Nil(t, appendTestFile(d, "ij", false))
+	assert.Equal(t, reads+1, cluster.reads)
+	assert.Equal(t, testChecksum("abcdefghij"), metadata.file("/folder/file").Checksum)
+}
+
+func TestDos_AppendFileCreates(t *testing.T) {
+	d, metadata, _ := newTestAppendDos(t, "")
+
+	assert.Nil(t, appendTestFile(d, "abcdef", false))
+	assert.Equal(t, testChecksum("abcdef"), metadata.file("/folder/file").Checksum)
+	assert.Equal(t, "abcdef", readTestFile(t, d, "/folder/file"))
+}
+
+func TestDos_AppendFileCompact(t *testing.T) {
+	d, metadata, cluster := newTestAppendDos(t, "abcdefgh")
+	compacted := metadata.file("/folder/file").Chunks
+
+	// the trailing chunks are uploaded again together with the appended content as a single chunk
+	cluster.chunkSize = 16
+	assert.Nil(t, appendTestFile(d, "ij", true))
+
+	file := metadata.file("/folder/file")
+	assert.Len(t, file.Chunks, 1)
+	assert.Equal(t, uint32(10), file.Chunks[0].Size)
+	assert.Equal(t, testChecksum("abcdefghij"), file.Checksum)
+	assert.Equal(t, "abcdefghij", readTestFile(t, d, "/folder/file"))
+
+	for _, chunk := range compacted {
+		assert.Contains(t, cluster.deleted, chunk.Hash)
+		assert.Equal(t, 0, cluster.usage(chunk.Hash))
+	}
+}
+
+func TestDos_TrailingChunks(t *testing.T) {
+	d, _, _ := newTestDos()
+
+	chunks := func(sizes ...uint32) common.DataChunks {
+		dataChunks := make(common.DataChunks, 0)
+		for i, size := range sizes {
+			dataChunks = append(dataChunks, common.NewDataChunk(uint32(i), size, fmt.Sprintf("%d", i)))
+		}
+		return dataChunks
+	}
+	sequences := func(dataChunks common.DataChunks) []uint32 {
+		s := make([]uint32, 0)
+		for _, chunk := range dataChunks {
+			s = append(s, chunk.Sequence)
+		}
+		return s
+	}
+
+	mb := uint32(1024 * 1024)
+
+	assert.Equal(t, []uint32{1, 2}, sequences(d.trailingChunks(chunks(5*mb, mb, 2*mb))))
+	assert.Equal(t, []uint32{2}, sequences(d.trailingChunks(chunks(mb, 2*mb, 3*mb))))
+	assert.Equal(t, []uint32{0, 1, 2, 3}, sequences(d.trailingChunks(chunks(mb, mb, mb, mb))))
+	assert.Empty(t, d.trailingChunks(chunks(mb, 4*mb)))
+	assert.Empty(t, d.trailingChunks(chunks()))
+}
+
+func TestDos_AppendFileChanged(t *testing.T) {
+	d, metadata, cluster := newTestAppendDos(t, "abcdefgh")
+
+	// another request replaces the content during the upload
+	metadata.beforeSave = func() {
+		metadata.mutex.Lock()
+		defer metadata.mutex.Unlock()
+
+		metadata.folders["/folder"].File("file").Chunks = common.DataChunks{common.NewDataChunk(0, 1, "replaced")}
+	}
+
+	blocks := len(cluster.blocks)
+	assert.Equal(t, errors.ErrLock, appendTestFile(d, "ijkl", false))
+	metadata.beforeSave = nil
+
+	// the uploaded chunk is dropped and the replaced content is kept
+	assert.Equal(t, blocks, len(cluster.blocks))
+	assert.Contains(t, cluster.deleted, testChecksum("ijkl"))
+	assert.Equal(t, "replaced", metadata.file("/folder/file").Chunks[0].Hash)
+	assert.Equal(t, uint64(8), metadata.file("/folder/file").Size)
+}
+
+func TestDos_AppendFileConcurrent(t *testing.T) {
+	d, metadata, _ := newTestAppendDos(t, "")
+
+	parts := make([]string, 0)
+	for i := 0; i < 10; i++ {
+		parts = append(parts, fmt.Sprintf("<%d>", i))
+	}
+
+	wg := &sync.WaitGroup{}
+	for _, part := range parts {
+		wg.Add(1)
+		go func(part string) {
+			defer wg.Done()
+			assert.Nil(t, appendTestFile(d, part, false))
+		}(part)
+	}
+	wg.Wait()
+
+	// every append is applied once in the order of the lock
+	content := readTestFile(t, d, "/folder/file")
+	assert.Equal(t, uint64(len(content)), metadata.file("/folder/file").Size)
+	assert.Equal(t, testChecksum(content), metadata.file("/folder/file").Checksum)
+
+	applied := make([]string, 0)
+	for _, part := range strings.SplitAfter(content, ">") {
+		if len(part) > 0 {
+			applied = append(applied, part)
+		}
+	}
+	sort.Strings(applied)
+	sort.Strings(parts)
+	assert.Equal(t, parts, applied)
+}
@@ -0,0 +1,305 @@
+package manager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"go.uber.org/zap"
+)
+
+type ArchiveType int
+
+const (
+	ATZip   ArchiveType = 1
+	ATTar   ArchiveType = 2
+	ATTarGz ArchiveType = 3
+)
+
+// ParseArchiveType converts the archive type name (zip, tar, tar.gz) to ArchiveType
+func ParseArchiveType(value string) (ArchiveType, error) {
+	switch strings.ToLower(value) {
+	case "zip":
+		return ATZip, nil
+	case "tar":
+		return ATTar, nil
+	case "tar.gz", "tgz":
+		return ATTarGz, nil
+	}
+	return 0, os.ErrInvalid
+}
+
+// Extension returns the file extension of the archive type
+func (a ArchiveType) Extension() string {
+	switch a {
+	case ATTar:
+		return ".tar"
+	case ATTarGz:
+		return ".tar.gz"
+	}
+	return ".zip"
+}
+
+// ContentType returns the mime type of the archive type
+func (a ArchiveType) ContentType() string {
+	switch a {
+	case ATTar:
+		return "application/x-tar"
+	case ATTarGz:
+		return "application/gzip"
+	}
+	return "application/zip"
+}
+
+type archiveWriter interface {
+	addFolder(name string, modified time.Time) error
+	// beginFile writes the entry header of the file and returns the writer of the entry content
+	beginFile(name string, file *common.File) (io.Writer, error)
+	// endFile completes the entry. The missing content is filled with zeros for the formats that declare
+	// the entry size in the header
+	endFile(file *common.File, written int64) error
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, archiveType ArchiveType) archiveWriter {
+	switch archiveType {
+	case ATTar:
+		return &tarArchive{writer: tar.NewWriter(w)}
+	case ATTarGz:
+		gzipWriter := gzip.NewWriter(w)
+		return &tarArchive{writer: tar.NewWriter(gzipWriter), compressor: gzipWriter}
+	}
+	return &zipArchive{writer: zip.NewWriter(w)}
+}
+
+type zipArchive struct {
+	writer *zip.Writer
+}
+
+func (z *zipArchive) addFolder(name string, modified time.Time) error {
+	_, err := z.writer.CreateHeader(&zip.FileHeader{
+		Name:     name + "/",
+		Modified: modified,
+	})
+	return err
+}
+
+func (z *zipArchive) beginFile(name string, file *common.File) (io.Writer, error) {
+	return z.writer.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: file.Modified,
+	})
+}
+
+func (z *zipArchive) endFile(_ *common.File, _ int64) error {
+	return nil
+}
+
+func (z *zipArchive) Close() error {
+	return z.writer.Close()
+}
+
+type tarArchive struct {
+	writer     *tar.Writer
+	compressor io.WriteCloser
+}
+
+func (t *tarArchive) addFolder(name string, modified time.Time) error {
+	return t.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     name + "/",
+		Mode:     0755,
+		ModTime:  modified,
+	})
+}
+
+func (t *tarArchive) beginFile(name string, file *common.File) (io.Writer, error) {
+	if err := t.writer.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(file.Size),
+		ModTime:  file.Modified,
+	}); err != nil {
+		return nil, err
+	}
+	return t.writer, nil
+}
+
+func (t *tarArchive) endFile(file *common.File, written int64) error {
+	missing := int64(file.Size) - written
+	if missing <= 0 {
+		return nil
+	}
+	_, err := io.CopyN(t.writer, zeroReader{}, missing)
+	return err
+}
+
+func (t *tarArchive) Close() error {
+	if err := t.writer.Close(); err != nil {
+		return err
+	}
+	if t.compressor != nil {
+		return t.compressor.Close()
+	}
+	return nil
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// archiveEntry writes the entry header with the first content of the file. So the file that can not be read
+// at all is left out of the archive. err keeps the failure of the archive output to tell it apart from the
+// read failures
+type archiveEntry struct {
+	writer archiveWriter
+	name   string
+	file   *common.File
+
+	w       io.Writer
+	written int64
+	err     error
+}
+
+func (e *archiveEntry) begin() error {
+	if e.w != nil {
+		return nil
+	}
+
+	w, err := e.writer.beginFile(e.name, e.file)
+	if err != nil {
+		e.err = err
+		return err
+	}
+	e.w = w
+
+	return nil
+}
+
+func (e *archiveEntry) Write(p []byte) (int, error) {
+	if err := e.begin(); err != nil {
+		return 0, err
+	}
+
+	n, err := e.w.Write(p)
+	e.written += int64(n)
+	if err != nil {
+		e.err = err
+	}
+	return n, err
+}
+
+func (e *archiveEntry) started() bool {
+	return e.w != nil
+}
+
+func (e *archiveEntry) close() error {
+	if err := e.begin(); err != nil {
+		return err
+	}
+	return e.writer.endFile(e.file, e.written)
+}
+
+// archive streams the folder subtree into the archive writer. Folders are read one by one and files are
+// streamed without buffering. Locked, zombie and unreadable files are skipped and returned as the list of
+// full paths. A file that fails in the middle of the streaming stays in the archive with incomplete content
+// and it is also returned in the list
+func (d *dos) archive(folderPath string, archiveType ArchiveType, w io.Writer) ([]string, error) {
+	folderPath = common.CorrectPath(folderPath)
+
+	writer := newArchiveWriter(w, archiveType)
+
+	skipped := make([]string, 0)
+	folderPaths := []string{folderPath}
+	for len(folderPaths) > 0 {
+		currentPath := folderPaths[0]
+		folderPaths = folderPaths[1:]
+
+		folder, err := d.folder(currentPath)
+		if err != nil {
+			if err == os.ErrNotExist && strings.Compare(currentPath, folderPath) != 0 {
+				// deleted during the streaming
+				continue
+			}
+			return skipped, err
+		}
+
+		relativePath := strings.TrimPrefix(strings.TrimPrefix(folder.Full, folderPath), "/")
+		if len(relativePath) > 0 {
+			if err := writer.addFolder(relativePath, folder.Modified); err != nil {
+				return skipped, err
+			}
+		}
+
+		sort.Sort(folder.Files)
+		for _, file := range folder.Files {
+			filePath := common.Join(folder.Full, file.Name)
+
+			entryName := file.Name
+			if len(relativePath) > 0 {
+				entryName = relativePath + "/" + file.Name
+			}
+
+			streamed, err := d.archiveFile(writer, entryName, file)
+			if err != nil {
+				return skipped, err
+			}
+			if !streamed {
+				skipped = append(skipped, filePath)
+			}
+		}
+
+		// sub folders are streamed after the files of the folder in the depth first order
+		sort.Sort(folder.Folders)
+		subFolderPaths := make([]string, 0, len(folder.Folders)+len(folderPaths))
+		for _, shadow := range folder.Folders {
+			subFolderPaths = append(subFolderPaths, shadow.Full)
+		}
+		folderPaths = append(subFolderPaths, folderPaths...)
+	}
+
+	return skipped, writer.Close()
+}
+
+// archiveFile streams the file as an archive entry. It returns false if the file is skipped or could not be
+// streamed completely. The error is only returned when the archive output is failed
+func (d *dos) archiveFile(writer archiveWriter, entryName string, file *common.File) (bool, error) {
+	if file.Locked() || file.ZombieCheck() {
+		return false, nil
+	}
+
+	streamHandler, err := d.cluster.Read(file.Chunks)
+	if err != nil {
+		d.logger.Warn("Reading file for folder archive is failed", zap.String("entry", entryName), zap.Error(err))
+		return false, nil
+	}
+
+	entry := &archiveEntry{writer: writer, name: entryName, file: file}
+	if err := streamHandler(entry, 0, -1); err != nil {
+		if entry.err != nil {
+			return false, entry.err
+		}
+		d.logger.Warn("Streaming file for folder archive is failed", zap.String("entry", entryName), zap.Error(err))
+
+		if !entry.started() {
+			return false, nil
+		}
+		// the partially streamed entry is completed to keep the rest of the archive readable
+		return false, entry.close()
+	}
+
+	return true, entry.close()
+}
@@ -0,0 +1,183 @@
+package manager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// testArchiveMetadata fails the test if the whole tree is loaded
+type testArchiveMetadata struct {
+	*testMetadata
+
+	t *testing.T
+}
+
+func (m *testArchiveMetadata) ChildrenTree(folderPath string, _ bool, _ bool) ([]*common.Folder, error) {
+	m.t.Fatalf("children tree of %s is loaded for the archive", folderPath)
+	return nil, nil
+}
+
+// testArchiveCluster fails the streaming of the broken chunk after the first bytes
+type testArchiveCluster struct {
+	*testCluster
+
+	broken string
+}
+
+func (c *testArchiveCluster) Read(chunks common.DataChunks) (func(w io.Writer, begins int64, ends int64) error, error) {
+	streamHandler, err := c.testCluster.Read(chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chunk := range chunks {
+		if strings.Compare(chunk.Hash, c.broken) != 0 {
+			continue
+		}
+		return func(w io.Writer, begins int64, ends int64) error {
+			if _, err := w.Write([]byte("ab")); err != nil {
+				return err
+			}
+			return fmt.Errorf("connection is lost")
+		}, nil
+	}
+
+	return streamHandler, nil
+}
+
+func newTestArchiveDos(t *testing.T) (*dos, *testCluster) {
+	d, metadata, cluster := newTestDos()
+
+	createTestFile(t, d, "/folder/a.txt", "abcdefgh")
+	createTestFile(t, d, "/folder/sub/b.txt", "ijk")
+	createTestFile(t, d, "/folder/sub/deep/c.txt", "lmnop")
+	createTestFile(t, d, "/folder/z.txt", "qrs")
+	createTestFile(t, d, "/folder/locked.txt", "locked")
+	assert.Nil(t, d.Lock("/folder/locked.txt", time.Minute))
+	createTestFile(t, d, "/folder/missing.txt", "missing")
+	createTestFile(t, d, "/folder/sub/partial.txt", "partial")
+	createTestFile(t, d, "/other/x.txt", "outside")
+
+	// chunks of the missing file are lost in the cluster
+	for _, chunk := range metadata.file("/folder/missing.txt").Chunks {
+		delete(cluster.blocks, chunk.Hash)
+	}
+
+	d.metadata = &testArchiveMetadata{testMetadata: metadata, t: t}
+	d.cluster = &testArchiveCluster{testCluster: cluster, broken: metadata.file("/folder/sub/partial.txt").Chunks[0].Hash}
+
+	return d, cluster
+}
+
+var testArchiveSkipped = []string{"/folder/locked.txt", "/folder/missing.txt", "/folder/sub/partial.txt"}
+
+func TestDos_ArchiveZip(t *testing.T) {
+	d, _ := newTestArchiveDos(t)
+
+	buffer := &bytes.Buffer{}
+	skipped, err := d.archive("/folder", ATZip, buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, testArchiveSkipped, skipped)
+
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	assert.Nil(t, err)
+
+	names := make([]string, 0)
+	contents := make(map[string]string)
+	for _, entry := range reader.File {
+		names = append(names, entry.Name)
+		if strings.HasSuffix(entry.Name, "/") {
+			continue
+		}
+
+		r, err := entry.Open()
+		assert.Nil(t, err)
+		content, err := io.ReadAll(r)
+		assert.Nil(t, err)
+		contents[entry.Name] = string(content)
+	}
+
+	// files of the folder come before the sub folders
+	assert.Equal(t, []string{"a.txt", "z.txt", "sub/", "sub/b.txt", "sub/partial.txt", "sub/deep/", "sub/deep/c.txt"}, names)
+	// the partial entry is left with the streamed content, it is reported as skipped
+	assert.Equal(t, map[string]string{
+		"a.txt":           "abcdefgh",
+		"z.txt":           "qrs",
+		"sub/b.txt":       "ijk",
+		"sub/partial.txt": "ab",
+		"sub/deep/c.txt":  "lmnop",
+	}, contents)
+}
+
+func TestDos_ArchiveTar(t *testing.T) {
+	d, _ := newTestArchiveDos(t)
+
+	buffer := &bytes.Buffer{}
+	skipped, err := d.archive("/folder", ATTar, buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, testArchiveSkipped, skipped)
+
+	reader := tar.NewReader(buffer)
+
+	names := make([]string, 0)
+	contents := make(map[string]string)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		if err != nil {
+			return
+		}
+
+		names = append(names, header.Name)
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+
+		content, err := io.ReadAll(reader)
+		assert.Nil(t, err)
+		contents[header.Name] = string(content)
+	}
+
+	assert.Equal(t, []string{"a.txt", "z.txt", "sub/", "sub/b.txt", "sub/partial.txt", "sub/deep/", "sub/deep/c.txt"}, names)
+	// the partial entry is filled up to its size to keep the following entries readable
+	assert.Equal(t, "ab\x00\x00\x00\x00\x00", contents["sub/partial.txt"])
+	assert.Equal(t, "lmnop", contents["sub/deep/c.txt"])
+}
+
+type testFailingWriter struct {
+	limit int
+}
+
+func (w *testFailingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		return 0, io.ErrShortWrite
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestDos_ArchiveOutputFailure(t *testing.T) {
+	d, _ := newTestArchiveDos(t)
+
+	_, err := d.archive("/folder", ATTar, &testFailingWriter{limit: 600})
+	assert.Equal(t, io.ErrShortWrite, err)
+}
+
+func TestDos_ArchiveMissingFolder(t *testing.T) {
+	d, _ := newTestArchiveDos(t)
+
+	_, err := d.archive("/unknown", ATZip, &bytes.Buffer{})
+	assert.NotNil(t, err)
+}
@@ -1,12 +1,15 @@
 package manager
 
 import (
+	"encoding"
+	"encoding/hex"
 	"os"
 	"strings"
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
 	"github.com/freakmaxi/kertish-dos/basics/errors"
 	"github.com/freakmaxi/kertish-dos/basics/hooks"
+	"go.uber.org/zap"
 )
 
 func (d *dos) Change(sources []string, target string, join bool, overwrite bool, move bool) error {
@@ -180,15 +183,36 @@ func (d *dos) changeFolder(sources []string, target string, move bool) error {
 	})
 }
 
+// changeFile copies or moves the source files to the target file. Multiple sources are composed into the target
+// by sharing their chunks, nothing is uploaded again. The target can be one of the sources only for copying
+// with overwrite, the previous chunks of the target are released after the composed file is saved
 func (d *dos) changeFile(sources []string, target string, overwrite bool, move bool) error {
+	sources = common.CorrectPaths(sources)
+	target = common.CorrectPath(target)
 	targetParent, targetFilename := common.Split(target)
 
+	inPlace := false
+	for _, source := range sources {
+		if strings.Compare(source, target) == 0 {
+			inPlace = true
+			break
+		}
+	}
+	if inPlace {
+		if move {
+			return os.ErrInvalid
+		}
+		if !overwrite {
+			return os.ErrExist
+		}
+	}
+
 	targetFolders, err := d.metadata.Get([]string{targetParent})
 	if err != nil && err != os.ErrNotExist {
 		return err
 	}
 
-	if targetFolders != nil {
+	if targetFolders != nil && !inPlace {
 		targetFile := targetFolders[0].File(targetFilename)
 		if targetFile != nil {
 			if !overwrite {
@@ -240,11 +264,18 @@ func (d *dos) changeFile(sources []string, target string, overwrite bool, move b
 		return err
 	}
 
+	var replacedFile *common.File
+	var composedFile *common.File
+
 	if err := d.metadata.SaveChain(targetParent, func(targetFolder *common.Folder) (bool, error) {
-		targetFile, err := targetFolder.NewFile(targetFilename)
+		targetFile, err := d.prepareTargetFile(targetFolder, targetFilename, inPlace)
 		if err != nil {
 			return false, err
 		}
+		if inPlace {
+			previousFile := *targetFile
+			replacedFile = &previousFile
+		}
 		targetFile.Reset(joinedFile.Mime, joinedFile.Size)
 		joinedFile.CloneInto(targetFile)
 
@@ -255,11 +286,31 @@ func (d *dos) changeFile(sources []string, target string, overwrite bool, move b
 		}
 		targetFile.Lock.Cancel()
 
+		composed := *targetFile
+		composedFile = &composed
+
 		return true, nil
 	}); err != nil {
 		return err
 	}
 
+	if replacedFile != nil {
+		// inline chunks are released with the metadata
+		if remoteChunks := replacedFile.Chunks.Remote(); len(remoteChunks) > 0 {
+			if _, err := d.cluster.Delete(remoteChunks); err != nil {
+				d.logger.Warn(
+					"Releasing the chunks of the replaced file is failed, repair may require",
+					zap.String("path", target),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+
+	if len(composedFile.Checksum) == 0 {
+		go d.calculateChecksum(target, composedFile)
+	}
+
 	if !move {
 		// Handle Hooks
 		for _, source := range sources {
@@ -289,3 +340,57 @@ func (d *dos) changeFile(sources []string, target string, overwrite bool, move b
 		return true, nil
 	})
 }
+
+func (d *dos) prepareTargetFile(targetFolder *common.Folder, targetFilename string, inPlace bool) (*common.File, error) {
+	if !inPlace {
+		return targetFolder.NewFile(targetFilename)
+	}
+
+	targetFile := targetFolder.File(targetFilename)
+	if targetFile == nil {
+		return nil, os.ErrNotExist
+	}
+	if targetFile.Locked() {
+		return nil, errors.ErrLock
+	}
+	return targetFile, nil
+}
+
+// calculateChecksum calculates the checksum of the composed file by reading its content in the background.
+// If the file is changed in the meantime or the calculation fails, the checksum is left empty for the repair
+func (d *dos) calculateChecksum(path string, file *common.File) {
+	folderPath, filename := common.Split(path)
+
+	checksumHash, err := d.checksumHash(file)
+	if err == nil {
+		var checksumState []byte
+		checksumState, err = checksumHash.(encoding.BinaryMarshaler).MarshalBinary()
+		if err == nil {
+			checksum := hex.EncodeToString(checksumHash.Sum(nil))
+
+			err = d.metadata.SaveBlock([]string{folderPath}, func(folders map[string]*common.Folder) (bool, error) {
+				folder := folders[folderPath]
+				if folder == nil {
+					return false, nil
+				}
+
+				current := folder.File(filename)
+				if current == nil || len(current.Checksum) > 0 || strings.Compare(current.ETag(), file.ETag()) != 0 {
+					return false, nil
+				}
+				current.Checksum = checksum
+				current.ChecksumState = checksumState
+
+				return true, nil
+			})
+		}
+	}
+
+	if err != nil {
+		d.logger.Warn(
+			"Calculating checksum of the composed file is failed, repair may require",
+			zap.String("path", path),
+			zap.Error(err),
+		)
+	}
+}
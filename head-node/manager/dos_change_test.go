@@ -0,0 +1,129 @@
+package manager
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// waitTestChecksum waits the background checksum calculation of the file
+func waitTestChecksum(t *testing.T, metadata *testMetadata, path string, checksum string) {
+	assert.Eventually(t, func() bool {
+		metadata.mutex.Lock()
+		defer metadata.mutex.Unlock()
+
+		folderPath, filename := common.Split(path)
+		return strings.Compare(metadata.folders[folderPath].File(filename).Checksum, checksum) == 0
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestDos_Compose(t *testing.T) {
+	d, metadata, cluster := newTestDos()
+
+	createTestFile(t, d, "/a", "abcdefgh")
+	createTestFile(t, d, "/b", "ijkl")
+
+	assert.Nil(t, d.Change([]string{"/a", "/b"}, "/c", true, false, false))
+	assert.Equal(t, "abcdefghijkl", readTestFile(t, d, "/c"))
+	waitTestChecksum(t, metadata, "/c", testChecksum("abcdefghijkl"))
+
+	// nothing is uploaded, the chunks are shared with the sources
+	for _, chunk := range metadata.file("/c").Chunks {
+		assert.Equal(t, 2, cluster.usage(chunk.Hash))
+	}
+}
+
+func TestDos_ComposeInPlace(t *testing.T) {
+	d, metadata, cluster := newTestDos()
+
+	createTestFile(t, d, "/a", "abcdefgh")
+	createTestFile(t, d, "/b", "ijkl")
+	previous := metadata.file("/a").Chunks
+
+	assert.Equal(t, os.ErrExist, d.Change([]string{"/a", "/b"}, "/a", true, false, false))
+	assert.Nil(t, d.Change([]string{"/a", "/b"}, "/a", true, true, false))
+
+	assert.Equal(t, "abcdefghijkl", readTestFile(t, d, "/a"))
+	assert.Equal(t, "ijkl", readTestFile(t, d, "/b"))
+	waitTestChecksum(t, metadata, "/a", testChecksum("abcdefghijkl"))
+
+	// the shadows of the previous chunks are released, so they are only used by the composed file
+	for _, chunk := range previous {
+		assert.Contains(t, cluster.deleted, chunk.Hash)
+		assert.Equal(t, 1, cluster.usage(chunk.Hash))
+	}
+	assert.Equal(t, 2, cluster.usage(testChecksum("ijkl")))
+}
+
+func TestDos_ComposeInPlaceMove(t *testing.T) {
+	d, metadata, cluster := newTestDos()
+
+	createTestFile(t, d, "/a", "abcd")
+	createTestFile(t, d, "/b", "efgh")
+
+	assert.Equal(t, os.ErrInvalid, d.Change([]string{"/a", "/b"}, "/a", true, true, true))
+
+	// nothing is changed
+	assert.Equal(t, "abcd", readTestFile(t, d, "/a"))
+	assert.Equal(t, "efgh", readTestFile(t, d, "/b"))
+	assert.Equal(t, testChecksum("abcd"), metadata.file("/a").Checksum)
+	assert.Empty(t, cluster.deleted)
+}
+
+func TestDos_ComposeInline(t *testing.T) {
+	d, metadata, cluster := newTestDos()
+	d.inlineLimit = 6
+
+	createTestFile(t, d, "/a", "abc")
+	createTestFile(t, d, "/b", "defghijk")
+	createTestFile(t, d, "/c", "lmn")
+	inline := metadata.file("/a").Chunks[0]
+	assert.True(t, inline.Inline)
+
+	assert.Nil(t, d.Change([]string{"/a", "/b", "/c"}, "/d", true, false, false))
+	assert.Equal(t, "abcdefghijklmn", readTestFile(t, d, "/d"))
+	waitTestChecksum(t, metadata, "/d", testChecksum("abcdefghijklmn"))
+
+	// the inline chunks are kept in the metadata of the composed file
+	chunks := metadata.file("/d").Chunks
+	assert.Len(t, chunks, 4)
+	assert.True(t, chunks[0].Intact())
+	assert.True(t, chunks[3].Intact())
+
+	// the inline chunks of the replaced file are not released on the cluster
+	assert.Nil(t, d.Change([]string{"/a", "/c"}, "/a", true, true, false))
+	assert.Equal(t, "abclmn", readTestFile(t, d, "/a"))
+	assert.NotContains(t, cluster.deleted, inline.Hash)
+	assert.Empty(t, cluster.deleted)
+}
+
+func TestDos_CalculateChecksum(t *testing.T) {
+	d, metadata, _ := newTestDos()
+
+	createTestFile(t, d, "/a", "abcd")
+	createTestFile(t, d, "/b", "efgh")
+	assert.Nil(t, metadata.SaveBlock([]string{"/"}, func(folders map[string]*common.Folder) (bool, error) {
+		folders["/"].File("a").Checksum = ""
+		return true, nil
+	}))
+	composed := metadata.file("/a")
+
+	// the content is changed before the checksum is calculated
+	assert.Nil(t, metadata.SaveBlock([]string{"/"}, func(folders map[string]*common.Folder) (bool, error) {
+		file := folders["/"].File("a")
+		file.Chunks = folders["/"].File("b").Chunks
+		return true, nil
+	}))
+
+	d.calculateChecksum("/a", composed)
+	assert.Empty(t, metadata.file("/a").Checksum)
+	assert.Empty(t, metadata.file("/a").ChecksumState)
+
+	d.calculateChecksum("/a", metadata.file("/a"))
+	assert.Equal(t, testChecksum("efgh"), metadata.file("/a").Checksum)
+	assert.NotEmpty(t, metadata.file("/a").ChecksumState)
+}
@@ -68,7 +68,7 @@ func (d *dos) CreateFile(path string, mime string, size uint64, overwrite bool,
 		return err
 	}
 
-	creationResult, err := d.cluster.Create(size, contentReader)
+	creationResult, err := d.create(size, contentReader)
 	if err != nil {
 		if errUpdate := d.update(path, nil); errUpdate != nil {
 			d.logger.Error(
@@ -99,6 +99,68 @@ func (d *dos) CreateFile(path string, mime string, size uint64, overwrite bool,
 	return err
 }
 
+// inlineable checks if the file content fits to the inline limit to be kept in the metadata
+func (d *dos) inlineable(size uint64) bool {
+	return d.inlineLimit > 0 && size <= d.inlineLimit
+}
+
+// create places the content in the metadata as inline chunk if it is inlineable, otherwise in the data nodes
+func (d *dos) create(size uint64, reader io.Reader) (*common.CreationResult, error) {
+	if !d.inlineable(size) {
+		return d.cluster.Create(size, reader)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		return nil, err
+	}
+	chunk := common.NewInlineDataChunk(0, data)
+
+	return common.NewCreationResult(chunk.Hash, common.DataChunks{chunk}), nil
+}
+
+// createBatch places the inlineable contents in the metadata and the rest in the data nodes under a single
+// reservation. Results and errors are in the same order with sizes
+func (d *dos) createBatch(sizes []uint64, readers []io.Reader) ([]*common.CreationResult, []error, error) {
+	if len(sizes) == 0 || len(sizes) != len(readers) {
+		return nil, nil, os.ErrInvalid
+	}
+
+	creationResults := make([]*common.CreationResult, len(sizes))
+	creationErrors := make([]error, len(sizes))
+
+	remoteIndices := make([]int, 0)
+	remoteSizes := make([]uint64, 0)
+	remoteReaders := make([]io.Reader, 0)
+	for i, size := range sizes {
+		if d.inlineable(size) {
+			creationResults[i], creationErrors[i] = d.create(size, readers[i])
+			continue
+		}
+		remoteIndices = append(remoteIndices, i)
+		remoteSizes = append(remoteSizes, size)
+		remoteReaders = append(remoteReaders, readers[i])
+	}
+	if len(remoteIndices) == 0 {
+		return creationResults, creationErrors, nil
+	}
+	if len(remoteIndices) == len(sizes) {
+		return d.cluster.CreateBatch(sizes, readers)
+	}
+
+	remoteResults, remoteErrors, err := d.cluster.CreateBatch(remoteSizes, remoteReaders)
+	for j, i := range remoteIndices {
+		if err != nil {
+			creationErrors[i] = err
+			continue
+		}
+		creationResults[i] = remoteResults[j]
+		creationErrors[i] = remoteErrors[j]
+	}
+
+	return creationResults, creationErrors, nil
+}
+
 func (d *dos) update(folderPath string, file *common.File) error {
 	parent, filename := common.Split(folderPath)
 
@@ -0,0 +1,523 @@
+package manager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/hooks"
+	"go.uber.org/zap"
+)
+
+const extractBatchCount = 500
+const extractBatchSize uint64 = 1024 * 1024 * 32 // 32Mb
+const extractStreamSize uint64 = 1024 * 1024 * 4 // 4Mb, bigger files are not batched
+
+type extractEntry struct {
+	name        string
+	folder      bool
+	unsupported bool
+	size        uint64
+	reader      io.Reader
+}
+
+type extractReader interface {
+	next() (*extractEntry, error)
+	Close() error
+}
+
+func newExtractReader(reader io.Reader, archiveType ArchiveType) (extractReader, error) {
+	switch archiveType {
+	case ATTar:
+		return &tarExtractReader{reader: tar.NewReader(reader)}, nil
+	case ATTarGz:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, os.ErrInvalid
+		}
+		return &tarExtractReader{reader: tar.NewReader(gzipReader), decompressor: gzipReader}, nil
+	}
+
+	// zip requires random access to read the central directory, so the body is kept in a temp file
+	tempFile, err := os.CreateTemp("", "kertish-extract-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(tempFile, reader)
+	if err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempFile.Name())
+		return nil, err
+	}
+
+	zipReader, err := zip.NewReader(tempFile, size)
+	if err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempFile.Name())
+		return nil, os.ErrInvalid
+	}
+	return &zipExtractReader{file: tempFile, reader: zipReader}, nil
+}
+
+type tarExtractReader struct {
+	reader       *tar.Reader
+	decompressor io.Closer
+}
+
+func (t *tarExtractReader) next() (*extractEntry, error) {
+	header, err := t.reader.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		return &extractEntry{name: header.Name, folder: true}, nil
+	case tar.TypeReg:
+		return &extractEntry{name: header.Name, size: uint64(header.Size), reader: t.reader}, nil
+	}
+	return &extractEntry{name: header.Name, unsupported: true}, nil
+}
+
+func (t *tarExtractReader) Close() error {
+	if t.decompressor != nil {
+		return t.decompressor.Close()
+	}
+	return nil
+}
+
+type zipExtractReader struct {
+	file    *os.File
+	reader  *zip.Reader
+	index   int
+	current io.ReadCloser
+}
+
+func (z *zipExtractReader) next() (*extractEntry, error) {
+	if z.current != nil {
+		_ = z.current.Close()
+		z.current = nil
+	}
+
+	if z.index == len(z.reader.File) {
+		return nil, io.EOF
+	}
+	file := z.reader.File[z.index]
+	z.index++
+
+	if file.FileInfo().IsDir() {
+		return &extractEntry{name: file.Name, folder: true}, nil
+	}
+	if !file.Mode().IsRegular() {
+		return &extractEntry{name: file.Name, unsupported: true}, nil
+	}
+
+	var err error
+	z.current, err = file.Open()
+	if err != nil {
+		return nil, err
+	}
+	return &extractEntry{name: file.Name, size: file.UncompressedSize64, reader: z.current}, nil
+}
+
+func (z *zipExtractReader) Close() error {
+	if z.current != nil {
+		_ = z.current.Close()
+	}
+	_ = z.file.Close()
+	return os.Remove(z.file.Name())
+}
+
+type extractItem struct {
+	path   string
+	parent string
+	name   string
+	mime   string
+	data   []byte
+}
+
+type extraction struct {
+	dos       *dos
+	overwrite bool
+	result    *common.ExtractionResult
+
+	folders      map[string]bool
+	hookActions  map[string][]hooks.Action
+	pending      []*extractItem
+	pendingBytes uint64
+}
+
+// Extract creates the folders and files of the archive under the folder path. Small files are collected
+// in batches and created with a single reservation and a single metadata save per batch.
+// Existing files are overwritten or reported as exists base on overwrite flag
+func (d *dos) Extract(folderPath string, archiveType ArchiveType, overwrite bool, reader io.Reader) (*common.ExtractionResult, error) {
+	folderPath = common.CorrectPath(folderPath)
+
+	archiveReader, err := newExtractReader(reader, archiveType)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = archiveReader.Close() }()
+
+	if err := d.CreateFolder(folderPath); err != nil {
+		return nil, err
+	}
+
+	e := &extraction{
+		dos:         d,
+		overwrite:   overwrite,
+		result:      common.NewExtractionResult(),
+		folders:     map[string]bool{folderPath: true},
+		hookActions: make(map[string][]hooks.Action),
+		pending:     make([]*extractItem, 0),
+	}
+
+	for {
+		entry, err := archiveReader.next()
+		if err != nil {
+			if err != io.EOF {
+				e.result.Add(folderPath, true, common.ESFailed, fmt.Errorf("archive is not readable: %s", err))
+			}
+			break
+		}
+
+		entryPath, valid := e.entryPath(folderPath, entry.name)
+		if !valid {
+			e.result.Add(entry.name, entry.folder, common.ESFailed, os.ErrInvalid)
+			continue
+		}
+
+		if entry.unsupported {
+			e.result.Add(entryPath, false, common.ESSkipped, fmt.Errorf("unsupported entry type"))
+			continue
+		}
+
+		if entry.folder {
+			e.folder(entryPath)
+			continue
+		}
+
+		if entry.size >= extractStreamSize {
+			e.flush()
+			e.stream(entryPath, entry)
+			continue
+		}
+
+		data := make([]byte, entry.size)
+		if _, err := io.ReadFull(entry.reader, data); err != nil {
+			e.result.Add(entryPath, false, common.ESFailed, err)
+			continue
+		}
+
+		parent, name := common.Split(entryPath)
+		e.pending = append(e.pending, &extractItem{
+			path:   entryPath,
+			parent: parent,
+			name:   name,
+			mime:   http.DetectContentType(data),
+			data:   data,
+		})
+		e.pendingBytes += entry.size
+
+		if len(e.pending) >= extractBatchCount || e.pendingBytes >= extractBatchSize {
+			e.flush()
+		}
+	}
+	e.flush()
+
+	return e.result, nil
+}
+
+func (e *extraction) entryPath(folderPath string, name string) (string, bool) {
+	parts := make([]string, 0)
+	for _, part := range strings.Split(strings.ReplaceAll(name, "\\", "/"), "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", false
+		}
+		parts = append(parts, part)
+	}
+	return common.CorrectPath(common.Join(append([]string{folderPath}, parts...)...)), true
+}
+
+func (e *extraction) ensureFolder(folderPath string) error {
+	if e.folders[folderPath] {
+		return nil
+	}
+	if err := e.dos.CreateFolder(folderPath); err != nil {
+		return err
+	}
+	e.folders[folderPath] = true
+	return nil
+}
+
+func (e *extraction) folder(folderPath string) {
+	if e.folders[folderPath] {
+		e.result.Add(folderPath, true, common.ESExists, nil)
+		return
+	}
+
+	if _, err := e.dos.folder(folderPath); err == nil {
+		e.folders[folderPath] = true
+		e.result.Add(folderPath, true, common.ESExists, nil)
+		return
+	}
+
+	if err := e.ensureFolder(folderPath); err != nil {
+		e.result.Add(folderPath, true, common.ESFailed, err)
+		return
+	}
+	e.result.Add(folderPath, true, common.ESCreated, nil)
+}
+
+func (e *extraction) stream(path string, entry *extractEntry) {
+	parent, name := common.Split(path)
+	if err := e.ensureFolder(parent); err != nil {
+		e.result.Add(path, false, common.ESFailed, err)
+		return
+	}
+
+	status := common.ESCreated
+	if folder, err := e.dos.folder(parent); err == nil && folder.File(name) != nil {
+		if !e.overwrite {
+			e.result.Add(path, false, common.ESExists, nil)
+			return
+		}
+		status = common.ESOverwritten
+	}
+
+	bufferedReader := bufio.NewReader(entry.reader)
+	head, _ := bufferedReader.Peek(512)
+
+	if err := e.dos.CreateFile(path, http.DetectContentType(head), entry.size, e.overwrite, bufferedReader); err != nil {
+		if err == os.ErrExist {
+			e.result.Add(path, false, common.ESExists, nil)
+			return
+		}
+		e.result.Add(path, false, common.ESFailed, err)
+		return
+	}
+	e.result.Add(path, false, status, nil)
+}
+
+func (e *extraction) flush() {
+	if len(e.pending) == 0 {
+		return
+	}
+	defer func() {
+		e.pending = make([]*extractItem, 0)
+		e.pendingBytes = 0
+	}()
+
+	items := e.prepare()
+	if len(items) == 0 {
+		return
+	}
+
+	sizes := make([]uint64, len(items))
+	readers := make([]io.Reader, len(items))
+	for i, item := range items {
+		sizes[i] = uint64(len(item.data))
+		readers[i] = bytes.NewReader(item.data)
+	}
+
+	creationResults, creationErrors, err := e.dos.createBatch(sizes, readers)
+	if err != nil {
+		for _, item := range items {
+			e.result.Add(item.path, false, common.ESFailed, err)
+		}
+		return
+	}
+
+	created := make(map[int]*common.CreationResult)
+	parents := make([]string, 0)
+	for i, item := range items {
+		if creationErrors[i] != nil {
+			e.result.Add(item.path, false, common.ESFailed, creationErrors[i])
+			continue
+		}
+		created[i] = creationResults[i]
+		parents = append(parents, item.parent)
+	}
+	if len(created) == 0 {
+		return
+	}
+
+	e.save(items, created, parents)
+}
+
+// prepare ensures the parent folders of the pending items and drops the ones that can not be written
+func (e *extraction) prepare() []*extractItem {
+	items := make([]*extractItem, 0)
+	for _, item := range e.pending {
+		if err := e.ensureFolder(item.parent); err != nil {
+			e.result.Add(item.path, false, common.ESFailed, err)
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return items
+	}
+
+	parents := make([]string, 0)
+	for _, item := range items {
+		parents = append(parents, item.parent)
+	}
+	folders, err := e.dos.metadata.Get(parents)
+	if err != nil {
+		for _, item := range items {
+			e.result.Add(item.path, false, common.ESFailed, err)
+		}
+		return make([]*extractItem, 0)
+	}
+
+	foldersMap := make(map[string]*common.Folder)
+	for _, folder := range folders {
+		foldersMap[folder.Full] = folder
+	}
+
+	writable := make([]*extractItem, 0)
+	for _, item := range items {
+		folder := foldersMap[item.parent]
+		if folder == nil {
+			e.result.Add(item.path, false, common.ESFailed, os.ErrNotExist)
+			continue
+		}
+		if folder.Folder(item.name) != nil {
+			e.result.Add(item.path, false, common.ESFailed, os.ErrExist)
+			continue
+		}
+
+		file := folder.File(item.name)
+		if file != nil {
+			if !e.overwrite {
+				e.result.Add(item.path, false, common.ESExists, nil)
+				continue
+			}
+			if file.Locked() {
+				e.result.Add(item.path, false, common.ESFailed, errors.ErrLock)
+				continue
+			}
+		}
+		writable = append(writable, item)
+	}
+	return writable
+}
+
+func (e *extraction) save(items []*extractItem, created map[int]*common.CreationResult, parents []string) {
+	statuses := make(map[int]string)
+	failures := make(map[int]error)
+	replaced := make(common.Files, 0)
+
+	if err := e.dos.metadata.SaveBlock(parents, func(folders map[string]*common.Folder) (bool, error) {
+		for i, creationResult := range created {
+			item := items[i]
+			folder := folders[item.parent]
+
+			status := common.ESCreated
+			file := folder.File(item.name)
+			if file != nil {
+				if !e.overwrite {
+					statuses[i] = common.ESExists
+					continue
+				}
+				if file.Locked() {
+					failures[i] = errors.ErrLock
+					continue
+				}
+
+				previous := *file
+				replaced = append(replaced, &previous)
+				status = common.ESOverwritten
+			} else {
+				var err error
+				file, err = folder.NewFile(item.name)
+				if err != nil {
+					failures[i] = err
+					continue
+				}
+			}
+
+			file.Reset(item.mime, uint64(len(item.data)))
+			file.Checksum = creationResult.Checksum
+			file.Chunks = append(file.Chunks, creationResult.Chunks...)
+			file.Lock.Cancel()
+
+			statuses[i] = status
+		}
+		return true, nil
+	}); err != nil {
+		for i := range created {
+			e.result.Add(items[i].path, false, common.ESFailed, err)
+		}
+		e.drop(created, nil)
+		return
+	}
+
+	for i := range items {
+		if _, has := created[i]; !has {
+			continue
+		}
+		if err, has := failures[i]; has {
+			e.result.Add(items[i].path, false, common.ESFailed, err)
+			continue
+		}
+		e.result.Add(items[i].path, false, statuses[i], nil)
+
+		if statuses[i] != common.ESExists {
+			actions := e.actions(items[i].parent)
+			e.dos.ExecuteActions(hooks.NewActionInfoForCreated(items[i].path, false), actions)
+		}
+	}
+
+	// chunks of the entries that are not written and the chunks of the overwritten files are not used anymore
+	unused := make(map[int]*common.CreationResult)
+	for i, creationResult := range created {
+		if _, has := failures[i]; has || statuses[i] == common.ESExists {
+			unused[i] = creationResult
+		}
+	}
+	e.drop(unused, replaced)
+}
+
+func (e *extraction) drop(created map[int]*common.CreationResult, replaced common.Files) {
+	chunks := make(common.DataChunks, 0)
+	for _, creationResult := range created {
+		chunks = append(chunks, creationResult.Chunks...)
+	}
+	for _, file := range replaced {
+		chunks = append(chunks, file.Chunks...)
+	}
+	if len(chunks) == 0 {
+		return
+	}
+
+	if _, err := e.dos.cluster.Delete(chunks); err != nil {
+		e.dos.logger.Warn(
+			"Dropping unused chunks of the extraction is failed, repair may require",
+			zap.Int("chunks", len(chunks)),
+			zap.Error(err),
+		)
+	}
+}
+
+func (e *extraction) actions(folderPath string) []hooks.Action {
+	actions, has := e.hookActions[folderPath]
+	if !has {
+		actions = e.dos.compileHookActions(folderPath, hooks.Created)
+		e.hookActions[folderPath] = actions
+	}
+	return actions
+}
@@ -0,0 +1,311 @@
+package manager
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// testExtractCluster records the sizes of the direct creations and the batches
+type testExtractCluster struct {
+	*testCluster
+
+	creates []uint64
+	batches [][]uint64
+}
+
+func (c *testExtractCluster) Create(size uint64, reader io.Reader) (*common.CreationResult, error) {
+	c.creates = append(c.creates, size)
+	return c.testCluster.Create(size, reader)
+}
+
+func (c *testExtractCluster) CreateBatch(sizes []uint64, readers []io.Reader) ([]*common.CreationResult, []error, error) {
+	c.batches = append(c.batches, append([]uint64{}, sizes...))
+	return c.testCluster.CreateBatch(sizes, readers)
+}
+
+func newTestExtractDos(chunkSize int) (*dos, *testExtractCluster) {
+	d, _, cluster := newTestDos()
+	cluster.chunkSize = chunkSize
+
+	extractCluster := &testExtractCluster{testCluster: cluster}
+	d.cluster = extractCluster
+
+	return d, extractCluster
+}
+
+type testArchiveEntry struct {
+	name    string
+	folder  bool
+	content []byte
+}
+
+func testTar(entries []testArchiveEntry) *bytes.Buffer {
+	buffer := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buffer)
+	for _, entry := range entries {
+		if entry.folder {
+			_ = tarWriter.WriteHeader(&tar.Header{Name: entry.name, Typeflag: tar.TypeDir, Mode: 0755})
+			continue
+		}
+		_ = tarWriter.WriteHeader(&tar.Header{Name: entry.name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(entry.content))})
+		_, _ = tarWriter.Write(entry.content)
+	}
+	_ = tarWriter.Close()
+	return buffer
+}
+
+func testZip(entries []testArchiveEntry) *bytes.Buffer {
+	buffer := &bytes.Buffer{}
+	zipWriter := zip.NewWriter(buffer)
+	for _, entry := range entries {
+		if entry.folder {
+			_, _ = zipWriter.Create(entry.name)
+			continue
+		}
+		writer, _ := zipWriter.Create(entry.name)
+		_, _ = writer.Write(entry.content)
+	}
+	_ = zipWriter.Close()
+	return buffer
+}
+
+// testExtractionStatuses maps the entry paths to their statuses
+func testExtractionStatuses(result *common.ExtractionResult) map[string]string {
+	statuses := make(map[string]string)
+	for _, entry := range result.Entries {
+		statuses[entry.Path] = entry.Status
+	}
+	return statuses
+}
+
+func testExtractEntries() []testArchiveEntry {
+	return []testArchiveEntry{
+		{name: "docs/", folder: true},
+		{name: "docs/readme.txt", content: []byte("read me")},
+		{name: "./docs/nested/deep.txt", content: []byte("deep content")},
+		{name: "root.txt", content: []byte("root")},
+	}
+}
+
+func testExtractArchive(t *testing.T, archiveType ArchiveType) {
+	d, _ := newTestExtractDos(4)
+
+	archive := testTar(testExtractEntries())
+	if archiveType == ATZip {
+		archive = testZip(testExtractEntries())
+	}
+
+	result, err := d.Extract("/target", archiveType, false, archive)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result.Failed)
+	assert.Equal(t, map[string]string{
+		"/target/docs":                 common.ESCreated,
+		"/target/docs/readme.txt":      common.ESCreated,
+		"/target/docs/nested/deep.txt": common.ESCreated,
+		"/target/root.txt":             common.ESCreated,
+	}, testExtractionStatuses(result))
+
+	assert.Equal(t, "read me", readTestFile(t, d, "/target/docs/readme.txt"))
+	assert.Equal(t, "deep content", readTestFile(t, d, "/target/docs/nested/deep.txt"))
+	assert.Equal(t, "root", readTestFile(t, d, "/target/root.txt"))
+}
+
+func TestDos_ExtractTar(t *testing.T) {
+	testExtractArchive(t, ATTar)
+}
+
+func TestDos_ExtractZip(t *testing.T) {
+	testExtractArchive(t, ATZip)
+}
+
+func TestDos_ExtractExisting(t *testing.T) {
+	d, _ := newTestExtractDos(4)
+	createTestFile(t, d, "/target/root.txt", "previous")
+
+	entries := []testArchiveEntry{{name: "root.txt", content: []byte("root")}}
+
+	result, err := d.Extract("/target", ATTar, false, testTar(entries))
+	assert.Nil(t, err)
+	assert.Equal(t, common.ESExists, testExtractionStatuses(result)["/target/root.txt"])
+	assert.Equal(t, "previous", readTestFile(t, d, "/target/root.txt"))
+
+	result, err = d.Extract("/target", ATTar, true, testTar(entries))
+	assert.Nil(t, err)
+	assert.Equal(t, common.ESOverwritten, testExtractionStatuses(result)["/target/root.txt"])
+	assert.Equal(t, "root", readTestFile(t, d, "/target/root.txt"))
+}
+
+func TestDos_ExtractPathTraversal(t *testing.T) {
+	d, _ := newTestExtractDos(4)
+
+	entries := []testArchiveEntry{
+		{name: "../evil.txt", content: []byte("evil")},
+		{name: "a/../../escape.txt", content: []byte("escape")},
+		{name: "safe.txt", content: []byte("safe")},
+	}
+
+	for _, archiveType := range []ArchiveType{ATTar, ATZip} {
+		archive := testTar(entries)
+		if archiveType == ATZip {
+			archive = testZip(entries)
+		}
+
+		result, err := d.Extract("/target", archiveType, true, archive)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, result.Failed)
+
+		statuses := testExtractionStatuses(result)
+		assert.Equal(t, common.ESFailed, statuses["../evil.txt"])
+		assert.Equal(t, common.ESFailed, statuses["a/../../escape.txt"])
+		assert.Contains(t, []string{common.ESCreated, common.ESOverwritten}, statuses["/target/safe.txt"])
+
+		_, err = d.folder("/target/a")
+		assert.Equal(t, os.ErrNotExist, err)
+		_, _, err = d.file([]string{"/evil.txt"})
+		assert.NotNil(t, err)
+		_, _, err = d.file([]string{"/escape.txt"})
+		assert.NotNil(t, err)
+	}
+}
+
+func TestDos_ExtractTempCleanup(t *testing.T) {
+	tempDir := t.TempDir()
+	t.Setenv("TMPDIR", tempDir)
+
+	spooled := func() []string {
+		matches, _ := filepath.Glob(filepath.Join(tempDir, "kertish-extract-*"))
+		return matches
+	}
+
+	d, _ := newTestExtractDos(4)
+
+	_, err := d.Extract("/target", ATZip, false, testZip(testExtractEntries()))
+	assert.Nil(t, err)
+	assert.Empty(t, spooled())
+
+	_, err = d.Extract("/target", ATZip, false, bytes.NewBufferString("not a zip archive"))
+	assert.Equal(t, os.ErrInvalid, err)
+	assert.Empty(t, spooled())
+}
+
+func TestDos_ExtractBatchCount(t *testing.T) {
+	d, cluster := newTestExtractDos(4)
+
+	entries := make([]testArchiveEntry, 0)
+	for i := 0; i < extractBatchCount*2+1; i++ {
+		entries = append(entries, testArchiveEntry{name: fmt.Sprintf("file-%d.txt", i), content: []byte(fmt.Sprintf("%d", i))})
+	}
+
+	result, err := d.Extract("/target", ATTar, false, testTar(entries))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result.Failed)
+	assert.Len(t, result.Entries, len(entries))
+
+	assert.Len(t, cluster.batches, 3)
+	assert.Len(t, cluster.batches[0], extractBatchCount)
+	assert.Len(t, cluster.batches[1], extractBatchCount)
+	assert.Len(t, cluster.batches[2], 1)
+	assert.Empty(t, cluster.creates)
+
+	assert.Equal(t, "1000", readTestFile(t, d, "/target/file-1000.txt"))
+}
+
+func TestDos_ExtractBatchSize(t *testing.T) {
+	d, cluster := newTestExtractDos(1024 * 1024)
+
+	size := int(extractStreamSize) - 1
+	count := int(extractBatchSize)/size + 2
+
+	entries := make([]testArchiveEntry, 0)
+	for i := 0; i < count; i++ {
+		entries = append(entries, testArchiveEntry{name: fmt.Sprintf("file-%d.bin", i), content: bytes.Repeat([]byte{byte(i)}, size)})
+	}
+
+	result, err := d.Extract("/target", ATTar, false, testTar(entries))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result.Failed)
+
+	assert.Len(t, cluster.batches, 2)
+	assert.Len(t, cluster.batches[0], count-1)
+	assert.Len(t, cluster.batches[1], 1)
+	assert.Empty(t, cluster.creates)
+}
+
+func TestDos_ExtractStream(t *testing.T) {
+	d, cluster := newTestExtractDos(1024 * 1024)
+
+	big := bytes.Repeat([]byte("0123456789abcdef"), int(extractStreamSize)/16)
+	entries := []testArchiveEntry{
+		{name: "small-1.txt", content: []byte("small 1")},
+		{name: "big.bin", content: big},
+		{name: "small-2.txt", content: []byte("small 2")},
+	}
+
+	result, err := d.Extract("/target", ATZip, false, testZip(entries))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, result.Failed)
+
+	// pending small files are flushed before the big file is streamed
+	assert.Equal(t, []uint64{extractStreamSize}, cluster.creates)
+	assert.Equal(t, [][]uint64{{7}, {7}}, cluster.batches)
+
+	assert.Equal(t, string(big), readTestFile(t, d, "/target/big.bin"))
+	assert.Equal(t, "small 2", readTestFile(t, d, "/target/small-2.txt"))
+}
+
+func TestDos_ExtractBatchFailure(t *testing.T) {
+	d, cluster := newTestExtractDos(4)
+	cluster.createErr[6] = fmt.Errorf("node is not reachable")
+
+	entries := []testArchiveEntry{
+		{name: "a.txt", content: []byte("a")},
+		{name: "broken.txt", content: []byte("broken")},
+		{name: "c.txt", content: []byte("ccc")},
+	}
+
+	result, err := d.Extract("/target", ATTar, false, testTar(entries))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, result.Failed)
+	assert.Equal(t, map[string]string{
+		"/target/a.txt":      common.ESCreated,
+		"/target/broken.txt": common.ESFailed,
+		"/target/c.txt":      common.ESCreated,
+	}, testExtractionStatuses(result))
+
+	assert.Nil(t, d.metadata.(*testMetadata).file("/target/broken.txt"))
+	assert.Equal(t, "ccc", readTestFile(t, d, "/target/c.txt"))
+}
+
+func TestDos_ExtractTruncated(t *testing.T) {
+	d, _ := newTestExtractDos(4)
+
+	archive := testTar([]testArchiveEntry{
+		{name: "a.txt", content: []byte("a")},
+		{name: "b.txt", content: bytes.Repeat([]byte("b"), 2048)},
+	})
+	truncated := bytes.NewReader(archive.Bytes()[:1024+512+100])
+
+	result, err := d.Extract("/target", ATTar, false, truncated)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, result.Failed)
+
+	statuses := testExtractionStatuses(result)
+	assert.Equal(t, common.ESCreated, statuses["/target/a.txt"])
+	assert.Equal(t, common.ESFailed, statuses["/target/b.txt"])
+	assert.Equal(t, common.ESFailed, statuses["/target"])
+	for _, entry := range result.Entries {
+		if entry.Path == "/target" {
+			assert.Contains(t, entry.Error, "archive is not readable")
+		}
+	}
+	assert.Equal(t, "a", readTestFile(t, d, "/target/a.txt"))
+}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"os"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+)
+
+// Stat returns the folder or the file metadata of the path without any lock or zombie check.
+// Only one of the returned folder and file is filled
+func (d *dos) Stat(path string) (*common.Folder, *common.File, error) {
+	path = common.CorrectPath(path)
+
+	folder, err := d.folder(path)
+	if err == nil {
+		return folder, nil, nil
+	}
+	if err != os.ErrNotExist {
+		return nil, nil, err
+	}
+
+	folderPath, filename := common.Split(path)
+	if len(filename) == 0 {
+		return nil, nil, os.ErrNotExist
+	}
+
+	folder, err = d.folder(folderPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file := folder.File(filename)
+	if file == nil {
+		return nil, nil, os.ErrNotExist
+	}
+	return nil, file, nil
+}
+
+func (d *dos) Lock(path string, duration time.Duration) error {
+	return d.changeLock(path, func(file *common.File) error {
+		if file.Locked() {
+			return errors.ErrLock
+		}
+		file.Lock = common.NewFileLock(duration)
+		return nil
+	})
+}
+
+func (d *dos) Unlock(path string) error {
+	return d.changeLock(path, func(file *common.File) error {
+		if file.Lock != nil {
+			file.Lock.Cancel()
+		}
+		return nil
+	})
+}
+
+func (d *dos) changeLock(path string, lockHandler func(file *common.File) error) error {
+	path = common.CorrectPath(path)
+
+	folderPath, filename := common.Split(path)
+	if len(filename) == 0 {
+		return os.ErrInvalid
+	}
+
+	return d.metadata.SaveBlock([]string{folderPath}, func(folders map[string]*common.Folder) (bool, error) {
+		file := folders[folderPath].File(filename)
+		if file == nil {
+			return false, os.ErrNotExist
+		}
+
+		if err := lockHandler(file); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+}
@@ -0,0 +1,123 @@
+package manager
+
+import (
+	"bytes"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"go.uber.org/zap"
+)
+
+// Promote moves the inline chunks that are bigger than the inline limit to the data nodes and returns
+// the promoted file count. It works online, the files that are locked or changed in the meantime are skipped
+func (d *dos) Promote() (int, error) {
+	paths, err := d.metadata.InlineFiles(d.inlineLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	promoted := 0
+	for _, path := range paths {
+		if err := d.promote(path); err != nil {
+			if err == errors.ErrQuit {
+				continue
+			}
+			d.logger.Warn(
+				"Promoting inline file to the data nodes is failed",
+				zap.String("path", path),
+				zap.Error(err),
+			)
+			continue
+		}
+		promoted++
+	}
+
+	return promoted, nil
+}
+
+func (d *dos) promote(path string) error {
+	folderPath, filename := common.Split(path)
+
+	folder, err := d.folder(folderPath)
+	if err != nil {
+		return err
+	}
+
+	file := folder.File(filename)
+	if file == nil {
+		return os.ErrNotExist
+	}
+	if file.Locked() {
+		return errors.ErrLock
+	}
+
+	// chunks are placed in the data nodes before the metadata is changed, they are dropped if the
+	// file is changed in the meantime
+	promotions := make(map[uint32]common.DataChunks)
+	created := make(common.DataChunks, 0)
+	for _, chunk := range file.Chunks {
+		if !chunk.Inline || uint64(chunk.Size) <= d.inlineLimit {
+			continue
+		}
+
+		creationResult, err := d.cluster.Create(uint64(chunk.Size), bytes.NewReader(chunk.Data))
+		if err != nil {
+			d.drop(created)
+			return err
+		}
+		promotions[chunk.Sequence] = creationResult.Chunks
+		created = append(created, creationResult.Chunks...)
+	}
+	if len(promotions) == 0 {
+		return errors.ErrQuit
+	}
+
+	if err := d.metadata.SaveBlock([]string{folderPath}, func(folders map[string]*common.Folder) (bool, error) {
+		folder := folders[folderPath]
+		if folder == nil {
+			return false, os.ErrNotExist
+		}
+
+		current := folder.File(filename)
+		if current == nil {
+			return false, os.ErrNotExist
+		}
+		if current.Locked() {
+			return false, errors.ErrLock
+		}
+		if strings.Compare(current.ETag(), file.ETag()) != 0 {
+			return false, errors.ErrQuit
+		}
+
+		sort.Sort(current.Chunks)
+
+		chunks := make(common.DataChunks, 0)
+		for _, chunk := range current.Chunks {
+			promotedChunks, has := promotions[chunk.Sequence]
+			if !has || !chunk.Inline {
+				chunks = append(chunks, chunk)
+				continue
+			}
+
+			sort.Sort(promotedChunks)
+			for _, promotedChunk := range promotedChunks {
+				shadow := *promotedChunk
+				chunks = append(chunks, &shadow)
+			}
+		}
+		for i, chunk := range chunks {
+			chunk.Sequence = uint32(i)
+		}
+		current.Chunks = chunks
+
+		return true, nil
+	}); err != nil {
+		d.drop(created)
+		return err
+	}
+
+	return nil
+}
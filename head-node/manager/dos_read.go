@@ -16,7 +16,7 @@ func (d *dos) Read(paths []string, join bool) (ReadContainer, error) {
 	if len(paths) == 1 {
 		folder, err := d.folder(paths[0])
 		if err == nil {
-			return newReadContainerForFolder(folder, d.tree), nil
+			return newReadContainerForFolder(folder, d.tree, d.archive), nil
 		}
 
 		if err != os.ErrNotExist {
@@ -2,6 +2,7 @@ package manager
 
 import (
 	"io"
+	"os"
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
 )
@@ -18,23 +19,31 @@ type ReadContainer interface {
 
 	Folder() *common.Folder
 	Tree() (*common.Tree, error)
+	// Archive streams the folder subtree as an archive and returns the skipped (locked, zombie or unreadable) file paths
+	Archive(w io.Writer, archiveType ArchiveType) ([]string, error)
 	File() *common.File
 
 	Read(w io.Writer, begins int64, ends int64) error
 }
 
 type readContainer struct {
-	folder      *common.Folder
-	treeHandler func(folderPath string) (*common.Tree, error)
+	folder         *common.Folder
+	treeHandler    func(folderPath string) (*common.Tree, error)
+	archiveHandler func(folderPath string, archiveType ArchiveType, w io.Writer) ([]string, error)
 
 	file          *common.File
 	streamHandler func(w io.Writer, begins int64, ends int64) error
 }
 
-func newReadContainerForFolder(folder *common.Folder, treeHandler func(folderPath string) (*common.Tree, error)) ReadContainer {
+func newReadContainerForFolder(
+	folder *common.Folder,
+	treeHandler func(folderPath string) (*common.Tree, error),
+	archiveHandler func(folderPath string, archiveType ArchiveType, w io.Writer) ([]string, error),
+) ReadContainer {
 	return &readContainer{
-		folder:      folder,
-		treeHandler: treeHandler,
+		folder:         folder,
+		treeHandler:    treeHandler,
+		archiveHandler: archiveHandler,
 	}
 }
 
@@ -63,6 +72,13 @@ func (r *readContainer) Tree() (*common.Tree, error) {
 	return r.treeHandler(r.folder.Full)
 }
 
+func (r *readContainer) Archive(w io.Writer, archiveType ArchiveType) ([]string, error) {
+	if r.file != nil {
+		return nil, os.ErrInvalid
+	}
+	return r.archiveHandler(r.folder.Full, archiveType, w)
+}
+
 func (r *readContainer) File() *common.File {
 	return r.file
 }
@@ -0,0 +1,19 @@
+package manager
+
+import (
+	"os"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+)
+
+func (d *dos) Search(query *common.SearchQuery) (*common.SearchResult, error) {
+	if err := query.Validate(); err != nil {
+		return nil, os.ErrInvalid
+	}
+
+	if _, err := d.folder(query.Root); err != nil {
+		return nil, err
+	}
+
+	return d.metadata.Search(query)
+}
@@ -0,0 +1,416 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/head-node/data"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// testMetadata keeps the folders in the memory. Handlers work on the copies of the folders and the copies replace
+// the stored folders only when the handler accepts the change like the mongo implementation does
+type testMetadata struct {
+	data.Metadata
+
+	mutex   sync.Mutex
+	folders map[string]*common.Folder
+	saves   int
+
+	// beforeSave is called before the handler of SaveBlock, it is used to simulate the concurrent changes
+	beforeSave func()
+}
+
+func newTestMetadata() *testMetadata {
+	return &testMetadata{
+		folders: map[string]*common.Folder{"/": common.NewFolder("/")},
+	}
+}
+
+func (m *testMetadata) Get(folderPaths []string) ([]*common.Folder, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	folders := make([]*common.Folder, 0)
+	for _, folderPath := range folderPaths {
+		folder, has := m.folders[folderPath]
+		if !has {
+			return nil, os.ErrNotExist
+		}
+		folders = append(folders, cloneTestFolder(folder))
+	}
+	return folders, nil
+}
+
+func (m *testMetadata) ChildrenTree(folderPath string, includeItself bool, reverseSort bool) ([]*common.Folder, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, has := m.folders[folderPath]; !has {
+		return nil, os.ErrNotExist
+	}
+
+	prefix := folderPath
+	if strings.Compare(prefix, "/") != 0 {
+		prefix += "/"
+	}
+
+	folders := make([]*common.Folder, 0)
+	for full, folder := range m.folders {
+		if strings.Compare(full, folderPath) == 0 {
+			if includeItself {
+				folders = append(folders, cloneTestFolder(folder))
+			}
+			continue
+		}
+		if strings.HasPrefix(full, prefix) {
+			folders = append(folders, cloneTestFolder(folder))
+		}
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		if reverseSort {
+			return strings.Compare(folders[i].Full, folders[j].Full) > 0
+		}
+		return strings.Compare(folders[i].Full, folders[j].Full) < 0
+	})
+
+	return folders, nil
+}
+
+func (m *testMetadata) ParentTree(folderPath string, includeItself bool, reverseSort bool) ([]*common.Folder, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	folders := make([]*common.Folder, 0)
+	for full, folder := range m.folders {
+		if strings.Compare(full, folderPath) == 0 {
+			if includeItself {
+				folders = append(folders, cloneTestFolder(folder))
+			}
+			continue
+		}
+		if strings.Compare(full, "/") == 0 || strings.HasPrefix(folderPath, full+"/") {
+			folders = append(folders, cloneTestFolder(folder))
+		}
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		if reverseSort {
+			return strings.Compare(folders[i].Full, folders[j].Full) > 0
+		}
+		return strings.Compare(folders[i].Full, folders[j].Full) < 0
+	})
+
+	return folders, nil
+}
+
+func (m *testMetadata) SaveBlock(folderPaths []string, saveHandler func(folders map[string]*common.Folder) (bool, error)) error {
+	if m.beforeSave != nil {
+		m.beforeSave()
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	folders := make(map[string]*common.Folder)
+	for _, folderPath := range folderPaths {
+		folder, has := m.folders[folderPath]
+		if !has {
+			folders[folderPath] = nil
+			continue
+		}
+		folders[folderPath] = cloneTestFolder(folder)
+	}
+
+	save, err := saveHandler(folders)
+	if save {
+		m.store(folders)
+	}
+	return err
+}
+
+func (m *testMetadata) SaveChain(folderPath string, saveHandler func(folder *common.Folder) (bool, error)) error {
+	folder, err := m.chain(folderPath)
+	if err != nil {
+		return err
+	}
+
+	save, err := saveHandler(folder)
+	if save {
+		m.mutex.Lock()
+		m.store(map[string]*common.Folder{folder.Full: folder})
+		m.mutex.Unlock()
+	}
+	return err
+}
+
+// chain creates the missing folders in the path and returns the copy of the last one
+func (m *testMetadata) chain(folderPath string) (*common.Folder, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	folders := make(map[string]*common.Folder)
+
+	current := m.folders["/"]
+	for _, name := range strings.Split(strings.Trim(folderPath, "/"), "/") {
+		if len(name) == 0 {
+			continue
+		}
+
+		child := common.Join(current.Full, name)
+		if next, has := m.folders[child]; has {
+			current = next
+			continue
+		}
+
+		parent := cloneTestFolder(current)
+		next, err := parent.NewFolder(name)
+		if err != nil {
+			return nil, err
+		}
+		folders[parent.Full] = parent
+		folders[next.Full] = next
+
+		m.store(folders)
+		current = next
+	}
+
+	return cloneTestFolder(current), nil
+}
+
+func (m *testMetadata) store(folders map[string]*common.Folder) {
+	for folderPath, folder := range folders {
+		if folder == nil {
+			delete(m.folders, folderPath)
+			continue
+		}
+		m.folders[folderPath] = folder
+	}
+	m.saves++
+}
+
+// file returns the stored copy of the file in the path, nil if it does not exist
+func (m *testMetadata) file(path string) *common.File {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	folderPath, filename := common.Split(path)
+	folder, has := m.folders[folderPath]
+	if !has {
+		return nil
+	}
+	return folder.File(filename)
+}
+
+func cloneTestFolder(folder *common.Folder) *common.Folder {
+	clone := *folder
+	folder.CloneInto(&clone)
+
+	for i, file := range clone.Files {
+		clone.Files[i].Chunks = make(common.DataChunks, 0, len(file.Chunks))
+		for _, chunk := range folder.Files[i].Chunks {
+			shadow := *chunk
+			clone.Files[i].Chunks = append(clone.Files[i].Chunks, &shadow)
+		}
+	}
+
+	return &clone
+}
+
+// testCluster keeps the chunks in the memory with their usages. Every created content is placed in chunks of
+// chunkSize bytes
+type testCluster struct {
+	Cluster
+
+	chunkSize int
+
+	mutex   sync.Mutex
+	blocks  map[string][]byte
+	usages  map[string]int
+	reads   int
+	deleted []string
+
+	// createErr fails the creation of the content that has the same size
+	createErr map[uint64]error
+}
+
+func newTestCluster(chunkSize int) *testCluster {
+	return &testCluster{
+		chunkSize: chunkSize,
+		blocks:    make(map[string][]byte),
+		usages:    make(map[string]int),
+		deleted:   make([]string, 0),
+		createErr: make(map[uint64]error),
+	}
+}
+
+func (c *testCluster) Create(size uint64, reader io.Reader) (*common.CreationResult, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err, has := c.createErr[size]; has {
+		return nil, err
+	}
+
+	content := make([]byte, size)
+	if _, err := io.ReadFull(reader, content); err != nil {
+		return nil, err
+	}
+
+	checksum := sha512.New512_256()
+	_, _ = checksum.Write(content)
+
+	chunks := make(common.DataChunks, 0)
+	for begins := 0; begins < len(content); begins += c.chunkSize {
+		ends := begins + c.chunkSize
+		if ends > len(content) {
+			ends = len(content)
+		}
+		chunks = append(chunks, c.place(uint32(len(chunks)), content[begins:ends]))
+	}
+
+	return common.NewCreationResult(hex.EncodeToString(checksum.Sum(nil)), chunks), nil
+}
+
+func (c *testCluster) CreateBatch(sizes []uint64, readers []io.Reader) ([]*common.CreationResult, []error, error) {
+	creationResults := make([]*common.CreationResult, len(sizes))
+	creationErrors := make([]error, len(sizes))
+	for i, size := range sizes {
+		creationResults[i], creationErrors[i] = c.Create(size, readers[i])
+	}
+	return creationResults, creationErrors, nil
+}
+
+func (c *testCluster) CreateShadow(chunks common.DataChunks) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	chunks = chunks.Remote()
+	for _, chunk := range chunks {
+		if _, has := c.blocks[chunk.Hash]; !has {
+			return os.ErrNotExist
+		}
+	}
+	for _, chunk := range chunks {
+		c.usages[chunk.Hash]++
+	}
+	return nil
+}
+
+func (c *testCluster) Read(chunks common.DataChunks) (func(w io.Writer, begins int64, ends int64) error, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.reads++
+
+	sorted := make(common.DataChunks, len(chunks))
+	copy(sorted, chunks)
+	sort.Sort(sorted)
+
+	content := &bytes.Buffer{}
+	for _, chunk := range sorted {
+		if chunk.Inline {
+			content.Write(chunk.Data)
+			continue
+		}
+
+		block, has := c.blocks[chunk.Hash]
+		if !has {
+			return nil, os.ErrNotExist
+		}
+		content.Write(block)
+	}
+
+	return func(w io.Writer, begins int64, ends int64) error {
+		data := content.Bytes()
+		if ends > -1 {
+			data = data[:ends+1]
+		}
+		_, err := w.Write(data[begins:])
+		return err
+	}, nil
+}
+
+func (c *testCluster) Delete(chunks common.DataChunks) (*common.DeletionResult, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	deletionResult := common.NewDeletionResult()
+	for _, chunk := range chunks {
+		c.deleted = append(c.deleted, chunk.Hash)
+
+		if _, has := c.blocks[chunk.Hash]; !has {
+			deletionResult.Missing = append(deletionResult.Missing, chunk.Hash)
+			continue
+		}
+
+		c.usages[chunk.Hash]--
+		if c.usages[chunk.Hash] == 0 {
+			delete(c.blocks, chunk.Hash)
+			delete(c.usages, chunk.Hash)
+		}
+		deletionResult.Deleted = append(deletionResult.Deleted, chunk.Hash)
+	}
+	return &deletionResult, nil
+}
+
+func (c *testCluster) place(sequence uint32, data []byte) *common.DataChunk {
+	sum := sha512.Sum512_256(data)
+	sha512Hex := hex.EncodeToString(sum[:])
+
+	if _, has := c.blocks[sha512Hex]; !has {
+		c.blocks[sha512Hex] = append([]byte{}, data...)
+	}
+	c.usages[sha512Hex]++
+
+	return common.NewDataChunk(sequence, uint32(len(data)), sha512Hex)
+}
+
+// usage returns the usage of the chunk, 0 if it does not exist
+func (c *testCluster) usage(sha512Hex string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.usages[sha512Hex]
+}
+
+// newTestDos creates the dos on the memory metadata and the cluster that places the content in 4 bytes chunks
+func newTestDos() (*dos, *testMetadata, *testCluster) {
+	metadata := newTestMetadata()
+	cluster := newTestCluster(4)
+
+	d := &dos{
+		metadata: metadata,
+		cluster:  cluster,
+		logger:   zap.NewNop(),
+	}
+
+	return d, metadata, cluster
+}
+
+func createTestFile(t *testing.T, d *dos, path string, content string) {
+	assert.Nil(t, d.CreateFile(path, "text/plain", uint64(len(content)), false, strings.NewReader(content)))
+}
+
+// readTestFile reads the whole content of the file in the path
+func readTestFile(t *testing.T, d *dos, path string) string {
+	file, streamHandler, err := d.file([]string{path})
+	assert.Nil(t, err)
+	if err != nil {
+		return ""
+	}
+
+	content := &bytes.Buffer{}
+	assert.Nil(t, streamHandler(content, 0, -1))
+	assert.Equal(t, uint64(content.Len()), file.Size)
+
+	return content.String()
+}
@@ -0,0 +1,146 @@
+package manager
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+)
+
+const defaultPresignDuration = time.Hour
+const maxPresignDuration = time.Hour * 24 * 7
+
+// Presign interface is to issue and verify HMAC signed urls that grant temporary access to a single path
+type Presign interface {
+	// Sign creates the signed query of the grant. Expires is the life time of the url
+	Sign(grant *PresignGrant, expires time.Duration) (url.Values, error)
+	// Verify validates the signed query and returns the grant that it carries
+	Verify(query url.Values) (*PresignGrant, error)
+}
+
+// PresignGrant struct is to hold the scope of the signed url
+// Method is GET for download and POST for upload
+// ContentLength and ContentType are optional upload constraints
+type PresignGrant struct {
+	Path          string
+	Method        string
+	Expires       time.Time
+	ContentLength *int64
+	ContentType   string
+	Overwrite     bool
+}
+
+type presign struct {
+	secret []byte
+}
+
+// NewPresign creates the signed url issuer and verifier with the secret
+func NewPresign(secret string) (Presign, error) {
+	if len(secret) == 0 {
+		return nil, os.ErrInvalid
+	}
+
+	return &presign{
+		secret: []byte(secret),
+	}, nil
+}
+
+func (p *presign) Sign(grant *PresignGrant, expires time.Duration) (url.Values, error) {
+	if !common.ValidatePath(grant.Path) {
+		return nil, os.ErrInvalid
+	}
+	grant.Path = common.CorrectPath(grant.Path)
+
+	switch grant.Method {
+	case http.MethodGet, http.MethodPost:
+	default:
+		return nil, os.ErrInvalid
+	}
+
+	if expires <= 0 {
+		expires = defaultPresignDuration
+	}
+	if expires > maxPresignDuration {
+		return nil, os.ErrInvalid
+	}
+	grant.Expires = time.Now().UTC().Add(expires).Truncate(time.Second)
+
+	query := url.Values{}
+	query.Set("path", grant.Path)
+	query.Set("method", grant.Method)
+	query.Set("expires", strconv.FormatInt(grant.Expires.Unix(), 10))
+	if grant.ContentLength != nil {
+		query.Set("length", strconv.FormatInt(*grant.ContentLength, 10))
+	}
+	if len(grant.ContentType) > 0 {
+		query.Set("type", grant.ContentType)
+	}
+	if grant.Overwrite {
+		query.Set("overwrite", "true")
+	}
+	query.Set("signature", p.signature(query))
+
+	return query, nil
+}
+
+func (p *presign) Verify(query url.Values) (*PresignGrant, error) {
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil || len(signature) == 0 {
+		return nil, os.ErrPermission
+	}
+
+	expected, _ := hex.DecodeString(p.signature(query))
+	if !hmac.Equal(signature, expected) {
+		return nil, os.ErrPermission
+	}
+
+	expiresUnix, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return nil, os.ErrPermission
+	}
+
+	grant := &PresignGrant{
+		Path:        query.Get("path"),
+		Method:      query.Get("method"),
+		Expires:     time.Unix(expiresUnix, 0).UTC(),
+		ContentType: query.Get("type"),
+		Overwrite:   strings.Compare(query.Get("overwrite"), "true") == 0,
+	}
+	if time.Now().UTC().After(grant.Expires) {
+		return nil, os.ErrDeadlineExceeded
+	}
+
+	if length := query.Get("length"); len(length) > 0 {
+		contentLength, err := strconv.ParseInt(length, 10, 64)
+		if err != nil {
+			return nil, os.ErrPermission
+		}
+		grant.ContentLength = &contentLength
+	}
+
+	return grant, nil
+}
+
+func (p *presign) signature(query url.Values) string {
+	canonical := strings.Join([]string{
+		query.Get("method"),
+		query.Get("path"),
+		query.Get("expires"),
+		query.Get("length"),
+		query.Get("type"),
+		query.Get("overwrite"),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, p.secret)
+	_, _ = mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var _ Presign = &presign{}
@@ -0,0 +1,92 @@
+package manager
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresign_SignVerify(t *testing.T) {
+	p, err := NewPresign("secret")
+	assert.Nil(t, err)
+
+	contentLength := int64(12)
+	query, err := p.Sign(&PresignGrant{
+		Path:          "/folder/../folder/file.txt",
+		Method:        http.MethodPost,
+		ContentLength: &contentLength,
+		ContentType:   "text/plain",
+		Overwrite:     true,
+	}, time.Minute)
+	assert.Nil(t, err)
+
+	grant, err := p.Verify(query)
+	assert.Nil(t, err)
+	assert.Equal(t, "/folder/file.txt", grant.Path)
+	assert.Equal(t, http.MethodPost, grant.Method)
+	assert.Equal(t, contentLength, *grant.ContentLength)
+	assert.Equal(t, "text/plain", grant.ContentType)
+	assert.True(t, grant.Overwrite)
+	assert.True(t, grant.Expires.After(time.Now()))
+}
+
+func TestPresign_Signature(t *testing.T) {
+	_, err := NewPresign("")
+	assert.Equal(t, os.ErrInvalid, err)
+
+	p, _ := NewPresign("secret")
+
+	for _, tamper := range []func(query url.Values){
+		func(query url.Values) { query.Set("path", "/other.txt") },
+		func(query url.Values) { query.Set("method", http.MethodPost) },
+		func(query url.Values) { query.Set("overwrite", "true") },
+		func(query url.Values) { query.Set("length", "1") },
+		func(query url.Values) { query.Set("type", "text/html") },
+		func(query url.Values) { query.Set("signature", "not-hex") },
+		func(query url.Values) { query.Del("signature") },
+	} {
+		query, err := p.Sign(&PresignGrant{Path: "/file.txt", Method: http.MethodGet}, 0)
+		assert.Nil(t, err)
+		tamper(query)
+
+		_, err = p.Verify(query)
+		assert.Equal(t, os.ErrPermission, err)
+	}
+
+	other, _ := NewPresign("other secret")
+	query, _ := p.Sign(&PresignGrant{Path: "/file.txt", Method: http.MethodGet}, 0)
+	_, err = other.Verify(query)
+	assert.Equal(t, os.ErrPermission, err)
+}
+
+func TestPresign_Expiry(t *testing.T) {
+	p, _ := NewPresign("secret")
+
+	query, err := p.Sign(&PresignGrant{Path: "/file.txt", Method: http.MethodGet}, 0)
+	assert.Nil(t, err)
+	expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	assert.InDelta(t, time.Now().Add(defaultPresignDuration).Unix(), expires, 2)
+
+	_, err = p.Sign(&PresignGrant{Path: "/file.txt", Method: http.MethodGet}, maxPresignDuration+time.Second)
+	assert.Equal(t, os.ErrInvalid, err)
+
+	query.Set("expires", strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10))
+	query.Set("signature", p.(*presign).signature(query))
+	_, err = p.Verify(query)
+	assert.Equal(t, os.ErrDeadlineExceeded, err)
+}
+
+func TestPresign_SignInvalid(t *testing.T) {
+	p, _ := NewPresign("secret")
+
+	_, err := p.Sign(&PresignGrant{Path: "/file.txt", Method: http.MethodDelete}, 0)
+	assert.Equal(t, os.ErrInvalid, err)
+
+	_, err = p.Sign(&PresignGrant{Path: "relative.txt", Method: http.MethodGet}, 0)
+	assert.Equal(t, os.ErrInvalid, err)
+}
@@ -12,15 +12,18 @@ import (
 )
 
 type dosRouter struct {
-	dos    manager.Dos
-	logger *zap.Logger
+	dos     manager.Dos
+	presign manager.Presign
+	logger  *zap.Logger
 
 	definitions []*Definition
 }
 
-func NewDosRouter(dos manager.Dos, logger *zap.Logger) Router {
+// NewDosRouter creates the file storage manipulation router. presign can be nil if signed urls are disabled
+func NewDosRouter(dos manager.Dos, presign manager.Presign, logger *zap.Logger) Router {
 	pR := &dosRouter{
 		dos:         dos,
+		presign:     presign,
 		logger:      logger,
 		definitions: make([]*Definition, 0),
 	}
@@ -46,6 +49,13 @@ func (d *dosRouter) Get() []*Definition {
 func (d *dosRouter) manipulate(w http.ResponseWriter, r *http.Request) {
 	defer func() { _ = r.Body.Close() }()
 
+	if d.presign != nil && r.URL.Query().Has("signature") {
+		if !d.applyPresign(r) {
+			w.WriteHeader(403)
+			return
+		}
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		d.handleGet(w, r)
@@ -62,7 +72,7 @@ func (d *dosRouter) manipulate(w http.ResponseWriter, r *http.Request) {
 
 func (d *dosRouter) validateApplyTo(applyTo string) bool {
 	switch applyTo {
-	case "folder", "file":
+	case "folder", "file", "archive":
 		return true
 	}
 	return false
@@ -38,6 +38,9 @@ func (d *dosRouter) handleGet(w http.ResponseWriter, r *http.Request) {
 		} else if err == errors.ErrZombie {
 			w.WriteHeader(524)
 			return
+		} else if err == errors.ErrTooLarge {
+			w.WriteHeader(413)
+			return
 		} else {
 			w.WriteHeader(500)
 		}
@@ -48,6 +51,11 @@ func (d *dosRouter) handleGet(w http.ResponseWriter, r *http.Request) {
 	if read.Type() == manager.RTFolder {
 		w.Header().Set("X-Type", "folder")
 
+		if archiveHeader := r.Header.Get("X-Archive"); len(archiveHeader) > 0 {
+			d.handleArchive(w, read, archiveHeader)
+			return
+		}
+
 		calculateUsageHeader := strings.ToLower(r.Header.Get("X-Calculate-Usage"))
 		calculateUsage := len(calculateUsageHeader) > 0 && (strings.Compare(calculateUsageHeader, "1") == 0 || strings.Compare(calculateUsageHeader, "true") == 0)
 
@@ -106,68 +114,28 @@ func (d *dosRouter) handleGet(w http.ResponseWriter, r *http.Request) {
 	downloadHeader := strings.ToLower(r.Header.Get("X-Download"))
 	download := len(downloadHeader) > 0 && (strings.Compare(downloadHeader, "1") == 0 || strings.Compare(downloadHeader, "true") == 0)
 
-	requestRange := r.Header.Get("Range")
-	partialRequest := len(requestRange) > 0
+	file := read.File()
 
-	push, begins, ends := d.prepareResponseHeaders(w, read.File(), download, partialRequest, requestRange)
-	if !push {
+	ranges, err := d.describeRanges(r, file)
+	if err != nil {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", file.Size))
+		w.WriteHeader(416)
 		return
 	}
 
-	if err := read.Read(w, begins, ends); err != nil {
-		d.logger.Warn(
-			"Streaming file content is failed",
-			zap.Strings("paths", requestedPaths),
-			zap.Int64("begins", begins),
-			zap.Int64("ends", ends),
-			zap.Error(err),
-		)
-	}
-}
-
-func (d *dosRouter) prepareResponseHeaders(w http.ResponseWriter, file *common.File, download bool, partialRequest bool, requestRange string) (bool, int64, int64) {
-	w.Header().Set("Content-Type", file.Mime)
-
-	begins, ends := int64(0), int64(file.Size)-1
+	d.prepareResponseHeaders(w, file, download)
 
-	if !partialRequest {
-		w.Header().Set("Accept-Ranges", "bytes")
+	switch len(ranges) {
+	case 0:
 		w.Header().Set("Content-Length", strconv.FormatUint(file.Size, 10))
-		if download {
-			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", file.Name))
-		}
 		w.Header().Set("X-Checksum", file.Checksum)
-		return true, begins, ends
-	}
-
-	bytesTag := "bytes="
-	bytesIdx := strings.Index(requestRange, bytesTag)
-	if bytesIdx == 0 {
-		ranges := requestRange[bytesIdx+len(bytesTag):]
-		byteRanges := strings.Split(ranges, "-")
-
-		var err error
-		begins, err = strconv.ParseInt(byteRanges[0], 10, 64)
-		if err != nil {
-			w.WriteHeader(416)
-			return false, 0, 0
-		}
-		ends, err = strconv.ParseInt(byteRanges[1], 10, 64)
-		if err != nil {
-			ends = int64(file.Size) - 1
-		}
-
-		if ends > int64(file.Size)-1 || begins > ends {
-			w.WriteHeader(416)
-			return false, 0, 0
-		}
-
-		w.Header().Set("Content-Length", strconv.FormatInt(ends-begins+1, 10))
-		w.Header().Set("Content-Encoding", "identity")
-		w.Header().Set("Content-Range",
-			fmt.Sprintf("bytes %d-%d/%d", begins, ends, file.Size))
+		d.streamRange(w, read, requestedPaths, 0, int64(file.Size)-1)
+	case 1:
+		w.Header().Set("Content-Length", strconv.FormatInt(ranges[0].Length(), 10))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", ranges[0].Begins, ranges[0].Ends, file.Size))
 		w.WriteHeader(206)
+		d.streamRange(w, read, requestedPaths, ranges[0].Begins, ranges[0].Ends)
+	default:
+		d.streamMultipartRanges(w, read, requestedPaths, ranges)
 	}
-
-	return true, begins, ends
 }
@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"go.uber.org/zap"
+)
+
+func (d *dosRouter) handleArchive(w http.ResponseWriter, read manager.ReadContainer, archiveHeader string) {
+	archiveType, err := manager.ParseArchiveType(archiveHeader)
+	if err != nil {
+		w.WriteHeader(422)
+		return
+	}
+
+	folder := read.Folder()
+
+	name := folder.Name
+	if len(name) == 0 {
+		name = "root"
+	}
+
+	w.Header().Set("Content-Type", archiveType.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s%s\"", name, archiveType.Extension()))
+	// skipped files and failures can only be known after streaming, so they are reported in trailers
+	w.Header().Set("Trailer", "X-Skipped, X-Skipped-Paths, X-Archive-Error")
+
+	aw := &archiveWriter{w: w}
+	skipped, err := read.Archive(aw, archiveType)
+	if err != nil {
+		if aw.written {
+			// the status is already sent with the streamed content, failure can only be reported in the trailer
+			w.Header().Set("X-Archive-Error", url.QueryEscape(err.Error()))
+		} else {
+			w.WriteHeader(500)
+		}
+		d.logger.Error(
+			"Streaming folder archive is failed",
+			zap.String("path", folder.Full),
+			zap.String("archive", archiveHeader),
+			zap.Error(err),
+		)
+		return
+	}
+
+	if len(skipped) > 0 {
+		paths := skippedPaths(skipped)
+
+		w.Header().Set("X-Skipped", strconv.Itoa(len(skipped)))
+		w.Header().Set("X-Skipped-Paths", paths)
+
+		d.logger.Warn(
+			"Locked, zombie or unreadable files are skipped in folder archive",
+			zap.String("path", folder.Full),
+			zap.Int("skipped", len(skipped)),
+			zap.String("paths", paths),
+		)
+	}
+}
+
+// skippedPathsLimit is the maximum count of the paths in the skipped paths trailer,
+// the total count is always reported in X-Skipped
+const skippedPathsLimit = 100
+
+// skippedPaths creates the comma separated and url encoded list of the first skipped paths
+func skippedPaths(skipped []string) string {
+	if len(skipped) > skippedPathsLimit {
+		skipped = skipped[:skippedPathsLimit]
+	}
+
+	escaped := make([]string, 0, len(skipped))
+	for _, path := range skipped {
+		escaped = append(escaped, url.QueryEscape(path))
+	}
+	return strings.Join(escaped, ",")
+}
+
+// archiveWriter keeps if the response body is started to be streamed
+type archiveWriter struct {
+	w       http.ResponseWriter
+	written bool
+}
+
+func (a *archiveWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		a.written = true
+	}
+	return a.w.Write(p)
+}
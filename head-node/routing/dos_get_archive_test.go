@@ -0,0 +1,23 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkippedPaths(t *testing.T) {
+	assert.Equal(t, "%2Fa+b%2Fc.txt,%2Fd.txt", skippedPaths([]string{"/a b/c.txt", "/d.txt"}))
+
+	skipped := make([]string, 0)
+	for i := 0; i < skippedPathsLimit*3; i++ {
+		skipped = append(skipped, fmt.Sprintf("/%d", i))
+	}
+
+	paths := strings.Split(skippedPaths(skipped), ",")
+	assert.Len(t, paths, skippedPathsLimit)
+	assert.Equal(t, "%2F0", paths[0])
+	assert.Equal(t, fmt.Sprintf("%%2F%d", skippedPathsLimit-1), paths[skippedPathsLimit-1])
+}
@@ -0,0 +1,101 @@
+package routing
+
+import (
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"go.uber.org/zap"
+)
+
+func (d *dosRouter) prepareResponseHeaders(w http.ResponseWriter, file *common.File, download bool) {
+	w.Header().Set("Content-Type", file.Mime)
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("ETag", file.ETag())
+	w.Header().Set("Last-Modified", file.Modified.UTC().Format(http.TimeFormat))
+	if download {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", file.Name))
+	}
+}
+
+// describeRanges returns the requested byte ranges. Empty result means the whole file should be served
+// because of the absence of the Range header, the unmatched If-Range condition or the ignorable Range value
+func (d *dosRouter) describeRanges(r *http.Request, file *common.File) ([]common.ReadRange, error) {
+	requestRange := r.Header.Get("Range")
+	if len(requestRange) == 0 || !d.matchIfRange(r.Header.Get("If-Range"), file) {
+		return nil, nil
+	}
+	return common.ParseByteRanges(requestRange, file.Size)
+}
+
+func (d *dosRouter) matchIfRange(ifRange string, file *common.File) bool {
+	ifRange = strings.TrimSpace(ifRange)
+	if len(ifRange) == 0 {
+		return true
+	}
+
+	// weak entity tags can not be used with If-Range
+	if strings.HasPrefix(ifRange, "W/") {
+		return false
+	}
+	if strings.HasPrefix(ifRange, "\"") {
+		return strings.Compare(ifRange, file.ETag()) == 0
+	}
+
+	modified, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	return file.Modified.UTC().Truncate(time.Second).Equal(modified.UTC())
+}
+
+func (d *dosRouter) streamRange(w http.ResponseWriter, read manager.ReadContainer, requestedPaths []string, begins int64, ends int64) {
+	if err := read.Read(w, begins, ends); err != nil {
+		d.logger.Warn(
+			"Streaming file content is failed",
+			zap.Strings("paths", requestedPaths),
+			zap.Int64("begins", begins),
+			zap.Int64("ends", ends),
+			zap.Error(err),
+		)
+	}
+}
+
+func (d *dosRouter) streamMultipartRanges(w http.ResponseWriter, read manager.ReadContainer, requestedPaths []string, ranges []common.ReadRange) {
+	file := read.File()
+
+	mw := multipart.NewWriter(w)
+	w.Header().Set("Content-Type", fmt.Sprintf("multipart/byteranges; boundary=%s", mw.Boundary()))
+	w.WriteHeader(206)
+
+	for _, readRange := range ranges {
+		partWriter, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {file.Mime},
+			"Content-Range": {fmt.Sprintf("bytes %d-%d/%d", readRange.Begins, readRange.Ends, file.Size)},
+		})
+		if err != nil {
+			d.logger.Warn("Creating multipart range is failed", zap.Strings("paths", requestedPaths), zap.Error(err))
+			return
+		}
+
+		if err := read.Read(partWriter, readRange.Begins, readRange.Ends); err != nil {
+			d.logger.Warn(
+				"Streaming file content is failed",
+				zap.Strings("paths", requestedPaths),
+				zap.Int64("begins", readRange.Begins),
+				zap.Int64("ends", readRange.Ends),
+				zap.Error(err),
+			)
+			return
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		d.logger.Warn("Closing multipart ranges is failed", zap.Strings("paths", requestedPaths), zap.Error(err))
+	}
+}
@@ -1,6 +1,7 @@
 package routing
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
@@ -57,7 +58,24 @@ func (d *dosRouter) handlePost(w http.ResponseWriter, r *http.Request) {
 		overwriteHeader := strings.ToLower(r.Header.Get("X-Overwrite"))
 		overwrite := len(overwriteHeader) > 0 && (strings.Compare(overwriteHeader, "1") == 0 || strings.Compare(overwriteHeader, "true") == 0)
 
-		if err := d.dos.CreateFile(requestedPaths[0], contentType, uint64(contentLength), overwrite, r.Body); err != nil {
+		appendHeader := strings.ToLower(r.Header.Get("X-Append"))
+		appendContent := len(appendHeader) > 0 && (strings.Compare(appendHeader, "1") == 0 || strings.Compare(appendHeader, "true") == 0)
+
+		compactHeader := strings.ToLower(r.Header.Get("X-Compact"))
+		compact := len(compactHeader) > 0 && (strings.Compare(compactHeader, "1") == 0 || strings.Compare(compactHeader, "true") == 0)
+
+		operation := "Create"
+		createHandler := func() error {
+			return d.dos.CreateFile(requestedPaths[0], contentType, uint64(contentLength), overwrite, r.Body)
+		}
+		if appendContent {
+			operation = "Append"
+			createHandler = func() error {
+				return d.dos.AppendFile(requestedPaths[0], contentType, uint64(contentLength), compact, r.Body)
+			}
+		}
+
+		if err := createHandler(); err != nil {
 			if err == os.ErrExist {
 				w.WriteHeader(409)
 				return
@@ -70,17 +88,29 @@ func (d *dosRouter) handlePost(w http.ResponseWriter, r *http.Request) {
 			} else if err == errors.ErrNoSpace {
 				w.WriteHeader(507)
 				return
+			} else if err == errors.ErrTooLarge {
+				w.WriteHeader(413)
+				return
+			} else if err == errors.ErrLock {
+				w.WriteHeader(523)
+				return
+			} else if err == errors.ErrZombie {
+				w.WriteHeader(524)
+				return
 			} else {
 				w.WriteHeader(500)
 			}
 			d.logger.Error(
-				"Create file request is failed",
+				fmt.Sprintf("%s file request is failed", operation),
 				zap.String("path", requestedPaths[0]),
 				zap.String("applyTo", applyTo),
 				zap.Error(err),
 			)
 			return
 		}
+	case "archive":
+		d.handleExtract(w, r, requestedPaths[0])
+		return
 	}
 
 	w.WriteHeader(202)
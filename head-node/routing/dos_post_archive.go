@@ -0,0 +1,50 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"go.uber.org/zap"
+)
+
+func (d *dosRouter) handleExtract(w http.ResponseWriter, r *http.Request, folderPath string) {
+	archiveType, err := manager.ParseArchiveType(r.Header.Get("X-Archive"))
+	if err != nil {
+		w.WriteHeader(422)
+		return
+	}
+
+	overwriteHeader := strings.ToLower(r.Header.Get("X-Overwrite"))
+	overwrite := len(overwriteHeader) > 0 && (strings.Compare(overwriteHeader, "1") == 0 || strings.Compare(overwriteHeader, "true") == 0)
+
+	result, err := d.dos.Extract(folderPath, archiveType, overwrite, r.Body)
+	if err != nil {
+		if err == os.ErrInvalid {
+			w.WriteHeader(422)
+			return
+		} else if err == errors.ErrRepair {
+			w.WriteHeader(526)
+			return
+		}
+		w.WriteHeader(500)
+		d.logger.Error(
+			"Extract archive request is failed",
+			zap.String("path", folderPath),
+			zap.Error(err),
+		)
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		w.WriteHeader(500)
+		d.logger.Error(
+			"Response of extract archive request is failed",
+			zap.String("path", folderPath),
+			zap.Error(err),
+		)
+	}
+}
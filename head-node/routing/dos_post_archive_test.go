@@ -0,0 +1,124 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// testExtractDos records the extraction request and replies with the prepared result or error
+type testExtractDos struct {
+	manager.Dos
+
+	folderPath  string
+	archiveType manager.ArchiveType
+	overwrite   bool
+	body        string
+
+	result *common.ExtractionResult
+	err    error
+}
+
+func (d *testExtractDos) Extract(folderPath string, archiveType manager.ArchiveType, overwrite bool, reader io.Reader) (*common.ExtractionResult, error) {
+	d.folderPath = folderPath
+	d.archiveType = archiveType
+	d.overwrite = overwrite
+
+	body, _ := io.ReadAll(reader)
+	d.body = string(body)
+
+	return d.result, d.err
+}
+
+func extractRequest(t *testing.T, dos manager.Dos, archive string, overwrite string) *httptest.ResponseRecorder {
+	router := NewDosRouter(dos, nil, zap.NewNop())
+
+	req := httptest.NewRequest(http.MethodPost, "/client/dos", strings.NewReader("archive content"))
+	req.Header.Set("X-Apply-To", "archive")
+	req.Header.Set("X-Path", "/target")
+	if len(archive) > 0 {
+		req.Header.Set("X-Archive", archive)
+	}
+	if len(overwrite) > 0 {
+		req.Header.Set("X-Overwrite", overwrite)
+	}
+
+	w := httptest.NewRecorder()
+	router.Get()[0].Handler(w, req)
+
+	if w.Code == http.StatusOK {
+		assert.Equal(t, "archive content", dos.(*testExtractDos).body)
+	}
+	return w
+}
+
+func TestDosRouter_Extract(t *testing.T) {
+	for archive, archiveType := range map[string]manager.ArchiveType{"tar": manager.ATTar, "zip": manager.ATZip, "tar.gz": manager.ATTarGz} {
+		result := common.NewExtractionResult()
+		result.Add("/target/a.txt", false, common.ESCreated, nil)
+
+		dos := &testExtractDos{result: result}
+		w := extractRequest(t, dos, archive, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "/target", dos.folderPath)
+		assert.Equal(t, archiveType, dos.archiveType)
+		assert.False(t, dos.overwrite)
+
+		response := common.ExtractionResult{}
+		assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 0, response.Failed)
+		assert.Len(t, response.Entries, 1)
+		assert.Equal(t, common.ESCreated, response.Entries[0].Status)
+	}
+
+	dos := &testExtractDos{result: common.NewExtractionResult()}
+	extractRequest(t, dos, "zip", "true")
+	assert.True(t, dos.overwrite)
+}
+
+func TestDosRouter_ExtractFailedEntries(t *testing.T) {
+	result := common.NewExtractionResult()
+	result.Add("../evil.txt", false, common.ESFailed, os.ErrInvalid)
+	result.Add("/target/a.txt", false, common.ESCreated, nil)
+	result.Add("/target/b.txt", false, common.ESFailed, fmt.Errorf("node is not reachable"))
+	result.Add("/target/c.txt", false, common.ESCreated, nil)
+
+	w := extractRequest(t, &testExtractDos{result: result}, "tar", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	response := common.ExtractionResult{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 2, response.Failed)
+	assert.Len(t, response.Entries, 4)
+	assert.Equal(t, "../evil.txt", response.Entries[0].Path)
+	assert.Equal(t, os.ErrInvalid.Error(), response.Entries[0].Error)
+	assert.Equal(t, "node is not reachable", response.Entries[2].Error)
+}
+
+func TestDosRouter_ExtractErrors(t *testing.T) {
+	w := extractRequest(t, &testExtractDos{}, "", "")
+	assert.Equal(t, 422, w.Code)
+
+	w = extractRequest(t, &testExtractDos{}, "rar", "")
+	assert.Equal(t, 422, w.Code)
+
+	w = extractRequest(t, &testExtractDos{err: os.ErrInvalid}, "zip", "")
+	assert.Equal(t, 422, w.Code)
+
+	w = extractRequest(t, &testExtractDos{err: errors.ErrRepair}, "tar", "")
+	assert.Equal(t, 526, w.Code)
+
+	w = extractRequest(t, &testExtractDos{err: fmt.Errorf("metadata is not reachable")}, "tar", "")
+	assert.Equal(t, 500, w.Code)
+}
@@ -0,0 +1,46 @@
+package routing
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// applyPresign verifies the signed url and replaces the request headers with the scope of the grant.
+// So the rest of the request handling works in the same way with the regular requests
+func (d *dosRouter) applyPresign(r *http.Request) bool {
+	grant, err := d.presign.Verify(r.URL.Query())
+	if err != nil {
+		d.logger.Warn("Signed url is rejected", zap.String("path", r.URL.Query().Get("path")), zap.Error(err))
+		return false
+	}
+
+	if strings.Compare(r.Method, grant.Method) != 0 {
+		return false
+	}
+
+	r.Header.Set("X-Path", url.QueryEscape(grant.Path))
+
+	if strings.Compare(grant.Method, http.MethodPost) != 0 {
+		return true
+	}
+
+	if len(grant.ContentType) > 0 && strings.Compare(r.Header.Get("Content-Type"), grant.ContentType) != 0 {
+		return false
+	}
+	if grant.ContentLength != nil && r.ContentLength != *grant.ContentLength {
+		return false
+	}
+
+	r.Header.Set("X-Apply-To", "file")
+	r.Header.Del("X-Overwrite")
+	r.Header.Del("X-Append")
+	r.Header.Del("X-Compact")
+	if grant.Overwrite {
+		r.Header.Set("X-Overwrite", "true")
+	}
+
+	return true
+}
@@ -0,0 +1,105 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func newPresignTestRouter(t *testing.T) (*dosRouter, manager.Presign) {
+	presign, err := manager.NewPresign("secret")
+	assert.Nil(t, err)
+
+	return NewDosRouter(nil, presign, zap.NewNop()).(*dosRouter), presign
+}
+
+func presignRequest(t *testing.T, presign manager.Presign, grant *manager.PresignGrant, method string, body string) *http.Request {
+	query, err := presign.Sign(grant, time.Minute)
+	assert.Nil(t, err)
+
+	return httptest.NewRequest(method, "/client/dos?"+query.Encode(), strings.NewReader(body))
+}
+
+func TestDosRouter_PresignDownload(t *testing.T) {
+	d, presign := newPresignTestRouter(t)
+
+	req := presignRequest(t, presign, &manager.PresignGrant{Path: "/folder/file.txt", Method: http.MethodGet}, http.MethodGet, "")
+	req.Header.Set("X-Path", url.QueryEscape("/other.txt"))
+	assert.True(t, d.applyPresign(req))
+	assert.Equal(t, url.QueryEscape("/folder/file.txt"), req.Header.Get("X-Path"))
+
+	req = presignRequest(t, presign, &manager.PresignGrant{Path: "/folder/file.txt", Method: http.MethodGet}, http.MethodDelete, "")
+	assert.False(t, d.applyPresign(req))
+
+	req = presignRequest(t, presign, &manager.PresignGrant{Path: "/folder/file.txt", Method: http.MethodGet}, http.MethodPost, "content")
+	assert.False(t, d.applyPresign(req))
+}
+
+func TestDosRouter_PresignUpload(t *testing.T) {
+	d, presign := newPresignTestRouter(t)
+
+	contentLength := int64(7)
+	grant := func() *manager.PresignGrant {
+		return &manager.PresignGrant{
+			Path:          "/folder/file.txt",
+			Method:        http.MethodPost,
+			ContentLength: &contentLength,
+			ContentType:   "text/plain",
+		}
+	}
+
+	req := presignRequest(t, presign, grant(), http.MethodPost, "content")
+	req.Header.Set("Content-Type", "text/plain")
+	req.Header.Set("X-Apply-To", "folder")
+	req.Header.Set("X-Overwrite", "true")
+	req.Header.Set("X-Append", "true")
+	req.Header.Set("X-Compact", "true")
+	assert.True(t, d.applyPresign(req))
+	assert.Equal(t, url.QueryEscape("/folder/file.txt"), req.Header.Get("X-Path"))
+	assert.Equal(t, "file", req.Header.Get("X-Apply-To"))
+	assert.Empty(t, req.Header.Get("X-Overwrite"))
+	assert.Empty(t, req.Header.Get("X-Append"))
+	assert.Empty(t, req.Header.Get("X-Compact"))
+
+	overwrite := grant()
+	overwrite.Overwrite = true
+	req = presignRequest(t, presign, overwrite, http.MethodPost, "content")
+	req.Header.Set("Content-Type", "text/plain")
+	assert.True(t, d.applyPresign(req))
+	assert.Equal(t, "true", req.Header.Get("X-Overwrite"))
+
+	req = presignRequest(t, presign, grant(), http.MethodPost, "content")
+	req.Header.Set("Content-Type", "text/html")
+	assert.False(t, d.applyPresign(req))
+
+	req = presignRequest(t, presign, grant(), http.MethodPost, "longer content")
+	req.Header.Set("Content-Type", "text/plain")
+	assert.False(t, d.applyPresign(req))
+
+	req = presignRequest(t, presign, grant(), http.MethodGet, "")
+	assert.False(t, d.applyPresign(req))
+}
+
+func TestDosRouter_PresignRejected(t *testing.T) {
+	d, presign := newPresignTestRouter(t)
+
+	query, err := presign.Sign(&manager.PresignGrant{Path: "/folder/file.txt", Method: http.MethodGet}, time.Minute)
+	assert.Nil(t, err)
+	query.Set("path", "/folder/secret.txt")
+
+	w := httptest.NewRecorder()
+	d.manipulate(w, httptest.NewRequest(http.MethodGet, "/client/dos?"+query.Encode(), nil))
+	assert.Equal(t, 403, w.Code)
+
+	query.Set("signature", "")
+	w = httptest.NewRecorder()
+	d.manipulate(w, httptest.NewRequest(http.MethodGet, "/client/dos?"+query.Encode(), nil))
+	assert.Equal(t, 403, w.Code)
+}
@@ -53,9 +53,15 @@ func (d *dosRouter) handlePut(w http.ResponseWriter, r *http.Request) {
 		} else if err == errors.ErrNoAvailableActionNode {
 			w.WriteHeader(503)
 			return
+		} else if err == errors.ErrLock {
+			w.WriteHeader(523)
+			return
 		} else if err == errors.ErrZombie {
 			w.WriteHeader(524)
 			return
+		} else if err == errors.ErrTooLarge {
+			w.WriteHeader(413)
+			return
 		} else {
 			w.WriteHeader(500)
 		}
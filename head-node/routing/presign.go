@@ -0,0 +1,53 @@
+package routing
+
+import (
+	"net/http"
+
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"go.uber.org/zap"
+)
+
+type presignRouter struct {
+	presign manager.Presign
+	logger  *zap.Logger
+
+	definitions []*Definition
+}
+
+func NewPresignRouter(presign manager.Presign, logger *zap.Logger) Router {
+	pR := &presignRouter{
+		presign:     presign,
+		logger:      logger,
+		definitions: make([]*Definition, 0),
+	}
+	pR.setup()
+
+	return pR
+}
+
+func (p *presignRouter) setup() {
+	p.definitions =
+		append(p.definitions,
+			&Definition{
+				Path:    "/client/presign",
+				Handler: p.manipulate,
+			},
+		)
+}
+
+func (p *presignRouter) Get() []*Definition {
+	return p.definitions
+}
+
+func (p *presignRouter) manipulate(w http.ResponseWriter, r *http.Request) {
+	defer func() { _ = r.Body.Close() }()
+
+	switch r.Method {
+	case http.MethodPost:
+		p.handlePost(w, r)
+	default:
+		w.WriteHeader(406)
+	}
+}
+
+var _ Router = &presignRouter{}
@@ -0,0 +1,80 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"go.uber.org/zap"
+)
+
+type presignResponse struct {
+	Url     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+func (p *presignRouter) handlePost(w http.ResponseWriter, r *http.Request) {
+	grant, expires, err := p.describeGrant(r)
+	if err != nil {
+		w.WriteHeader(422)
+		return
+	}
+
+	query, err := p.presign.Sign(grant, expires)
+	if err != nil {
+		w.WriteHeader(422)
+		return
+	}
+
+	response := presignResponse{
+		Url:     fmt.Sprintf("/client/dos?%s", query.Encode()),
+		Expires: grant.Expires,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		w.WriteHeader(500)
+		p.logger.Error("Response of presign request is failed", zap.String("path", grant.Path), zap.Error(err))
+	}
+}
+
+func (p *presignRouter) describeGrant(r *http.Request) (*manager.PresignGrant, time.Duration, error) {
+	path, err := url.QueryUnescape(r.Header.Get("X-Path"))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	grant := &manager.PresignGrant{
+		Path:        path,
+		Method:      strings.ToUpper(r.Header.Get("X-Method")),
+		ContentType: r.Header.Get("X-Content-Type"),
+	}
+	if len(grant.Method) == 0 {
+		grant.Method = http.MethodGet
+	}
+
+	if contentLength := r.Header.Get("X-Content-Length"); len(contentLength) > 0 {
+		length, err := strconv.ParseInt(contentLength, 10, 64)
+		if err != nil || length < 0 {
+			return nil, 0, fmt.Errorf("content length is not valid")
+		}
+		grant.ContentLength = &length
+	}
+
+	overwriteHeader := strings.ToLower(r.Header.Get("X-Overwrite"))
+	grant.Overwrite = len(overwriteHeader) > 0 && (strings.Compare(overwriteHeader, "1") == 0 || strings.Compare(overwriteHeader, "true") == 0)
+
+	expires := time.Duration(0)
+	if expiresHeader := r.Header.Get("X-Expires"); len(expiresHeader) > 0 {
+		seconds, err := strconv.ParseUint(expiresHeader, 10, 32)
+		if err != nil {
+			return nil, 0, fmt.Errorf("expires is not valid")
+		}
+		expires = time.Duration(seconds) * time.Second
+	}
+
+	return grant, expires, nil
+}
@@ -10,9 +10,12 @@ type Router interface {
 	Get() []*Definition
 }
 
+// Definition struct is to hold the route of the handler
+// Prefix serves all the paths under the Path instead of the exact match
 type Definition struct {
 	Path    string
 	Handler func(http.ResponseWriter, *http.Request)
+	Prefix  bool
 }
 
 type Manager struct {
@@ -29,6 +32,10 @@ func NewManager() *Manager {
 
 func (m *Manager) Add(router Router) {
 	for _, d := range router.Get() {
+		if d.Prefix {
+			m.mux.PathPrefix(d.Path).HandlerFunc(d.Handler)
+			continue
+		}
 		m.mux.HandleFunc(d.Path, d.Handler)
 	}
 }
@@ -0,0 +1,53 @@
+package routing
+
+import (
+	"net/http"
+
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"go.uber.org/zap"
+)
+
+type searchRouter struct {
+	dos    manager.Dos
+	logger *zap.Logger
+
+	definitions []*Definition
+}
+
+func NewSearchRouter(dos manager.Dos, logger *zap.Logger) Router {
+	pR := &searchRouter{
+		dos:         dos,
+		logger:      logger,
+		definitions: make([]*Definition, 0),
+	}
+	pR.setup()
+
+	return pR
+}
+
+func (s *searchRouter) setup() {
+	s.definitions =
+		append(s.definitions,
+			&Definition{
+				Path:    "/client/search",
+				Handler: s.manipulate,
+			},
+		)
+}
+
+func (s *searchRouter) Get() []*Definition {
+	return s.definitions
+}
+
+func (s *searchRouter) manipulate(w http.ResponseWriter, r *http.Request) {
+	defer func() { _ = r.Body.Close() }()
+
+	switch r.Method {
+	case http.MethodGet:
+		s.handleGet(w, r)
+	default:
+		w.WriteHeader(406)
+	}
+}
+
+var _ Router = &searchRouter{}
@@ -0,0 +1,112 @@
+package routing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"go.uber.org/zap"
+)
+
+func (s *searchRouter) handleGet(w http.ResponseWriter, r *http.Request) {
+	query, err := s.describeQuery(r)
+	if err != nil {
+		w.WriteHeader(422)
+		return
+	}
+
+	result, err := s.dos.Search(query)
+	if err != nil {
+		if err == os.ErrNotExist {
+			w.WriteHeader(404)
+			return
+		} else if err == os.ErrInvalid {
+			w.WriteHeader(422)
+			return
+		}
+		w.WriteHeader(500)
+		s.logger.Error("Search request is failed", zap.String("root", query.Root), zap.Error(err))
+		return
+	}
+
+	if len(result.Continue) > 0 {
+		w.Header().Set("X-Continue", result.Continue)
+	}
+	if result.Incomplete {
+		w.Header().Set("X-Incomplete", "1")
+	}
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		w.WriteHeader(500)
+		s.logger.Error(
+			"Response of search request is failed",
+			zap.String("root", query.Root),
+			zap.Error(err),
+		)
+	}
+}
+
+func (s *searchRouter) describeQuery(r *http.Request) (*common.SearchQuery, error) {
+	root, err := url.QueryUnescape(r.Header.Get("X-Path"))
+	if err != nil || !common.ValidatePath(root) {
+		return nil, os.ErrInvalid
+	}
+	query := common.NewSearchQuery(root)
+
+	if query.Name, err = url.QueryUnescape(r.Header.Get("X-Name")); err != nil {
+		return nil, os.ErrInvalid
+	}
+	if query.NameRegex, err = url.QueryUnescape(r.Header.Get("X-Name-Regex")); err != nil {
+		return nil, os.ErrInvalid
+	}
+	query.Mime = r.Header.Get("X-Mime")
+
+	if sizeRange := r.Header.Get("X-Size"); len(sizeRange) > 0 {
+		if query.SizeMin, query.SizeMax, err = common.ParseSizeRange(sizeRange); err != nil {
+			return nil, os.ErrInvalid
+		}
+	}
+	if createdRange := r.Header.Get("X-Created"); len(createdRange) > 0 {
+		if query.CreatedAfter, query.CreatedBefore, err = common.ParseTimeRange(createdRange); err != nil {
+			return nil, os.ErrInvalid
+		}
+	}
+	if modifiedRange := r.Header.Get("X-Modified"); len(modifiedRange) > 0 {
+		if query.ModifiedAfter, query.ModifiedBefore, err = common.ParseTimeRange(modifiedRange); err != nil {
+			return nil, os.ErrInvalid
+		}
+	}
+
+	if query.Zombie, err = s.describeFlag(r.Header.Get("X-Zombie")); err != nil {
+		return nil, os.ErrInvalid
+	}
+	if query.Locked, err = s.describeFlag(r.Header.Get("X-Locked")); err != nil {
+		return nil, os.ErrInvalid
+	}
+
+	if pageSize := r.Header.Get("X-Page-Size"); len(pageSize) > 0 {
+		if query.PageSize, err = strconv.Atoi(pageSize); err != nil {
+			return nil, os.ErrInvalid
+		}
+	}
+	query.Continue = r.Header.Get("X-Continue")
+
+	return query, nil
+}
+
+func (s *searchRouter) describeFlag(value string) (*bool, error) {
+	value = strings.ToLower(value)
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	flag := strings.Compare(value, "1") == 0 || strings.Compare(value, "true") == 0
+	if !flag && strings.Compare(value, "0") != 0 && strings.Compare(value, "false") != 0 {
+		return nil, os.ErrInvalid
+	}
+	return &flag, nil
+}
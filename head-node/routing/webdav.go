@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"net/http"
+
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"go.uber.org/zap"
+	"golang.org/x/net/webdav"
+)
+
+const webdavPrefix = "/webdav"
+
+type webdavRouter struct {
+	handler *webdav.Handler
+	logger  *zap.Logger
+
+	definitions []*Definition
+}
+
+// NewWebdavRouter creates the router that serves the dos namespace over WebDAV
+func NewWebdavRouter(dos manager.Dos, logger *zap.Logger) Router {
+	wR := &webdavRouter{
+		logger:      logger,
+		definitions: make([]*Definition, 0),
+	}
+	wR.handler = &webdav.Handler{
+		Prefix:     webdavPrefix,
+		FileSystem: newWebdavFS(dos),
+		LockSystem: newWebdavLockSystem(),
+		Logger:     wR.log,
+	}
+	wR.setup()
+
+	return wR
+}
+
+func (d *webdavRouter) setup() {
+	d.definitions =
+		append(d.definitions,
+			&Definition{
+				Path:    webdavPrefix,
+				Handler: d.redirect,
+			},
+			&Definition{
+				Path:    webdavPrefix + "/",
+				Handler: d.manipulate,
+				Prefix:  true,
+			},
+		)
+}
+
+func (d *webdavRouter) Get() []*Definition {
+	return d.definitions
+}
+
+func (d *webdavRouter) redirect(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, webdavPrefix+"/", http.StatusMovedPermanently)
+}
+
+func (d *webdavRouter) manipulate(w http.ResponseWriter, r *http.Request) {
+	defer func() { _ = r.Body.Close() }()
+
+	d.handler.ServeHTTP(w, r)
+}
+
+func (d *webdavRouter) log(r *http.Request, err error) {
+	if err == nil {
+		return
+	}
+	d.logger.Warn("WebDAV request is failed", zap.String("method", r.Method), zap.String("path", r.URL.Path), zap.Error(err))
+}
+
+var _ Router = &webdavRouter{}
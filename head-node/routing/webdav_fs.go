@@ -0,0 +1,369 @@
+package routing
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"golang.org/x/net/webdav"
+)
+
+// webdavFS maps the WebDAV file system operations to the dos manager
+type webdavFS struct {
+	dos manager.Dos
+}
+
+func newWebdavFS(dos manager.Dos) webdav.FileSystem {
+	return &webdavFS{
+		dos: dos,
+	}
+}
+
+func (f *webdavFS) Mkdir(ctx context.Context, name string, _ os.FileMode) error {
+	name = common.CorrectPath(name)
+
+	if _, err := f.Stat(ctx, name); err == nil {
+		return os.ErrExist
+	}
+
+	parent, _ := common.Split(name)
+	if _, err := f.Stat(ctx, parent); err != nil {
+		return err
+	}
+
+	return f.dos.CreateFolder(name)
+}
+
+func (f *webdavFS) OpenFile(_ context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	name = common.CorrectPath(name)
+
+	folder, file, err := f.dos.Stat(name)
+	if err != nil && err != os.ErrNotExist {
+		return nil, f.mapError(err)
+	}
+
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC) == 0 {
+		if err != nil {
+			return nil, os.ErrNotExist
+		}
+		if folder != nil {
+			return newWebdavFolder(folder), nil
+		}
+		return newWebdavFileReader(f.dos, name, file), nil
+	}
+
+	if folder != nil {
+		return nil, os.ErrInvalid
+	}
+	if file != nil && flag&os.O_EXCL > 0 {
+		return nil, os.ErrExist
+	}
+	if file == nil && flag&os.O_CREATE == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	parent, _ := common.Split(name)
+	if _, _, err := f.dos.Stat(parent); err != nil {
+		return nil, f.mapError(err)
+	}
+
+	return newWebdavFileWriter(f.dos, name)
+}
+
+func (f *webdavFS) RemoveAll(_ context.Context, name string) error {
+	name = common.CorrectPath(name)
+
+	if err := f.dos.Delete(name, false); err != nil {
+		if err == os.ErrNotExist {
+			return nil
+		}
+		return f.mapError(err)
+	}
+	return nil
+}
+
+func (f *webdavFS) Rename(_ context.Context, oldName, newName string) error {
+	return f.mapError(f.dos.Change([]string{common.CorrectPath(oldName)}, common.CorrectPath(newName), false, false, true))
+}
+
+func (f *webdavFS) Stat(_ context.Context, name string) (os.FileInfo, error) {
+	folder, file, err := f.dos.Stat(common.CorrectPath(name))
+	if err != nil {
+		return nil, f.mapError(err)
+	}
+	if folder != nil {
+		return newWebdavFolderInfo(folder), nil
+	}
+	return newWebdavFileInfo(file), nil
+}
+
+func (f *webdavFS) mapError(err error) error {
+	switch err {
+	case errors.ErrLock:
+		return webdav.ErrLocked
+	case errors.ErrZombie, errors.ErrNotEmpty:
+		return os.ErrPermission
+	}
+	return err
+}
+
+// webdavFileInfo is the os.FileInfo of the dos folder or file
+type webdavFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	folder  bool
+	file    *common.File
+}
+
+func newWebdavFolderInfo(folder *common.Folder) *webdavFileInfo {
+	return &webdavFileInfo{
+		name:    folder.Name,
+		modTime: folder.Modified,
+		folder:  true,
+	}
+}
+
+func newWebdavFileInfo(file *common.File) *webdavFileInfo {
+	return &webdavFileInfo{
+		name:    file.Name,
+		size:    int64(file.Size),
+		modTime: file.Modified,
+		file:    file,
+	}
+}
+
+func (i *webdavFileInfo) Name() string       { return i.name }
+func (i *webdavFileInfo) Size() int64        { return i.size }
+func (i *webdavFileInfo) ModTime() time.Time { return i.modTime }
+func (i *webdavFileInfo) IsDir() bool        { return i.folder }
+func (i *webdavFileInfo) Sys() interface{}   { return nil }
+
+func (i *webdavFileInfo) Mode() os.FileMode {
+	if i.folder {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+func (i *webdavFileInfo) ContentType(_ context.Context) (string, error) {
+	if i.file == nil {
+		return "", webdav.ErrNotImplemented
+	}
+	return i.file.Mime, nil
+}
+
+func (i *webdavFileInfo) ETag(_ context.Context) (string, error) {
+	if i.file == nil {
+		return "", webdav.ErrNotImplemented
+	}
+	return i.file.ETag(), nil
+}
+
+// webdavFolder is the read only webdav.File of the dos folder
+type webdavFolder struct {
+	folder *common.Folder
+	index  int
+}
+
+func newWebdavFolder(folder *common.Folder) webdav.File {
+	return &webdavFolder{folder: folder}
+}
+
+func (w *webdavFolder) Close() error { return nil }
+
+func (w *webdavFolder) Read(_ []byte) (int, error) { return 0, os.ErrInvalid }
+
+func (w *webdavFolder) Seek(_ int64, _ int) (int64, error) { return 0, os.ErrInvalid }
+
+func (w *webdavFolder) Write(_ []byte) (int, error) { return 0, os.ErrInvalid }
+
+func (w *webdavFolder) Stat() (os.FileInfo, error) { return newWebdavFolderInfo(w.folder), nil }
+
+func (w *webdavFolder) Readdir(count int) ([]os.FileInfo, error) {
+	infos := make([]os.FileInfo, 0)
+	for _, shadow := range w.folder.Folders {
+		infos = append(infos, &webdavFileInfo{name: shadow.Name, modTime: shadow.Created, folder: true})
+	}
+	for _, file := range w.folder.Files {
+		infos = append(infos, newWebdavFileInfo(file))
+	}
+
+	if count <= 0 {
+		infos = infos[w.index:]
+		w.index += len(infos)
+		return infos, nil
+	}
+
+	if w.index >= len(infos) {
+		return nil, io.EOF
+	}
+	end := w.index + count
+	if end > len(infos) {
+		end = len(infos)
+	}
+	infos = infos[w.index:end]
+	w.index = end
+	return infos, nil
+}
+
+// webdavFileReader streams the dos file content from the current offset. Seeking drops the active stream
+type webdavFileReader struct {
+	dos    manager.Dos
+	path   string
+	file   *common.File
+	offset int64
+
+	stream *io.PipeReader
+}
+
+func newWebdavFileReader(dos manager.Dos, path string, file *common.File) webdav.File {
+	return &webdavFileReader{
+		dos:  dos,
+		path: path,
+		file: file,
+	}
+}
+
+func (r *webdavFileReader) Read(p []byte) (int, error) {
+	if r.offset >= int64(r.file.Size) {
+		return 0, io.EOF
+	}
+
+	if r.stream == nil {
+		read, err := r.dos.Read([]string{r.path}, false)
+		if err != nil {
+			return 0, err
+		}
+
+		pr, pw := io.Pipe()
+		go func(begins int64) {
+			pw.CloseWithError(read.Read(pw, begins, int64(r.file.Size)-1))
+		}(r.offset)
+		r.stream = pr
+	}
+
+	n, err := r.stream.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *webdavFileReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += int64(r.file.Size)
+	}
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+
+	if offset != r.offset {
+		r.closeStream()
+		r.offset = offset
+	}
+	return r.offset, nil
+}
+
+func (r *webdavFileReader) closeStream() {
+	if r.stream == nil {
+		return
+	}
+	_ = r.stream.Close()
+	r.stream = nil
+}
+
+func (r *webdavFileReader) Close() error {
+	r.closeStream()
+	return nil
+}
+
+func (r *webdavFileReader) Readdir(_ int) ([]os.FileInfo, error) { return nil, os.ErrInvalid }
+
+func (r *webdavFileReader) Stat() (os.FileInfo, error) { return newWebdavFileInfo(r.file), nil }
+
+func (r *webdavFileReader) Write(_ []byte) (int, error) { return 0, os.ErrInvalid }
+
+// webdavFileWriter keeps the written content in a temp file because the dos requires the size before
+// the upload. The file is created in the dos when it is closed
+type webdavFileWriter struct {
+	dos  manager.Dos
+	path string
+
+	temp *os.File
+	info *webdavFileInfo
+}
+
+func newWebdavFileWriter(dos manager.Dos, name string) (webdav.File, error) {
+	temp, err := os.CreateTemp("", "kertish-webdav-*")
+	if err != nil {
+		return nil, err
+	}
+
+	_, filename := common.Split(name)
+	return &webdavFileWriter{
+		dos:  dos,
+		path: name,
+		temp: temp,
+		info: &webdavFileInfo{name: filename, modTime: time.Now().UTC()},
+	}, nil
+}
+
+func (w *webdavFileWriter) Write(p []byte) (int, error) {
+	n, err := w.temp.Write(p)
+	w.info.size += int64(n)
+	return n, err
+}
+
+func (w *webdavFileWriter) Close() error {
+	defer func() {
+		_ = w.temp.Close()
+		_ = os.Remove(w.temp.Name())
+	}()
+
+	if _, err := w.temp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(w.temp, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	contentType := mime.TypeByExtension(path.Ext(w.path))
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(head[:n])
+	}
+
+	if _, err := w.temp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := w.dos.CreateFile(w.path, contentType, uint64(w.info.size), true, w.temp); err != nil {
+		return err
+	}
+
+	if _, file, err := w.dos.Stat(w.path); err == nil {
+		w.info.file = file
+		w.info.modTime = file.Modified
+	}
+	return nil
+}
+
+func (w *webdavFileWriter) Read(_ []byte) (int, error) { return 0, os.ErrInvalid }
+
+func (w *webdavFileWriter) Seek(_ int64, _ int) (int64, error) { return 0, os.ErrInvalid }
+
+func (w *webdavFileWriter) Readdir(_ int) ([]os.FileInfo, error) { return nil, os.ErrInvalid }
+
+func (w *webdavFileWriter) Stat() (os.FileInfo, error) { return w.info, nil }
+
+var _ webdav.FileSystem = &webdavFS{}
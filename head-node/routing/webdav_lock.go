@@ -0,0 +1,48 @@
+package routing
+
+import (
+	"time"
+
+	"golang.org/x/net/webdav"
+)
+
+// webdavLockSystem keeps the webdav lock tokens in memory. Locks are only confirmed by the webdav handler
+// for the mutating requests (PUT, DELETE, MOVE, LOCK...), the dos file lock is not used so the locked files
+// can still be read and copied from
+type webdavLockSystem struct {
+	memory webdav.LockSystem
+}
+
+func newWebdavLockSystem() *webdavLockSystem {
+	return &webdavLockSystem{
+		memory: webdav.NewMemLS(),
+	}
+}
+
+func (l *webdavLockSystem) Confirm(now time.Time, name0, name1 string, conditions ...webdav.Condition) (func(), error) {
+	return l.memory.Confirm(now, name0, name1, conditions...)
+}
+
+func (l *webdavLockSystem) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	details.Duration = lockDuration(details.Duration)
+	return l.memory.Create(now, details)
+}
+
+func (l *webdavLockSystem) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	return l.memory.Refresh(now, token, lockDuration(duration))
+}
+
+func (l *webdavLockSystem) Unlock(now time.Time, token string) error {
+	return l.memory.Unlock(now, token)
+}
+
+// lockDuration limits the infinite webdav lock duration to one hour, so the locks of the disappeared
+// clients do not live forever
+func lockDuration(duration time.Duration) time.Duration {
+	if duration <= 0 {
+		return time.Hour
+	}
+	return duration
+}
+
+var _ webdav.LockSystem = &webdavLockSystem{}
@@ -0,0 +1,456 @@
+package routing
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/basics/hooks"
+	"github.com/freakmaxi/kertish-dos/head-node/manager"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+const webdavLockBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:">
+  <D:lockscope><D:exclusive/></D:lockscope>
+  <D:locktype><D:write/></D:locktype>
+  <D:owner>tester</D:owner>
+</D:lockinfo>`
+
+func TestWebdav_Folders(t *testing.T) {
+	server, dos := newWebdavTestServer()
+	defer server.Close()
+
+	res := webdavRequest(t, server, "MKCOL", "/Movies", nil, nil)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.True(t, dos.hasFolder("/Movies"))
+
+	res = webdavRequest(t, server, "MKCOL", "/Movies", nil, nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+
+	res = webdavRequest(t, server, "MKCOL", "/Missing/Movies", nil, nil)
+	assert.Equal(t, http.StatusConflict, res.StatusCode)
+
+	res = webdavRequest(t, server, http.MethodPut, "/Movies/Sample.txt", []byte("sample"), nil)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	res = webdavRequest(t, server, "PROPFIND", "/Movies", nil, map[string]string{"Depth": "1"})
+	assert.Equal(t, http.StatusMultiStatus, res.StatusCode)
+	body := readBody(t, res)
+	assert.Contains(t, body, "/webdav/Movies/")
+	assert.Contains(t, body, "/webdav/Movies/Sample.txt")
+
+	res = webdavRequest(t, server, http.MethodDelete, "/Movies", nil, nil)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.False(t, dos.hasFolder("/Movies"))
+	assert.False(t, dos.hasFile("/Movies/Sample.txt"))
+}
+
+func TestWebdav_Files(t *testing.T) {
+	server, dos := newWebdavTestServer()
+	defer server.Close()
+
+	res := webdavRequest(t, server, http.MethodPut, "/Sample.txt", []byte("sample content"), nil)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "sample content", dos.content("/Sample.txt"))
+
+	res = webdavRequest(t, server, http.MethodGet, "/Sample.txt", nil, nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "sample content", readBody(t, res))
+
+	res = webdavRequest(t, server, http.MethodGet, "/Sample.txt", nil, map[string]string{"Range": "bytes=7-"})
+	assert.Equal(t, http.StatusPartialContent, res.StatusCode)
+	assert.Equal(t, "content", readBody(t, res))
+
+	res = webdavRequest(t, server, http.MethodPut, "/Sample.txt", []byte("changed"), nil)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "changed", dos.content("/Sample.txt"))
+
+	res = webdavRequest(t, server, "PROPFIND", "/Sample.txt", nil, map[string]string{"Depth": "0"})
+	assert.Equal(t, http.StatusMultiStatus, res.StatusCode)
+	assert.Contains(t, readBody(t, res), "<D:getcontentlength>7</D:getcontentlength>")
+
+	res = webdavRequest(t, server, "COPY", "/Sample.txt", nil, map[string]string{"Destination": server.URL + webdavPrefix + "/Copy.txt"})
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "changed", dos.content("/Copy.txt"))
+	assert.Equal(t, "changed", dos.content("/Sample.txt"))
+
+	res = webdavRequest(t, server, "MOVE", "/Copy.txt", nil, map[string]string{"Destination": server.URL + webdavPrefix + "/Moved.txt"})
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.False(t, dos.hasFile("/Copy.txt"))
+	assert.Equal(t, "changed", dos.content("/Moved.txt"))
+
+	res = webdavRequest(t, server, http.MethodDelete, "/Moved.txt", nil, nil)
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.False(t, dos.hasFile("/Moved.txt"))
+
+	res = webdavRequest(t, server, http.MethodGet, "/Moved.txt", nil, nil)
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestWebdav_Lock(t *testing.T) {
+	server, dos := newWebdavTestServer()
+	defer server.Close()
+
+	res := webdavRequest(t, server, http.MethodPut, "/Sample.txt", []byte("sample"), nil)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+
+	res = webdavRequest(t, server, "LOCK", "/Sample.txt", []byte(webdavLockBody), map[string]string{"Timeout": "Second-600"})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	token := res.Header.Get("Lock-Token")
+	assert.NotEmpty(t, token)
+	ifHeader := map[string]string{"If": "(" + token + ")"}
+
+	// webdav locks are not the dos file locks
+	assert.False(t, dos.locked("/Sample.txt"))
+
+	// GET and COPY of a locked resource succeed
+	res = webdavRequest(t, server, http.MethodGet, "/Sample.txt", nil, nil)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "sample", readBody(t, res))
+
+	res = webdavRequest(t, server, "COPY", "/Sample.txt", nil, map[string]string{"Destination": server.URL + webdavPrefix + "/Copy.txt"})
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "sample", dos.content("/Copy.txt"))
+
+	// non-owner changes on a locked resource get 423
+	res = webdavRequest(t, server, http.MethodPut, "/Sample.txt", []byte("other"), nil)
+	assert.Equal(t, http.StatusLocked, res.StatusCode)
+	res = webdavRequest(t, server, http.MethodDelete, "/Sample.txt", nil, nil)
+	assert.Equal(t, http.StatusLocked, res.StatusCode)
+	res = webdavRequest(t, server, "MOVE", "/Sample.txt", nil, map[string]string{"Destination": server.URL + webdavPrefix + "/Moved.txt"})
+	assert.Equal(t, http.StatusLocked, res.StatusCode)
+	res = webdavRequest(t, server, "LOCK", "/Sample.txt", []byte(webdavLockBody), nil)
+	assert.Equal(t, http.StatusLocked, res.StatusCode)
+	assert.Equal(t, "sample", dos.content("/Sample.txt"))
+
+	// refresh extends the lock of the owner
+	res = webdavRequest(t, server, "LOCK", "/Sample.txt", nil, map[string]string{"If": "(" + token + ")", "Timeout": "Second-1200"})
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, readBody(t, res), "Second-1200")
+
+	// owner changes the locked resource
+	res = webdavRequest(t, server, http.MethodPut, "/Sample.txt", []byte("owner"), ifHeader)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "owner", dos.content("/Sample.txt"))
+
+	res = webdavRequest(t, server, "UNLOCK", "/Sample.txt", nil, map[string]string{"Lock-Token": token})
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	res = webdavRequest(t, server, http.MethodPut, "/Sample.txt", []byte("other"), nil)
+	assert.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "other", dos.content("/Sample.txt"))
+}
+
+func newWebdavTestServer() (*httptest.Server, *fakeDos) {
+	dos := newFakeDos()
+
+	mux := http.NewServeMux()
+	for _, definition := range NewWebdavRouter(dos, zap.NewNop()).Get() {
+		mux.HandleFunc(definition.Path, definition.Handler)
+	}
+	return httptest.NewServer(mux), dos
+}
+
+func webdavRequest(t *testing.T, server *httptest.Server, method string, path string, body []byte, headers map[string]string) *http.Response {
+	req, err := http.NewRequest(method, server.URL+webdavPrefix+path, bytes.NewReader(body))
+	assert.Nil(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	t.Cleanup(func() { _ = res.Body.Close() })
+
+	return res
+}
+
+func readBody(t *testing.T, res *http.Response) string {
+	content, err := io.ReadAll(res.Body)
+	assert.Nil(t, err)
+	return string(content)
+}
+
+// fakeDos keeps the folders and the files in memory to serve the webdav requests
+type fakeDos struct {
+	mutex    sync.Mutex
+	folders  map[string]bool
+	files    map[string]*common.File
+	contents map[string][]byte
+}
+
+func newFakeDos() *fakeDos {
+	return &fakeDos{
+		folders:  map[string]bool{"/": true},
+		files:    make(map[string]*common.File),
+		contents: make(map[string][]byte),
+	}
+}
+
+func (f *fakeDos) hasFolder(path string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.folders[path]
+}
+
+func (f *fakeDos) hasFile(path string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	_, has := f.files[path]
+	return has
+}
+
+func (f *fakeDos) content(path string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return string(f.contents[path])
+}
+
+func (f *fakeDos) locked(path string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	file, has := f.files[path]
+	return has && file.Locked()
+}
+
+func (f *fakeDos) CreateFolder(folderPath string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	folderPath = common.CorrectPath(folderPath)
+	if f.folders[folderPath] {
+		return os.ErrExist
+	}
+	f.folders[folderPath] = true
+	return nil
+}
+
+func (f *fakeDos) CreateFile(path string, mime string, size uint64, overwrite bool, contentReader io.Reader) error {
+	content, err := io.ReadAll(contentReader)
+	if err != nil {
+		return err
+	}
+	if uint64(len(content)) != size {
+		return os.ErrInvalid
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.put(common.CorrectPath(path), mime, content, overwrite)
+}
+
+func (f *fakeDos) put(path string, mime string, content []byte, overwrite bool) error {
+	folderPath, filename := common.Split(path)
+	if !f.folders[folderPath] {
+		return os.ErrNotExist
+	}
+
+	if current, has := f.files[path]; has {
+		if !overwrite {
+			return os.ErrExist
+		}
+		if current.Locked() {
+			return errors.ErrLock
+		}
+	}
+
+	now := time.Now().UTC()
+	f.files[path] = &common.File{
+		Name:     filename,
+		Mime:     mime,
+		Size:     uint64(len(content)),
+		Created:  now,
+		Modified: now,
+		Chunks:   common.DataChunks{common.NewInlineDataChunk(0, content)},
+	}
+	f.contents[path] = content
+	return nil
+}
+
+func (f *fakeDos) AppendFile(_ string, _ string, _ uint64, _ bool, _ io.Reader) error {
+	return os.ErrInvalid
+}
+
+func (f *fakeDos) Extract(_ string, _ manager.ArchiveType, _ bool, _ io.Reader) (*common.ExtractionResult, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *fakeDos) Read(paths []string, _ bool) (manager.ReadContainer, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	path := common.CorrectPath(paths[0])
+	file, has := f.files[path]
+	if !has {
+		return nil, os.ErrNotExist
+	}
+	if file.Locked() {
+		return nil, errors.ErrLock
+	}
+	return &fakeReadContainer{file: *file, content: f.contents[path]}, nil
+}
+
+func (f *fakeDos) Size(_ string) (uint64, error) {
+	return 0, os.ErrInvalid
+}
+
+func (f *fakeDos) Stat(path string) (*common.Folder, *common.File, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	path = common.CorrectPath(path)
+	if file, has := f.files[path]; has {
+		stat := *file
+		return nil, &stat, nil
+	}
+	if !f.folders[path] {
+		return nil, nil, os.ErrNotExist
+	}
+
+	folder := common.NewFolder(path)
+	for folderPath := range f.folders {
+		if parent, _ := common.Split(folderPath); strings.Compare(folderPath, "/") != 0 && strings.Compare(parent, path) == 0 {
+			folder.Folders = append(folder.Folders, common.NewFolderShadow(folderPath))
+		}
+	}
+	for filePath, file := range f.files {
+		if parent, _ := common.Split(filePath); strings.Compare(parent, path) == 0 {
+			stat := *file
+			folder.Files = append(folder.Files, &stat)
+		}
+	}
+	return folder, nil, nil
+}
+
+func (f *fakeDos) Search(_ *common.SearchQuery) (*common.SearchResult, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *fakeDos) Change(sources []string, target string, _ bool, overwrite bool, move bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	source := common.CorrectPath(sources[0])
+	file, has := f.files[source]
+	if !has {
+		return os.ErrNotExist
+	}
+	if move && file.Locked() {
+		return errors.ErrLock
+	}
+
+	if err := f.put(common.CorrectPath(target), file.Mime, f.contents[source], overwrite); err != nil {
+		return err
+	}
+	if move {
+		delete(f.files, source)
+		delete(f.contents, source)
+	}
+	return nil
+}
+
+func (f *fakeDos) Delete(path string, _ bool) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	path = common.CorrectPath(path)
+	if file, has := f.files[path]; has {
+		if file.Locked() {
+			return errors.ErrLock
+		}
+		delete(f.files, path)
+		delete(f.contents, path)
+		return nil
+	}
+	if !f.folders[path] {
+		return os.ErrNotExist
+	}
+
+	for folderPath := range f.folders {
+		if strings.HasPrefix(folderPath, path+"/") {
+			delete(f.folders, folderPath)
+		}
+	}
+	for filePath := range f.files {
+		if strings.HasPrefix(filePath, path+"/") {
+			delete(f.files, filePath)
+			delete(f.contents, filePath)
+		}
+	}
+	delete(f.folders, path)
+	return nil
+}
+
+func (f *fakeDos) Lock(path string, duration time.Duration) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	file, has := f.files[common.CorrectPath(path)]
+	if !has {
+		return os.ErrNotExist
+	}
+	if file.Locked() {
+		return errors.ErrLock
+	}
+	file.Lock = common.NewFileLock(duration)
+	return nil
+}
+
+func (f *fakeDos) Unlock(path string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	file, has := f.files[common.CorrectPath(path)]
+	if !has {
+		return os.ErrNotExist
+	}
+	file.Lock = nil
+	return nil
+}
+
+func (f *fakeDos) Promote() (int, error) {
+	return 0, nil
+}
+
+func (f *fakeDos) ExecuteActions(_ *hooks.ActionInfo, _ []hooks.Action) {}
+
+// fakeReadContainer streams the content of the fake dos file
+type fakeReadContainer struct {
+	file    common.File
+	content []byte
+}
+
+func (r *fakeReadContainer) Type() manager.ReadType { return manager.RTFile }
+
+func (r *fakeReadContainer) Folder() *common.Folder { return nil }
+
+func (r *fakeReadContainer) Tree() (*common.Tree, error) { return nil, os.ErrInvalid }
+
+func (r *fakeReadContainer) Archive(_ io.Writer, _ manager.ArchiveType) ([]string, error) {
+	return nil, os.ErrInvalid
+}
+
+func (r *fakeReadContainer) File() *common.File { return &r.file }
+
+func (r *fakeReadContainer) Read(w io.Writer, begins int64, ends int64) error {
+	_, err := w.Write(r.content[begins : ends+1])
+	return err
+}
+
+var _ manager.Dos = &fakeDos{}
+var _ manager.ReadContainer = &fakeReadContainer{}
@@ -23,12 +23,13 @@ const (
 	commandMode             = "MODE"
 	commandLeave            = "LEAV"
 	commandWipe             = "WIPE"
-	commandSyncCreate       = "SYCR"
-	commandSyncDelete       = "SYDE"
+	commandSyncCreate       = "SXCR"
+	commandSyncCreateWait   = "SXCW"
+	commandSyncDelete       = "SXDE"
 	commandSyncMove         = "SYMV"
-	commandSyncList         = "SYLS"
+	commandSyncList         = "SXLS"
 	commandSyncFull         = "SYFL"
-	commandSyncUsage        = "SYUS"
+	commandSyncUsage        = "SXUS"
 	commandSnapshotCreate   = "SSCR"
 	commandSnapshotDelete   = "SSDE"
 	commandSnapshotRestore  = "SSRS"
@@ -52,12 +53,14 @@ type DataNode interface {
 	Leave() bool
 	Wipe() bool
 
-	SyncCreate(sha512Hex string, usage uint16, sourceNodeAddr string) error
-	SyncDelete(sha512Hex string, usage uint16) error
+	SyncCreate(sha512Hex string, usage uint64, sourceNodeAddr string) error
+	// SyncCreateWait creates the chunk on the node from the source node and returns when it is stored
+	SyncCreateWait(sha512Hex string, usage uint64, sourceNodeAddr string, timeout time.Duration) error
+	SyncDelete(sha512Hex string, usage uint64) error
 	SyncMove(sha512Hex string, sourceNodeAddr string) error
 	SyncList(snapshotTime *time.Time) (*common.SyncContainer, error)
 	SyncFull(sourceNodeAddr string) bool
-	SyncUsage(usageMap map[string]uint16) error
+	SyncUsage(usageMap map[string]uint64) error
 
 	SnapshotCreate() bool
 	SnapshotDelete(snapshotIndex uint64) bool
@@ -380,43 +383,56 @@ func (d *dataNode) Wipe() bool {
 	}) == nil
 }
 
-func (d *dataNode) SyncCreate(sha512Hex string, usage uint16, sourceNodeAddr string) error {
+func (d *dataNode) SyncCreate(sha512Hex string, usage uint64, sourceNodeAddr string) error {
+	return d.connect(func(conn net.Conn) error {
+		return d.syncCreate(conn, commandSyncCreate, sha512Hex, usage, sourceNodeAddr)
+	})
+}
+
+func (d *dataNode) SyncCreateWait(sha512Hex string, usage uint64, sourceNodeAddr string, timeout time.Duration) error {
+	return d.connectWithTimeout(timeout, func(conn net.Conn) error {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+		return d.syncCreate(conn, commandSyncCreateWait, sha512Hex, usage, sourceNodeAddr)
+	})
+}
+
+func (d *dataNode) syncCreate(conn net.Conn, command string, sha512Hex string, usage uint64, sourceNodeAddr string) error {
 	sha512Sum, err := hex.DecodeString(sha512Hex)
 	if err != nil {
 		return err
 	}
 
-	return d.connect(func(conn net.Conn) error {
-		if _, err := conn.Write([]byte(commandSyncCreate)); err != nil {
-			return err
-		}
+	if _, err := conn.Write([]byte(command)); err != nil {
+		return err
+	}
 
-		if _, err := conn.Write(sha512Sum); err != nil {
-			return err
-		}
+	if _, err := conn.Write(sha512Sum); err != nil {
+		return err
+	}
 
-		if err := binary.Write(conn, binary.LittleEndian, usage); err != nil {
-			return err
-		}
+	if err := binary.Write(conn, binary.LittleEndian, usage); err != nil {
+		return err
+	}
 
-		sourceBindAddrLength := uint8(len(sourceNodeAddr))
-		if err := binary.Write(conn, binary.LittleEndian, sourceBindAddrLength); err != nil {
-			return err
-		}
+	sourceBindAddrLength := uint8(len(sourceNodeAddr))
+	if err := binary.Write(conn, binary.LittleEndian, sourceBindAddrLength); err != nil {
+		return err
+	}
 
-		if _, err := conn.Write([]byte(sourceNodeAddr)); err != nil {
-			return err
-		}
+	if _, err := conn.Write([]byte(sourceNodeAddr)); err != nil {
+		return err
+	}
 
-		if !d.result(conn) {
-			return fmt.Errorf("sync create command is failed on data node")
-		}
+	if !d.result(conn) {
+		return fmt.Errorf("sync create command is failed on data node")
+	}
 
-		return nil
-	})
+	return nil
 }
 
-func (d *dataNode) SyncDelete(sha512Hex string, usage uint16) error {
+func (d *dataNode) SyncDelete(sha512Hex string, usage uint64) error {
 	sha512Sum, err := hex.DecodeString(sha512Hex)
 	if err != nil {
 		return err
@@ -525,7 +541,7 @@ func (d *dataNode) SyncList(snapshotTime *time.Time) (*common.SyncContainer, err
 				break
 			}
 
-			var usage uint16
+			var usage uint64
 			if err := binary.Read(conn, binary.LittleEndian, &usage); err != nil {
 				return err
 			}
@@ -578,7 +594,7 @@ func (d *dataNode) SyncFull(sourceNodeAddr string) bool {
 	}) == nil
 }
 
-func (d *dataNode) SyncUsage(usageMap map[string]uint16) error {
+func (d *dataNode) SyncUsage(usageMap map[string]uint64) error {
 	return d.connect(func(conn net.Conn) error {
 		if _, err := conn.Write([]byte(commandSyncUsage)); err != nil {
 			return err
@@ -362,12 +362,12 @@ func (i *index) CompareMap(clusterId string, items common.SyncFileItemMap) bool
 			return false
 		}
 
-		usage, err := strconv.ParseUint(itemValue[:pipeIdx], 10, 16)
+		usage, err := strconv.ParseUint(itemValue[:pipeIdx], 10, 64)
 		if err != nil {
 			return false
 		}
 
-		if fileItem.Usage != uint16(usage) {
+		if fileItem.Usage != usage {
 			return false
 		}
 	}
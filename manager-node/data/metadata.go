@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/document"
 	"github.com/freakmaxi/kertish-dos/basics/errors"
 	"github.com/freakmaxi/locking-center-client-go/mutex"
 	"go.mongodb.org/mongo-driver/bson"
@@ -18,7 +19,9 @@ import (
 )
 
 type Metadata interface {
+	Count() (uint64, error)
 	Cursor(folderHandler func(folder *common.Folder) (bool, error), parallelSize uint8) error
+	LiveCursor(folderHandler func(folder *common.Folder) (bool, error), parallelSize uint8) error
 	LockTree(folderHandler func(folders []*common.Folder) ([]*common.Folder, error)) error
 
 	Lock()
@@ -32,15 +35,18 @@ type metadata struct {
 	mutex mutex.LockingCenter
 	conn  *Connection
 	col   *mongo.Collection
+	files *mongo.Collection
 }
 
 func NewMetadata(mutex mutex.LockingCenter, conn *Connection, database string) (Metadata, error) {
 	dosCol := conn.client.Database(database).Collection(metadataCollection)
+	filesCol := conn.client.Database(database).Collection(metadataFilesCollection)
 
 	return &metadata{
 		mutex: mutex,
 		conn:  conn,
 		col:   dosCol,
+		files: filesCol,
 	}, nil
 }
 
@@ -63,33 +69,18 @@ func (m *metadata) find(filter interface{}, opts ...*options.FindOptions) (*mong
 	return m.col.Find(ctx, filter, opts...)
 }
 
-func (m *metadata) findOne(filter interface{}, opts ...*options.FindOneOptions) (*common.Folder, error) {
+func (m *metadata) findOne(filter interface{}, opts ...*options.FindOneOptions) (*common.Folder, *document.State, error) {
 	ctx, cancelFunc := m.context(context.Background())
 	defer cancelFunc()
 
-	var folder *common.Folder
-	if err := m.col.FindOne(ctx, filter, opts...).Decode(&folder); err != nil {
+	raw, err := m.col.FindOne(ctx, filter, opts...).Raw()
+	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			return nil, os.ErrNotExist
+			return nil, nil, os.ErrNotExist
 		}
-		return nil, err
+		return nil, nil, err
 	}
-	return folder, nil
-}
-
-func (m *metadata) next(cursor *mongo.Cursor) (*common.Folder, error) {
-	ctx, cancelFunc := m.context(context.Background())
-	defer cancelFunc()
-
-	if !cursor.Next(ctx) {
-		return nil, io.EOF
-	}
-
-	var folder *common.Folder
-	if err := cursor.Decode(&folder); err != nil {
-		return nil, err
-	}
-	return folder, nil
+	return m.decodeFolder(context.Background(), raw)
 }
 
 func (m *metadata) nextRaw(cursor *mongo.Cursor) (bson.Raw, error) {
@@ -102,13 +93,28 @@ func (m *metadata) nextRaw(cursor *mongo.Cursor) (bson.Raw, error) {
 	return cursor.Current, nil
 }
 
-func (m *metadata) updateOne(parentContext context.Context, folder common.Folder) error {
+func (m *metadata) updateOne(parentContext context.Context, folder common.Folder, state *document.State) error {
 	ctx, cancelFunc := m.context(parentContext)
 	defer cancelFunc()
 
+	update := bson.M{"$set": folder}
+	if state.Legacy() {
+		update["$unset"] = bson.M{"files": ""}
+	}
+
 	opts := (&options.UpdateOptions{}).SetUpsert(true)
-	_, err := m.col.UpdateOne(ctx, bson.M{"full": folder.Full}, bson.M{"$set": folder}, opts)
-	return err
+	if _, err := m.col.UpdateOne(ctx, bson.M{"full": folder.Full}, update, opts); err != nil {
+		return err
+	}
+	return m.syncFiles(parentContext, folder.Full, &folder, state)
+}
+
+func (m *metadata) Count() (uint64, error) {
+	total, err := m.countDocuments()
+	if err != nil {
+		return 0, err
+	}
+	return uint64(total), nil
 }
 
 func (m *metadata) Lock() {
@@ -120,6 +126,16 @@ func (m *metadata) Unlock() {
 }
 
 func (m *metadata) Cursor(folderHandler func(folder *common.Folder) (bool, error), parallelSize uint8) error {
+	return m.cursor(folderHandler, parallelSize, true)
+}
+
+// LiveCursor traverses the folders while the metadata is still in use. Folders can be created or deleted
+// during the traversal so the handled document count is not validated
+func (m *metadata) LiveCursor(folderHandler func(folder *common.Folder) (bool, error), parallelSize uint8) error {
+	return m.cursor(folderHandler, parallelSize, false)
+}
+
+func (m *metadata) cursor(folderHandler func(folder *common.Folder) (bool, error), parallelSize uint8, validateCount bool) error {
 	semaphoreChan := make(chan bool, parallelSize)
 	for i := 0; i < cap(semaphoreChan); i++ {
 		semaphoreChan <- true
@@ -157,7 +173,7 @@ func (m *metadata) Cursor(folderHandler func(folder *common.Folder) (bool, error
 		m.mutex.Lock(folderPath)
 		defer m.mutex.Unlock(folderPath)
 
-		folder, err := m.findOne(bson.M{"_id": id})
+		folder, state, err := m.findOne(bson.M{"_id": id})
 		if err != nil {
 			if err != os.ErrNotExist {
 				errorChan <- err
@@ -174,7 +190,7 @@ func (m *metadata) Cursor(folderHandler func(folder *common.Folder) (bool, error
 			return
 		}
 
-		if err := m.save([]*common.Folder{folder}, false); err != nil {
+		if err := m.save([]*common.Folder{folder}, map[string]*document.State{folder.Full: state}); err != nil {
 			errorChan <- err
 		}
 	}
@@ -225,7 +241,7 @@ func (m *metadata) Cursor(folderHandler func(folder *common.Folder) (bool, error
 		return bulkError
 	}
 
-	if handled != total {
+	if validateCount && handled != total {
 		return fmt.Errorf("total (%d) and handled (%d) document counts didn't match", total, handled)
 	}
 
@@ -256,15 +272,22 @@ func (m *metadata) LockTree(folderHandler func(folders []*common.Folder) ([]*com
 	}()
 
 	folders := make([]*common.Folder, 0)
+	states := make(map[string]*document.State)
 	for {
-		folder, err := m.next(cursor)
+		raw, err := m.nextRaw(cursor)
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 			return err
 		}
+
+		folder, state, err := m.decodeFolder(context.Background(), raw)
+		if err != nil {
+			return err
+		}
 		folders = append(folders, folder)
+		states[folder.Full] = state
 	}
 
 	result, err := folderHandler(folders)
@@ -276,10 +299,10 @@ func (m *metadata) LockTree(folderHandler func(folders []*common.Folder) ([]*com
 		return nil
 	}
 
-	return m.save(result, true)
+	return m.save(result, states)
 }
 
-func (m *metadata) save(folders []*common.Folder, upsert bool) error {
+func (m *metadata) save(folders []*common.Folder, states map[string]*document.State) error {
 	session, err := m.conn.client.StartSession()
 	if err != nil {
 		return err
@@ -299,7 +322,7 @@ func (m *metadata) save(folders []*common.Folder, upsert bool) error {
 		}
 
 		for _, folder := range folders {
-			if err := m.updateOne(parentContext, *folder); err != nil {
+			if err := m.updateOne(parentContext, *folder, states[folder.Full]); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,115 @@
+package data
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/document"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const metadataFilesCollection = "metadata-files"
+
+func (m *metadata) decodeFolder(parentContext context.Context, raw bson.Raw) (*common.Folder, *document.State, error) {
+	folder, state, err := document.Decode(raw)
+	if err != nil || state != nil {
+		return folder, state, err
+	}
+
+	filesMap, err := m.findFiles(parentContext, bson.M{"parent": folder.Full})
+	if err != nil {
+		return nil, nil, err
+	}
+	folder.Files = m.filesOf(filesMap, folder.Full)
+
+	state, err = document.NewState(folder, false)
+	return folder, state, err
+}
+
+func (m *metadata) findFiles(parentContext context.Context, filter interface{}) (map[string]common.Files, error) {
+	ctx, cancelFunc := m.context(parentContext)
+	defer cancelFunc()
+
+	opts := options.Find()
+	opts.SetSort(bson.D{{Key: "parent", Value: 1}, {Key: "name", Value: 1}})
+
+	cursor, err := m.files.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		ctx, cancelFunc := m.context(context.Background())
+		defer cancelFunc()
+
+		_ = cursor.Close(ctx)
+	}()
+
+	filesMap := make(map[string]common.Files)
+	for {
+		fileDocument, err := m.nextFile(cursor)
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		file := fileDocument.File
+		filesMap[fileDocument.Parent] = append(filesMap[fileDocument.Parent], &file)
+	}
+	return filesMap, nil
+}
+
+func (m *metadata) nextFile(cursor *mongo.Cursor) (*document.File, error) {
+	ctx, cancelFunc := m.context(context.Background())
+	defer cancelFunc()
+
+	if !cursor.Next(ctx) {
+		return nil, io.EOF
+	}
+
+	var fileDocument *document.File
+	if err := cursor.Decode(&fileDocument); err != nil {
+		return nil, err
+	}
+	return fileDocument, nil
+}
+
+func (m *metadata) filesOf(filesMap map[string]common.Files, folderPath string) common.Files {
+	files, has := filesMap[folderPath]
+	if !has {
+		return make(common.Files, 0)
+	}
+	sort.Sort(files)
+	return files
+}
+
+func (m *metadata) syncFiles(parentContext context.Context, folderPath string, folder *common.Folder, state *document.State) error {
+	changes, err := state.Changes(folder)
+	if err != nil {
+		return err
+	}
+
+	models := changes.WriteModels(folderPath)
+	if len(models) == 0 {
+		return nil
+	}
+
+	ctx, cancelFunc := m.context(parentContext)
+	defer cancelFunc()
+
+	_, err = m.files.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (m *metadata) dropFiles(parentContext context.Context, folderPath string) error {
+	ctx, cancelFunc := m.context(parentContext)
+	defer cancelFunc()
+
+	_, err := m.files.DeleteMany(ctx, bson.M{"parent": folderPath})
+	return err
+}
@@ -1,16 +1,28 @@
 package data
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/mediocregopher/radix/v3"
 )
 
 type Operation interface {
 	RepairDetail() (RepairDetail, error)
-	SetRepairing(repairing bool, completed bool) error
+	SetRepairing(repairing bool, online bool, dryRun bool, completed bool) error
+	SaveCheckpoint(checkpoint *RepairCheckpoint) error
+
+	Journal(sha512HexList []string) error
+	Journaled() (map[string]bool, error)
+	ResetJournal() error
+
+	AppendReport(lines []string) error
+	Report() ([]string, error)
+	ResetReport() error
 }
 
 type operation struct {
@@ -21,8 +33,54 @@ type operation struct {
 }
 
 type RepairDetail struct {
-	Processing bool
-	Timestamp  *time.Time
+	Processing bool              `json:"processing"`
+	Online     bool              `json:"online"`
+	DryRun     bool              `json:"dryRun"`
+	Timestamp  *time.Time        `json:"timestamp,omitempty"`
+	Checkpoint *RepairCheckpoint `json:"checkpoint,omitempty"`
+}
+
+// RepairCheckpoint keeps the position and the counters of the repair to be able to resume after a restart
+type RepairCheckpoint struct {
+	Type              int       `json:"type"`
+	StartedAt         time.Time `json:"startedAt"`
+	Phases            []string  `json:"phases"`
+	Phase             int       `json:"phase"`
+	Total             uint64    `json:"total"`
+	Processed         uint64    `json:"processed"`
+	CompletedClusters []string  `json:"completedClusters"`
+	OrphansRemoved    uint64    `json:"orphansRemoved"`
+	UsagesFixed       uint64    `json:"usagesFixed"`
+	ChecksumsRebuilt  uint64    `json:"checksumsRebuilt"`
+	ZombiesFound      uint64    `json:"zombiesFound"`
+}
+
+// Percentage calculates the completion of the repair over the phases
+func (r *RepairCheckpoint) Percentage() float64 {
+	if len(r.Phases) == 0 {
+		return 0
+	}
+
+	phaseCompletion := float64(0)
+	if r.Total > 0 {
+		phaseCompletion = float64(r.Processed) / float64(r.Total)
+		if phaseCompletion > 1 {
+			phaseCompletion = 1
+		}
+	}
+
+	return (float64(r.Phase) + phaseCompletion) / float64(len(r.Phases)) * 100
+}
+
+// ETA estimates the remaining duration of the repair based on the elapsed time and the completion
+func (r *RepairCheckpoint) ETA() (time.Duration, bool) {
+	percentage := r.Percentage()
+	if percentage <= 0 {
+		return 0, false
+	}
+
+	elapsed := time.Since(r.StartedAt)
+	return time.Duration(float64(elapsed) / percentage * (100 - percentage)), true
 }
 
 func NewOperation(client CacheClient, keyPrefix string) Operation {
@@ -59,18 +117,41 @@ func (o *operation) RepairDetail() (RepairDetail, error) {
 		}
 	}
 
+	online, has := v["online"]
+	if !has {
+		online = "false"
+	}
+
+	dryRun, has := v["dryRun"]
+	if !has {
+		dryRun = "false"
+	}
+
+	var checkpoint *RepairCheckpoint
+	if c, has := v["checkpoint"]; has && len(c) > 0 {
+		_c := &RepairCheckpoint{}
+		if err := json.Unmarshal([]byte(c), _c); err == nil {
+			checkpoint = _c
+		}
+	}
+
 	return RepairDetail{
 		Processing: strings.Compare(processing, "true") == 0,
+		Online:     strings.Compare(online, "true") == 0,
+		DryRun:     strings.Compare(dryRun, "true") == 0,
 		Timestamp:  timestamp,
+		Checkpoint: checkpoint,
 	}, nil
 }
 
-func (o *operation) SetRepairing(repairing bool, completed bool) error {
+func (o *operation) SetRepairing(repairing bool, online bool, dryRun bool, completed bool) error {
 	o.mutex.Lock()
 	defer o.mutex.Unlock()
 
 	content := make(map[string]string)
 	content["processing"] = strconv.FormatBool(repairing)
+	content["online"] = strconv.FormatBool(online)
+	content["dryRun"] = strconv.FormatBool(dryRun)
 	content["timestamp"] = ""
 
 	if completed {
@@ -80,4 +161,71 @@ func (o *operation) SetRepairing(repairing bool, completed bool) error {
 	return o.client.HMSet(o.key("repairing"), content)
 }
 
+func (o *operation) SaveCheckpoint(checkpoint *RepairCheckpoint) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	c, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	return o.client.HSet(o.key("repairing"), "checkpoint", string(c))
+}
+
+// Journal records the chunks that are created or deleted while an online repair is running
+func (o *operation) Journal(sha512HexList []string) error {
+	if len(sha512HexList) == 0 {
+		return nil
+	}
+
+	journaledAt := time.Now().UTC().Format(time.RFC3339)
+
+	content := make(map[string]string)
+	for _, sha512Hex := range sha512HexList {
+		content[sha512Hex] = journaledAt
+	}
+
+	return o.client.HMSet(o.key("repair_journal"), content)
+}
+
+func (o *operation) Journaled() (map[string]bool, error) {
+	v, err := o.client.HGetAll(o.key("repair_journal"))
+	if err != nil {
+		return nil, err
+	}
+
+	journaled := make(map[string]bool)
+	for sha512Hex := range v {
+		journaled[sha512Hex] = true
+	}
+	return journaled, nil
+}
+
+func (o *operation) ResetJournal() error {
+	return o.client.Del(o.key("repair_journal"))
+}
+
+// AppendReport adds the intended changes of the dry-run repair to the report
+func (o *operation) AppendReport(lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	args := append([]string{o.key("repair_report")}, lines...)
+	return o.client.Do(radix.Cmd(nil, "RPUSH", args...))
+}
+
+func (o *operation) Report() ([]string, error) {
+	lines := make([]string, 0)
+	if err := o.client.Do(radix.Cmd(&lines, "LRANGE", o.key("repair_report"), "0", "-1")); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+func (o *operation) ResetReport() error {
+	return o.client.Del(o.key("repair_report"))
+}
+
 var _ Operation = &operation{}
@@ -154,6 +154,9 @@ func main() {
 		logger.Error("Cluster Manager is failed", zap.Error(err))
 		os.Exit(25)
 	}
+	snapshotScheduler := manager.NewSnapshotScheduler(dataClusters, managerCluster, logger)
+	snapshotScheduler.Start()
+
 	managerRouter := routing.NewManagerRouter(managerCluster, synchronize, repair, health, logger)
 
 	if err := managerCluster.Handshake(); err != nil {
@@ -162,10 +165,14 @@ func main() {
 		logger.Info("Handshake is completed with cluster nodes...")
 	}
 
+	if err := repair.Resume(); err != nil {
+		logger.Error("Resuming interrupted repair is failed", zap.Error(err))
+	}
+
 	routerManager := routing.NewManager()
 	routerManager.Add(managerRouter)
 
-	managerNode := manager.NewNode(dataClusters, index, logger)
+	managerNode := manager.NewNode(dataClusters, index, operation, logger)
 	nodeRouter := routing.NewNodeRouter(managerNode, logger)
 	routerManager.Add(nodeRouter)
 
@@ -85,17 +85,17 @@ func (b *balance) Balance(clusterIds []string) error {
 		b.indexingMap[cluster.Id] = fileItemList
 	}
 
-	getSemaphoreFunc := func(clusterId string) chan bool {
+	getSemaphoreFunc := func(cluster *common.Cluster) chan bool {
 		b.semaphoreMutex.Lock()
 		defer b.semaphoreMutex.Unlock()
 
-		s, has := b.semaphoreChan[clusterId]
+		s, has := b.semaphoreChan[cluster.Id]
 		if !has {
-			s = make(chan bool, balanceSemaphoreLimit)
+			s = make(chan bool, moveConcurrency(cluster.Master(), balanceSemaphoreLimit))
 			for i := 0; i < cap(s); i++ {
 				s <- true
 			}
-			b.semaphoreChan[clusterId] = s
+			b.semaphoreChan[cluster.Id] = s
 		}
 		return s
 	}
@@ -134,7 +134,7 @@ func (b *balance) Balance(clusterIds []string) error {
 		atomicSizeFunc(emptiestCluster.Id, uint64(sourceCacheFileItem.FileItem.Size), true)
 		atomicSizeFunc(fullestCluster.Id, uint64(sourceCacheFileItem.FileItem.Size), false)
 
-		semaphoreChan := getSemaphoreFunc(emptiestCluster.Id)
+		semaphoreChan := getSemaphoreFunc(emptiestCluster)
 		<-semaphoreChan
 
 		wg.Add(1)
@@ -3,11 +3,13 @@ package manager
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
 	"github.com/freakmaxi/kertish-dos/basics/errors"
 	cluster2 "github.com/freakmaxi/kertish-dos/manager-node/cluster"
 	"github.com/freakmaxi/kertish-dos/manager-node/data"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
@@ -25,6 +27,8 @@ type Cluster interface {
 	GetCluster(clusterId string) (*common.Cluster, error)
 
 	Reserve(size uint64) (*common.ReservationMap, error)
+	// ReserveBatch creates the reservation maps for multiple files under a single reservation id
+	ReserveBatch(sizes []uint64) ([]*common.ReservationMap, error)
 	Commit(reservationId string, clusterMap map[string]uint64) error
 	Discard(reservationId string) error
 
@@ -32,10 +36,17 @@ type Cluster interface {
 	BalanceClusters(clusterIds []string) error
 	ChangeState(clusterId string, state common.States) error
 	ChangeStateAll(state common.States) error
+	// ChangeWriteQuorum sets the count of the nodes that should have the chunk before the creation is acknowledged
+	ChangeWriteQuorum(clusterId string, writeQuorum int) error
+	// ChangeThrottle sets the background transfer limit of the operation for the nodes of the target. targetId can
+	// be a cluster id, a node id or empty to apply all the nodes in the setup
+	ChangeThrottle(targetId string, operation common.ThrottleOperations, limit common.ThrottleLimit) error
 
 	CreateSnapshot(clusterId string) error
 	DeleteSnapshot(clusterId string, snapshotIndex uint64) error
 	RestoreSnapshot(clusterId string, snapshotIndex uint64) error
+	// ScheduleSnapshots sets the snapshot schedule of the cluster. nil schedule removes the existing one
+	ScheduleSnapshots(clusterId string, schedule *common.SnapshotSchedule) error
 
 	Map(sha512HexList []string, mapType common.MapType) (map[string][]string, error)
 	Find(sha512Hex string, mapType common.MapType) (string, []string, error)
@@ -298,7 +309,7 @@ func (c *cluster) Reserve(size uint64) (*common.ReservationMap, error) {
 
 	if err := c.clusters.SaveAll(func(clusters common.Clusters) error {
 		var err error
-		reservationMap, err = c.createReservationMap(size, clusters)
+		reservationMap, err = c.createReservationMap(uuid.New().String(), size, clusters)
 
 		return err
 	}); err != nil {
@@ -308,6 +319,27 @@ func (c *cluster) Reserve(size uint64) (*common.ReservationMap, error) {
 	return reservationMap, nil
 }
 
+func (c *cluster) ReserveBatch(sizes []uint64) ([]*common.ReservationMap, error) {
+	reservationMaps := make([]*common.ReservationMap, 0, len(sizes))
+
+	if err := c.clusters.SaveAll(func(clusters common.Clusters) error {
+		reservationId := uuid.New().String()
+
+		for _, size := range sizes {
+			reservationMap, err := c.createReservationMap(reservationId, size, clusters)
+			if err != nil {
+				return err
+			}
+			reservationMaps = append(reservationMaps, reservationMap)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return reservationMaps, nil
+}
+
 func (c *cluster) Commit(reservationId string, clusterMap map[string]uint64) error {
 	return c.clusters.SaveAll(func(clusters common.Clusters) error {
 		for _, cluster := range clusters {
@@ -362,6 +394,83 @@ func (c *cluster) ChangeStateAll(state common.States) error {
 	})
 }
 
+func (c *cluster) ChangeWriteQuorum(clusterId string, writeQuorum int) error {
+	quorumNodes := make(common.NodeList, 0)
+
+	if err := c.clusters.Save(clusterId, func(cluster *common.Cluster) error {
+		if writeQuorum < 0 || writeQuorum > len(cluster.Nodes) {
+			return fmt.Errorf("write quorum should be between 0 and the node count (%d) of the cluster", len(cluster.Nodes))
+		}
+		cluster.WriteQuorum = writeQuorum
+		quorumNodes = append(quorumNodes, cluster.Nodes...)
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Data nodes get the write quorum with the handshake
+	c.requestHandshake(quorumNodes, "Write quorum will be applied on the next handshake of the data node")
+
+	return nil
+}
+
+func (c *cluster) ChangeThrottle(targetId string, operation common.ThrottleOperations, limit common.ThrottleLimit) error {
+	throttledNodes := make(common.NodeList, 0)
+
+	if err := c.clusters.SaveAll(func(clusters common.Clusters) error {
+		for _, cluster := range clusters {
+			for _, node := range cluster.Nodes {
+				if len(targetId) > 0 && strings.Compare(cluster.Id, targetId) != 0 && strings.Compare(node.Id, targetId) != 0 {
+					continue
+				}
+				node.Throttle.SetLimit(operation, limit)
+				throttledNodes = append(throttledNodes, node)
+			}
+		}
+
+		if len(throttledNodes) == 0 {
+			return errors.ErrNotFound
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Data nodes get the throttle limits with the handshake
+	c.requestHandshake(throttledNodes, "Throttle limits will be applied on the next handshake of the data node")
+
+	return nil
+}
+
+// requestHandshake makes the data nodes to handshake with the manager to receive the changed cluster settings
+func (c *cluster) requestHandshake(nodes common.NodeList, failureMessage string) {
+	for _, node := range nodes {
+		dn, err := cluster2.NewDataNode(node.Address)
+		if err == nil && dn.RequestHandshake() {
+			continue
+		}
+
+		c.logger.Warn(
+			failureMessage,
+			zap.String("nodeId", node.Id),
+			zap.String("nodeAddress", node.Address),
+		)
+	}
+}
+
+func (c *cluster) ScheduleSnapshots(clusterId string, schedule *common.SnapshotSchedule) error {
+	return c.clusters.Save(clusterId, func(cluster *common.Cluster) error {
+		// snapshots of the previous schedule stay under the retention
+		if cluster.SnapshotSchedule != nil && schedule != nil {
+			schedule.Created = cluster.SnapshotSchedule.Created
+			schedule.Track(cluster.Snapshots, cluster.Snapshots)
+		}
+		cluster.SnapshotSchedule = schedule
+		return nil
+	})
+}
+
 func (c *cluster) CreateSnapshot(clusterId string) error {
 	cluster, err := c.clusters.Get(clusterId)
 	if err != nil {
@@ -5,14 +5,15 @@ import (
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
 	"github.com/freakmaxi/kertish-dos/basics/errors"
-	"github.com/google/uuid"
 )
 
 const blockSize uint32 = 1024 * 1024 * 32 // 32Mb
 
-func (c *cluster) createReservationMap(size uint64, clusters common.Clusters) (*common.ReservationMap, error) {
-	chunks := c.calculateChunks(size)
-	reservationId := uuid.New().String()
+func (c *cluster) createReservationMap(reservationId string, size uint64, clusters common.Clusters) (*common.ReservationMap, error) {
+	chunks, err := c.calculateChunks(size)
+	if err != nil {
+		return nil, err
+	}
 
 	r := make([]common.ClusterMap, 0)
 	for len(chunks) > 0 {
@@ -49,14 +50,20 @@ func (c *cluster) createReservationMap(size uint64, clusters common.Clusters) (*
 	}, nil
 }
 
-func (c *cluster) calculateChunks(size uint64) []common.Chunk {
+// calculateChunks splits the size in to the chunks of the block size. Returns errors.ErrTooLarge if the chunk count
+// exceeds the chunk sequence limit
+func (c *cluster) calculateChunks(size uint64) ([]common.Chunk, error) {
 	if size < uint64(blockSize) {
-		return []common.Chunk{{Index: 0, Size: uint32(size)}}
+		return []common.Chunk{{Index: 0, Size: uint32(size)}}, nil
+	}
+
+	if (size-1)/uint64(blockSize) > common.MaxChunkSequence {
+		return nil, errors.ErrTooLarge
 	}
 
 	chunks := make([]common.Chunk, 0)
 	idx := uint64(0)
-	for seq := uint16(0); idx < size; seq++ {
+	for seq := uint32(0); idx < size; seq++ {
 		chunkSize := blockSize
 		if (size - idx) < uint64(chunkSize) {
 			chunkSize = uint32(size - idx)
@@ -65,5 +72,5 @@ func (c *cluster) calculateChunks(size uint64) []common.Chunk {
 		idx += uint64(chunkSize)
 	}
 
-	return chunks
+	return chunks, nil
 }
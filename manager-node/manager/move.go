@@ -20,22 +20,27 @@ type move struct {
 	index       data.Index
 	synchronize Synchronize
 	logger      *zap.Logger
-
-	semaphoreWG   sync.WaitGroup
-	semaphoreChan chan bool
 }
 
 func newMove(clusters data.Clusters, index data.Index, synchronize Synchronize, logger *zap.Logger) *move {
 	return &move{
-		clusters:      clusters,
-		index:         index,
-		synchronize:   synchronize,
-		logger:        logger,
-		semaphoreWG:   sync.WaitGroup{},
-		semaphoreChan: make(chan bool, moveSemaphoreLimit),
+		clusters:    clusters,
+		index:       index,
+		synchronize: synchronize,
+		logger:      logger,
 	}
 }
 
+// moveConcurrency returns the parallel transfer count for the chunks that are moved to the node. The move throttle
+// of the node is used when it is defined and lower than the limit
+func moveConcurrency(targetNode *common.Node, limit int) int {
+	concurrency := targetNode.Throttle.Move.Concurrency
+	if concurrency > 0 && concurrency < limit {
+		return concurrency
+	}
+	return limit
+}
+
 func (m *move) Move(sourceClusterId string, targetClusterId string) error {
 	m.logger.Info(fmt.Sprintf("Cluster moving from %s to %s is started...", sourceClusterId, targetClusterId))
 
@@ -105,6 +110,9 @@ func (m *move) Move(sourceClusterId string, targetClusterId string) error {
 
 	m.logger.Info("Cluster moving operation is taking place...")
 
+	semaphoreWG := &sync.WaitGroup{}
+	semaphoreChan := make(chan bool, moveConcurrency(targetMasterNode, moveSemaphoreLimit))
+
 	bulkErr := errors.NewBulkError()
 	errorThreshold := int(float64(len(sourceContainer.FileItems)) * moveFailureThreshold)
 	for _, fileItem := range sourceContainer.FileItems {
@@ -115,11 +123,11 @@ func (m *move) Move(sourceClusterId string, targetClusterId string) error {
 			break
 		}
 
-		m.semaphoreChan <- true
-		m.semaphoreWG.Add(1)
-		go m.move(targetCluster.Id, targetMasterNode.Id, tmdn, sourceCluster.Id, sourceMasterNode.Address, fileItem, bulkErr)
+		semaphoreChan <- true
+		semaphoreWG.Add(1)
+		go m.move(semaphoreWG, semaphoreChan, targetCluster.Id, targetMasterNode.Id, tmdn, sourceCluster.Id, sourceMasterNode.Address, fileItem, bulkErr)
 	}
-	m.semaphoreWG.Wait()
+	semaphoreWG.Wait()
 	m.index.WaitQueueCompletion()
 
 	if bulkErr.HasError() {
@@ -146,6 +154,8 @@ func (m *move) Move(sourceClusterId string, targetClusterId string) error {
 }
 
 func (m *move) move(
+	semaphoreWG *sync.WaitGroup,
+	semaphoreChan chan bool,
 	targetClusterId string,
 	targetMasterNodeId string,
 	targetDataNode cluster2.DataNode,
@@ -154,8 +164,8 @@ func (m *move) move(
 	fileItem common.SyncFileItem,
 	bulkError *errors.BulkError) {
 	defer func() {
-		<-m.semaphoreChan
-		m.semaphoreWG.Done()
+		<-semaphoreChan
+		semaphoreWG.Done()
 	}()
 
 	if targetDataNode.SyncMove(fileItem.Sha512Hex, sourceNodeAddr) != nil {
@@ -12,15 +12,21 @@ import (
 const retryLimit = 10
 
 type Node interface {
-	Handshake(nodeHardwareAddr string, nodeAddress string, size uint64) (string, string, string, error)
-	Notify(nodeId string, notificationContainerList common.NotificationContainerList) error
+	// Handshake returns the cluster id, the node id, the master address for the slaves, the write quorum of the
+	// cluster and the throttle limits of the node
+	Handshake(nodeHardwareAddr string, nodeAddress string, size uint64) (string, string, string, int, *common.Throttle, error)
+	// Notify registers the chunk changes of the node. It returns the chunks that could not reach the write quorum
+	// of the cluster, the node should revert them
+	Notify(nodeId string, notificationContainerList common.NotificationContainerList) ([]string, error)
 }
 
 type node struct {
-	index    data.Index
-	clusters data.Clusters
+	index     data.Index
+	clusters  data.Clusters
+	operation data.Operation
 
 	nodeSyncManager *nodeSyncManager
+	nodeQuorum      *nodeQuorum
 }
 
 type targetContainer struct {
@@ -36,15 +42,17 @@ type nodeSync struct {
 	clusterId  string
 	sourceAddr string
 	sha512Hex  string
-	usage      uint16
+	usage      uint64
 	targets    []*targetContainer
 }
 
-func NewNode(clusters data.Clusters, index data.Index, logger *zap.Logger) Node {
+func NewNode(clusters data.Clusters, index data.Index, operation data.Operation, logger *zap.Logger) Node {
 	return &node{
 		index:           index,
 		clusters:        clusters,
+		operation:       operation,
 		nodeSyncManager: newNodeSyncManager(clusters, index, logger),
+		nodeQuorum:      newNodeQuorum(index, logger),
 	}
 }
 
@@ -60,12 +68,12 @@ func (n *node) makeTargetContainerList(nodes common.NodeList) []*targetContainer
 	return targetContainers
 }
 
-func (n *node) Handshake(nodeHardwareAddr string, nodeAddress string, size uint64) (string, string, string, error) {
+func (n *node) Handshake(nodeHardwareAddr string, nodeAddress string, size uint64) (string, string, string, int, *common.Throttle, error) {
 	nodeId := newNodeId(nodeHardwareAddr, nodeAddress, size)
 
 	cluster, err := n.clusters.GetByNodeId(nodeId)
 	if err != nil {
-		return "", "", "", err
+		return "", "", "", 0, nil, err
 	}
 
 	syncSourceAddrBind := ""
@@ -74,10 +82,12 @@ func (n *node) Handshake(nodeHardwareAddr string, nodeAddress string, size uint6
 		syncSourceAddrBind = cluster.Master().Address
 	}
 
-	return cluster.Id, node.Id, syncSourceAddrBind, nil
+	return cluster.Id, node.Id, syncSourceAddrBind, cluster.WriteQuorum, &node.Throttle, nil
 }
 
-func (n *node) Notify(nodeId string, notificationContainerList common.NotificationContainerList) error {
+func (n *node) Notify(nodeId string, notificationContainerList common.NotificationContainerList) ([]string, error) {
+	quorumFailedList := make([]string, 0)
+
 	creatingNotificationContainerList := make(common.NotificationContainerList, 0)
 	deletingNotificationContainerList := make(common.NotificationContainerList, 0)
 
@@ -88,7 +98,7 @@ func (n *node) Notify(nodeId string, notificationContainerList common.Notificati
 			if len(deletingNotificationContainerList) > 0 {
 				if err := n.delete(nodeId, deletingNotificationContainerList.ExportFileItemList()); err != nil {
 					deletingNotificationContainerList = append(deletingNotificationContainerList, notificationContainerList...)
-					return common.NewNotificationError(deletingNotificationContainerList, err)
+					return nil, common.NewNotificationError(deletingNotificationContainerList, err)
 				}
 				deletingNotificationContainerList = make(common.NotificationContainerList, 0)
 			}
@@ -99,10 +109,12 @@ func (n *node) Notify(nodeId string, notificationContainerList common.Notificati
 		}
 
 		if len(creatingNotificationContainerList) > 0 {
-			if err := n.create(nodeId, creatingNotificationContainerList.ExportFileItemList()); err != nil {
+			failedList, err := n.create(nodeId, creatingNotificationContainerList.ExportFileItemList())
+			if err != nil {
 				creatingNotificationContainerList = append(creatingNotificationContainerList, notificationContainerList...)
-				return common.NewNotificationError(creatingNotificationContainerList, err)
+				return nil, common.NewNotificationError(creatingNotificationContainerList, err)
 			}
+			quorumFailedList = append(quorumFailedList, failedList...)
 			creatingNotificationContainerList = make(common.NotificationContainerList, 0)
 		}
 
@@ -111,38 +123,68 @@ func (n *node) Notify(nodeId string, notificationContainerList common.Notificati
 	}
 
 	if len(creatingNotificationContainerList) > 0 {
-		if err := n.create(nodeId, creatingNotificationContainerList.ExportFileItemList()); err != nil {
-			return common.NewNotificationError(creatingNotificationContainerList, err)
+		failedList, err := n.create(nodeId, creatingNotificationContainerList.ExportFileItemList())
+		if err != nil {
+			return nil, common.NewNotificationError(creatingNotificationContainerList, err)
 		}
+		quorumFailedList = append(quorumFailedList, failedList...)
 	}
 
 	if len(deletingNotificationContainerList) > 0 {
 		if err := n.delete(nodeId, deletingNotificationContainerList.ExportFileItemList()); err != nil {
-			return common.NewNotificationError(deletingNotificationContainerList, err)
+			return nil, common.NewNotificationError(deletingNotificationContainerList, err)
 		}
 	}
 
-	return nil
+	return quorumFailedList, nil
 }
 
-func (n *node) create(nodeId string, fileItemList common.SyncFileItemList) error {
+func (n *node) create(nodeId string, fileItemList common.SyncFileItemList) ([]string, error) {
 	cluster, err := n.clusters.GetByNodeId(nodeId)
 	if err != nil {
-		return fmt.Errorf("getting cluster by node id is failed. nodeId: %s, error: %s", nodeId, err)
+		return nil, fmt.Errorf("getting cluster by node id is failed. nodeId: %s, error: %s", nodeId, err)
 	}
 
 	sourceNode := cluster.Node(nodeId)
 	targetNodes := cluster.Others(nodeId)
 	if targetNodes == nil {
-		return fmt.Errorf("node id didn't match to get others: %s", nodeId)
+		return nil, fmt.Errorf("node id didn't match to get others: %s", nodeId)
+	}
+
+	if err := n.journal(fileItemList); err != nil {
+		return nil, err
+	}
+
+	syncReplicas := 0
+	replicated := make(map[string]map[string]bool)
+	if sourceNode.Master {
+		syncReplicas = cluster.SyncReplicas()
+		if syncReplicas > 0 {
+			replicated = n.nodeQuorum.Replicate(sourceNode.Address, fileItemList, targetNodes, syncReplicas)
+		}
 	}
 
+	quorumFailedList := make([]string, 0)
 	nodeSyncItems := make([]*nodeSync, 0)
 
 	for _, fileItem := range fileItemList {
 		n.index.QueueUpsert(common.NewCacheFileItem(cluster.Id, nodeId, fileItem), nil)
 
-		if len(targetNodes) == 0 {
+		replicatedNodeIds := replicated[fileItem.Sha512Hex]
+		if len(replicatedNodeIds) < syncReplicas {
+			// the node reverts the chunk and the revert is synced to the nodes that already have it
+			quorumFailedList = append(quorumFailedList, fileItem.Sha512Hex)
+			continue
+		}
+
+		remainingNodes := make(common.NodeList, 0)
+		for _, targetNode := range targetNodes {
+			if !replicatedNodeIds[targetNode.Id] {
+				remainingNodes = append(remainingNodes, targetNode)
+			}
+		}
+
+		if len(remainingNodes) == 0 {
 			continue
 		}
 
@@ -153,13 +195,13 @@ func (n *node) create(nodeId string, fileItemList common.SyncFileItemList) error
 			sourceAddr: sourceNode.Address,
 			sha512Hex:  fileItem.Sha512Hex,
 			usage:      fileItem.Usage,
-			targets:    n.makeTargetContainerList(targetNodes),
+			targets:    n.makeTargetContainerList(remainingNodes),
 		})
 	}
 
 	n.nodeSyncManager.QueueMany(nodeSyncItems)
 
-	return nil
+	return quorumFailedList, nil
 }
 
 func (n *node) delete(nodeId string, fileItemList common.SyncFileItemList) error {
@@ -168,6 +210,10 @@ func (n *node) delete(nodeId string, fileItemList common.SyncFileItemList) error
 		return fmt.Errorf("getting cluster by node id is failed. nodeId: %s, error: %s", nodeId, err)
 	}
 
+	if err := n.journal(fileItemList); err != nil {
+		return err
+	}
+
 	return n.clusters.Save(cluster.Id, func(cluster *common.Cluster) error {
 		sourceNode := cluster.Node(nodeId)
 		targetNodes := cluster.Others(nodeId)
@@ -202,4 +248,25 @@ func (n *node) delete(nodeId string, fileItemList common.SyncFileItemList) error
 	})
 }
 
+// journal keeps the track of the chunk changes for the online repair to not to touch the in-flight chunks
+func (n *node) journal(fileItemList common.SyncFileItemList) error {
+	repairDetail, err := n.operation.RepairDetail()
+	if err != nil {
+		return fmt.Errorf("getting repair detail is failed for journaling: %s", err)
+	}
+	if !repairDetail.Processing || !repairDetail.Online {
+		return nil
+	}
+
+	sha512HexList := make([]string, 0, len(fileItemList))
+	for _, fileItem := range fileItemList {
+		sha512HexList = append(sha512HexList, fileItem.Sha512Hex)
+	}
+
+	if err := n.operation.Journal(sha512HexList); err != nil {
+		return fmt.Errorf("journaling chunk changes for online repair is failed: %s", err)
+	}
+	return nil
+}
+
 var _ Node = &node{}
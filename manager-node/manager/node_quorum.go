@@ -0,0 +1,119 @@
+package manager
+
+import (
+	"sync"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	cluster2 "github.com/freakmaxi/kertish-dos/manager-node/cluster"
+	"github.com/freakmaxi/kertish-dos/manager-node/data"
+	"go.uber.org/zap"
+)
+
+// quorumTimeout should stay well under the quorum wait duration of the data node (60 seconds), otherwise
+// the data node reverts the creation before the quorum result arrives
+const quorumTimeout = time.Second * 20
+
+// nodeQuorum copies the created chunks to the slave nodes synchronously till the write quorum of the cluster
+// is satisfied. The nodes that could not receive the chunk in time are left to the async sync queue
+type nodeQuorum struct {
+	nodeCacheMutex sync.Mutex
+	nodeCache      map[string]cluster2.DataNode
+
+	index  data.Index
+	logger *zap.Logger
+}
+
+func newNodeQuorum(index data.Index, logger *zap.Logger) *nodeQuorum {
+	return &nodeQuorum{
+		nodeCacheMutex: sync.Mutex{},
+		nodeCache:      make(map[string]cluster2.DataNode),
+		index:          index,
+		logger:         logger,
+	}
+}
+
+func (q *nodeQuorum) get(node *common.Node) (cluster2.DataNode, error) {
+	q.nodeCacheMutex.Lock()
+	defer q.nodeCacheMutex.Unlock()
+
+	dn, has := q.nodeCache[node.Address]
+	if !has {
+		var err error
+		dn, err = cluster2.NewDataNode(node.Address)
+		if err != nil {
+			return nil, err
+		}
+		q.nodeCache[node.Address] = dn
+	}
+
+	return dn, nil
+}
+
+// Replicate copies the file items to the target nodes and returns the node ids that have the chunk for each
+// item. It returns as soon as every item reaches the replica count or fails to reach it
+func (q *nodeQuorum) Replicate(sourceAddr string, fileItemList common.SyncFileItemList, targetNodes common.NodeList, replicas int) map[string]map[string]bool {
+	completedMutex := sync.Mutex{}
+	completed := make(map[string]map[string]bool)
+
+	wg := &sync.WaitGroup{}
+	for _, fileItem := range fileItemList {
+		completed[fileItem.Sha512Hex] = make(map[string]bool)
+
+		wg.Add(1)
+		go func(fileItem common.SyncFileItem) {
+			defer wg.Done()
+
+			for _, nodeId := range q.replicate(sourceAddr, fileItem, targetNodes, replicas) {
+				completedMutex.Lock()
+				completed[fileItem.Sha512Hex][nodeId] = true
+				completedMutex.Unlock()
+			}
+		}(fileItem)
+	}
+	wg.Wait()
+
+	return completed
+}
+
+func (q *nodeQuorum) replicate(sourceAddr string, fileItem common.SyncFileItem, targetNodes common.NodeList, replicas int) []string {
+	resultChan := make(chan string, len(targetNodes))
+
+	for _, targetNode := range targetNodes {
+		go func(targetNode *common.Node) {
+			dn, err := q.get(targetNode)
+			if err == nil {
+				err = dn.SyncCreateWait(fileItem.Sha512Hex, fileItem.Usage, sourceAddr, quorumTimeout)
+			}
+			if err != nil {
+				q.logger.Warn(
+					"Quorum sync is unsuccessful (CREATE)",
+					zap.String("sha512Hex", fileItem.Sha512Hex),
+					zap.String("targetNodeId", targetNode.Id),
+					zap.String("sourceAddress", sourceAddr),
+					zap.Error(err),
+				)
+				resultChan <- ""
+				return
+			}
+
+			q.index.QueueUpsertChunkNode(fileItem.Sha512Hex, targetNode.Id)
+			resultChan <- targetNode.Id
+		}(targetNode)
+	}
+
+	nodeIds := make([]string, 0)
+	for i := 0; i < len(targetNodes); i++ {
+		nodeId := <-resultChan
+		if len(nodeId) == 0 {
+			continue
+		}
+
+		nodeIds = append(nodeIds, nodeId)
+		if len(nodeIds) >= replicas {
+			break
+		}
+	}
+
+	return nodeIds
+}
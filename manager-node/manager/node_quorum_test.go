@@ -0,0 +1,235 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	cluster2 "github.com/freakmaxi/kertish-dos/manager-node/cluster"
+	"github.com/freakmaxi/kertish-dos/manager-node/data"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type testQuorumDataNode struct {
+	cluster2.DataNode
+
+	delay time.Duration
+	err   error
+
+	mutex   sync.Mutex
+	created []string
+	timeout time.Duration
+}
+
+func (d *testQuorumDataNode) SyncCreateWait(sha512Hex string, _ uint64, _ string, timeout time.Duration) error {
+	time.Sleep(d.delay)
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.timeout = timeout
+	if d.err != nil {
+		return d.err
+	}
+	d.created = append(d.created, sha512Hex)
+
+	return nil
+}
+
+type testQuorumIndex struct {
+	data.Index
+
+	mutex      sync.Mutex
+	chunkNodes map[string][]string
+	upserted   []string
+}
+
+func newTestQuorumIndex() *testQuorumIndex {
+	return &testQuorumIndex{
+		chunkNodes: make(map[string][]string),
+		upserted:   make([]string, 0),
+	}
+}
+
+func (i *testQuorumIndex) QueueUpsertChunkNode(sha512Hex string, nodeId string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.chunkNodes[sha512Hex] = append(i.chunkNodes[sha512Hex], nodeId)
+	sort.Strings(i.chunkNodes[sha512Hex])
+}
+
+func (i *testQuorumIndex) QueueUpsert(item *common.CacheFileItem, _ *time.Time) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.upserted = append(i.upserted, item.FileItem.Sha512Hex)
+}
+
+func (i *testQuorumIndex) nodes(sha512Hex string) []string {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	return i.chunkNodes[sha512Hex]
+}
+
+type testQuorumClusters struct {
+	data.Clusters
+
+	cluster *common.Cluster
+}
+
+func (c *testQuorumClusters) GetByNodeId(_ string) (*common.Cluster, error) {
+	return c.cluster, nil
+}
+
+type testQuorumOperation struct {
+	data.Operation
+}
+
+func (o *testQuorumOperation) RepairDetail() (data.RepairDetail, error) {
+	return data.RepairDetail{}, nil
+}
+
+// newTestNodeQuorum creates the quorum that uses the data nodes in the map of the node ids. Node ids are used as
+// the node addresses
+func newTestNodeQuorum(index data.Index, dataNodes map[string]*testQuorumDataNode) (*nodeQuorum, common.NodeList) {
+	q := newNodeQuorum(index, zap.NewNop())
+
+	nodes := make(common.NodeList, 0)
+	for nodeId, dn := range dataNodes {
+		q.nodeCache[nodeId] = dn
+		nodes = append(nodes, &common.Node{Id: nodeId, Address: nodeId})
+	}
+	sort.Sort(nodes)
+
+	return q, nodes
+}
+
+func TestNodeQuorum_Replicate(t *testing.T) {
+	index := newTestQuorumIndex()
+	q, targetNodes := newTestNodeQuorum(index, map[string]*testQuorumDataNode{
+		"node-1": {},
+		"node-2": {delay: time.Millisecond * 50},
+		"node-3": {err: fmt.Errorf("unreachable")},
+	})
+
+	fileItems := common.SyncFileItemList{
+		{Sha512Hex: "first", Usage: 1, Size: 10},
+		{Sha512Hex: "second", Usage: 1, Size: 20},
+	}
+
+	completed := q.Replicate("master", fileItems, targetNodes, 2)
+
+	// the slow node is waited because the failed node can not complete the quorum
+	for _, fileItem := range fileItems {
+		assert.Equal(t, map[string]bool{"node-1": true, "node-2": true}, completed[fileItem.Sha512Hex])
+		assert.Equal(t, []string{"node-1", "node-2"}, index.nodes(fileItem.Sha512Hex))
+	}
+
+	dn := q.nodeCache["node-1"].(*testQuorumDataNode)
+	assert.Equal(t, quorumTimeout, dn.timeout)
+}
+
+func TestNodeQuorum_ReplicateReturnsOnQuorum(t *testing.T) {
+	index := newTestQuorumIndex()
+	q, targetNodes := newTestNodeQuorum(index, map[string]*testQuorumDataNode{
+		"node-1": {},
+		"node-2": {delay: time.Second},
+	})
+
+	begins := time.Now()
+	completed := q.Replicate("master", common.SyncFileItemList{{Sha512Hex: "chunk", Usage: 1}}, targetNodes, 1)
+
+	// the slow node is left to complete in the background
+	assert.Less(t, time.Since(begins), time.Millisecond*500)
+	assert.Equal(t, map[string]bool{"node-1": true}, completed["chunk"])
+}
+
+func TestNodeQuorum_ReplicateMissed(t *testing.T) {
+	index := newTestQuorumIndex()
+	q, targetNodes := newTestNodeQuorum(index, map[string]*testQuorumDataNode{
+		"node-1": {},
+		"node-2": {err: fmt.Errorf("unreachable")},
+		"node-3": {err: fmt.Errorf("timeout")},
+	})
+
+	completed := q.Replicate("master", common.SyncFileItemList{{Sha512Hex: "chunk", Usage: 1}}, targetNodes, 2)
+
+	assert.Equal(t, map[string]bool{"node-1": true}, completed["chunk"])
+	assert.Equal(t, []string{"node-1"}, index.nodes("chunk"))
+}
+
+func TestNode_CreateQuorum(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		err      error
+		expected []string
+	}{
+		{name: "Met", err: nil, expected: []string{}},
+		{name: "Missed", err: fmt.Errorf("unreachable"), expected: []string{"chunk"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := common.NewCluster("cluster")
+			cluster.WriteQuorum = 2
+			cluster.Nodes = common.NodeList{
+				{Id: "master", Address: "master", Master: true},
+				{Id: "slave", Address: "slave"},
+			}
+
+			index := newTestQuorumIndex()
+			q, _ := newTestNodeQuorum(index, map[string]*testQuorumDataNode{
+				"slave": {err: test.err},
+			})
+
+			n := &node{
+				index:           index,
+				clusters:        &testQuorumClusters{cluster: cluster},
+				operation:       &testQuorumOperation{},
+				nodeSyncManager: newNodeSyncManager(nil, index, zap.NewNop()),
+				nodeQuorum:      q,
+			}
+
+			failed, err := n.create("master", common.SyncFileItemList{{Sha512Hex: "chunk", Usage: 1, Size: 10}})
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, failed)
+
+			// the chunk of the master is indexed in any case, the revert of the data node drops it
+			assert.Equal(t, []string{"chunk"}, index.upserted)
+		})
+	}
+}
+
+func TestNode_CreateQuorumOnSlave(t *testing.T) {
+	cluster := common.NewCluster("cluster")
+	cluster.WriteQuorum = 2
+	cluster.Nodes = common.NodeList{
+		{Id: "master", Address: "master", Master: true},
+		{Id: "slave", Address: "slave"},
+	}
+
+	index := newTestQuorumIndex()
+	q, _ := newTestNodeQuorum(index, map[string]*testQuorumDataNode{
+		"master": {err: fmt.Errorf("should not be called")},
+	})
+
+	n := &node{
+		index:           index,
+		clusters:        &testQuorumClusters{cluster: cluster},
+		operation:       &testQuorumOperation{},
+		nodeSyncManager: newNodeSyncManager(nil, index, zap.NewNop()),
+		nodeQuorum:      q,
+	}
+
+	// the chunks of the slaves are not replicated synchronously
+	n.nodeSyncManager.workers[cluster.Id] = newNodeSyncWorker(nil, index, zap.NewNop())
+
+	failed, err := n.create("slave", common.SyncFileItemList{{Sha512Hex: "chunk", Usage: 1, Size: 10}})
+	assert.Nil(t, err)
+	assert.Empty(t, failed)
+	assert.Empty(t, q.nodeCache["master"].(*testQuorumDataNode).created)
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
 	"github.com/freakmaxi/kertish-dos/basics/errors"
@@ -32,8 +33,10 @@ const (
 )
 
 type Repair interface {
-	Start(repairType RepairType) error
+	Start(repairType RepairType, online bool, dryRun bool) error
+	Resume() error
 	Status() data.RepairDetail
+	Report() ([]string, error)
 }
 
 type repair struct {
@@ -43,6 +46,8 @@ type repair struct {
 	operation   data.Operation
 	synchronize Synchronize
 	logger      *zap.Logger
+
+	progress *repairProgress
 }
 
 func NewRepair(clusters data.Clusters, metadata data.Metadata, index data.Index, operation data.Operation, synchronize Synchronize, logger *zap.Logger) Repair {
@@ -64,15 +69,15 @@ func (r *repair) Status() data.RepairDetail {
 	return v
 }
 
-func (r *repair) Start(repairType RepairType) error {
+func (r *repair) Report() ([]string, error) {
+	return r.operation.Report()
+}
+
+func (r *repair) Start(repairType RepairType, online bool, dryRun bool) error {
 	if r.Status().Processing {
 		return errors.ErrProcessing
 	}
 
-	if err := r.operation.SetRepairing(true, false); err != nil {
-		return err
-	}
-
 	clusters, err := r.clusters.GetAll()
 	if err != nil {
 		return err
@@ -82,46 +87,135 @@ func (r *repair) Start(repairType RepairType) error {
 		if !cluster.CanSchedule() {
 			return errors.ErrNotAvailableForClusterAction
 		}
-		if err := r.clusters.UpdateStateWithMaintain(cluster.Id, common.StateReadonly, true, common.TopicRepair); err != nil {
+	}
+
+	if online {
+		// Journal should be clean before online repair starts to collect the chunk changes
+		if err := r.operation.ResetJournal(); err != nil {
 			return err
 		}
+	}
+
+	if err := r.operation.ResetReport(); err != nil {
+		return err
+	}
 
-		cluster.Maintain = true
-		cluster.State = common.StateReadonly
+	checkpoint := &data.RepairCheckpoint{
+		Type:              int(repairType),
+		StartedAt:         time.Now().UTC(),
+		Phases:            repairPhases(repairType, online),
+		CompletedClusters: make([]string, 0),
+	}
+	if err := r.operation.SaveCheckpoint(checkpoint); err != nil {
+		return err
 	}
 
-	go func() {
-		zapRepairType := zap.String("repairType", "full")
-		switch repairType {
-		case RTStructure:
-			zapRepairType = zap.String("repairType", "structure")
-		case RTStructureWithIntegrity:
-			zapRepairType = zap.String("repairType", "structure with integrity")
-		case RTIntegrity:
-			zapRepairType = zap.String("repairType", "integrity")
-		case RTIntegrityWithChecksumRebuild:
-			zapRepairType = zap.String("repairType", "integrity with rebuilding checksum calculation")
-		case RTCalculatingMissingChecksum:
-			zapRepairType = zap.String("repairType", "calculating missing checksums")
-		case RTChecksumRebuild:
-			zapRepairType = zap.String("repairType", "rebuilding checksum calculation")
+	if err := r.operation.SetRepairing(true, online, dryRun, false); err != nil {
+		return err
+	}
+
+	if !online {
+		for _, cluster := range clusters {
+			if err := r.clusters.UpdateStateWithMaintain(cluster.Id, common.StateReadonly, true, common.TopicRepair); err != nil {
+				r.releaseClusters(clusters)
+				_ = r.operation.SetRepairing(false, online, dryRun, false)
+				return err
+			}
+
+			cluster.Maintain = true
+			cluster.State = common.StateReadonly
 		}
-		r.logger.Info("Consistency repair is started...", zapRepairType)
+	}
+
+	go r.run(clusters, checkpoint, online, dryRun)
+
+	return nil
+}
 
-		if err := r.start(clusters, repairType); err != nil {
-			r.releaseClusters(clusters)
-			_ = r.operation.SetRepairing(false, false)
-			r.logger.Error("Consistency repair is failed", zap.Error(err))
-			return
+// Resume continues the repair from its last checkpoint if it was interrupted by a manager restart
+func (r *repair) Resume() error {
+	repairDetail, err := r.operation.RepairDetail()
+	if err != nil {
+		return err
+	}
+	if !repairDetail.Processing {
+		return nil
+	}
+
+	if repairDetail.Checkpoint == nil {
+		r.logger.Warn("Interrupted repair does not have a checkpoint to resume, it is cancelled")
+		return r.operation.SetRepairing(false, repairDetail.Online, repairDetail.DryRun, false)
+	}
+
+	clusters, err := r.clusters.GetAll()
+	if err != nil {
+		return err
+	}
+
+	// Offline repair keeps the clusters in readonly and maintain mode from the beginning
+	if !repairDetail.Online {
+		for _, cluster := range clusters {
+			if err := r.clusters.UpdateStateWithMaintain(cluster.Id, common.StateReadonly, true, common.TopicRepair); err != nil {
+				return err
+			}
+
+			cluster.Maintain = true
+			cluster.State = common.StateReadonly
 		}
-		r.releaseClusters(clusters)
-		_ = r.operation.SetRepairing(false, true)
-		r.logger.Info("Consistency repair is completed")
-	}()
+	}
+
+	r.logger.Info(
+		"Interrupted consistency repair is resuming...",
+		zap.Strings("phases", repairDetail.Checkpoint.Phases),
+		zap.Int("phase", repairDetail.Checkpoint.Phase),
+	)
+
+	go r.run(clusters, repairDetail.Checkpoint, repairDetail.Online, repairDetail.DryRun)
 
 	return nil
 }
 
+func (r *repair) run(clusters common.Clusters, checkpoint *data.RepairCheckpoint, online bool, dryRun bool) {
+	repairType := RepairType(checkpoint.Type)
+
+	zapRepairType := zap.String("repairType", "full")
+	switch repairType {
+	case RTStructure:
+		zapRepairType = zap.String("repairType", "structure")
+	case RTStructureWithIntegrity:
+		zapRepairType = zap.String("repairType", "structure with integrity")
+	case RTIntegrity:
+		zapRepairType = zap.String("repairType", "integrity")
+	case RTIntegrityWithChecksumRebuild:
+		zapRepairType = zap.String("repairType", "integrity with rebuilding checksum calculation")
+	case RTCalculatingMissingChecksum:
+		zapRepairType = zap.String("repairType", "calculating missing checksums")
+	case RTChecksumRebuild:
+		zapRepairType = zap.String("repairType", "rebuilding checksum calculation")
+	}
+	r.logger.Info("Consistency repair is started...", zapRepairType, zap.Bool("online", online), zap.Bool("dryRun", dryRun))
+
+	r.progress = newRepairProgress(r.operation, checkpoint, dryRun, r.logger)
+
+	var err error
+	if online {
+		err = r.startOnline(clusters, repairType)
+		_ = r.operation.ResetJournal()
+	} else {
+		err = r.start(clusters, repairType)
+		r.releaseClusters(clusters)
+	}
+	r.progress.flush()
+
+	if err != nil {
+		_ = r.operation.SetRepairing(false, online, dryRun, false)
+		r.logger.Error("Consistency repair is failed", zap.Error(err))
+		return
+	}
+	_ = r.operation.SetRepairing(false, online, dryRun, true)
+	r.logger.Info("Consistency repair is completed")
+}
+
 func (r *repair) releaseClusters(clusters common.Clusters) {
 	for _, cluster := range clusters {
 		if err := r.clusters.UpdateStateWithMaintain(cluster.Id, common.StateOnline, false, common.TopicNone); err != nil {
@@ -149,7 +243,7 @@ func (r *repair) start(clusters common.Clusters, repairType RepairType) error {
 	repairStructure := repairType == RTFull || repairType == RTStructure || repairType == RTStructureWithIntegrity
 	repairIntegrity := repairType == RTFull || repairType == RTStructureWithIntegrity || repairType == RTIntegrity || repairType == RTIntegrityWithChecksumRebuild
 
-	if repairStructure {
+	if repairStructure && !r.progress.passed(rpStructure) {
 		r.logger.Info("Repairing metadata structure consistency...")
 		if err := r.repairStructure(); err != nil {
 			return err
@@ -169,7 +263,9 @@ func (r *repair) start(clusters common.Clusters, repairType RepairType) error {
 }
 
 func (r *repair) repairStructure() error {
-	return r.metadata.LockTree(func(folders []*common.Folder) ([]*common.Folder, error) {
+	r.progress.begin(rpStructure, 0)
+
+	if err := r.metadata.LockTree(func(folders []*common.Folder) ([]*common.Folder, error) {
 		if len(folders) == 0 {
 			return nil, nil
 		}
@@ -178,8 +274,28 @@ func (r *repair) repairStructure() error {
 		if err := tree.Fill(nil, folders); err != nil {
 			return nil, err
 		}
-		return tree.Normalize(), nil
-	})
+		normalized := tree.Normalize()
+
+		if !r.progress.dryRun {
+			return normalized, nil
+		}
+
+		existing := make(map[string]bool)
+		for _, folder := range folders {
+			existing[folder.Full] = true
+		}
+		for _, folder := range normalized {
+			if !existing[folder.Full] {
+				r.progress.record("create-folder", folder.Full)
+			}
+		}
+		return nil, nil
+	}); err != nil {
+		return err
+	}
+
+	r.progress.finish(rpStructure)
+	return nil
 }
 
 func (r *repair) repairIntegrity(clusters common.Clusters, rebuildChecksum bool) error {
@@ -199,10 +315,12 @@ func (r *repair) repairIntegrity(clusters common.Clusters, rebuildChecksum bool)
 		clusterMap[cluster.Id] = cluster
 	}
 
-	r.logger.Info("Phase 1: Repairing metadata usage alignment...")
-	if err := r.repairIntegrityPhase1(clusterIndexMap, clusterMap); err != nil {
-		r.metadata.Unlock()
-		return err
+	if !r.progress.passed(rpIntegrityUsage) {
+		r.logger.Info("Phase 1: Repairing metadata usage alignment...")
+		if err := r.repairIntegrityPhase1(clusterIndexMap, clusterMap); err != nil {
+			r.metadata.Unlock()
+			return err
+		}
 	}
 
 	r.metadata.Unlock()
@@ -291,7 +409,7 @@ func (r *repair) repairIntegrityPhase1(clusterIndexMap map[string]map[string]str
 	}
 
 	metadataUsageMapMutex := sync.Mutex{}
-	metadataUsageMap := make(map[string]uint16)
+	metadataUsageMap := make(map[string]uint64)
 	increaseUsageMapFunc := func(sha512Hex string) {
 		metadataUsageMapMutex.Lock()
 		defer metadataUsageMapMutex.Unlock()
@@ -302,20 +420,28 @@ func (r *repair) repairIntegrityPhase1(clusterIndexMap map[string]map[string]str
 		metadataUsageMap[sha512Hex]++
 	}
 
+	total, err := r.metadata.Count()
+	if err != nil {
+		return err
+	}
+	r.progress.begin(rpIntegrityUsage, total)
+
 	r.logger.Info("Start traversing metadata entries for usage alignment cache")
 
 	if err := r.metadata.Cursor(func(folder *common.Folder) (bool, error) {
+		defer r.progress.step()
+
 		if len(folder.Files) == 0 {
 			return false, nil
 		}
 
 		for _, file := range folder.Files {
-			for _, chunk := range file.Chunks {
+			for _, chunk := range file.Chunks.Remote() {
 				increaseUsageMapFunc(chunk.Hash)
 			}
 
 			// Cache missing hashes in case of index matching
-			for _, chunk := range file.Missing {
+			for _, chunk := range file.Missing.Remote() {
 				increaseUsageMapFunc(chunk.Hash)
 			}
 		}
@@ -327,7 +453,7 @@ func (r *repair) repairIntegrityPhase1(clusterIndexMap map[string]map[string]str
 
 	r.logger.Info("Examine usages of metadata entries with data nodes")
 
-	mismatchedUsageMap := make(map[string]map[string]uint16)
+	mismatchedUsageMap := make(map[string]map[string]uint64)
 
 	for sha512Hex, metadataUsage := range metadataUsageMap {
 		indexValue, has := indexUsageMap[sha512Hex]
@@ -344,12 +470,12 @@ func (r *repair) repairIntegrityPhase1(clusterIndexMap map[string]map[string]str
 			return fmt.Errorf("faulty index entry for %s", sha512Hex)
 		}
 
-		indexUsage, err := strconv.ParseUint(indexValue[:pipeIdx], 10, 16)
+		indexUsage, err := strconv.ParseUint(indexValue[:pipeIdx], 10, 64)
 		if err != nil {
 			return err
 		}
 
-		if metadataUsage == uint16(indexUsage) {
+		if metadataUsage == indexUsage {
 			continue
 		}
 
@@ -365,20 +491,29 @@ func (r *repair) repairIntegrityPhase1(clusterIndexMap map[string]map[string]str
 		}
 
 		if _, has := mismatchedUsageMap[cluster.Id]; !has {
-			mismatchedUsageMap[cluster.Id] = make(map[string]uint16)
+			mismatchedUsageMap[cluster.Id] = make(map[string]uint64)
 		}
 		mismatchedUsageMap[cluster.Id][sha512Hex] = metadataUsage
 
 		r.logger.Warn(
 			fmt.Sprintf("Found mismatching usage for %s, expected: %d, found: %d", sha512Hex, metadataUsage, indexUsage),
 			zap.String("sha512Hex", sha512Hex),
-			zap.Uint16("metadataUsage", metadataUsage),
-			zap.Uint16("indexUsage", uint16(indexUsage)),
+			zap.Uint64("metadataUsage", metadataUsage),
+			zap.Uint64("indexUsage", indexUsage),
 		)
 	}
 
 	if len(mismatchedUsageMap) == 0 {
 		r.logger.Info("Metadata and data nodes are perfectly aligned, nothing to do here...")
+		r.progress.finish(rpIntegrityUsage)
+		return nil
+	}
+
+	if r.progress.dryRun {
+		for clusterId, usageMap := range mismatchedUsageMap {
+			r.recordUsages(clusterId, usageMap)
+		}
+		r.progress.finish(rpIntegrityUsage)
 		return nil
 	}
 
@@ -405,10 +540,19 @@ func (r *repair) repairIntegrityPhase1(clusterIndexMap map[string]map[string]str
 		return bulkError
 	}
 
+	r.progress.finish(rpIntegrityUsage)
 	return nil
 }
 
-func (r *repair) fixUsage(wg *sync.WaitGroup, clusterId string, masterNode *common.Node, usageMap map[string]uint16, errCh chan error) {
+// recordUsages adds the intended usage changes of the cluster to the dry-run report
+func (r *repair) recordUsages(clusterId string, usageMap map[string]uint64) {
+	r.progress.usagesFixed(len(usageMap))
+	for sha512Hex, usage := range usageMap {
+		r.progress.record("usage", clusterId, sha512Hex, strconv.FormatUint(uint64(usage), 10))
+	}
+}
+
+func (r *repair) fixUsage(wg *sync.WaitGroup, clusterId string, masterNode *common.Node, usageMap map[string]uint64, errCh chan error) {
 	defer wg.Done()
 
 	mdn, err := cluster2.NewDataNode(masterNode.Address)
@@ -435,6 +579,7 @@ func (r *repair) fixUsage(wg *sync.WaitGroup, clusterId string, masterNode *comm
 		errCh <- err
 		return
 	}
+	r.progress.usagesFixed(len(usageMap))
 }
 
 func (r *repair) repairIntegrityPhase2(checksumRebuild bool, clusterIndexMap map[string]map[string]string, clusterMap map[string]*common.Cluster) error {
@@ -446,139 +591,197 @@ func (r *repair) repairIntegrityPhase2(checksumRebuild bool, clusterIndexMap map
 		delete(clusterIndexMap[clusterId], sha512Hex)
 	}
 
+	total, err := r.metadata.Count()
+	if err != nil {
+		return err
+	}
+	r.progress.begin(rpIntegrityChunk, total)
+
 	r.logger.Info("Start traversing metadata entries for integrity check up")
 
 	if err := r.metadata.Cursor(func(folder *common.Folder) (bool, error) {
+		defer r.progress.step()
+
 		if len(folder.Files) == 0 {
 			return false, nil
 		}
 
 		for _, file := range folder.Files {
-			file.Resurrect()
+			if err := r.checkFileIntegrity(folder.Full, file, checksumRebuild, clusterMap, deleteFromIndexMapFunc); err != nil {
+				return false, err
+			}
+		}
 
-			if len(file.Chunks) == 0 {
-				r.logger.Warn(
-					"Every file should have at least one chunk entry, this file does not.",
-					zap.String("filePath", folder.Full),
-					zap.String("fileName", file.Name),
-				)
+		return !r.progress.dryRun, nil
+	}, parallelRepair); err != nil {
+		return err
+	}
 
-				file.Size = 0
-				file.Zombie = true
+	r.progress.finish(rpIntegrityChunk)
+	r.progress.begin(rpOrphanCleanup, uint64(len(clusterIndexMap)))
 
-				continue
-			}
+	r.logger.Info("Start orphan chunk cleanup on clusters")
 
-			deletionResult := common.NewDeletionResult()
+	// Make Orphan File Chunk Cleanup
+	wg := &sync.WaitGroup{}
+	for clusterId, indexMap := range clusterIndexMap {
+		if r.progress.clusterCompleted(clusterId) {
+			continue
+		}
+		masterNode := clusterMap[clusterId].Master()
 
-			sha512Failed := false
-			sha512Hash := sha512.New512_256()
+		wg.Add(1)
+		go r.cleanupOrphan(wg, clusterId, masterNode, indexMap, false)
+	}
+	wg.Wait()
 
-			sort.Sort(file.Chunks)
-			for _, chunk := range file.Chunks {
-				cacheFileItem, err := r.index.Get(chunk.Hash)
-				if err != nil {
-					if err != os.ErrNotExist {
-						return false, err
-					}
-					deletionResult.Missing = append(deletionResult.Missing, chunk.Hash)
-					continue
-				}
+	r.progress.finish(rpOrphanCleanup)
+	return nil
+}
 
-				if cacheFileItem.FileItem.Size != chunk.Size {
-					deletionResult.Missing = append(deletionResult.Missing, chunk.Hash)
-					continue
-				}
+// checkFileIntegrity examines the chunks of the file in the index, marks the missing ones and rebuilds the
+// checksum if it is requested. located is called for every chunk that is found in the index
+func (r *repair) checkFileIntegrity(folderPath string, file *common.File, checksumRebuild bool, clusterMap map[string]*common.Cluster, located func(clusterId string, sha512Hex string)) error {
+	file.Resurrect()
 
-				_, has := clusterMap[cacheFileItem.ClusterId]
-				if !has {
-					deletionResult.Missing = append(deletionResult.Missing, chunk.Hash)
-					continue
-				}
+	if len(file.Chunks) == 0 {
+		r.logger.Warn(
+			"Every file should have at least one chunk entry, this file does not.",
+			zap.String("filePath", folderPath),
+			zap.String("fileName", file.Name),
+		)
 
-				deletionResult.Untouched = append(deletionResult.Untouched, chunk.Hash)
-				deleteFromIndexMapFunc(cacheFileItem.ClusterId, cacheFileItem.FileItem.Sha512Hex)
+		file.Size = 0
+		file.Zombie = true
 
-				if !checksumRebuild {
-					continue
-				}
+		r.progress.zombieFound()
+		r.progress.record("zombie", common.Join(folderPath, file.Name))
 
-				masterNode := clusterMap[cacheFileItem.ClusterId].Master()
-				mdn, err := cluster2.NewDataNode(masterNode.Address)
-				if err != nil {
-					r.logger.Error(
-						"Unable to make connection to master data node for checksum calculation",
-						zap.String("clusterId", cacheFileItem.ClusterId),
-						zap.String("nodeId", masterNode.Id),
-						zap.String("nodeAddress", masterNode.Address),
-						zap.Error(err),
-					)
-					sha512Failed = true
-					continue
-				}
+		return nil
+	}
 
-				if err := mdn.Read(chunk.Hash, 0, 0, func(data []byte) error {
-					_, err := sha512Hash.Write(data)
-					return err
-				}); err != nil {
-					r.logger.Error(
-						fmt.Sprintf("Reading chunk %s from %s is failed, skipping checksum calculation for %s.", chunk.Hash, cacheFileItem.ClusterId, file.Name),
-						zap.String("clusterId", cacheFileItem.ClusterId),
-						zap.String("sha512Hex", chunk.Hash),
-						zap.Error(err),
-					)
-					sha512Failed = true
-				}
-			}
-			file.IngestDeletion(deletionResult)
+	deletionResult := common.NewDeletionResult()
 
-			if file.Zombie {
-				r.logger.Warn(
-					"A zombie file is found",
-					zap.String("filePath", folder.Full),
-					zap.String("fileName", file.Name),
-				)
+	sha512Failed := false
+	sha512Hash := sha512.New512_256()
+
+	sort.Sort(file.Chunks)
+	for _, chunk := range file.Chunks {
+		// inline chunks are kept in the metadata, they can only be validated, not repaired
+		if chunk.Inline {
+			if !chunk.Intact() {
+				deletionResult.Missing = append(deletionResult.Missing, chunk.Hash)
 				continue
 			}
+			deletionResult.Untouched = append(deletionResult.Untouched, chunk.Hash)
 
 			if checksumRebuild {
-				if sha512Failed {
-					r.logger.Warn(
-						fmt.Sprintf("Updating checksum of %s is not possible because of the failure(s) on calculation operation", file.Name),
-						zap.String("filePath", folder.Full),
-						zap.String("filename", file.Name),
-					)
-					continue
-				}
-				file.Checksum = hex.EncodeToString(sha512Hash.Sum(nil))
+				_, _ = sha512Hash.Write(chunk.Data)
 			}
+			continue
 		}
 
-		return true, nil
-	}, parallelRepair); err != nil {
-		return err
+		cacheFileItem, err := r.index.Get(chunk.Hash)
+		if err != nil {
+			if err != os.ErrNotExist {
+				return err
+			}
+			deletionResult.Missing = append(deletionResult.Missing, chunk.Hash)
+			continue
+		}
+
+		if cacheFileItem.FileItem.Size != chunk.Size {
+			deletionResult.Missing = append(deletionResult.Missing, chunk.Hash)
+			continue
+		}
+
+		_, has := clusterMap[cacheFileItem.ClusterId]
+		if !has {
+			deletionResult.Missing = append(deletionResult.Missing, chunk.Hash)
+			continue
+		}
+
+		deletionResult.Untouched = append(deletionResult.Untouched, chunk.Hash)
+		located(cacheFileItem.ClusterId, cacheFileItem.FileItem.Sha512Hex)
+
+		if !checksumRebuild {
+			continue
+		}
+
+		masterNode := clusterMap[cacheFileItem.ClusterId].Master()
+		mdn, err := cluster2.NewDataNode(masterNode.Address)
+		if err != nil {
+			r.logger.Error(
+				"Unable to make connection to master data node for checksum calculation",
+				zap.String("clusterId", cacheFileItem.ClusterId),
+				zap.String("nodeId", masterNode.Id),
+				zap.String("nodeAddress", masterNode.Address),
+				zap.Error(err),
+			)
+			sha512Failed = true
+			continue
+		}
+
+		if err := mdn.Read(chunk.Hash, 0, 0, func(data []byte) error {
+			_, err := sha512Hash.Write(data)
+			return err
+		}); err != nil {
+			r.logger.Error(
+				fmt.Sprintf("Reading chunk %s from %s is failed, skipping checksum calculation for %s.", chunk.Hash, cacheFileItem.ClusterId, file.Name),
+				zap.String("clusterId", cacheFileItem.ClusterId),
+				zap.String("sha512Hex", chunk.Hash),
+				zap.Error(err),
+			)
+			sha512Failed = true
+		}
 	}
+	file.IngestDeletion(deletionResult)
 
-	r.logger.Info("Start orphan chunk cleanup on clusters")
+	if file.Zombie {
+		r.logger.Warn(
+			"A zombie file is found",
+			zap.String("filePath", folderPath),
+			zap.String("fileName", file.Name),
+		)
 
-	// Make Orphan File Chunk Cleanup
-	wg := &sync.WaitGroup{}
-	for clusterId, indexMap := range clusterIndexMap {
-		masterNode := clusterMap[clusterId].Master()
+		r.progress.zombieFound()
+		for _, sha512Hex := range deletionResult.Missing {
+			r.progress.record("missing", common.Join(folderPath, file.Name), sha512Hex)
+		}
+		r.progress.record("zombie", common.Join(folderPath, file.Name))
 
-		wg.Add(1)
-		go r.cleanupOrphan(wg, clusterId, masterNode, indexMap)
+		return nil
 	}
-	wg.Wait()
 
+	if checksumRebuild {
+		if sha512Failed {
+			r.logger.Warn(
+				fmt.Sprintf("Updating checksum of %s is not possible because of the failure(s) on calculation operation", file.Name),
+				zap.String("filePath", folderPath),
+				zap.String("filename", file.Name),
+			)
+			return nil
+		}
+
+		checksum := hex.EncodeToString(sha512Hash.Sum(nil))
+		if strings.Compare(file.Checksum, checksum) != 0 {
+			r.progress.checksumRebuilt()
+			r.progress.record("checksum", common.Join(folderPath, file.Name), file.Checksum, checksum)
+
+			// the checksum state belongs to the wrong checksum
+			file.ChecksumState = nil
+		}
+		file.Checksum = checksum
+	}
 	return nil
 }
 
-func (r *repair) cleanupOrphan(wg *sync.WaitGroup, clusterId string, masterNode *common.Node, indexMap map[string]string) {
+func (r *repair) cleanupOrphan(wg *sync.WaitGroup, clusterId string, masterNode *common.Node, indexMap map[string]string, online bool) {
 	defer wg.Done()
 
 	if len(indexMap) == 0 {
 		r.logger.Info(fmt.Sprintf("%s does not have orphan chunks", clusterId))
+		r.progress.completeCluster(clusterId)
 		return
 	}
 
@@ -592,6 +795,15 @@ func (r *repair) cleanupOrphan(wg *sync.WaitGroup, clusterId string, masterNode
 		zap.Strings("sha512HexList", clusterSha512HexList),
 	)
 
+	if r.progress.dryRun {
+		for _, sha512Hex := range clusterSha512HexList {
+			r.progress.orphanRemoved()
+			r.progress.record("orphan", clusterId, sha512Hex)
+		}
+		r.progress.completeCluster(clusterId)
+		return
+	}
+
 	mdn, err := cluster2.NewDataNode(masterNode.Address)
 	if err != nil {
 		r.logger.Error(
@@ -623,6 +835,8 @@ func (r *repair) cleanupOrphan(wg *sync.WaitGroup, clusterId string, masterNode
 			)
 			continue
 		}
+		r.progress.orphanRemoved()
+
 		r.logger.Info(
 			fmt.Sprintf("Orphan chunk %s from %s is deleted", sha512Hex, clusterId),
 			zap.String("clusterId", clusterId),
@@ -630,17 +844,20 @@ func (r *repair) cleanupOrphan(wg *sync.WaitGroup, clusterId string, masterNode
 		)
 	}
 
-	// Sync cluster for snapshot
-	if err = r.synchronize.Cluster(clusterId, true, true, true); err != nil {
+	// Sync cluster for snapshot, online repair does not hold the cluster in maintain mode
+	if err = r.synchronize.Cluster(clusterId, !online, !online, !online); err != nil {
 		r.logger.Warn("Cluster sync is failed for the completion of orphan cleanup",
 			zap.String("clusterId", clusterId),
 			zap.Error(err),
 		)
 	}
 
-	// Recover cluster state for repair
-	_ = r.clusters.UpdateStateWithMaintain(clusterId, common.StateReadonly, true, common.TopicRepair)
+	if !online {
+		// Recover cluster state for repair
+		_ = r.clusters.UpdateStateWithMaintain(clusterId, common.StateReadonly, true, common.TopicRepair)
+	}
 
+	r.progress.completeCluster(clusterId)
 	r.logger.Info(fmt.Sprintf("Orphan chunks cleanup for %s is completed", clusterId))
 }
 
@@ -662,17 +879,30 @@ func (r *repair) repairChecksum(clusters common.Clusters, rebuildChecksum bool)
 	r.metadata.Unlock()
 
 	r.logger.Info("Repairing metadata file checksum...")
-	if err := r.repairChecksumCalculation(rebuildChecksum, clusterMap); err != nil {
+	if err := r.repairChecksumCalculation(rebuildChecksum, clusterMap, false); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func (r *repair) repairChecksumCalculation(rebuildChecksum bool, clusterMap map[string]*common.Cluster) error {
+func (r *repair) repairChecksumCalculation(rebuildChecksum bool, clusterMap map[string]*common.Cluster, online bool) error {
+	total, err := r.metadata.Count()
+	if err != nil {
+		return err
+	}
+	r.progress.begin(rpChecksum, total)
+
 	r.logger.Info("Start traversing metadata entries for checksum check up")
 
-	if err := r.metadata.Cursor(func(folder *common.Folder) (bool, error) {
+	cursor := r.metadata.Cursor
+	if online {
+		cursor = r.metadata.LiveCursor
+	}
+
+	if err := cursor(func(folder *common.Folder) (bool, error) {
+		defer r.progress.step()
+
 		if len(folder.Files) == 0 {
 			return false, nil
 		}
@@ -683,6 +913,11 @@ func (r *repair) repairChecksumCalculation(rebuildChecksum bool, clusterMap map[
 				continue
 			}
 
+			// Online repair should not touch the files that are being written
+			if online && file.Locked() {
+				continue
+			}
+
 			if len(file.Chunks) == 0 {
 				r.logger.Warn(
 					"Every file should have at least one chunk entry, this file does not.",
@@ -697,6 +932,20 @@ func (r *repair) repairChecksumCalculation(rebuildChecksum bool, clusterMap map[
 
 			sort.Sort(file.Chunks)
 			for _, chunk := range file.Chunks {
+				if chunk.Inline {
+					if !chunk.Intact() {
+						r.logger.Error(
+							fmt.Sprintf("Inline chunk is corrupted, skipping checksum calculation for %s.", file.Name),
+							zap.String("filePath", folder.Full),
+							zap.String("fileName", file.Name),
+						)
+						sha512Failed = true
+						break
+					}
+					_, _ = sha512Hash.Write(chunk.Data)
+					continue
+				}
+
 				cacheFileItem, err := r.index.Get(chunk.Hash)
 				if err != nil {
 					if err != os.ErrNotExist {
@@ -771,15 +1020,23 @@ func (r *repair) repairChecksumCalculation(rebuildChecksum bool, clusterMap map[
 				continue
 			}
 
-			file.Checksum = hex.EncodeToString(sha512Hash.Sum(nil))
+			checksum := hex.EncodeToString(sha512Hash.Sum(nil))
+			r.progress.checksumRebuilt()
+			r.progress.record("checksum", common.Join(folder.Full, file.Name), file.Checksum, checksum)
+
+			if strings.Compare(file.Checksum, checksum) != 0 {
+				file.ChecksumState = nil
+			}
+			file.Checksum = checksum
 			updatedChecksum++
 		}
 
-		return updatedChecksum > 0, nil
+		return updatedChecksum > 0 && !r.progress.dryRun, nil
 	}, parallelRepair); err != nil {
 		return err
 	}
 
+	r.progress.finish(rpChecksum)
 	return nil
 }
 
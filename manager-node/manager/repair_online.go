@@ -0,0 +1,343 @@
+package manager
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"go.uber.org/zap"
+)
+
+// onlineRepairSettleDuration is the time given to the uploads that were already in progress when the online
+// repair is started. Their chunks are not in the journal so the metadata traversal waits for them to be saved
+const onlineRepairSettleDuration = time.Minute * 5
+
+// startOnline runs the repair without taking the clusters into readonly and maintain mode. Clusters are processed
+// one at a time and the chunks that are created or deleted during the run are collected in the repair journal to
+// keep them out of the usage alignment and orphan cleanup
+func (r *repair) startOnline(clusters common.Clusters, repairType RepairType) error {
+	startedAt := r.progress.checkpoint.StartedAt
+
+	clusterMap := make(map[string]*common.Cluster)
+	for _, cluster := range clusters {
+		clusterMap[cluster.Id] = cluster
+	}
+
+	repairChecksum := repairType == RTCalculatingMissingChecksum || repairType == RTChecksumRebuild
+
+	if repairChecksum {
+		if err := r.synchronizeOnline(clusters); err != nil {
+			return err
+		}
+
+		r.logger.Info("Repairing metadata file checksum...")
+		if err := r.repairChecksumCalculation(repairType == RTChecksumRebuild, clusterMap, true); err != nil {
+			return err
+		}
+		r.logger.Info("Metadata file checksum repair is completed")
+		return nil
+	}
+
+	repairStructure := repairType == RTFull || repairType == RTStructure || repairType == RTStructureWithIntegrity
+	repairIntegrity := repairType == RTFull || repairType == RTStructureWithIntegrity || repairType == RTIntegrity || repairType == RTIntegrityWithChecksumRebuild
+
+	if repairStructure && !r.progress.passed(rpStructure) {
+		r.logger.Info("Repairing metadata structure consistency...")
+		if err := r.repairStructure(); err != nil {
+			return err
+		}
+		r.logger.Info("Metadata structure consistency repair is completed")
+	}
+
+	if repairIntegrity {
+		r.logger.Info("Repairing metadata integrity online...")
+		if err := r.repairIntegrityOnline(startedAt, clusterMap, repairType == RTFull || repairType == RTIntegrityWithChecksumRebuild); err != nil {
+			return err
+		}
+		r.logger.Info("Metadata integrity repair is completed")
+	}
+
+	return nil
+}
+
+// synchronizeOnline synchronises the clusters one by one. Only the synchronising cluster is in maintain mode
+// for the duration of its master node index creation
+func (r *repair) synchronizeOnline(clusters common.Clusters) error {
+	for _, cluster := range clusters {
+		if err := r.synchronize.Cluster(cluster.Id, false, false, false); err != nil {
+			r.logger.Error("Cluster sync is failed for online repair",
+				zap.String("clusterId", cluster.Id),
+				zap.Error(err),
+			)
+			return errors.ErrSync
+		}
+	}
+	return nil
+}
+
+func (r *repair) repairIntegrityOnline(startedAt time.Time, clusterMap map[string]*common.Cluster, rebuildChecksum bool) error {
+	r.logger.Info("Online integrity repairing requires cluster synchronisation one by one.")
+
+	clusters := make(common.Clusters, 0, len(clusterMap))
+	for _, cluster := range clusterMap {
+		clusters = append(clusters, cluster)
+	}
+
+	if err := r.synchronizeOnline(clusters); err != nil {
+		return err
+	}
+
+	if wait := onlineRepairSettleDuration - time.Since(startedAt); wait > 0 {
+		r.logger.Info(fmt.Sprintf("Waiting %s for the in-flight uploads to settle", wait.Round(time.Second)))
+		time.Sleep(wait)
+	}
+
+	r.logger.Info("Phase 1: Repairing metadata chunk integrity and caching usages...")
+	metadataUsageMap, err := r.repairIntegrityOnlinePhase1(startedAt, rebuildChecksum, clusterMap)
+	if err != nil {
+		return err
+	}
+
+	r.logger.Info("Phase 2: Aligning usages and collecting orphan chunks cluster by cluster...")
+	orphanMap, err := r.repairIntegrityOnlinePhase2(metadataUsageMap, clusterMap)
+	if err != nil {
+		return err
+	}
+
+	if len(orphanMap) == 0 {
+		r.logger.Info("Clusters do not have orphan chunks, nothing to do here...")
+		r.progress.finish(rpOrphanCleanup)
+		return nil
+	}
+
+	r.logger.Info("Phase 3: Confirming orphan chunks...")
+	if err := r.confirmOrphans(orphanMap); err != nil {
+		return err
+	}
+
+	r.progress.begin(rpOrphanCleanup, uint64(len(orphanMap)))
+
+	r.logger.Info("Start orphan chunk cleanup on clusters one by one")
+
+	for clusterId, indexMap := range orphanMap {
+		if r.progress.clusterCompleted(clusterId) {
+			continue
+		}
+
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		r.cleanupOrphan(wg, clusterId, clusterMap[clusterId].Master(), indexMap, true)
+	}
+
+	r.progress.finish(rpOrphanCleanup)
+	return nil
+}
+
+// repairIntegrityOnlinePhase1 examines the file chunks folder by folder and returns the chunk usages in metadata.
+// The files that are locked or changed after the repair is started are counted but not examined
+func (r *repair) repairIntegrityOnlinePhase1(startedAt time.Time, rebuildChecksum bool, clusterMap map[string]*common.Cluster) (map[string]uint64, error) {
+	metadataUsageMapMutex := sync.Mutex{}
+	metadataUsageMap := make(map[string]uint64)
+	increaseUsageMapFunc := func(file *common.File) {
+		metadataUsageMapMutex.Lock()
+		defer metadataUsageMapMutex.Unlock()
+
+		for _, chunk := range file.Chunks.Remote() {
+			metadataUsageMap[chunk.Hash]++
+		}
+		for _, chunk := range file.Missing.Remote() {
+			metadataUsageMap[chunk.Hash]++
+		}
+	}
+
+	total, err := r.metadata.Count()
+	if err != nil {
+		return nil, err
+	}
+	r.progress.begin(rpIntegrityChunk, total)
+
+	r.logger.Info("Start traversing metadata entries for integrity check up")
+
+	if err := r.metadata.LiveCursor(func(folder *common.Folder) (bool, error) {
+		defer r.progress.step()
+
+		if len(folder.Files) == 0 {
+			return false, nil
+		}
+
+		examined := 0
+		for _, file := range folder.Files {
+			increaseUsageMapFunc(file)
+
+			if file.Locked() || file.Modified.After(startedAt) {
+				continue
+			}
+
+			if err := r.checkFileIntegrity(folder.Full, file, rebuildChecksum, clusterMap, func(string, string) {}); err != nil {
+				return false, err
+			}
+			examined++
+		}
+
+		return examined > 0 && !r.progress.dryRun, nil
+	}, parallelRepair); err != nil {
+		return nil, err
+	}
+
+	r.progress.finish(rpIntegrityChunk)
+	return metadataUsageMap, nil
+}
+
+// repairIntegrityOnlinePhase2 compares the cluster indices with the metadata usages one cluster at a time. Usages
+// are only increased in online repair, decreasing them may cause data loss if the file is moved during the traversal.
+// Returns the orphan chunk candidates of the clusters
+func (r *repair) repairIntegrityOnlinePhase2(metadataUsageMap map[string]uint64, clusterMap map[string]*common.Cluster) (map[string]map[string]string, error) {
+	orphanMap := make(map[string]map[string]string)
+
+	r.progress.begin(rpIntegrityUsage, uint64(len(clusterMap)))
+
+	for clusterId, cluster := range clusterMap {
+		r.progress.step()
+
+		r.logger.Info(fmt.Sprintf("Examining usages of %s", clusterId), zap.String("clusterId", clusterId))
+
+		indexMap, err := r.index.PullMap(clusterId)
+		if err != nil {
+			return nil, err
+		}
+
+		journaled, err := r.operation.Journaled()
+		if err != nil {
+			return nil, err
+		}
+
+		mismatchedUsageMap := make(map[string]uint64)
+		for sha512Hex, indexValue := range indexMap {
+			if journaled[sha512Hex] {
+				continue
+			}
+
+			pipeIdx := strings.Index(indexValue, "|")
+			if pipeIdx == -1 {
+				return nil, fmt.Errorf("faulty index entry for %s", sha512Hex)
+			}
+
+			indexUsage, err := strconv.ParseUint(indexValue[:pipeIdx], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+
+			metadataUsage, has := metadataUsageMap[sha512Hex]
+			if !has {
+				if _, has := orphanMap[clusterId]; !has {
+					orphanMap[clusterId] = make(map[string]string)
+				}
+				orphanMap[clusterId][sha512Hex] = indexValue
+				continue
+			}
+
+			if metadataUsage == indexUsage {
+				continue
+			}
+
+			if metadataUsage < indexUsage {
+				r.logger.Warn(
+					fmt.Sprintf("Found higher usage for %s, expected: %d, found: %d. Run offline repair to decrease", sha512Hex, metadataUsage, indexUsage),
+					zap.String("sha512Hex", sha512Hex),
+					zap.Uint64("metadataUsage", metadataUsage),
+					zap.Uint64("indexUsage", indexUsage),
+				)
+				continue
+			}
+
+			mismatchedUsageMap[sha512Hex] = metadataUsage
+
+			r.logger.Warn(
+				fmt.Sprintf("Found mismatching usage for %s, expected: %d, found: %d", sha512Hex, metadataUsage, indexUsage),
+				zap.String("sha512Hex", sha512Hex),
+				zap.Uint64("metadataUsage", metadataUsage),
+				zap.Uint64("indexUsage", indexUsage),
+			)
+		}
+
+		if len(mismatchedUsageMap) == 0 {
+			continue
+		}
+
+		if r.progress.dryRun {
+			r.recordUsages(clusterId, mismatchedUsageMap)
+			continue
+		}
+
+		r.logger.Info(fmt.Sprintf("Start usage resetting on %s", clusterId), zap.String("clusterId", clusterId))
+
+		errCh := make(chan error, 1)
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		r.fixUsage(wg, clusterId, cluster.Master(), mismatchedUsageMap, errCh)
+		close(errCh)
+
+		if err := <-errCh; err != nil {
+			return nil, fmt.Errorf("resetting usage is failed: %s", err)
+		}
+	}
+
+	r.progress.finish(rpIntegrityUsage)
+	return orphanMap, nil
+}
+
+// confirmOrphans traverses the metadata once more and drops the orphan candidates that are referenced by a file
+// or journaled in the meantime. A file moving between folders may be missed by a single traversal
+func (r *repair) confirmOrphans(orphanMap map[string]map[string]string) error {
+	orphanMapMutex := sync.Mutex{}
+	dropFromOrphanMapFunc := func(file *common.File) {
+		orphanMapMutex.Lock()
+		defer orphanMapMutex.Unlock()
+
+		for _, indexMap := range orphanMap {
+			for _, chunk := range file.Chunks.Remote() {
+				delete(indexMap, chunk.Hash)
+			}
+			for _, chunk := range file.Missing.Remote() {
+				delete(indexMap, chunk.Hash)
+			}
+		}
+	}
+
+	total, err := r.metadata.Count()
+	if err != nil {
+		return err
+	}
+	r.progress.begin(rpOrphanConfirmation, total)
+
+	if err := r.metadata.LiveCursor(func(folder *common.Folder) (bool, error) {
+		defer r.progress.step()
+
+		for _, file := range folder.Files {
+			dropFromOrphanMapFunc(file)
+		}
+		return false, nil
+	}, parallelRepair); err != nil {
+		return err
+	}
+
+	journaled, err := r.operation.Journaled()
+	if err != nil {
+		return err
+	}
+
+	for _, indexMap := range orphanMap {
+		for sha512Hex := range indexMap {
+			if journaled[sha512Hex] {
+				delete(indexMap, sha512Hex)
+			}
+		}
+	}
+
+	r.progress.finish(rpOrphanConfirmation)
+	return nil
+}
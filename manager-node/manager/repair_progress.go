@@ -0,0 +1,208 @@
+package manager
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/manager-node/data"
+	"go.uber.org/zap"
+)
+
+const repairCheckpointInterval = time.Second * 5
+
+const (
+	rpStructure          = "structure"
+	rpIntegrityUsage     = "integrity-usage"
+	rpIntegrityChunk     = "integrity-chunk"
+	rpOrphanConfirmation = "orphan-confirmation"
+	rpOrphanCleanup      = "orphan-cleanup"
+	rpChecksum           = "checksum"
+)
+
+// repairProgress keeps the checkpoint of the running repair and persists it periodically. Report lines of the
+// dry-run are buffered and persisted with the checkpoint
+type repairProgress struct {
+	mutex sync.Mutex
+
+	operation  data.Operation
+	logger     *zap.Logger
+	checkpoint *data.RepairCheckpoint
+	dryRun     bool
+
+	report  []string
+	savedAt time.Time
+}
+
+func newRepairProgress(operation data.Operation, checkpoint *data.RepairCheckpoint, dryRun bool, logger *zap.Logger) *repairProgress {
+	return &repairProgress{
+		operation:  operation,
+		logger:     logger,
+		checkpoint: checkpoint,
+		dryRun:     dryRun,
+		report:     make([]string, 0),
+	}
+}
+
+// repairPhases creates the phase plan of the repair type
+func repairPhases(repairType RepairType, online bool) []string {
+	if repairType == RTCalculatingMissingChecksum || repairType == RTChecksumRebuild {
+		return []string{rpChecksum}
+	}
+
+	phases := make([]string, 0)
+	if repairType == RTFull || repairType == RTStructure || repairType == RTStructureWithIntegrity {
+		phases = append(phases, rpStructure)
+	}
+	if repairType == RTFull || repairType == RTStructureWithIntegrity || repairType == RTIntegrity || repairType == RTIntegrityWithChecksumRebuild {
+		if online {
+			phases = append(phases, rpIntegrityChunk, rpIntegrityUsage, rpOrphanConfirmation, rpOrphanCleanup)
+		} else {
+			phases = append(phases, rpIntegrityUsage, rpIntegrityChunk, rpOrphanCleanup)
+		}
+	}
+	return phases
+}
+
+func (p *repairProgress) phaseIndex(phase string) int {
+	for i, v := range p.checkpoint.Phases {
+		if strings.Compare(v, phase) == 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// passed checks if the phase is already completed before the repair is resumed
+func (p *repairProgress) passed(phase string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.phaseIndex(phase) < p.checkpoint.Phase
+}
+
+// begin moves the checkpoint to the phase with the total work count of it
+func (p *repairProgress) begin(phase string, total uint64) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if idx := p.phaseIndex(phase); idx > -1 {
+		p.checkpoint.Phase = idx
+	}
+	p.checkpoint.Total = total
+	p.checkpoint.Processed = 0
+
+	p.save()
+}
+
+// finish moves the checkpoint after the phase
+func (p *repairProgress) finish(phase string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if idx := p.phaseIndex(phase); idx > -1 {
+		p.checkpoint.Phase = idx + 1
+	}
+	p.checkpoint.Total = 0
+	p.checkpoint.Processed = 0
+
+	p.save()
+}
+
+func (p *repairProgress) step() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.checkpoint.Processed++
+
+	if time.Since(p.savedAt) < repairCheckpointInterval {
+		return
+	}
+	p.save()
+}
+
+func (p *repairProgress) clusterCompleted(clusterId string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, id := range p.checkpoint.CompletedClusters {
+		if strings.Compare(id, clusterId) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *repairProgress) completeCluster(clusterId string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.checkpoint.CompletedClusters = append(p.checkpoint.CompletedClusters, clusterId)
+	p.checkpoint.Processed++
+
+	p.save()
+}
+
+func (p *repairProgress) orphanRemoved() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.checkpoint.OrphansRemoved++
+}
+
+func (p *repairProgress) usagesFixed(count int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.checkpoint.UsagesFixed += uint64(count)
+}
+
+func (p *repairProgress) checksumRebuilt() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.checkpoint.ChecksumsRebuilt++
+}
+
+func (p *repairProgress) zombieFound() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.checkpoint.ZombiesFound++
+}
+
+// record adds the intended change to the dry-run report. Fields are separated with tab
+func (p *repairProgress) record(action string, fields ...string) {
+	if !p.dryRun {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.report = append(p.report, strings.Join(append([]string{action}, fields...), "\t"))
+}
+
+// flush persists the checkpoint and the buffered report lines
+func (p *repairProgress) flush() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.save()
+}
+
+func (p *repairProgress) save() {
+	p.savedAt = time.Now()
+
+	if len(p.report) > 0 {
+		if err := p.operation.AppendReport(p.report); err != nil {
+			p.logger.Warn("Saving repair report is failed", zap.Error(err))
+		} else {
+			p.report = make([]string, 0)
+		}
+	}
+
+	if err := p.operation.SaveCheckpoint(p.checkpoint); err != nil {
+		p.logger.Warn("Saving repair checkpoint is failed", zap.Error(err))
+	}
+}
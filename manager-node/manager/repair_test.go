@@ -0,0 +1,536 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/manager-node/data"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type testRepairOperation struct {
+	data.Operation
+
+	mutex       sync.Mutex
+	detail      data.RepairDetail
+	checkpoints []data.RepairCheckpoint
+	journal     map[string]bool
+	report      []string
+}
+
+func newTestRepairOperation() *testRepairOperation {
+	return &testRepairOperation{
+		checkpoints: make([]data.RepairCheckpoint, 0),
+		journal:     make(map[string]bool),
+		report:      make([]string, 0),
+	}
+}
+
+func (o *testRepairOperation) RepairDetail() (data.RepairDetail, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return o.detail, nil
+}
+
+func (o *testRepairOperation) SetRepairing(repairing bool, online bool, dryRun bool, _ bool) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.detail.Processing = repairing
+	o.detail.Online = online
+	o.detail.DryRun = dryRun
+
+	return nil
+}
+
+func (o *testRepairOperation) SaveCheckpoint(checkpoint *data.RepairCheckpoint) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	saved := *checkpoint
+	saved.CompletedClusters = append([]string{}, checkpoint.CompletedClusters...)
+
+	o.checkpoints = append(o.checkpoints, saved)
+	o.detail.Checkpoint = &saved
+
+	return nil
+}
+
+func (o *testRepairOperation) Journaled() (map[string]bool, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	journaled := make(map[string]bool)
+	for sha512Hex := range o.journal {
+		journaled[sha512Hex] = true
+	}
+	return journaled, nil
+}
+
+func (o *testRepairOperation) ResetJournal() error {
+	return nil
+}
+
+func (o *testRepairOperation) AppendReport(lines []string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.report = append(o.report, lines...)
+	return nil
+}
+
+func (o *testRepairOperation) Report() ([]string, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return append([]string{}, o.report...), nil
+}
+
+func (o *testRepairOperation) ResetReport() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	o.report = make([]string, 0)
+	return nil
+}
+
+// checkpoint returns the last saved checkpoint
+func (o *testRepairOperation) checkpoint() data.RepairCheckpoint {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return o.checkpoints[len(o.checkpoints)-1]
+}
+
+type testRepairClusters struct {
+	data.Clusters
+
+	clusters common.Clusters
+
+	mutex  sync.Mutex
+	states []string
+}
+
+func (c *testRepairClusters) GetAll() (common.Clusters, error) {
+	clusters := make(common.Clusters, 0)
+	for _, cluster := range c.clusters {
+		shadow := *cluster
+		clusters = append(clusters, &shadow)
+	}
+	return clusters, nil
+}
+
+func (c *testRepairClusters) UpdateStateWithMaintain(clusterId string, state common.States, maintain bool, _ common.Topics) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.states = append(c.states, fmt.Sprintf("%s:%d:%t", clusterId, state, maintain))
+	return nil
+}
+
+func (c *testRepairClusters) stateChanges() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]string{}, c.states...)
+}
+
+// testRepairSynchronize records the synchronisation requests and the maximum number of clusters that are
+// synchronising at the same time
+type testRepairSynchronize struct {
+	Synchronize
+
+	mutex       sync.Mutex
+	requests    []string
+	running     int
+	maxRunning  int
+	syncingTime time.Duration
+}
+
+func (s *testRepairSynchronize) Cluster(clusterId string, ignoreMaintainMode bool, keepInMaintainMode bool, waitFullSync bool) error {
+	s.mutex.Lock()
+	s.requests = append(s.requests, fmt.Sprintf("%s:%t:%t:%t", clusterId, ignoreMaintainMode, keepInMaintainMode, waitFullSync))
+	s.running++
+	if s.running > s.maxRunning {
+		s.maxRunning = s.running
+	}
+	s.mutex.Unlock()
+
+	time.Sleep(s.syncingTime)
+
+	s.mutex.Lock()
+	s.running--
+	s.mutex.Unlock()
+
+	return nil
+}
+
+// testRepairMetadata keeps the folders in the memory. Cursor saves the folder copy only if the handler asks
+type testRepairMetadata struct {
+	data.Metadata
+
+	mutex     sync.Mutex
+	folders   []*common.Folder
+	saves     int
+	treeLocks int
+}
+
+func (m *testRepairMetadata) Count() (uint64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return uint64(len(m.folders)), nil
+}
+
+func (m *testRepairMetadata) Cursor(folderHandler func(folder *common.Folder) (bool, error), _ uint8) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, folder := range m.folders {
+		clone := cloneTestRepairFolder(folder)
+		save, err := folderHandler(clone)
+		if err != nil {
+			return err
+		}
+		if save {
+			m.folders[i] = clone
+			m.saves++
+		}
+	}
+	return nil
+}
+
+func (m *testRepairMetadata) LiveCursor(folderHandler func(folder *common.Folder) (bool, error), parallelSize uint8) error {
+	return m.Cursor(folderHandler, parallelSize)
+}
+
+func (m *testRepairMetadata) LockTree(folderHandler func(folders []*common.Folder) ([]*common.Folder, error)) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.treeLocks++
+
+	folders := make([]*common.Folder, 0)
+	for _, folder := range m.folders {
+		folders = append(folders, cloneTestRepairFolder(folder))
+	}
+
+	normalized, err := folderHandler(folders)
+	if err != nil {
+		return err
+	}
+	if normalized != nil {
+		m.folders = normalized
+		m.saves++
+	}
+	return nil
+}
+
+func (m *testRepairMetadata) Lock()   {}
+func (m *testRepairMetadata) Unlock() {}
+
+func (m *testRepairMetadata) file(path string) *common.File {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	folderPath, filename := common.Split(path)
+	for _, folder := range m.folders {
+		if strings.Compare(folder.Full, folderPath) == 0 {
+			return folder.File(filename)
+		}
+	}
+	return nil
+}
+
+func cloneTestRepairFolder(folder *common.Folder) *common.Folder {
+	clone := *folder
+	folder.CloneInto(&clone)
+
+	for i, file := range clone.Files {
+		clone.Files[i].Chunks = make(common.DataChunks, 0, len(file.Chunks))
+		for _, chunk := range folder.Files[i].Chunks {
+			shadow := *chunk
+			clone.Files[i].Chunks = append(clone.Files[i].Chunks, &shadow)
+		}
+	}
+
+	return &clone
+}
+
+// testRepairIndex keeps the chunk index maps of the clusters in "usage|size" format
+type testRepairIndex struct {
+	data.Index
+
+	indexMaps map[string]map[string]string
+}
+
+func (i *testRepairIndex) Get(sha512Hex string) (*common.CacheFileItem, error) {
+	for clusterId, indexMap := range i.indexMaps {
+		indexValue, has := indexMap[sha512Hex]
+		if !has {
+			continue
+		}
+
+		var usage uint64
+		var size uint32
+		_, _ = fmt.Sscanf(indexValue, "%d|%d", &usage, &size)
+
+		return &common.CacheFileItem{
+			FileItem:  common.SyncFileItem{Sha512Hex: sha512Hex, Usage: usage, Size: size},
+			ClusterId: clusterId,
+		}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (i *testRepairIndex) PullMap(clusterId string) (map[string]string, error) {
+	indexMap := make(map[string]string)
+	for sha512Hex, indexValue := range i.indexMaps[clusterId] {
+		indexMap[sha512Hex] = indexValue
+	}
+	return indexMap, nil
+}
+
+func newTestRepairCluster(clusterId string) *common.Cluster {
+	cluster := common.NewCluster(clusterId)
+	cluster.Paralyzed = false
+	cluster.Maintain = false
+	cluster.State = common.StateOnline
+	cluster.Nodes = common.NodeList{{Id: clusterId + "-master", Address: clusterId + "-master", Master: true}}
+	return cluster
+}
+
+func newTestRepairFile(folder *common.Folder, name string, checksum string, chunks ...*common.DataChunk) *common.File {
+	file, _ := folder.NewFile(name)
+	file.Checksum = checksum
+	file.Chunks = chunks
+	for _, chunk := range chunks {
+		file.Size += uint64(chunk.Size)
+	}
+	file.Modified = time.Now().UTC().Add(-time.Hour)
+	file.Zombie = false
+	file.Lock.Cancel()
+	return file
+}
+
+type testRepair struct {
+	*repair
+
+	operation   *testRepairOperation
+	clusters    *testRepairClusters
+	synchronize *testRepairSynchronize
+	metadata    *testRepairMetadata
+	index       *testRepairIndex
+}
+
+// newTestRepair creates the repair of the c1 and c2 clusters. /folder has the "intact" file on c1, the "broken"
+// file that lost its second chunk and the "shared" file that is used more than the c1 index knows. c2 keeps
+// an orphan chunk
+func newTestRepair() *testRepair {
+	folder := common.NewFolder("/folder")
+	newTestRepairFile(folder, "intact", "checksum", common.NewDataChunk(0, 4, "intact-0"))
+	newTestRepairFile(folder, "broken", "checksum", common.NewDataChunk(0, 4, "broken-0"), common.NewDataChunk(1, 4, "broken-1"))
+	newTestRepairFile(folder, "shared", "checksum", common.NewDataChunk(0, 4, "shared-0"))
+	newTestRepairFile(folder, "shared-copy", "checksum", common.NewDataChunk(0, 4, "shared-0"))
+
+	tr := &testRepair{
+		operation:   newTestRepairOperation(),
+		clusters:    &testRepairClusters{clusters: common.Clusters{newTestRepairCluster("c1"), newTestRepairCluster("c2")}},
+		synchronize: &testRepairSynchronize{syncingTime: time.Millisecond * 10},
+		metadata:    &testRepairMetadata{folders: []*common.Folder{common.NewFolder("/"), folder}},
+		index: &testRepairIndex{
+			indexMaps: map[string]map[string]string{
+				"c1": {"intact-0": "1|4", "broken-0": "1|4", "shared-0": "1|4"},
+				"c2": {"orphan-0": "1|4"},
+			},
+		},
+	}
+	tr.repair = NewRepair(tr.clusters, tr.metadata, tr.index, tr.operation, tr.synchronize, zap.NewNop()).(*repair)
+
+	return tr
+}
+
+func (tr *testRepair) wait(t *testing.T) {
+	assert.Eventually(t, func() bool { return !tr.Status().Processing }, time.Second*5, time.Millisecond*10)
+}
+
+func (tr *testRepair) report(t *testing.T) []string {
+	report, err := tr.Report()
+	assert.Nil(t, err)
+	sort.Strings(report)
+	return report
+}
+
+func TestRepair_OfflineFreezesAllClusters(t *testing.T) {
+	tr := newTestRepair()
+
+	assert.Nil(t, tr.Start(RTCalculatingMissingChecksum, false, false))
+	tr.wait(t)
+
+	states := tr.clusters.stateChanges()
+	assert.Equal(t, []string{"c1:1:true", "c2:1:true"}, states[:2])
+	assert.Equal(t, []string{"c1:0:false", "c2:0:false"}, states[len(states)-2:])
+
+	// offline repair synchronises the clusters together and keeps them in maintain mode
+	assert.Equal(t, 2, tr.synchronize.maxRunning)
+	for _, request := range tr.synchronize.requests {
+		assert.True(t, strings.HasSuffix(request, ":true:true:false"))
+	}
+}
+
+func TestRepair_OnlineFreezesOneClusterAtATime(t *testing.T) {
+	tr := newTestRepair()
+
+	assert.Nil(t, tr.Start(RTCalculatingMissingChecksum, true, false))
+	tr.wait(t)
+
+	// clusters stay writable, only the synchronising cluster is in maintain mode for its sync duration
+	assert.Empty(t, tr.clusters.stateChanges())
+	assert.Equal(t, 1, tr.synchronize.maxRunning)
+	assert.Equal(t, []string{"c1:false:false:false", "c2:false:false:false"}, tr.synchronize.requests)
+}
+
+func TestRepair_StartRejected(t *testing.T) {
+	tr := newTestRepair()
+	tr.clusters.clusters[1].Maintain = true
+
+	assert.NotNil(t, tr.Start(RTFull, true, false))
+	assert.False(t, tr.Status().Processing)
+	assert.Empty(t, tr.operation.checkpoints)
+}
+
+func TestRepair_DryRunReport(t *testing.T) {
+	tr := newTestRepair()
+
+	assert.Nil(t, tr.Start(RTIntegrity, false, true))
+	tr.wait(t)
+
+	assert.Equal(t, []string{
+		"missing\t/folder/broken\tbroken-1",
+		"orphan\tc2\torphan-0",
+		"usage\tc1\tshared-0\t2",
+		"zombie\t/folder/broken",
+	}, tr.report(t))
+
+	// dry-run does not touch the metadata
+	assert.Equal(t, 0, tr.metadata.saves)
+	assert.False(t, tr.metadata.file("/folder/broken").Zombie)
+
+	checkpoint := tr.operation.checkpoint()
+	assert.Equal(t, uint64(1), checkpoint.ZombiesFound)
+	assert.Equal(t, uint64(1), checkpoint.UsagesFixed)
+	assert.Equal(t, uint64(1), checkpoint.OrphansRemoved)
+}
+
+func TestRepair_Checkpoint(t *testing.T) {
+	tr := newTestRepair()
+
+	assert.Nil(t, tr.Start(RTFull, false, true))
+	tr.wait(t)
+
+	phases := []string{rpStructure, rpIntegrityUsage, rpIntegrityChunk, rpOrphanCleanup}
+
+	first := tr.operation.checkpoints[0]
+	assert.Equal(t, int(RTFull), first.Type)
+	assert.Equal(t, phases, first.Phases)
+	assert.Equal(t, 0, first.Phase)
+
+	// every phase is persisted when it begins and the checkpoint moves after the last phase at the end
+	begins := make(map[int]bool)
+	for _, checkpoint := range tr.operation.checkpoints {
+		begins[checkpoint.Phase] = true
+	}
+	for i := range phases {
+		assert.True(t, begins[i], phases[i])
+	}
+
+	last := tr.operation.checkpoint()
+	assert.Equal(t, len(phases), last.Phase)
+	assert.ElementsMatch(t, []string{"c1", "c2"}, last.CompletedClusters)
+	assert.Equal(t, float64(100), last.Percentage())
+	assert.Equal(t, 1, tr.metadata.treeLocks)
+}
+
+func TestRepair_Resume(t *testing.T) {
+	tr := newTestRepair()
+
+	tr.operation.detail = data.RepairDetail{
+		Processing: true,
+		DryRun:     true,
+		Checkpoint: &data.RepairCheckpoint{
+			Type:              int(RTFull),
+			StartedAt:         time.Now().UTC().Add(-time.Hour),
+			Phases:            []string{rpStructure, rpIntegrityUsage, rpIntegrityChunk, rpOrphanCleanup},
+			Phase:             2,
+			CompletedClusters: []string{"c2"},
+			UsagesFixed:       1,
+		},
+	}
+
+	assert.Nil(t, tr.Resume())
+	tr.wait(t)
+
+	// structure and usage phases are passed and the orphans of c2 are already cleaned up
+	assert.Equal(t, 0, tr.metadata.treeLocks)
+	assert.Equal(t, []string{
+		"missing\t/folder/broken\tbroken-1",
+		"zombie\t/folder/broken",
+	}, tr.report(t))
+
+	states := tr.clusters.stateChanges()
+	assert.Equal(t, []string{"c1:1:true", "c2:1:true"}, states[:2])
+
+	last := tr.operation.checkpoint()
+	assert.Equal(t, 4, last.Phase)
+	assert.Equal(t, uint64(1), last.UsagesFixed)
+	assert.Equal(t, uint64(0), last.OrphansRemoved)
+}
+
+func TestRepair_ResumeOnline(t *testing.T) {
+	tr := newTestRepair()
+	tr.operation.journal["orphan-0"] = true
+
+	// the settle duration is already passed for the repair that is started an hour ago
+	tr.operation.detail = data.RepairDetail{
+		Processing: true,
+		Online:     true,
+		DryRun:     true,
+		Checkpoint: &data.RepairCheckpoint{
+			Type:      int(RTIntegrity),
+			StartedAt: time.Now().UTC().Add(-time.Hour),
+			Phases:    repairPhases(RTIntegrity, true),
+		},
+	}
+
+	assert.Nil(t, tr.Resume())
+	tr.wait(t)
+
+	// the journaled chunk is created during the repair, it is not an orphan
+	assert.Equal(t, []string{
+		"missing\t/folder/broken\tbroken-1",
+		"usage\tc1\tshared-0\t2",
+		"zombie\t/folder/broken",
+	}, tr.report(t))
+	assert.Empty(t, tr.clusters.stateChanges())
+	assert.Equal(t, 1, tr.synchronize.maxRunning)
+}
+
+func TestRepair_ResumeWithoutCheckpoint(t *testing.T) {
+	tr := newTestRepair()
+
+	assert.Nil(t, tr.Resume())
+	assert.Empty(t, tr.synchronize.requests)
+
+	tr.operation.detail = data.RepairDetail{Processing: true}
+	assert.Nil(t, tr.Resume())
+	assert.False(t, tr.Status().Processing)
+	assert.Empty(t, tr.synchronize.requests)
+}
@@ -0,0 +1,191 @@
+package manager
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
+	"github.com/freakmaxi/kertish-dos/manager-node/data"
+	"go.uber.org/zap"
+)
+
+const snapshotScheduleInterval = time.Minute
+
+// SnapshotScheduler interface creates the scheduled snapshots of the clusters and deletes the ones that are
+// expired by the retention policy
+type SnapshotScheduler interface {
+	Start()
+}
+
+type snapshotScheduler struct {
+	clusters data.Clusters
+	cluster  Cluster
+	logger   *zap.Logger
+
+	runningMutex sync.Mutex
+	running      map[string]bool
+}
+
+// NewSnapshotScheduler creates the instance of the snapshot scheduler
+func NewSnapshotScheduler(clusters data.Clusters, cluster Cluster, logger *zap.Logger) SnapshotScheduler {
+	return &snapshotScheduler{
+		clusters:     clusters,
+		cluster:      cluster,
+		logger:       logger,
+		runningMutex: sync.Mutex{},
+		running:      make(map[string]bool),
+	}
+}
+
+func (s *snapshotScheduler) Start() {
+	go s.schedule()
+}
+
+func (s *snapshotScheduler) schedule() {
+	for {
+		time.Sleep(snapshotScheduleInterval)
+
+		clusters, err := s.clusters.GetAll()
+		if err != nil {
+			s.logger.Error("Unable to get cluster list for snapshot schedules", zap.Error(err))
+			continue
+		}
+
+		for _, cluster := range clusters {
+			if cluster.SnapshotSchedule == nil || cluster.State == common.StateOffline || !s.lock(cluster.Id) {
+				continue
+			}
+
+			go func(cluster *common.Cluster) {
+				defer s.unlock(cluster.Id)
+				s.run(cluster)
+			}(cluster)
+		}
+	}
+}
+
+func (s *snapshotScheduler) lock(clusterId string) bool {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	if s.running[clusterId] {
+		return false
+	}
+	s.running[clusterId] = true
+
+	return true
+}
+
+func (s *snapshotScheduler) unlock(clusterId string) {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	delete(s.running, clusterId)
+}
+
+// run creates the snapshot if it is due, otherwise deletes the oldest expired snapshot that is created by the
+// schedule. Snapshot list of the cluster is refreshed with the sync after each operation so a single operation
+// is done in a run
+func (s *snapshotScheduler) run(cluster *common.Cluster) {
+	now := time.Now().UTC()
+
+	if cluster.SnapshotSchedule.Due(now) {
+		s.create(cluster, now)
+		return
+	}
+
+	s.prune(cluster, now)
+}
+
+func (s *snapshotScheduler) create(cluster *common.Cluster, now time.Time) {
+	clusterId := cluster.Id
+	previous := cluster.Snapshots
+
+	err := s.cluster.CreateSnapshot(clusterId)
+	if err == errors.ErrMaintain {
+		// cluster is busy, it will be tried in the next run
+		return
+	}
+
+	status := "Succeeded"
+	if err != nil {
+		status = err.Error()
+		s.logger.Error("Scheduled snapshot creation is failed", zap.String("clusterId", clusterId), zap.Error(err))
+	} else {
+		s.logger.Info("Scheduled snapshot is created", zap.String("clusterId", clusterId))
+	}
+
+	s.save(clusterId, func(schedule *common.SnapshotSchedule, snapshots common.Snapshots) error {
+		// only the snapshots that are created by the schedule are pruned by the retention
+		schedule.Track(previous, snapshots)
+		schedule.LastRun = now
+		schedule.LastStatus = status
+		return schedule.Schedule(now)
+	})
+}
+
+func (s *snapshotScheduler) prune(cluster *common.Cluster, now time.Time) {
+	if cluster.Maintain {
+		return
+	}
+
+	expired := cluster.SnapshotSchedule.Expired(cluster.Snapshots, now)
+	if len(expired) == 0 {
+		return
+	}
+
+	snapshots := make(common.Snapshots, len(cluster.Snapshots))
+	copy(snapshots, cluster.Snapshots)
+	sort.Sort(snapshots)
+
+	snapshotIndex := -1
+	for i, snapshot := range snapshots {
+		if snapshot.Equal(expired[0]) {
+			snapshotIndex = i
+			break
+		}
+	}
+	if snapshotIndex == -1 {
+		return
+	}
+
+	err := s.cluster.DeleteSnapshot(cluster.Id, uint64(snapshotIndex))
+	if err == errors.ErrMaintain {
+		return
+	}
+
+	status := "Succeeded"
+	if err != nil {
+		status = err.Error()
+		s.logger.Error(
+			"Deleting expired snapshot is failed",
+			zap.String("clusterId", cluster.Id),
+			zap.Time("snapshot", expired[0]),
+			zap.Error(err),
+		)
+	} else {
+		s.logger.Info("Expired snapshot is deleted", zap.String("clusterId", cluster.Id), zap.Time("snapshot", expired[0]))
+	}
+
+	s.save(cluster.Id, func(schedule *common.SnapshotSchedule, snapshots common.Snapshots) error {
+		schedule.Track(snapshots, snapshots)
+		schedule.LastPrune = now
+		schedule.LastPruneStatus = status
+		return nil
+	})
+}
+
+func (s *snapshotScheduler) save(clusterId string, saveHandler func(schedule *common.SnapshotSchedule, snapshots common.Snapshots) error) {
+	if err := s.clusters.Save(clusterId, func(cluster *common.Cluster) error {
+		if cluster.SnapshotSchedule == nil {
+			return nil
+		}
+		return saveHandler(cluster.SnapshotSchedule, cluster.Snapshots)
+	}); err != nil {
+		s.logger.Error("Unable to save snapshot schedule status", zap.String("clusterId", clusterId), zap.Error(err))
+	}
+}
+
+var _ SnapshotScheduler = &snapshotScheduler{}
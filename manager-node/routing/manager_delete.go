@@ -29,6 +29,8 @@ func (m *managerRouter) handleDelete(w http.ResponseWriter, r *http.Request) {
 		m.handleCommit(w, r)
 	case "discard":
 		m.handleDiscard(w, r)
+	case "schedule":
+		m.handleDeleteSnapshotSchedule(w, r)
 	default:
 		w.WriteHeader(406)
 	}
@@ -149,9 +151,35 @@ func (m *managerRouter) handleDiscard(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 }
 
+func (m *managerRouter) handleDeleteSnapshotSchedule(w http.ResponseWriter, r *http.Request) {
+	clusterId := r.Header.Get("X-Options")
+	if len(clusterId) == 0 {
+		w.WriteHeader(422)
+		return
+	}
+
+	err := m.manager.ScheduleSnapshots(clusterId, nil)
+	if err == nil {
+		return
+	}
+
+	if err == errors.ErrNotFound {
+		w.WriteHeader(404)
+		return
+	}
+
+	w.WriteHeader(500)
+	m.logger.Error("Delete snapshot schedule request is failed", zap.Error(err))
+
+	e := common.NewError(390, err.Error())
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		m.logger.Error("Response of delete snapshot schedule request is failed", zap.Error(err))
+	}
+}
+
 func (m *managerRouter) validateDeleteAction(action string) bool {
 	switch action {
-	case "unregister", "snapshot", "commit", "discard":
+	case "unregister", "snapshot", "commit", "discard", "schedule":
 		return true
 	}
 	return false
@@ -27,6 +27,10 @@ func (m *managerRouter) handleGet(w http.ResponseWriter, r *http.Request) {
 		m.handleSync(w, r)
 	case "repair":
 		m.handleRepairConsistency(w, r)
+	case "repair-status":
+		m.handleRepairStatus(w, r)
+	case "repair-report":
+		m.handleRepairReport(w, r)
 	case "health":
 		m.handleHealth(w, r)
 	case "move":
@@ -94,7 +98,10 @@ func (m *managerRouter) handleRepairConsistency(w http.ResponseWriter, r *http.R
 		repairType = manager.RTFull
 	}
 
-	err := m.repair.Start(repairType)
+	online := strings.Compare(strings.ToLower(r.Header.Get("X-Online")), "true") == 0
+	dryRun := strings.Compare(strings.ToLower(r.Header.Get("X-Dry-Run")), "true") == 0
+
+	err := m.repair.Start(repairType, online, dryRun)
 	if err == nil {
 		w.WriteHeader(202)
 		return
@@ -115,6 +122,47 @@ func (m *managerRouter) handleRepairConsistency(w http.ResponseWriter, r *http.R
 	}
 }
 
+func (m *managerRouter) handleRepairStatus(w http.ResponseWriter, _ *http.Request) {
+	repairStatus := m.repair.Status()
+
+	if repairStatus.Checkpoint != nil {
+		w.Header().Set("X-Repairing-Percentage", strconv.FormatFloat(repairStatus.Checkpoint.Percentage(), 'f', 2, 64))
+		if repairStatus.Processing {
+			if eta, has := repairStatus.Checkpoint.ETA(); has {
+				w.Header().Set("X-Repairing-ETA", strconv.FormatInt(int64(eta.Seconds()), 10))
+			}
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(repairStatus); err != nil {
+		m.logger.Error("Response of repair status request is failed", zap.Error(err))
+	}
+}
+
+func (m *managerRouter) handleRepairReport(w http.ResponseWriter, _ *http.Request) {
+	lines, err := m.repair.Report()
+	if err == nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"repair-report.txt\"")
+
+		for _, line := range lines {
+			if _, err := w.Write([]byte(line + "\n")); err != nil {
+				m.logger.Error("Response of repair report request is failed", zap.Error(err))
+				return
+			}
+		}
+		return
+	}
+
+	w.WriteHeader(500)
+	m.logger.Error("Repair report request is failed", zap.Error(err))
+
+	e := common.NewError(106, err.Error())
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		m.logger.Error("Response of repair report request is failed", zap.Error(err))
+	}
+}
+
 func (m *managerRouter) handleHealth(w http.ResponseWriter, r *http.Request) {
 	report, err := m.health.Report()
 	if err == nil {
@@ -207,6 +255,9 @@ func (m *managerRouter) handleClusters(w http.ResponseWriter, r *http.Request) {
 		repairStatus := m.repair.Status()
 
 		w.Header().Add("X-Repairing", strconv.FormatBool(repairStatus.Processing))
+		if repairStatus.Processing {
+			w.Header().Add("X-Repairing-Online", strconv.FormatBool(repairStatus.Online))
+		}
 		if repairStatus.Timestamp != nil {
 			w.Header().Add("X-Repairing-Timestamp", repairStatus.Timestamp.Format(time.RFC3339))
 		}
@@ -258,7 +309,7 @@ func (m *managerRouter) handleFind(w http.ResponseWriter, r *http.Request) {
 
 func (m *managerRouter) validateGetAction(action string) bool {
 	switch action {
-	case "sync", "repair", "health", "move", "balance", "clusters", "find":
+	case "sync", "repair", "repair-status", "repair-report", "health", "move", "balance", "clusters", "find":
 		return true
 	}
 	return false
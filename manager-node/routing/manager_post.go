@@ -27,6 +27,8 @@ func (m *managerRouter) handlePost(w http.ResponseWriter, r *http.Request) {
 		m.handleCreateSnapshot(w, r)
 	case "reserve":
 		m.handleReserve(w, r)
+	case "reserveBatch":
+		m.handleReserveBatch(w, r)
 	case "readMap", "createMap", "deleteMap":
 		mapType := common.MTRead
 		switch action {
@@ -120,6 +122,8 @@ func (m *managerRouter) handleReserve(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(503)
 	} else if err == errors.ErrNoDiskSpace {
 		w.WriteHeader(507)
+	} else if err == errors.ErrTooLarge {
+		w.WriteHeader(413)
 	} else {
 		w.WriteHeader(400)
 		m.logger.Error("Reserve request is failed", zap.Uint64("size", size), zap.Error(err))
@@ -131,6 +135,42 @@ func (m *managerRouter) handleReserve(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (m *managerRouter) handleReserveBatch(w http.ResponseWriter, r *http.Request) {
+	sizes := make([]uint64, 0)
+	for _, sizeString := range strings.Split(r.Header.Get("X-Options"), ",") {
+		size, err := strconv.ParseUint(sizeString, 10, 64)
+		if err != nil {
+			w.WriteHeader(422)
+			return
+		}
+		sizes = append(sizes, size)
+	}
+
+	reservationMaps, err := m.manager.ReserveBatch(sizes)
+	if err == nil {
+		if err := json.NewEncoder(w).Encode(reservationMaps); err != nil {
+			m.logger.Error("Response of reserve batch request is failed", zap.Error(err))
+		}
+		return
+	}
+
+	if err == errors.ErrNoAvailableClusterNode {
+		w.WriteHeader(503)
+	} else if err == errors.ErrNoDiskSpace {
+		w.WriteHeader(507)
+	} else if err == errors.ErrTooLarge {
+		w.WriteHeader(413)
+	} else {
+		w.WriteHeader(400)
+		m.logger.Error("Reserve batch request is failed", zap.Int("count", len(sizes)), zap.Error(err))
+	}
+
+	e := common.NewError(210, err.Error())
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		m.logger.Error("Response of reserve batch request is failed", zap.Error(err))
+	}
+}
+
 func (m *managerRouter) handleMap(w http.ResponseWriter, r *http.Request, mapType common.MapType) {
 	sha512HexList := strings.Split(r.Header.Get("X-Options"), ",")
 	if len(sha512HexList) == 0 {
@@ -163,7 +203,7 @@ func (m *managerRouter) handleMap(w http.ResponseWriter, r *http.Request, mapTyp
 
 func (m *managerRouter) validatePostAction(action string) bool {
 	switch action {
-	case "register", "snapshot", "reserve", "readMap", "createMap", "deleteMap":
+	case "register", "snapshot", "reserve", "reserveBatch", "readMap", "createMap", "deleteMap":
 		return true
 	}
 	return false
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/freakmaxi/kertish-dos/basics/common"
+	"github.com/freakmaxi/kertish-dos/basics/errors"
 	"go.uber.org/zap"
 )
 
@@ -29,6 +30,12 @@ func (m *managerRouter) handlePut(w http.ResponseWriter, r *http.Request) {
 		m.handleState(w, r)
 	case "snapshot":
 		m.handleRestoreSnapshot(w, r)
+	case "quorum":
+		m.handleWriteQuorum(w, r)
+	case "throttle":
+		m.handleThrottle(w, r)
+	case "schedule":
+		m.handleSnapshotSchedule(w, r)
 	default:
 		w.WriteHeader(406)
 	}
@@ -101,9 +108,98 @@ func (m *managerRouter) handleRestoreSnapshot(w http.ResponseWriter, r *http.Req
 	}
 }
 
+func (m *managerRouter) handleWriteQuorum(w http.ResponseWriter, r *http.Request) {
+	clusterId, writeQuorum, err := m.describeWriteQuorumOptions(r.Header.Get("X-Options"))
+	if err != nil {
+		w.WriteHeader(422)
+		e := common.NewError(310, err.Error())
+		if err := json.NewEncoder(w).Encode(e); err != nil {
+			m.logger.Error("Response of change write quorum request is failed", zap.Error(err))
+		}
+		return
+	}
+
+	err = m.manager.ChangeWriteQuorum(clusterId, writeQuorum)
+	if err == nil {
+		return
+	}
+
+	w.WriteHeader(400)
+	m.logger.Error("Change write quorum request is failed", zap.Error(err))
+
+	e := common.NewError(310, err.Error())
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		m.logger.Error("Response of change write quorum request is failed", zap.Error(err))
+	}
+}
+
+func (m *managerRouter) handleThrottle(w http.ResponseWriter, r *http.Request) {
+	targetId, operation, limit, err := m.describeThrottleOptions(r.Header.Get("X-Options"))
+	if err != nil {
+		w.WriteHeader(422)
+		e := common.NewError(320, err.Error())
+		if err := json.NewEncoder(w).Encode(e); err != nil {
+			m.logger.Error("Response of change throttle request is failed", zap.Error(err))
+		}
+		return
+	}
+
+	err = m.manager.ChangeThrottle(targetId, operation, limit)
+	if err == nil {
+		return
+	}
+
+	if err == errors.ErrNotFound {
+		w.WriteHeader(404)
+		return
+	}
+
+	w.WriteHeader(400)
+	m.logger.Error("Change throttle request is failed", zap.Error(err))
+
+	e := common.NewError(320, err.Error())
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		m.logger.Error("Response of change throttle request is failed", zap.Error(err))
+	}
+}
+
+func (m *managerRouter) handleSnapshotSchedule(w http.ResponseWriter, r *http.Request) {
+	clusterId, schedule, err := m.describeSnapshotScheduleOptions(r.Header.Get("X-Options"), r.Header.Get("X-Retention"))
+	if err != nil {
+		w.WriteHeader(422)
+		e := common.NewError(390, err.Error())
+		if err := json.NewEncoder(w).Encode(e); err != nil {
+			m.logger.Error("Response of snapshot schedule request is failed", zap.Error(err))
+		}
+		return
+	}
+
+	err = m.manager.ScheduleSnapshots(clusterId, schedule)
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(schedule); err != nil {
+			m.logger.Error("Response of snapshot schedule request is failed", zap.Error(err))
+		}
+		return
+	}
+
+	if err == errors.ErrNotFound {
+		w.WriteHeader(404)
+		return
+	}
+
+	w.WriteHeader(400)
+	m.logger.Error("Snapshot schedule request is failed", zap.Error(err))
+
+	e := common.NewError(390, err.Error())
+	if err := json.NewEncoder(w).Encode(e); err != nil {
+		m.logger.Error("Response of snapshot schedule request is failed", zap.Error(err))
+	}
+}
+
 func (m *managerRouter) validatePutAction(action string) bool {
 	switch action {
-	case "state", "snapshot":
+	case "state", "snapshot", "quorum", "throttle", "schedule":
 		return true
 	}
 	return false
@@ -152,3 +248,78 @@ func (m *managerRouter) describeRestoreSnapshotOptions(options string) (string,
 
 	return clusterId, snapshotIndex, nil
 }
+
+func (m *managerRouter) describeWriteQuorumOptions(options string) (string, int, error) {
+	eqIdx := strings.Index(options, "=")
+	if eqIdx < 1 {
+		return "", 0, fmt.Errorf("write quorum options should be in clusterId=quorum format")
+	}
+
+	writeQuorum, err := strconv.ParseUint(options[eqIdx+1:], 10, 8)
+	if err != nil {
+		return "", 0, fmt.Errorf("write quorum should be 0 or positive numeric value")
+	}
+
+	return options[:eqIdx], int(writeQuorum), nil
+}
+
+func (m *managerRouter) describeThrottleOptions(options string) (string, common.ThrottleOperations, common.ThrottleLimit, error) {
+	targetId := ""
+	eqIdx := strings.Index(options, "=")
+	if eqIdx > -1 {
+		targetId = options[:eqIdx]
+		options = options[eqIdx+1:]
+	}
+
+	throttleOptions := strings.Split(options, ",")
+	if len(throttleOptions) != 3 {
+		return "", "", common.ThrottleLimit{}, fmt.Errorf("throttle options should be in targetId=operation,rate,concurrency format")
+	}
+
+	operation, err := common.ParseThrottleOperation(throttleOptions[0])
+	if err != nil {
+		return "", "", common.ThrottleLimit{}, err
+	}
+
+	rate, err := strconv.ParseUint(throttleOptions[1], 10, 64)
+	if err != nil {
+		return "", "", common.ThrottleLimit{}, fmt.Errorf("throttle rate should be 0 or positive numeric value")
+	}
+
+	concurrency, err := strconv.ParseUint(throttleOptions[2], 10, 16)
+	if err != nil {
+		return "", "", common.ThrottleLimit{}, fmt.Errorf("throttle concurrency should be 0 or positive numeric value")
+	}
+
+	return targetId, operation, common.ThrottleLimit{Rate: rate, Concurrency: int(concurrency)}, nil
+}
+
+func (m *managerRouter) describeSnapshotScheduleOptions(options string, retention string) (string, *common.SnapshotSchedule, error) {
+	eqIdx := strings.Index(options, "=")
+	if eqIdx < 1 {
+		return "", nil, fmt.Errorf("snapshot schedule options should be in clusterId=expression format")
+	}
+
+	keeps := []int{0, 0, 0}
+	if len(retention) > 0 {
+		retentionValues := strings.Split(retention, ",")
+		if len(retentionValues) != len(keeps) {
+			return "", nil, fmt.Errorf("snapshot retention should be in last,daily,weekly format")
+		}
+
+		for i, retentionValue := range retentionValues {
+			keep, err := strconv.ParseUint(retentionValue, 10, 16)
+			if err != nil {
+				return "", nil, fmt.Errorf("snapshot retention values should be 0 or positive numeric value")
+			}
+			keeps[i] = int(keep)
+		}
+	}
+
+	schedule, err := common.NewSnapshotSchedule(options[eqIdx+1:], keeps[0], keeps[1], keeps[2])
+	if err != nil {
+		return "", nil, err
+	}
+
+	return options[:eqIdx], schedule, nil
+}
@@ -39,7 +39,7 @@ func (n *nodeRouter) handleHandshake(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	clusterId, nodeId, syncSourceNodeAddr, err := n.manager.Handshake(nodeHardwareAddr, nodeAddress, size)
+	clusterId, nodeId, syncSourceNodeAddr, writeQuorum, throttle, err := n.manager.Handshake(nodeHardwareAddr, nodeAddress, size)
 	if err != nil {
 		if err == errors.ErrNotFound {
 			w.WriteHeader(404)
@@ -53,6 +53,14 @@ func (n *nodeRouter) handleHandshake(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("X-Cluster-Id", clusterId)
 	w.Header().Set("X-Node-Id", nodeId)
 	w.Header().Set("X-Master", syncSourceNodeAddr)
+	w.Header().Set("X-Write-Quorum", strconv.Itoa(writeQuorum))
+
+	throttleHeader, err := json.Marshal(throttle)
+	if err != nil {
+		n.logger.Error("Throttle limits of the node can not be serialized", zap.Error(err))
+		return
+	}
+	w.Header().Set("X-Throttle", string(throttleHeader))
 }
 
 func (n *nodeRouter) handleNotify(w http.ResponseWriter, r *http.Request) {
@@ -62,7 +70,8 @@ func (n *nodeRouter) handleNotify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := n.manager.Notify(nodeId, notificationContainerList); err != nil {
+	quorumFailedList, err := n.manager.Notify(nodeId, notificationContainerList)
+	if err != nil {
 		notificationError := err.(*common.NotificationError)
 
 		if notificationError.Is(errors.ErrNotFound) {
@@ -79,6 +88,14 @@ func (n *nodeRouter) handleNotify(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.WriteHeader(202)
+
+	if len(quorumFailedList) == 0 {
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(quorumFailedList); err != nil {
+		n.logger.Error("Response of bulk notify request quorum result is failed", zap.Error(err))
+	}
 }
 
 func (n *nodeRouter) validatePostAction(action string) bool {